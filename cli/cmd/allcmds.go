@@ -3,6 +3,7 @@ package cmd
 import (
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -46,32 +47,69 @@ func (a *add) Run(ctx context.Context) error {
 	return got.Add(ctx, a.addFlag, a.args...)
 }
 
+// git-blame - Show what revision and author last modified each line of a file
+type blame struct {
+	path, rev string
+	ranges    []string
+	porcelain bool
+}
+
+func (b *blame) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	bl, err := got.Blame(b.path, b.rev)
+	if err != nil {
+		return err
+	}
+	rdr, err := bl.Format(b.ranges, b.porcelain)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, rdr)
+	return err
+}
+
+// git-branch - List, create, or delete branches
 type branch struct {
-	name   string
-	delete bool
+	delete, move, force bool
+	upstream            string
+	args                []string
 }
 
 func (b *branch) Run(ctx context.Context) error {
 	got := pkg.NewGot()
-	if b.name == "" {
+	switch {
+	case b.delete:
+		for _, name := range b.args {
+			if err := got.DeleteBranch(ctx, name, b.force); err != nil {
+				return err
+			}
+		}
+		return nil
+	case b.move:
+		if len(b.args) == 1 {
+			return got.RenameBranch(ctx, "", b.args[0], b.force)
+		}
+		return got.RenameBranch(ctx, b.args[0], b.args[1], b.force)
+	case b.upstream != "":
+		name := ""
+		if len(b.args) == 1 {
+			name = b.args[0]
+		}
+		return got.SetUpstream(ctx, name, b.upstream)
+	case len(b.args) == 0:
 		rdr, err := got.Branches()
 		if err != nil {
 			return err
 		}
 		_, err = io.Copy(os.Stdout, rdr)
 		return err
+	default:
+		start := ""
+		if len(b.args) == 2 {
+			start = b.args[1]
+		}
+		return got.NewBranch(ctx, b.args[0], start, b.force)
 	}
-
-	if b.delete {
-		err := got.DeleteBranch(ctx, b.name)
-		return err
-	}
-
-	if err := got.NewBranch(ctx, b.name); err != nil {
-		return err
-	}
-
-	return nil
 }
 
 type catType int
@@ -115,38 +153,72 @@ func (c *cat) Run(ctx context.Context) error {
 		return fmt.Errorf("Problem validating arguments, more than one of -t, -s, -p is set, or none is")
 	}
 	got := pkg.NewGot()
-	if c.size {
-		c.mode = s
-	} else if c._type {
-		c.mode = t
-	} else {
-		c.mode = p
-	}
-
 	rdr, err := got.CatFile(ctx, (*c).prefix, int((*c).mode))
-	if err == nil {
-		err := got.Log(rdr)
+	if err != nil {
 		return err
 	}
+	_, err = io.Copy(os.Stdout, rdr)
 	return err
 }
 
+// git-checkout - Switch branches, updating the index and the working tree to match
 type checkout struct {
-	name string
-	new  bool
+	name, start               string
+	new, reset, force, detach bool
 }
 
 func (c *checkout) Run(ctx context.Context) error {
 	got := pkg.NewGot()
 	if c.new {
-		err := got.NewBranch(ctx, c.name)
-		if err != nil {
+		if err := got.CheckoutNewBranch(ctx, c.name, c.start, c.reset, c.force); err != nil {
 			return err
 		}
+		_, err := fmt.Fprintf(os.Stdout, "Switched to a new branch '%s'\n", c.name)
+		return err
 	}
-	if err := got.Checkout(ctx, c.name); err != nil {
+	c.name = got.ExpandPrevious(c.name)
+	if err := got.Checkout(ctx, c.name, c.force, c.detach); err != nil {
 		return err
 	}
+	if c.detach || !got.BranchExists(c.name) {
+		head, err := got.HeadSummary()
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintf(os.Stdout, "HEAD is now at %s\n", head)
+		return err
+	}
+	_, err := fmt.Fprintf(os.Stdout, "Switched to branch '%s'\n", c.name)
+	return err
+}
+
+// git-check-ref-format - Ensures that a reference name is well formed
+type checkRefFormat struct {
+	name                             string
+	branch, allowOneLevel, normalize bool
+}
+
+// like git, a bad ref name only shows in the exit status. a bad branch name is an error
+func (c *checkRefFormat) Run(ctx context.Context) error {
+	if c.branch {
+		got := pkg.NewGot()
+		name, err := got.CheckBranchName(c.name)
+		if err != nil {
+			return err
+		}
+		fmt.Println(name)
+		return nil
+	}
+	name := c.name
+	if c.normalize {
+		name = pkg.NormalizeRefName(name)
+	}
+	if err := pkg.CheckRefFormat(name, c.allowOneLevel); err != nil {
+		os.Exit(1)
+	}
+	if c.normalize {
+		fmt.Println(name)
+	}
 	return nil
 }
 
@@ -182,9 +254,13 @@ func (c *commit) Run(ctx context.Context) error {
 		c.msg = string(msg)
 
 	}
-	//comeback. we're doing nothing with the string returned here
-	_, err := got.Commit(ctx, (*c).msg, c.all)
-	return err
+	sha, err := got.Commit(ctx, (*c).msg, c.all)
+	if err != nil {
+		return err
+	}
+	subject := strings.SplitN(strings.TrimSpace(c.msg), "\n", 2)[0]
+	fmt.Printf("[%s] %s\n", sha[:7], subject)
+	return nil
 }
 
 type config struct {
@@ -236,21 +312,75 @@ func (c *config) Run(ctx context.Context) error {
 //changes between two trees,
 // changes resulting from a merge, changes between two blob objects, or changes between two files on disk.
 
-// we limit our diff command here. if an arg is provided, we find the diff between the current working tree
-// and the last commit (not the index).
-// if cached is set, it means we expect no argument, and we wish to find the diff between the current state
-// of the index file and the files being tracked by it in the working tree. otherwise, we'll be diffing for
-// files that are not yet being tracked
+// without revisions, diff shows what changed in the working tree since the files were staged.
+// with --cached, what is staged since HEAD, or the given commit. with one commit, what changed in the working
+// tree since that commit, and with two, or a range, what changed between them. paths limit the diff
 type diff struct {
-	cached      bool
-	output, arg string
+	opts   pkg.DiffOpts
+	output string
+	//args are revisions and paths, split by LogArgs
+	args []string
 }
 
 func (d *diff) Run(ctx context.Context) error {
 	got := pkg.NewGot()
+	revs, paths, err := got.LogArgs(d.args)
+	if err != nil {
+		return err
+	}
+	d.opts.Revs, d.opts.Paths = revs, paths
+	rdr, err := got.Diff(ctx, d.opts)
+	if err != nil {
+		return err
+	}
+	out := io.Writer(os.Stdout)
+	if d.output != "" {
+		f, err := os.Create(d.output)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+	_, err = io.Copy(out, rdr)
+	return err
+}
 
-	err := got.Diff(d.cached, d.output, d.arg)
+// git-diff-tree, git-diff-index and git-diff-files - Compare two trees, a tree with the working tree or the index,
+// or the index with the working tree, listing the changed paths
+type diffPlumb struct {
+	cmd                     string
+	args                    []string
+	recursive, root, cached bool
+	renames                 pkg.RenameOpts
+	format                  pkg.DiffFormat
+}
 
+func (d *diffPlumb) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	revs, paths, err := got.LogArgs(d.args)
+	if err != nil {
+		return err
+	}
+	var rdr io.Reader
+	switch d.cmd {
+	case "diff-tree":
+		rdr, err = got.DiffTree(ctx, revs, paths, d.recursive, d.root, d.renames, d.format)
+	case "diff-index":
+		if len(revs) != 1 {
+			return fmt.Errorf("diff-index takes one tree-ish")
+		}
+		rdr, err = got.DiffIndex(ctx, revs[0], paths, d.cached, d.renames, d.format)
+	default:
+		if len(revs) != 0 {
+			return fmt.Errorf("diff-files takes no revisions")
+		}
+		rdr, err = got.DiffFiles(ctx, paths, d.renames, d.format)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, rdr)
 	return err
 }
 
@@ -300,12 +430,20 @@ func (h *hashObj) Run(ctx context.Context) error {
 // git-ls-files - Show information about files in the index and the working tree
 
 type lsFiles struct {
-	lstaged, lcached, ldeleted, lmodified, lothers bool
+	lstaged, lcached, ldeleted, lmodified, lothers, lverbose bool
 }
 
 func (l *lsFiles) Run(ctx context.Context) error {
 	got := pkg.NewGot()
-	err := got.LsFiles(ctx, l.lstaged, l.lcached, l.ldeleted, l.lmodified, l.lothers)
+	// like git, we show the cached files when nothing else was asked for
+	if !l.ldeleted && !l.lmodified && !l.lothers {
+		l.lcached = true
+	}
+	rdr, err := got.LsFiles(ctx, l.lstaged, l.lcached, l.ldeleted, l.lmodified, l.lothers, l.lverbose)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, rdr)
 	return err
 }
 
@@ -360,15 +498,95 @@ func (p *push) Run(ctx context.Context) error {
 	return err
 }
 
-// // git-read-tree - Reads tree information into the index
-// type readTree struct {
-// 	treeish string
-// }
+// git-pack-refs - Pack heads and tags for efficient repository access
+type packRefs struct {
+	all bool
+}
 
-// func (r *readTree) Run(ctx context.Context) error {
-// 	got := pkg.NewGot()
-// 	return got.ReadTree(r.treeish)
-// }
+func (p *packRefs) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	return got.PackRefs(ctx, p.all)
+}
+
+// git-read-tree - Reads tree information into the index
+type readTree struct {
+	treeish string
+}
+
+func (r *readTree) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	return got.ReadTree(r.treeish)
+}
+
+// git-reflog - Manage reflog information
+type reflog struct {
+	sub, expire string
+	all         bool
+	args        []string
+}
+
+func (r *reflog) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	switch r.sub {
+	case "expire":
+		return got.ReflogExpire(ctx, r.args, r.all, r.expire)
+	case "delete":
+		return got.ReflogDelete(ctx, r.args)
+	}
+	ref := ""
+	if len(r.args) == 1 {
+		ref = r.args[0]
+	}
+	rdr, err := got.Reflog(ctx, ref)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, rdr)
+	return err
+}
+
+// git-rev-list - Lists commit objects in reverse chronological order
+type revList struct {
+	opts pkg.RevListOpts
+	args []string
+}
+
+func (r *revList) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	revs, paths, err := got.LogArgs(r.args)
+	if err != nil {
+		return err
+	}
+	r.opts.Revs, r.opts.Paths = revs, paths
+	rdr, err := got.RevList(ctx, r.opts)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, rdr)
+	return err
+}
+
+// git-rev-parse - Pick out and massage parameters
+type revParse struct {
+	verify, abbrevRef bool
+	short             int
+	revs              []string
+}
+
+func (r *revParse) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	for _, rev := range r.revs {
+		out, err := got.RevParse(rev, r.short, r.abbrevRef)
+		if err != nil {
+			if r.verify {
+				return fmt.Errorf("Needed a single revision")
+			}
+			return err
+		}
+		fmt.Println(out)
+	}
+	return nil
+}
 
 type remote struct {
 	name  string
@@ -399,6 +617,36 @@ func (a *rm) Run(ctx context.Context) error {
 	return nil
 }
 
+// git-sparse-checkout - Reduce your working tree to a subset of tracked files
+type sparseCheckout struct {
+	sub  string
+	cone bool
+	args []string
+}
+
+func (s *sparseCheckout) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	switch s.sub {
+	case "init":
+		return got.SparseInit(ctx, s.cone)
+	case "set":
+		return got.SparseSet(ctx, s.args)
+	case "add":
+		return got.SparseAdd(ctx, s.args)
+	case "list":
+		rdr, err := got.SparseList(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(os.Stdout, rdr)
+		return err
+	case "disable":
+		return got.SparseDisable(ctx)
+	default:
+		return fmt.Errorf("invalid subcommand for sparse-checkout: %s", s.sub)
+	}
+}
+
 // Displays paths that have differences between the index file and the current HEAD commit,
 // paths that have differences between the working tree and the index file, and paths in the working tree that are not tracked by Git
 type status struct{}
@@ -412,32 +660,84 @@ func (s *status) Run(ctx context.Context) error {
 
 // Switch to a specified branch. The working tree and the index are updated to match the branch.
 // All new commits will be added to the tip of this branch.
+// unlike checkout, switch won't detach HEAD unless it is asked to
 type _switch struct {
-	name string
-	new  bool
+	name, start               string
+	new, reset, force, detach bool
 }
 
 func (s *_switch) Run(ctx context.Context) error {
 	got := pkg.NewGot()
 	if s.new {
-		err := got.NewBranch(ctx, s.name)
+		if err := got.CheckoutNewBranch(ctx, s.name, s.start, s.reset, s.force); err != nil {
+			return err
+		}
+		_, err := fmt.Fprintf(os.Stdout, "Switched to a new branch '%s'\n", s.name)
+		return err
+	}
+	s.name = got.ExpandPrevious(s.name)
+	if !s.detach && !got.BranchExists(s.name) {
+		return fmt.Errorf("a branch is expected, got '%s'. use --detach to switch to a commit", s.name)
+	}
+	if err := got.Checkout(ctx, s.name, s.force, s.detach); err != nil {
+		return err
+	}
+	if s.detach {
+		head, err := got.HeadSummary()
 		if err != nil {
 			return err
 		}
+		_, err = fmt.Fprintf(os.Stdout, "HEAD is now at %s\n", head)
+		return err
 	}
-	if err := got.Checkout(ctx, s.name); err != nil {
+	_, err := fmt.Fprintf(os.Stdout, "Switched to branch '%s'\n", s.name)
+	return err
+}
+
+// git-symbolic-ref - Read, modify and delete symbolic refs
+type symbolicRef struct {
+	name, target, msg    string
+	quiet, short, delete bool
+}
+
+func (s *symbolicRef) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	if s.delete {
+		return got.DeleteSymbolicRef(ctx, s.name)
+	}
+	if s.target != "" {
+		return got.SetSymbolicRef(ctx, s.name, s.target, s.msg)
+	}
+	target, err := got.SymbolicRef(s.name, s.short)
+	if err != nil {
+		if s.quiet && errors.Is(err, pkg.NotSymrefErr) {
+			os.Exit(1)
+		}
 		return err
 	}
+	fmt.Println(target)
 	return nil
 }
 
 // git-update-index - Register file contents in the working tree to the index
 type updateIndex struct {
-	add, remove bool
+	add, remove                                                      bool
+	assumeUnchanged, noAssumeUnchanged, skipWorktree, noSkipWorktree bool
+	paths                                                            []string
 }
 
 func (u *updateIndex) Run(ctx context.Context) error {
 	got := pkg.NewGot()
+	if u.assumeUnchanged || u.noAssumeUnchanged {
+		if err := got.SetIndexFlag(ctx, pkg.AssumeUnchanged, u.assumeUnchanged, u.paths...); err != nil {
+			return err
+		}
+	}
+	if u.skipWorktree || u.noSkipWorktree {
+		if err := got.SetIndexFlag(ctx, pkg.SkipWorktree, u.skipWorktree, u.paths...); err != nil {
+			return err
+		}
+	}
 	return got.UpdateIndex(ctx, u.add, u.remove)
 }
 
@@ -466,3 +766,188 @@ func (w *writeTree) Run(ctx context.Context) error {
 	_, err = io.Copy(os.Stdout, strings.NewReader(s))
 	return err
 }
+
+type tagMode int
+
+const (
+	tagListMode tagMode = iota
+	tagCreateMode
+	tagDeleteMode
+	tagVerifyMode
+)
+
+// git-tag - Create, list, delete or verify a tag object
+type tag struct {
+	mode            tagMode
+	annotate, force bool
+	msg             string
+	args            []string
+}
+
+func (t *tag) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	switch t.mode {
+	case tagListMode:
+		rdr, err := got.Tags(ctx, t.args)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(os.Stdout, rdr)
+		return err
+	case tagDeleteMode:
+		rdr, err := got.DeleteTags(ctx, t.args)
+		io.Copy(os.Stdout, rdr)
+		return err
+	case tagVerifyMode:
+		for _, name := range t.args {
+			rdr, err := got.VerifyTag(ctx, name)
+			if err != nil {
+				return err
+			}
+			io.Copy(os.Stdout, rdr)
+		}
+		return nil
+	default:
+		rev := ""
+		if len(t.args) == 2 {
+			rev = t.args[1]
+		}
+		out, err := got.CreateTag(ctx, t.args[0], rev, t.annotate, t.msg, t.force)
+		if err != nil {
+			return err
+		}
+		fmt.Print(out)
+		return nil
+	}
+}
+
+// git-for-each-ref - Output information on each ref
+type forEachRef struct {
+	patterns, sorts  []string
+	format           string
+	count            int
+	contains, merged string
+}
+
+func (f *forEachRef) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	rdr, err := got.ForEachRef(ctx, f.patterns, f.format, f.sorts, f.count, f.contains, f.merged)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, rdr)
+	return err
+}
+
+// git-update-ref - Update the object name stored in a ref safely
+type updateRef struct {
+	ref, new, old       string
+	haveOld, del, stdin bool
+	msg                 string
+}
+
+func (u *updateRef) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	if u.stdin {
+		return got.UpdateRefStdin(ctx, os.Stdin, os.Stdout, u.msg)
+	}
+	return got.UpdateRef(ctx, u.ref, u.new, u.old, u.haveOld, u.del, u.msg)
+}
+
+// git-show - Show various types of objects
+type show struct {
+	opts pkg.ShowOpts
+	//args are objects and paths, split by LogArgs
+	args []string
+}
+
+func (s *show) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	objects, paths, err := got.LogArgs(s.args)
+	if err != nil {
+		return err
+	}
+	s.opts.Objects, s.opts.Paths = objects, paths
+	rdr, err := got.Show(ctx, s.opts)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, rdr)
+	return err
+}
+
+// git-show-ref - List references in a local repository
+type showRef struct {
+	patterns                                []string
+	heads, tags, verify, deref, hash, quiet bool
+}
+
+// like git, finding nothing only shows in the exit status, unless a ref was asked for by name with --verify
+func (s *showRef) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	rdr, err := got.ShowRef(ctx, s.patterns, s.heads, s.tags, s.verify, s.deref, s.hash)
+	if !s.quiet && rdr != nil {
+		io.Copy(os.Stdout, rdr)
+	}
+	if err != nil && (s.quiet || errors.Is(err, pkg.RefNotFoundErr)) {
+		os.Exit(1)
+	}
+	return err
+}
+
+// git-describe - Give an object a human readable name based on an available ref
+type describe struct {
+	revs       []string
+	tags, long bool
+	abbrev     int
+	dirty      string
+}
+
+func (d *describe) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	for _, rev := range d.revs {
+		name, err := got.Describe(ctx, rev, d.tags, d.long, d.abbrev, d.dirty)
+		if err != nil {
+			return err
+		}
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// git-log - Show commit logs
+type logHist struct {
+	opts pkg.LogOpts
+	//args are revisions and paths, split by LogArgs
+	args []string
+}
+
+func (l *logHist) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	revs, paths, err := got.LogArgs(l.args)
+	if err != nil {
+		return err
+	}
+	l.opts.Revs, l.opts.Paths = revs, paths
+	rdr, err := got.Log(ctx, l.opts)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(os.Stdout, rdr)
+	return err
+}
+
+// git-name-rev - Find symbolic names for given revs
+type nameRev struct {
+	revs           []string
+	tags, nameOnly bool
+}
+
+func (n *nameRev) Run(ctx context.Context) error {
+	got := pkg.NewGot()
+	rdr, err := got.NameRev(ctx, n.revs, n.tags, n.nameOnly)
+	if rdr != nil {
+		io.Copy(os.Stdout, rdr)
+	}
+	return err
+}
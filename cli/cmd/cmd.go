@@ -7,9 +7,11 @@ import (
 	"log"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 
 	"github.com/OLUWAMUYIWA/got/pkg"
+	gotdiff "github.com/OLUWAMUYIWA/got/pkg/diff"
 )
 
 type app struct {
@@ -51,8 +53,8 @@ func (a *app) Run() error {
 }
 
 //command list
-// add 	branch cat 	commit 	config 	diff 	fetch 	hash 	init 	ls-files 	ls-tree 	merge
-// pull 	push 	read-tree 	remote 	rm 	status 	switch 	update-index 	verify-pack 	write-tree
+// add 	blame 	branch cat 	check-ref-format 	commit 	config 	describe 	diff 	diff-files 	diff-index 	diff-tree 	fetch 	for-each-ref 	hash 	init 	log 	ls-files 	ls-tree 	merge
+// name-rev 	pack-refs 	pull 	push 	read-tree 	reflog 	remote 	rev-list 	rev-parse 	rm 	show 	show-ref 	status 	switch 	symbolic-ref 	tag 	update-index 	update-ref 	verify-pack 	write-tree
 //
 
 //comeback handle exit codes and context
@@ -66,11 +68,27 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 	addCmd.BoolVar(&aall, "all", false, "Specify that all files starting from root directory should be added, default false")
 	addCmd.BoolVar(&aall, "A", false, "Specify that all files starting from root directory should be added (shorthand). default: false")
 
+	// blame
+	blameCmd := flag.NewFlagSet("blame", flag.ExitOnError)
+	var blameRanges stringsFlag
+	var blamePorcelain bool
+	blameCmd.Var(&blameRanges, "L", "Annotate only the line range given by <start>,<end>, <start>,+<count> or <start>,-<count>. may be given more than once")
+	blameCmd.BoolVar(&blamePorcelain, "porcelain", false, "Show in a format designed for machine consumption")
+
 	//branch
 	branchCmd := flag.NewFlagSet("branch", flag.ExitOnError)
-	var deleteBranch bool
+	var deleteBranch, forceDeleteBranch, moveBranch, forceMoveBranch, forceBranch bool
+	var upstreamBranch string
 	branchCmd.BoolVar(&deleteBranch, "d", false, "delete the named branch")
 	branchCmd.BoolVar(&deleteBranch, "delete", false, "delete the named branch")
+	branchCmd.BoolVar(&forceDeleteBranch, "D", false, "delete the named branch, even if it is not fully merged")
+	branchCmd.BoolVar(&moveBranch, "m", false, "rename a branch. with one argument, the current branch is renamed")
+	branchCmd.BoolVar(&moveBranch, "move", false, "rename a branch. with one argument, the current branch is renamed")
+	branchCmd.BoolVar(&forceMoveBranch, "M", false, "rename a branch, even if the new name already exists")
+	branchCmd.BoolVar(&forceBranch, "f", false, "reset the branch to the start point even if it exists. allows -d and -m to proceed regardless")
+	branchCmd.BoolVar(&forceBranch, "force", false, "reset the branch to the start point even if it exists. allows -d and -m to proceed regardless")
+	branchCmd.StringVar(&upstreamBranch, "u", "", "set up the branch's tracking information so the given upstream is its upstream branch")
+	branchCmd.StringVar(&upstreamBranch, "set-upstream-to", "", "set up the branch's tracking information so the given upstream is its upstream branch")
 
 	//cat
 	catCmd := flag.NewFlagSet("cat-file", flag.ExitOnError)
@@ -81,8 +99,19 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 
 	//checkout
 	checkoutCmd := flag.NewFlagSet("checkout", flag.ExitOnError)
-	var newBranchCheckout bool
+	var newBranchCheckout, resetBranchCheckout, forceCheckout, detachCheckout bool
 	checkoutCmd.BoolVar(&newBranchCheckout, "b", false, "Creates a new branch and checks it out")
+	checkoutCmd.BoolVar(&resetBranchCheckout, "B", false, "Creates the branch, or resets it if it exists, and checks it out")
+	checkoutCmd.BoolVar(&forceCheckout, "f", false, "Proceed even if the index or the working tree differs from HEAD. local changes are thrown away")
+	checkoutCmd.BoolVar(&forceCheckout, "force", false, "Proceed even if the index or the working tree differs from HEAD. local changes are thrown away")
+	checkoutCmd.BoolVar(&detachCheckout, "detach", false, "Check out the commit and detach HEAD at it, even if it is a branch")
+
+	// check-ref-format
+	checkRefCmd := flag.NewFlagSet("check-ref-format", flag.ExitOnError)
+	var checkBranch, allowOneLevel, normalizeRef bool
+	checkRefCmd.BoolVar(&checkBranch, "branch", false, "Check a branch name, expanding @{-n}, and print it")
+	checkRefCmd.BoolVar(&allowOneLevel, "allow-onelevel", false, "Accept refs without a slash")
+	checkRefCmd.BoolVar(&normalizeRef, "normalize", false, "Collapse slashes, then check and print the name")
 
 	// commit
 	// supports only the first two ways of committing as described in https://git-scm.com/docs/git-commit
@@ -106,19 +135,70 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 	configCmd.BoolVar(&cread, "get", false, "says it should set the confiuration")
 	configCmd.BoolVar(&unset, "unset", false, "unsets the value")
 
+	// describe
+	describeCmd := flag.NewFlagSet("describe", flag.ExitOnError)
+	var describeTags, describeLong bool
+	var describeAbbrev int
+	describeDirty := markFlag{def: "-dirty"}
+	describeCmd.BoolVar(&describeTags, "tags", false, "Use any tag found in refs/tags, not just annotated tags")
+	describeCmd.BoolVar(&describeLong, "long", false, "Always output the long format, even when the commit is tagged")
+	describeCmd.IntVar(&describeAbbrev, "abbrev", 7, "Use that many hex digits, or as many as needed for a unique name. 0 shows the tag only")
+	describeCmd.Var(&describeDirty, "dirty", "Describe the working tree. append -dirty, or the given mark, when it has changes")
+
 	// diff
 	diffCmd := flag.NewFlagSet("diff", flag.ExitOnError)
 	var cached bool
 	var output string
 	diffCmd.BoolVar(&cached, "cached", false,
-		`Cached instructs git-diff to check for changes in the working tree
-	 on files that have already  been staged in the index. if its not set, git-diff
-	 checks for changes in  WT that have not been added`)
+		`Cached instructs git-diff to compare the index with HEAD, or the given commit,
+	 instead of comparing the working tree with the index or the commit`)
+	diffCmd.BoolVar(&cached, "staged", false, "A synonym of --cached")
 	diffCmd.StringVar(&output, "output", "", "Dumps diff in file instead of standard output")
+	var diffOptFlags diffFlags
+	diffOptFlags.register(diffCmd)
+
+	// diff-tree, diff-index and diff-files
+	diffTreeCmd := flag.NewFlagSet("diff-tree", flag.ExitOnError)
+	diffIndexCmd := flag.NewFlagSet("diff-index", flag.ExitOnError)
+	diffFilesCmd := flag.NewFlagSet("diff-files", flag.ExitOnError)
+	var diffTreeRecursive, diffTreeRoot, diffIndexCached bool
+	var plumbRaw, plumbNameStatus, plumbNameOnly bool
+	diffTreeCmd.BoolVar(&diffTreeRecursive, "r", false, "Recurse into sub-trees")
+	diffTreeCmd.BoolVar(&diffTreeRoot, "root", false, "Show the root commit as a big creation event")
+	diffIndexCmd.BoolVar(&diffIndexCached, "cached", false, "Do not consider the on-disk file at all, compare with the index")
+	for _, fs := range []*flag.FlagSet{diffTreeCmd, diffIndexCmd, diffFilesCmd} {
+		fs.BoolVar(&plumbRaw, "raw", false, "Generate the diff in raw format. This is the default")
+		fs.BoolVar(&plumbNameStatus, "name-status", false, "Show only names and status of changed files")
+		fs.BoolVar(&plumbNameOnly, "name-only", false, "Show only names of changed files")
+	}
+	var plumbRenames renameFlags
+	for _, fs := range []*flag.FlagSet{diffTreeCmd, diffIndexCmd, diffFilesCmd} {
+		plumbRenames.register(fs)
+	}
+	plumbFormat := func() pkg.DiffFormat {
+		switch {
+		case plumbNameStatus:
+			return pkg.DiffNameStatus
+		case plumbNameOnly:
+			return pkg.DiffNameOnly
+		}
+		return pkg.DiffRaw
+	}
 
 	// fetch
 	fetchCmd := flag.NewFlagSet("fetch", flag.ExitOnError)
 
+	// for-each-ref
+	forEachRefCmd := flag.NewFlagSet("for-each-ref", flag.ExitOnError)
+	var refFormat, refContains, refMerged string
+	var refSorts stringsFlag
+	var refCount int
+	forEachRefCmd.StringVar(&refFormat, "format", "", "Interpolate %(fieldname) from each ref shown, and the object it points at")
+	forEachRefCmd.Var(&refSorts, "sort", "A field name to sort on. prefix - to sort in descending order. may be given more than once, the last key is the primary one")
+	forEachRefCmd.IntVar(&refCount, "count", 0, "Stop after showing that many refs")
+	forEachRefCmd.StringVar(&refContains, "contains", "", "Only list refs which contain the specified commit")
+	forEachRefCmd.StringVar(&refMerged, "merged", "", "Only list refs whose tips are reachable from the specified commit")
+
 	// hash-object
 	hashObjCmd := flag.NewFlagSet("hash-object", flag.ExitOnError)
 	var hashW bool
@@ -132,12 +212,40 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 
 	// ls-files
 	lsFilesCmd := flag.NewFlagSet("ls-files", flag.ExitOnError)
-	var lstaged, lcached, ldeleted, lmodified, lothers bool
+	var lstaged, lcached, ldeleted, lmodified, lothers, lverbose bool
 	lsFilesCmd.BoolVar(&lstaged, "s", false, "Show staged contents' mode bits, object name and stage number in the output.")
-	lsFilesCmd.BoolVar(&lcached, "c", true, "Show cached files in the output, default") //comeback
+	lsFilesCmd.BoolVar(&lcached, "c", false, "Show cached files in the output, default when no other selection is made")
 	lsFilesCmd.BoolVar(&ldeleted, "d", false, "Show deleted files in the output ")
 	lsFilesCmd.BoolVar(&lmodified, "m", false, "Show modified files in the output ")
 	lsFilesCmd.BoolVar(&lothers, "o", false, "Show untracked files in the output ")
+	lsFilesCmd.BoolVar(&lverbose, "v", false, "Tag each file with its status. assume-unchanged files use lowercase tags")
+
+	// log
+	logCmd := flag.NewFlagSet("log", flag.ExitOnError)
+	var logOpts pkg.LogOpts
+	var logTopo, logDateOrder bool
+	logCmd.IntVar(&logOpts.MaxCount, "n", 0, "Limit the number of commits to output")
+	logCmd.IntVar(&logOpts.MaxCount, "max-count", 0, "Limit the number of commits to output")
+	logCmd.StringVar(&logOpts.Author, "author", "", "Show only commits whose author matches the regular expression")
+	logCmd.StringVar(&logOpts.Grep, "grep", "", "Show only commits whose message matches the regular expression")
+	logCmd.StringVar(&logOpts.Since, "since", "", "Show commits more recent than a specific date")
+	logCmd.StringVar(&logOpts.Since, "after", "", "Show commits more recent than a specific date")
+	logCmd.StringVar(&logOpts.Until, "until", "", "Show commits older than a specific date")
+	logCmd.StringVar(&logOpts.Until, "before", "", "Show commits older than a specific date")
+	logCmd.BoolVar(&logTopo, "topo-order", false, "Show no parents before all of its children, and avoid mixing lines of history")
+	logCmd.BoolVar(&logDateOrder, "date-order", false, "Show no parents before all of its children, otherwise show commits in timestamp order")
+	logCmd.BoolVar(&logOpts.FirstParent, "first-parent", false, "Follow only the first parent commit upon seeing a merge commit")
+	logCmd.BoolVar(&logOpts.Merges, "merges", false, "Print only merge commits")
+	logCmd.BoolVar(&logOpts.NoMerges, "no-merges", false, "Do not print commits with more than one parent")
+	logCmd.BoolVar(&logOpts.Reverse, "reverse", false, "Output the commits chosen to be shown in reverse order")
+	logCmd.BoolVar(&logOpts.Oneline, "oneline", false, "Shorthand for --pretty=oneline --abbrev-commit")
+	logCmd.BoolVar(&logOpts.Graph, "graph", false, "Draw a text-based graphical representation of the commit history")
+	logCmd.StringVar(&logOpts.Pretty, "pretty", "", "Pretty-print the commits: oneline, short, medium, full, fuller, format:<string> or tformat:<string>")
+	var logFormat string
+	logCmd.StringVar(&logFormat, "format", "", "Pretty-print the commits in the given format, like --pretty=tformat:<format>")
+	logCmd.StringVar(&logOpts.Date, "date", "", "The format of dates: relative, local, iso, iso-strict, rfc, short, raw, unix or default")
+	logCmd.BoolVar(&logOpts.Decorate, "decorate", false, "Print the ref names of the commits that are shown")
+	logCmd.BoolVar(&logOpts.Stat, "stat", false, "Show a diffstat of each commit, renames detected")
 
 	// //ls-tree
 	// lsTreeCmd := flag.NewFlagSet("ls-tree", flag.ExitOnError)
@@ -145,6 +253,17 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 	//merge
 	mergeCmd := flag.NewFlagSet("merge", flag.ExitOnError)
 
+	// name-rev
+	nameRevCmd := flag.NewFlagSet("name-rev", flag.ExitOnError)
+	var nameRevTags, nameRevNameOnly bool
+	nameRevCmd.BoolVar(&nameRevTags, "tags", false, "Do not use branch names, but only tags to name the commits")
+	nameRevCmd.BoolVar(&nameRevNameOnly, "name-only", false, "Print only the name, not the revision as given")
+
+	// pack-refs
+	packRefsCmd := flag.NewFlagSet("pack-refs", flag.ExitOnError)
+	var packAll bool
+	packRefsCmd.BoolVar(&packAll, "all", false, "Pack all refs, not just tags and the refs that are already packed")
+
 	// pull
 	pullCmd := flag.NewFlagSet("pull", flag.ExitOnError)
 	var rebase bool
@@ -153,12 +272,40 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 	// push
 	pushCmd := flag.NewFlagSet("push", flag.ExitOnError)
 
-	// //read-tree
-	// readTreeCmd := flag.NewFlagSet("read-tree", flag.ExitOnError)
+	//read-tree
+	readTreeCmd := flag.NewFlagSet("read-tree", flag.ExitOnError)
+
+	// reflog
+	// like sparse-checkout, the subcommand comes first. a missing one, or a ref in its place, means show
+	reflogCmd := flag.NewFlagSet("reflog", flag.ExitOnError)
+	var reflogSub, reflogExpire string
+	var reflogAll bool
+	reflogCmd.StringVar(&reflogExpire, "expire", "90.days.ago", "With expire, prune entries older than this date")
+	reflogCmd.BoolVar(&reflogAll, "all", false, "With expire, process the reflogs of all references")
 
 	// remote
 	rmtCmd := flag.NewFlagSet("remote", flag.ExitOnError)
 
+	// rev-list
+	revListCmd := flag.NewFlagSet("rev-list", flag.ExitOnError)
+	var revListOpts pkg.RevListOpts
+	revListCmd.IntVar(&revListOpts.MaxCount, "n", 0, "Limit the number of commits to output")
+	revListCmd.IntVar(&revListOpts.MaxCount, "max-count", 0, "Limit the number of commits to output")
+	revListCmd.BoolVar(&revListOpts.Count, "count", false, "Print a number stating how many commits would have been listed")
+	revListCmd.BoolVar(&revListOpts.FirstParent, "first-parent", false, "Follow only the first parent commit upon seeing a merge commit")
+	revListCmd.BoolVar(&revListOpts.Merges, "merges", false, "Print only merge commits")
+	revListCmd.BoolVar(&revListOpts.NoMerges, "no-merges", false, "Do not print commits with more than one parent")
+	revListCmd.BoolVar(&revListOpts.Objects, "objects", false, "Print the object IDs of any object referenced by the listed commits")
+	revListCmd.BoolVar(&revListOpts.ObjectsEdge, "objects-edge", false, "Like --objects, but also print the IDs of excluded commits prefixed with a dash")
+
+	// rev-parse
+	revParseCmd := flag.NewFlagSet("rev-parse", flag.ExitOnError)
+	var verifyRev, abbrevRef bool
+	var shortRev shortFlag
+	revParseCmd.BoolVar(&verifyRev, "verify", false, "Verify that exactly one parameter is given, and that it names an object")
+	revParseCmd.Var(&shortRev, "short", "Like --verify, but shorten the object name to a unique prefix of at least 7, or the given number of, characters")
+	revParseCmd.BoolVar(&abbrevRef, "abbrev-ref", false, "A non-ambiguous short name of the ref")
+
 	// rm
 	rmvCmd := flag.NewFlagSet("rm", flag.ExitOnError)
 	// comeback. just trying something out
@@ -169,22 +316,100 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 	rmvCmd.BoolVar(&rcached, "c", false, `Use this option to unstage and remove paths only from the index. 
 		Working tree files, whether modified or not, will be left alone.`[1:])
 
+	// sparse-checkout
+	// the subcommand comes first, so its flags are parsed after it is taken off the args
+	sparseCmd := flag.NewFlagSet("sparse-checkout", flag.ExitOnError)
+	var sparseCone bool
+	var sparseSub string
+	sparseCmd.BoolVar(&sparseCone, "cone", false, "With init, only allow directories to be specified, instead of arbitrary patterns")
+
+	// show
+	showCmd := flag.NewFlagSet("show", flag.ExitOnError)
+	var showOpts pkg.ShowOpts
+	var showFormat string
+	showCmd.BoolVar(&showOpts.Oneline, "oneline", false, "Shorthand for --pretty=oneline --abbrev-commit")
+	showCmd.StringVar(&showOpts.Pretty, "pretty", "", "Pretty-print the commits: oneline, short, medium, full, fuller, format:<string> or tformat:<string>")
+	showCmd.StringVar(&showFormat, "format", "", "Pretty-print the commits in the given format, like --pretty=tformat:<format>")
+	showCmd.StringVar(&showOpts.Date, "date", "", "The format of dates: relative, local, iso, iso-strict, rfc, short, raw, unix or default")
+	showCmd.BoolVar(&showOpts.NoPatch, "s", false, "Suppress the diff output of commits")
+	showCmd.BoolVar(&showOpts.NoPatch, "no-patch", false, "A synonym of -s")
+	var showDiffFlags diffFlags
+	showDiffFlags.register(showCmd)
+
+	// show-ref
+	showRefCmd := flag.NewFlagSet("show-ref", flag.ExitOnError)
+	var showHeads, showTags, showVerify, showDeref, showHash, showQuiet bool
+	showRefCmd.BoolVar(&showHeads, "heads", false, "Limit to refs/heads")
+	showRefCmd.BoolVar(&showTags, "tags", false, "Limit to refs/tags")
+	showRefCmd.BoolVar(&showVerify, "verify", false, "Enable stricter reference checking by requiring an exact ref path")
+	showRefCmd.BoolVar(&showDeref, "d", false, "Dereference tags into object IDs as well. they are shown with ^{} appended")
+	showRefCmd.BoolVar(&showDeref, "dereference", false, "Dereference tags into object IDs as well. they are shown with ^{} appended")
+	showRefCmd.BoolVar(&showHash, "s", false, "Only show the object IDs, not the reference names")
+	showRefCmd.BoolVar(&showHash, "hash", false, "Only show the object IDs, not the reference names")
+	showRefCmd.BoolVar(&showQuiet, "q", false, "Do not print any results. the exit status tells whether the refs exist")
+	showRefCmd.BoolVar(&showQuiet, "quiet", false, "Do not print any results. the exit status tells whether the refs exist")
+
 	// status
 	statusCmd := flag.NewFlagSet("status", flag.ExitOnError)
 
 	//switch
 	switchCmd := flag.NewFlagSet("switch", flag.ExitOnError)
-	var newBranchSwitch bool
+	var newBranchSwitch, resetBranchSwitch, forceSwitch, detachSwitch bool
 	switchCmd.BoolVar(&newBranchSwitch, "c", false, "Creates a new branch and checks it out")
-	switchCmd.BoolVar(&newBranchSwitch, "C", false, "Creates a new branch and checks it out")
+	switchCmd.BoolVar(&newBranchSwitch, "create", false, "Creates a new branch and checks it out")
+	switchCmd.BoolVar(&resetBranchSwitch, "C", false, "Creates the branch, or resets it if it exists, and checks it out")
+	switchCmd.BoolVar(&forceSwitch, "f", false, "Proceed even if the index or the working tree differs from HEAD. local changes are thrown away")
+	switchCmd.BoolVar(&forceSwitch, "discard-changes", false, "Proceed even if the index or the working tree differs from HEAD. local changes are thrown away")
+	switchCmd.BoolVar(&detachSwitch, "d", false, "Switch to a commit for inspection, detaching HEAD")
+	switchCmd.BoolVar(&detachSwitch, "detach", false, "Switch to a commit for inspection, detaching HEAD")
+
+	// symbolic-ref
+	symRefCmd := flag.NewFlagSet("symbolic-ref", flag.ExitOnError)
+	var symQuiet, symShort, symDelete bool
+	var symMsg string
+	symRefCmd.BoolVar(&symQuiet, "q", false, "Do not print an error if the ref is not a symbolic ref, just exit with a non-zero status")
+	symRefCmd.BoolVar(&symQuiet, "quiet", false, "Do not print an error if the ref is not a symbolic ref, just exit with a non-zero status")
+	symRefCmd.BoolVar(&symShort, "short", false, "When showing the value of the ref, shorten it, e.g. refs/heads/main to main")
+	symRefCmd.BoolVar(&symDelete, "d", false, "Delete the symbolic ref")
+	symRefCmd.BoolVar(&symDelete, "delete", false, "Delete the symbolic ref")
+	symRefCmd.StringVar(&symMsg, "m", "", "Update the reflog of the ref with this reason")
+
+	// tag
+	tagCmd := flag.NewFlagSet("tag", flag.ExitOnError)
+	var tagAnnotate, tagDelete, tagForce, tagList, tagVerify bool
+	var tagMsg string
+	tagCmd.BoolVar(&tagAnnotate, "a", false, "Make an unsigned, annotated tag object")
+	tagCmd.BoolVar(&tagAnnotate, "annotate", false, "Make an unsigned, annotated tag object")
+	tagCmd.StringVar(&tagMsg, "m", "", "Use the given tag message. implies -a")
+	tagCmd.BoolVar(&tagDelete, "d", false, "Delete existing tags with the given names")
+	tagCmd.BoolVar(&tagDelete, "delete", false, "Delete existing tags with the given names")
+	tagCmd.BoolVar(&tagForce, "f", false, "Replace an existing tag with the given name")
+	tagCmd.BoolVar(&tagForce, "force", false, "Replace an existing tag with the given name")
+	tagCmd.BoolVar(&tagList, "l", false, "List tags. with patterns, only the tags that match")
+	tagCmd.BoolVar(&tagList, "list", false, "List tags. with patterns, only the tags that match")
+	tagCmd.BoolVar(&tagVerify, "v", false, "Verify the GPG signature of the given tags")
+	tagCmd.BoolVar(&tagVerify, "verify", false, "Verify the GPG signature of the given tags")
+
+	// update-ref
+	updRefCmd := flag.NewFlagSet("update-ref", flag.ExitOnError)
+	var updRefDelete, updRefStdin bool
+	var updRefMsg string
+	updRefCmd.BoolVar(&updRefDelete, "d", false, "Delete the ref, after verifying it still contains <old> if given")
+	updRefCmd.StringVar(&updRefMsg, "m", "", "The reason to record in the reflog")
+	updRefCmd.BoolVar(&updRefStdin, "stdin", false, "Read update, create, delete and verify commands from stdin and apply them in one transaction")
 
 	// update-index
 	updIndCmd := flag.NewFlagSet("update-index", flag.ExitOnError)
 	var addInd, rmvInd bool
+	var assumeUnchanged, noAssumeUnchanged, skipWorktree, noSkipWorktree bool
 	updIndCmd.BoolVar(&addInd, "add", false, `If a specified file isn’t in the index already then it’s added. 
 		Default behaviour is to ignore new files.`)
-	updIndCmd.BoolVar(&rmvInd, "add", false, `If a specified file isn’t in the index already then it’s removed. 
+	updIndCmd.BoolVar(&rmvInd, "remove", false, `If a specified file is in the index but is missing from the working tree then it’s removed. 
 		Default behaviour is to ignore removed files.`)
+	updIndCmd.BoolVar(&assumeUnchanged, "assume-unchanged", false, "Set the assume unchanged bit for the paths, status stops checking them")
+	updIndCmd.BoolVar(&noAssumeUnchanged, "no-assume-unchanged", false, "Unset the assume unchanged bit for the paths")
+	updIndCmd.BoolVar(&skipWorktree, "skip-worktree", false, "Set the skip-worktree bit for the paths, the working tree copy is ignored")
+	updIndCmd.BoolVar(&noSkipWorktree, "no-skip-worktree", false, "Unset the skip-worktree bit for the paths")
 
 	//verify-pack
 	verifyPackCmd := flag.NewFlagSet("verify-pack", flag.ExitOnError)
@@ -209,44 +434,91 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 	// parse the flags as defined by the flag sets above
 	case "add":
 		addCmd.Parse(args[1:])
+	case "blame":
+		blameCmd.Parse(stuckArgs(args[1:], "L"))
 	case "branch":
 		branchCmd.Parse(args[1:])
 	case "cat-file":
 		catCmd.Parse(args[1:])
 	case "checkout":
 		checkoutCmd.Parse(args[1:])
+	case "check-ref-format":
+		checkRefCmd.Parse(args[1:])
 	case "commit":
 		commitCmd.Parse(args[1:])
 	case "config":
 		configCmd.Parse(args[1:])
+	case "describe":
+		describeCmd.Parse(args[1:])
 	case "diff":
-		diffCmd.Parse(args[1:])
+		diffCmd.Parse(stuckArgs(args[1:], "M", "C", "U"))
+	case "diff-files":
+		diffFilesCmd.Parse(stuckArgs(args[1:], "M", "C"))
+	case "diff-index":
+		diffIndexCmd.Parse(stuckArgs(args[1:], "M", "C"))
+	case "diff-tree":
+		diffTreeCmd.Parse(stuckArgs(args[1:], "M", "C"))
 	case "fetch":
 		fetchCmd.Parse(args[1:])
+	case "for-each-ref":
+		forEachRefCmd.Parse(args[1:])
 	case "hash-object":
 		hashObjCmd.Parse(args[1:])
 	case "init":
 		initCmd.Parse(args[1:])
+	case "log":
+		logCmd.Parse(args[1:])
 	case "ls-files":
 		lsFilesCmd.Parse(args[1:])
 	// case "ls-tree":
 	// 	lsTreeCmd.Parse(args[1:])
 	case "merge":
 		mergeCmd.Parse(args[1:])
+	case "name-rev":
+		nameRevCmd.Parse(args[1:])
+	case "pack-refs":
+		packRefsCmd.Parse(args[1:])
 	case "pull":
 		pullCmd.Parse(args[1:])
 	case "push":
 		pushCmd.Parse(args[1:])
-	// case "read-tree":
-	// 	readTreeCmd.Parse(args[1:])
+	case "read-tree":
+		readTreeCmd.Parse(args[1:])
+	case "reflog":
+		reflogSub = "show"
+		rest := args[1:]
+		if len(rest) > 0 && (rest[0] == "show" || rest[0] == "expire" || rest[0] == "delete") {
+			reflogSub, rest = rest[0], rest[1:]
+		}
+		reflogCmd.Parse(rest)
 	case "remote":
 		rmtCmd.Parse(args[1:])
+	case "rev-list":
+		revListCmd.Parse(args[1:])
+	case "rev-parse":
+		revParseCmd.Parse(args[1:])
 	case "rm":
 		rmvCmd.Parse(args[1:])
+	case "sparse-checkout":
+		if len(args) < 2 {
+			return nil, fmt.Errorf("sparse-checkout expects one of these subcommands: init, set, add, list, disable")
+		}
+		sparseSub = args[1]
+		sparseCmd.Parse(args[2:])
+	case "show":
+		showCmd.Parse(stuckArgs(args[1:], "M", "C", "U"))
+	case "show-ref":
+		showRefCmd.Parse(args[1:])
 	case "status":
 		statusCmd.Parse(args[1:])
 	case "switch":
 		switchCmd.Parse(args[1:])
+	case "symbolic-ref":
+		symRefCmd.Parse(args[1:])
+	case "tag":
+		tagCmd.Parse(args[1:])
+	case "update-ref":
+		updRefCmd.Parse(args[1:])
 	case "update-index":
 		updIndCmd.Parse(args[1:])
 	case "verify-pack":
@@ -279,15 +551,53 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 
 		}
 
-	case branchCmd.Parsed():
+	case blameCmd.Parsed():
 		{
-			if len(branchCmd.Args()) < 1 { //no path argument provided
-				return nil, fmt.Errorf("branch needs  the name specified as an argument. \n.")
+			//[<rev>] [--] <file>
+			args := blameCmd.Args()
+			var rev string
+			switch {
+			case len(args) == 3 && args[1] == "--":
+				rev, args = args[0], args[2:]
+			case len(args) == 2 && args[0] == "--":
+				args = args[1:]
+			case len(args) == 2:
+				rev, args = args[0], args[1:]
 			}
-			name := branchCmd.Arg(0)
+			if len(args) != 1 {
+				return nil, fmt.Errorf("usage: got blame [-L <range>] [--porcelain] [<rev>] [--] <file>")
+			}
+			return &blame{path: args[0], rev: rev, ranges: blameRanges, porcelain: blamePorcelain}, nil
+		}
+
+	case branchCmd.Parsed():
+		{
+			bArgs := branchCmd.Args()
 			b := branch{
-				name:   name,
-				delete: deleteBranch,
+				delete:   deleteBranch || forceDeleteBranch,
+				move:     moveBranch || forceMoveBranch,
+				force:    forceBranch || forceDeleteBranch || forceMoveBranch,
+				upstream: upstreamBranch,
+				args:     bArgs,
+			}
+			ops := 0
+			for _, op := range []bool{b.delete, b.move, b.upstream != ""} {
+				if op {
+					ops++
+				}
+			}
+			if ops > 1 {
+				return nil, fmt.Errorf("only one of -d, -m and --set-upstream-to can be used at a time")
+			}
+			switch {
+			case b.delete && len(bArgs) == 0:
+				return nil, fmt.Errorf("branch name required")
+			case b.move && (len(bArgs) == 0 || len(bArgs) > 2):
+				return nil, fmt.Errorf("branch -m expects the new name, optionally preceded by the old one")
+			case b.upstream != "" && len(bArgs) > 1:
+				return nil, fmt.Errorf("too many arguments to set new upstream")
+			case ops == 0 && len(bArgs) > 2:
+				return nil, fmt.Errorf("branch expects a name and an optional start point")
 			}
 			return &b, nil
 		}
@@ -313,17 +623,38 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 		{
 
 			name := checkoutCmd.Arg(0)
-			if name == "" {
+			if name == "" || checkoutCmd.NArg() > 2 {
 				return nil, fmt.Errorf("Error parsing flags")
 			}
+			newBranch := newBranchCheckout || resetBranchCheckout
+			if checkoutCmd.NArg() == 2 && !newBranch {
+				return nil, fmt.Errorf("a start point is only allowed when creating a branch with -b or -B")
+			}
 
 			return &checkout{
-				name: name,
-				new:  newBranchCheckout,
+				name:   name,
+				start:  checkoutCmd.Arg(1),
+				new:    newBranch,
+				reset:  resetBranchCheckout,
+				force:  forceCheckout,
+				detach: detachCheckout,
 			}, nil
 
 		}
 
+	case checkRefCmd.Parsed():
+		{
+			if len(checkRefCmd.Args()) != 1 {
+				return nil, fmt.Errorf("usage: got check-ref-format [--branch] [--allow-onelevel] [--normalize] <refname>")
+			}
+			return &checkRefFormat{
+				name:          checkRefCmd.Arg(0),
+				branch:        checkBranch,
+				allowOneLevel: allowOneLevel,
+				normalize:     normalizeRef,
+			}, nil
+		}
+
 	case commitCmd.Parsed():
 		{
 			if len(commitCmd.Args()) > 0 {
@@ -365,17 +696,86 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 			return conf, nil
 		}
 
-	case diffCmd.Parsed():
+	case describeCmd.Parsed():
 		{
-			// cached and diff arg provided == error
-			if cached && diffCmd.Arg(0) != "" {
-				return nil, fmt.Errorf("We do not support ")
+			dArgs := describeCmd.Args()
+			if describeDirty.val != "" && len(dArgs) != 0 {
+				return nil, fmt.Errorf("--dirty is incompatible with commit-ishes")
 			}
+			if len(dArgs) == 0 {
+				dArgs = []string{"HEAD"}
+			}
+			return &describe{
+				revs:   dArgs,
+				tags:   describeTags,
+				long:   describeLong,
+				abbrev: describeAbbrev,
+				dirty:  describeDirty.val,
+			}, nil
+		}
 
+	case diffCmd.Parsed():
+		{
+			diffOpts, err := diffOptFlags.parse()
+			if err != nil {
+				return nil, err
+			}
+			diffOpts.Cached = cached
 			return &diff{
-				cached: cached,
+				opts:   diffOpts,
 				output: output,
-				arg:    diffCmd.Arg(0),
+				args:   diffCmd.Args(),
+			}, nil
+		}
+
+	case diffTreeCmd.Parsed():
+		{
+			if len(diffTreeCmd.Args()) == 0 {
+				return nil, fmt.Errorf("diff-tree expects one or two tree-ishes")
+			}
+			renames, err := plumbRenames.opts(false)
+			if err != nil {
+				return nil, err
+			}
+			return &diffPlumb{
+				cmd:       "diff-tree",
+				args:      diffTreeCmd.Args(),
+				recursive: diffTreeRecursive,
+				root:      diffTreeRoot,
+				renames:   renames,
+				format:    plumbFormat(),
+			}, nil
+		}
+
+	case diffIndexCmd.Parsed():
+		{
+			if len(diffIndexCmd.Args()) == 0 {
+				return nil, fmt.Errorf("diff-index expects a tree-ish")
+			}
+			renames, err := plumbRenames.opts(false)
+			if err != nil {
+				return nil, err
+			}
+			return &diffPlumb{
+				cmd:     "diff-index",
+				args:    diffIndexCmd.Args(),
+				cached:  diffIndexCached,
+				renames: renames,
+				format:  plumbFormat(),
+			}, nil
+		}
+
+	case diffFilesCmd.Parsed():
+		{
+			renames, err := plumbRenames.opts(false)
+			if err != nil {
+				return nil, err
+			}
+			return &diffPlumb{
+				cmd:     "diff-files",
+				args:    diffFilesCmd.Args(),
+				renames: renames,
+				format:  plumbFormat(),
 			}, nil
 		}
 
@@ -388,6 +788,18 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 			return &fetch{remote: fetchArgs[0]}, nil
 		}
 
+	case forEachRefCmd.Parsed():
+		{
+			return &forEachRef{
+				patterns: forEachRefCmd.Args(),
+				format:   refFormat,
+				sorts:    refSorts,
+				count:    refCount,
+				contains: refContains,
+				merged:   refMerged,
+			}, nil
+		}
+
 	case hashObjCmd.Parsed():
 		{
 			if len(hashObjCmd.Args()) != 1 {
@@ -409,6 +821,7 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 				ldeleted:  ldeleted,
 				lmodified: lmodified,
 				lothers:   lothers,
+				lverbose:  lverbose,
 			}, nil
 		}
 
@@ -456,15 +869,82 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 			}, nil
 		}
 
-	// case readTreeCmd.Parsed():
-	// 	{
-	// 		if len(readTreeCmd.Args()) != 1 {
-	// 			return nil, fmt.Errorf("Error parsing flags")
-	// 		}
-	// 		return &readTree{
-	// 			treeish: readTreeCmd.Arg(0),
-	// 		}, nil
-	// 	}
+	case logCmd.Parsed():
+		{
+			switch {
+			case logTopo && logDateOrder:
+				return nil, fmt.Errorf("--topo-order and --date-order cannot be used together")
+			case logOpts.Reverse && logOpts.Graph:
+				return nil, fmt.Errorf("options '--reverse' and '--graph' cannot be used together")
+			case logTopo:
+				logOpts.Order = "topo"
+			case logDateOrder:
+				logOpts.Order = "date-order"
+			}
+			if logFormat != "" {
+				//a --format with no placeholders names a pretty format, like --pretty
+				if strings.Contains(logFormat, "%") && !strings.Contains(logFormat, "format:") {
+					logFormat = "tformat:" + logFormat
+				}
+				logOpts.Pretty = logFormat
+			}
+			return &logHist{opts: logOpts, args: logCmd.Args()}, nil
+		}
+
+	case nameRevCmd.Parsed():
+		{
+			if len(nameRevCmd.Args()) == 0 {
+				return nil, fmt.Errorf("name-rev expects at least one commit")
+			}
+			return &nameRev{
+				revs:     nameRevCmd.Args(),
+				tags:     nameRevTags,
+				nameOnly: nameRevNameOnly,
+			}, nil
+		}
+
+	case packRefsCmd.Parsed():
+		{
+			if len(packRefsCmd.Args()) != 0 {
+				return nil, fmt.Errorf("pack-refs takes no arguments")
+			}
+			return &packRefs{all: packAll}, nil
+		}
+
+	case readTreeCmd.Parsed():
+		{
+			if len(readTreeCmd.Args()) != 1 {
+				return nil, fmt.Errorf("Error parsing flags")
+			}
+			return &readTree{
+				treeish: readTreeCmd.Arg(0),
+			}, nil
+		}
+
+	case reflogCmd.Parsed():
+		{
+			rArgs := reflogCmd.Args()
+			switch reflogSub {
+			case "show":
+				if len(rArgs) > 1 {
+					return nil, fmt.Errorf("reflog show takes at most one ref")
+				}
+			case "delete":
+				if len(rArgs) == 0 {
+					return nil, fmt.Errorf("reflog delete expects entries like HEAD@{1}")
+				}
+			case "expire":
+				if len(rArgs) == 0 && !reflogAll {
+					return nil, fmt.Errorf("reflog expire expects refs, or --all")
+				}
+			}
+			return &reflog{
+				sub:    reflogSub,
+				expire: reflogExpire,
+				all:    reflogAll,
+				args:   rArgs,
+			}, nil
+		}
 
 	case rmtCmd.Parsed():
 		{
@@ -484,6 +964,29 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 			return &rmt, nil
 		}
 
+	case revListCmd.Parsed():
+		{
+			if len(revListCmd.Args()) == 0 {
+				return nil, fmt.Errorf("rev-list expects at least one revision")
+			}
+			return &revList{opts: revListOpts, args: revListCmd.Args()}, nil
+		}
+
+	case revParseCmd.Parsed():
+		{
+			revArgs := revParseCmd.Args()
+			//--short implies --verify, like in git
+			if (verifyRev || shortRev > 0) && len(revArgs) != 1 {
+				return nil, fmt.Errorf("Needed a single revision")
+			}
+			return &revParse{
+				verify:    verifyRev || shortRev > 0,
+				short:     int(shortRev),
+				abbrevRef: abbrevRef,
+				revs:      revArgs,
+			}, nil
+		}
+
 	case rmvCmd.Parsed():
 		{
 			rmvArgs := rmvCmd.Args()
@@ -497,6 +1000,62 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 			}
 		}
 
+	case sparseCmd.Parsed():
+		{
+			switch sparseSub {
+			case "init", "list", "disable":
+				if len(sparseCmd.Args()) != 0 {
+					return nil, fmt.Errorf("sparse-checkout %s takes no arguments", sparseSub)
+				}
+			case "set", "add":
+				if sparseSub == "add" && len(sparseCmd.Args()) == 0 {
+					return nil, fmt.Errorf("sparse-checkout add expects at least one directory or pattern")
+				}
+			default:
+				return nil, fmt.Errorf("sparse-checkout expects one of these subcommands: init, set, add, list, disable")
+			}
+			return &sparseCheckout{
+				sub:  sparseSub,
+				cone: sparseCone,
+				args: sparseCmd.Args(),
+			}, nil
+		}
+
+	case showCmd.Parsed():
+		{
+			diffOpts, err := showDiffFlags.parse()
+			if err != nil {
+				return nil, err
+			}
+			showOpts.Diff = diffOpts
+			formatSet := false
+			showCmd.Visit(func(f *flag.Flag) { formatSet = formatSet || f.Name == "format" })
+			if formatSet {
+				//a --format with no placeholders names a pretty format, like --pretty. an empty one prints nothing
+				if showFormat == "" || strings.Contains(showFormat, "%") && !strings.Contains(showFormat, "format:") {
+					showFormat = "tformat:" + showFormat
+				}
+				showOpts.Pretty = showFormat
+			}
+			return &show{opts: showOpts, args: showCmd.Args()}, nil
+		}
+
+	case showRefCmd.Parsed():
+		{
+			if showVerify && len(showRefCmd.Args()) == 0 {
+				return nil, fmt.Errorf("--verify requires a reference")
+			}
+			return &showRef{
+				patterns: showRefCmd.Args(),
+				heads:    showHeads,
+				tags:     showTags,
+				verify:   showVerify,
+				deref:    showDeref,
+				hash:     showHash,
+				quiet:    showQuiet,
+			}, nil
+		}
+
 	case statusCmd.Parsed():
 		{
 			return &status{}, nil
@@ -505,24 +1064,120 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 	case switchCmd.Parsed():
 		{
 			name := switchCmd.Arg(0)
-			if name == "" {
+			if name == "" || switchCmd.NArg() > 2 {
 				return nil, fmt.Errorf("Error parsing flags")
 			}
+			newBranch := newBranchSwitch || resetBranchSwitch
+			if switchCmd.NArg() == 2 && !newBranch {
+				return nil, fmt.Errorf("a start point is only allowed when creating a branch with -c or -C")
+			}
 
 			return &_switch{
-				name: name,
-				new:  newBranchSwitch,
+				name:   name,
+				start:  switchCmd.Arg(1),
+				new:    newBranch,
+				reset:  resetBranchSwitch,
+				force:  forceSwitch,
+				detach: detachSwitch,
 			}, nil
 		}
 
+	case tagCmd.Parsed():
+		{
+			tArgs := tagCmd.Args()
+			t := &tag{
+				annotate: tagAnnotate || tagMsg != "",
+				msg:      tagMsg,
+				force:    tagForce,
+				args:     tArgs,
+			}
+			switch {
+			case tagDelete:
+				t.mode = tagDeleteMode
+			case tagVerify:
+				t.mode = tagVerifyMode
+			case tagList || len(tArgs) == 0:
+				t.mode = tagListMode
+			default:
+				t.mode = tagCreateMode
+			}
+			if (t.mode == tagDeleteMode || t.mode == tagVerifyMode) && len(tArgs) == 0 {
+				return nil, fmt.Errorf("tag -d and -v expect tag names")
+			}
+			if t.mode == tagCreateMode && len(tArgs) > 2 {
+				return nil, fmt.Errorf("too many arguments. tag expects a name and an optional object")
+			}
+			if t.annotate && t.msg == "" && t.mode == tagCreateMode {
+				return nil, fmt.Errorf("an annotated tag needs a message. use -m")
+			}
+			return t, nil
+		}
+
+	case updRefCmd.Parsed():
+		{
+			uArgs := updRefCmd.Args()
+			u := &updateRef{del: updRefDelete, stdin: updRefStdin, msg: updRefMsg}
+			switch {
+			case updRefStdin:
+				if len(uArgs) != 0 {
+					return nil, fmt.Errorf("update-ref --stdin takes no arguments")
+				}
+				return u, nil
+			case updRefDelete:
+				if len(uArgs) < 1 || len(uArgs) > 2 {
+					return nil, fmt.Errorf("usage: got update-ref -d <ref> [<old>]")
+				}
+				u.ref = uArgs[0]
+				if len(uArgs) == 2 {
+					u.old, u.haveOld = uArgs[1], true
+				}
+			default:
+				if len(uArgs) < 2 || len(uArgs) > 3 {
+					return nil, fmt.Errorf("usage: got update-ref <ref> <new> [<old>]")
+				}
+				u.ref, u.new = uArgs[0], uArgs[1]
+				if len(uArgs) == 3 {
+					u.old, u.haveOld = uArgs[2], true
+				}
+			}
+			return u, nil
+		}
+
 	case updIndCmd.Parsed():
 		{
+			if (assumeUnchanged && noAssumeUnchanged) || (skipWorktree && noSkipWorktree) {
+				return nil, fmt.Errorf("a flag and its --no- counterpart can't be set together")
+			}
 			return &updateIndex{
-				add:    addInd,
-				remove: rmvInd,
+				add:               addInd,
+				remove:            rmvInd,
+				assumeUnchanged:   assumeUnchanged,
+				noAssumeUnchanged: noAssumeUnchanged,
+				skipWorktree:      skipWorktree,
+				noSkipWorktree:    noSkipWorktree,
+				paths:             updIndCmd.Args(),
 			}, nil
 		}
 
+	case symRefCmd.Parsed():
+		{
+			symArgs := symRefCmd.Args()
+			if len(symArgs) < 1 || len(symArgs) > 2 || (symDelete && len(symArgs) != 1) {
+				return nil, fmt.Errorf("usage: got symbolic-ref [-q] [--short] [-d] [-m <reason>] <name> [<ref>]")
+			}
+			sym := &symbolicRef{
+				name:   symArgs[0],
+				quiet:  symQuiet,
+				short:  symShort,
+				delete: symDelete,
+				msg:    symMsg,
+			}
+			if len(symArgs) == 2 {
+				sym.target = symArgs[1]
+			}
+			return sym, nil
+		}
+
 	case verifyPackCmd.Parsed():
 		{
 			if len(verifyPackCmd.Args()) != 1 {
@@ -543,3 +1198,192 @@ func (a *app) parseArgs(ctx context.Context) (Runner, error) {
 		}
 	}
 }
+
+// shortFlag is a number that may be left out, like `--short` or `--short=10`. left out, it is 7
+type shortFlag int
+
+func (s *shortFlag) String() string {
+	return fmt.Sprint(int(*s))
+}
+
+func (s *shortFlag) Set(v string) error {
+	if v == "true" {
+		*s = 7
+		return nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("expected a number: %s", v)
+	}
+	*s = shortFlag(n)
+	return nil
+}
+
+// IsBoolFlag lets the flag package accept the flag without a value
+func (s *shortFlag) IsBoolFlag() bool {
+	return true
+}
+
+// stringsFlag collects every use of a flag that may be given more than once, like `--sort`
+type stringsFlag []string
+
+func (s *stringsFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringsFlag) Set(v string) error {
+	*s = append(*s, v)
+	return nil
+}
+
+// markFlag is a string that may be left out, like `--dirty` or `--dirty=-modified`. left out, it is def
+type markFlag struct {
+	val, def string
+}
+
+func (m *markFlag) String() string {
+	if m == nil {
+		return ""
+	}
+	return m.val
+}
+
+func (m *markFlag) Set(v string) error {
+	if v == "true" {
+		v = m.def
+	}
+	m.val = v
+	return nil
+}
+
+// IsBoolFlag lets the flag package accept the flag without a value
+func (m *markFlag) IsBoolFlag() bool {
+	return true
+}
+
+// diffFlags are the flags of the commands that print diffs: how lines are compared, the context, renames, and
+// what's printed
+type diffFlags struct {
+	opts       pkg.DiffOpts
+	renames    renameFlags
+	algorithm  string
+	wordDiff   markFlag
+	colorWords bool
+}
+
+func (d *diffFlags) register(fs *flag.FlagSet) {
+	d.renames.register(fs)
+	fs.StringVar(&d.algorithm, "diff-algorithm", "myers", "Choose a diff algorithm: myers (the default), minimal, patience or histogram")
+	fs.IntVar(&d.opts.Context, "U", 3, "Generate diffs with <n> lines of context")
+	fs.IntVar(&d.opts.Context, "unified", 3, "A synonym of -U")
+	fs.BoolVar(&d.opts.IgnoreSpaceChange, "b", false, "Ignore changes in amount of whitespace")
+	fs.BoolVar(&d.opts.IgnoreSpaceChange, "ignore-space-change", false, "A synonym of -b")
+	fs.BoolVar(&d.opts.IgnoreAllSpace, "w", false, "Ignore whitespace when comparing lines")
+	fs.BoolVar(&d.opts.IgnoreAllSpace, "ignore-all-space", false, "A synonym of -w")
+	fs.BoolVar(&d.opts.Stat, "stat", false, "Generate a diffstat")
+	fs.BoolVar(&d.opts.Numstat, "numstat", false, "Like --stat, but show the added and deleted lines in decimal, and no abbreviated paths")
+	fs.BoolVar(&d.opts.Shortstat, "shortstat", false, "Output only the last line of the --stat format")
+	fs.BoolVar(&d.opts.NameOnly, "name-only", false, "Show only the names of changed files")
+	fs.BoolVar(&d.opts.NameStatus, "name-status", false, "Show only the names and status of changed files")
+	fs.BoolVar(&d.opts.Patch, "p", false, "Generate a patch, along with the stats")
+	fs.BoolVar(&d.opts.Patch, "patch", false, "A synonym of -p")
+	d.wordDiff.def = "plain"
+	fs.Var(&d.wordDiff, "word-diff", "Show a word diff, marking the changed words. <mode> is plain (the default), color or porcelain")
+	fs.BoolVar(&d.colorWords, "color-words", false, "A synonym of --word-diff=color")
+}
+
+// parse checks the flags and turns them into diff options
+func (d *diffFlags) parse() (pkg.DiffOpts, error) {
+	opts := d.opts
+	var err error
+	if opts.Renames, err = d.renames.opts(true); err != nil {
+		return opts, err
+	}
+	if opts.Algorithm, err = gotdiff.ParseAlgorithm(d.algorithm); err != nil {
+		return opts, err
+	}
+	if opts.Context < 0 {
+		return opts, fmt.Errorf("-U expects a number of lines, not %d", opts.Context)
+	}
+	if d.colorWords {
+		d.wordDiff.val = "color"
+	}
+	if d.wordDiff.val != "" {
+		if opts.WordDiff, err = gotdiff.ParseWordStyle(d.wordDiff.val); err != nil {
+			return opts, err
+		}
+	}
+	return opts, nil
+}
+
+// renameFlags are the rename detection flags of the diff commands: -M[<n>], -C[<n>], --find-copies-harder
+// and --no-renames
+type renameFlags struct {
+	renames          markFlag
+	copies           countedMark
+	harder, noRename bool
+}
+
+// countedMark is a markFlag that counts how often it was given, -C -C looks harder than -C
+type countedMark struct {
+	markFlag
+	times int
+}
+
+func (c *countedMark) Set(v string) error {
+	c.times++
+	return c.markFlag.Set(v)
+}
+
+func (r *renameFlags) register(fs *flag.FlagSet) {
+	r.renames.def, r.copies.def = "50%", "50%"
+	fs.Var(&r.renames, "M", "Detect renames. the optional n is the similarity a file needs to be a rename, 50% by default")
+	fs.Var(&r.renames, "find-renames", "Detect renames, like -M")
+	fs.Var(&r.copies, "C", "Detect copies as well as renames. the optional n is the similarity, like for -M")
+	fs.Var(&r.copies, "find-copies", "Detect copies as well as renames, like -C")
+	fs.BoolVar(&r.harder, "find-copies-harder", false, "Look for the source of copies in unmodified files too")
+	fs.BoolVar(&r.noRename, "no-renames", false, "Turn off rename detection")
+}
+
+// opts is the rename detection asked for. byDefault is whether the command detects renames unasked
+func (r *renameFlags) opts(byDefault bool) (pkg.RenameOpts, error) {
+	var opts pkg.RenameOpts
+	if r.noRename {
+		return opts, nil
+	}
+	opts.Renames = byDefault || r.renames.val != ""
+	opts.Copies = r.copies.val != ""
+	opts.CopiesHarder = r.harder || r.copies.times > 1
+	//like in git, there's one score, and the last of -M and -C given sets it. -C wins here
+	score := r.renames.val
+	if r.copies.val != "" {
+		score = r.copies.val
+	}
+	if score != "" {
+		n, err := pkg.ParseRenameScore(score)
+		if err != nil {
+			return opts, err
+		}
+		opts.Score = n
+	}
+	return opts, nil
+}
+
+// stuckArgs splits the value off short flags written stuck to it, like -M50%, which the flag package
+// would take for a flag named M50%. it becomes -M=50%
+func stuckArgs(args []string, short ...string) []string {
+	out := make([]string, 0, len(args))
+	for i, a := range args {
+		if a == "--" {
+			return append(out, args[i:]...)
+		}
+		for _, s := range short {
+			if strings.HasPrefix(a, "-"+s) && len(a) > len(s)+1 && a[len(s)+1] != '=' {
+				a = "-" + s + "=" + a[len(s)+1:]
+				break
+			}
+		}
+		out = append(out, a)
+	}
+	return out
+}
@@ -0,0 +1,545 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/OLUWAMUYIWA/got/pkg/diff"
+)
+
+//  |||BLAME||| //
+//blame puts each line of a file down to the commit that last changed it. every line starts out suspected on the
+//commit blamed, and history is walked from there, children before parents. a commit diffs its version of the file
+//against each parent's and passes the lines they have in common on to the parent. the lines nobody took are the
+//commit's own. a parent without the file may have it under another name, which is found by rename detection,
+//so the blame goes on past renames. a root commit is where history ends: it keeps what's left, as a boundary.
+//with no revision, the file in the working tree is blamed, as a commit of its own on top of HEAD,
+//`Not Committed Yet`. like git, the diffs are myers with no context and the indent heuristic.
+//read: https://git-scm.com/docs/git-blame and blame.c in https://github.com/git/git
+
+// Blame is a file with each of its lines put down to a commit
+type Blame struct {
+	got *Got
+	//Path is the file blamed, and Lines its lines, in order
+	Path  string
+	Lines []BlameLine
+}
+
+// BlameLine is a line of a blamed file and where it came from
+type BlameLine struct {
+	//Commit brought the line in. it's all zeros for a line that isn't committed yet
+	Commit Sha1
+	//Path is the file's name in Commit, and OrigLine the line's number there, from 1
+	Path     string
+	OrigLine int
+	//Line is the line's number in the file blamed, from 1, and Text the line, with its newline
+	Line int
+	Text string
+	//Boundary is set for a line of a root commit, which may be older than that
+	Boundary bool
+
+	origin *blameOrigin
+}
+
+// blameOrigin is a version of the file: a commit and the file's path in it. suspects are the lines of the blame
+// that may still come from it, their numbers there in orig. previous is the origin in the parent it was diffed
+// against first
+type blameOrigin struct {
+	commit   *Comm
+	path     string
+	blob     Sha1
+	data     []byte
+	suspects []int
+	previous *blameOrigin
+}
+
+// blamer is a blame being worked out: lines are the file's, and orig where each line is in its suspect.
+// pending counts the lines that still have no commit of their own
+type blamer struct {
+	got     *Got
+	lines   []BlameLine
+	orig    []int
+	pending int
+	origins map[Sha1][]*blameOrigin
+	commits map[Sha1]*Comm
+}
+
+// notCommitted is the name the working tree goes by in a blame
+const notCommitted = "Not Committed Yet"
+
+// Blame blames the file at rev, or in the working tree when rev is empty
+func (got *Got) Blame(path, rev string) (*Blame, error) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	b := &blamer{got: got, origins: make(map[Sha1][]*blameOrigin), commits: make(map[Sha1]*Comm)}
+	var final *blameOrigin
+	var err error
+	if rev == "" {
+		final, err = b.worktreeOrigin(path)
+	} else {
+		final, err = b.commitOrigin(rev, path)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	text := diff.SplitLines(string(final.data))
+	b.lines, b.orig, b.pending = make([]BlameLine, len(text)), make([]int, len(text)), len(text)
+	for i, l := range text {
+		b.lines[i] = BlameLine{Line: i + 1, Text: l, origin: final}
+		b.orig[i] = i
+		final.suspects = append(final.suspects, i)
+	}
+	if err := b.run(final); err != nil {
+		return nil, err
+	}
+
+	for i := range b.lines {
+		l := &b.lines[i]
+		l.Commit, l.Path, l.OrigLine = l.origin.commit.sha, l.origin.path, b.orig[i]+1
+		l.Boundary = len(l.origin.commit.parents) == 0
+	}
+	return &Blame{got: got, Path: path, Lines: b.lines}, nil
+}
+
+// commitOrigin is the file as it is at rev
+func (b *blamer) commitOrigin(rev, path string) (*blameOrigin, error) {
+	sha, err := b.got.peelRev(rev, "commit")
+	if err != nil {
+		return nil, err
+	}
+	c, err := b.commit(sha)
+	if err != nil {
+		return nil, err
+	}
+	it, err := b.got.treeLookup(c.treeSha, path)
+	if err != nil || modType(it.mode) == treefile {
+		return nil, fmt.Errorf("no such path %s in %s", path, rev)
+	}
+	return b.origin(c, path, it.sha)
+}
+
+// worktreeOrigin is the file in the working tree, made a commit on top of HEAD. git takes the file to be tracked
+// if HEAD or the index has it
+func (b *blamer) worktreeOrigin(path string) (*blameOrigin, error) {
+	head, err := b.got.peelRev("HEAD", "commit")
+	if err != nil {
+		return nil, err
+	}
+	hc, err := b.commit(head)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := b.got.treeLookup(hc.treeSha, path); err != nil {
+		if _, err := b.got.indexPath(path); err != nil {
+			return nil, fmt.Errorf("no such path '%s' in HEAD", path)
+		}
+	}
+	data, err := os.ReadFile(filepath.Join(b.got.baseDir, path))
+	if err != nil {
+		return nil, err
+	}
+	blob, err := hashWithObjFormat(data, "blob")
+	if err != nil {
+		return nil, err
+	}
+	who := Sign{name: notCommitted, email: "not.committed.yet", time: time.Now()}
+	c := &Comm{parents: []Sha1{head}, author: who, committer: who, msg: fmt.Sprintf("Version of %s from %s\n", path, path)}
+	o := &blameOrigin{commit: c, path: path, blob: blob, data: data}
+	b.origins[c.sha] = append(b.origins[c.sha], o)
+	return o, nil
+}
+
+// commit reads a commit once
+func (b *blamer) commit(sha Sha1) (*Comm, error) {
+	if c, ok := b.commits[sha]; ok {
+		return c, nil
+	}
+	c, err := b.got.readCommit(shaToString(sha))
+	if err != nil {
+		return nil, err
+	}
+	b.commits[sha] = c
+	return c, nil
+}
+
+// origin is the file at path in the commit, the same one each time it's asked for
+func (b *blamer) origin(c *Comm, path string, blob Sha1) (*blameOrigin, error) {
+	for _, o := range b.origins[c.sha] {
+		if o.path == path {
+			return o, nil
+		}
+	}
+	data, err := b.got.sideData(&diffSide{path: path, sha: blob})
+	if err != nil {
+		return nil, err
+	}
+	o := &blameOrigin{commit: c, path: path, blob: blob, data: data}
+	b.origins[c.sha] = append(b.origins[c.sha], o)
+	return o, nil
+}
+
+// run walks history from the final origin until every line found its commit
+func (b *blamer) run(final *blameOrigin) error {
+	start := final.commit.sha
+	if final.commit.sha == (Sha1{}) {
+		if err := b.pass(final); err != nil {
+			return err
+		}
+		start = final.commit.parents[0]
+	}
+	w := b.got.NewRevWalk()
+	w.Sorting(SortTopoDate, false)
+	if err := w.Push(start); err != nil {
+		return err
+	}
+	err := w.Walk(func(c *Comm) error {
+		b.commits[c.sha] = c
+		for _, o := range b.origins[c.sha] {
+			if err := b.pass(o); err != nil {
+				return err
+			}
+		}
+		if b.pending == 0 {
+			return io.EOF
+		}
+		return nil
+	})
+	if err == io.EOF {
+		return nil
+	}
+	return err
+}
+
+// pass hands the lines an origin has in common with its parents on to them, and keeps the rest.
+// a parent with the very same file takes them all
+func (b *blamer) pass(o *blameOrigin) error {
+	if len(o.suspects) == 0 {
+		return nil
+	}
+	//what's left after the parents took theirs is the commit's own
+	defer func() {
+		b.pending -= len(o.suspects)
+		o.suspects = nil
+	}()
+	var parents []*blameOrigin
+	for _, p := range o.commit.parents {
+		pc, err := b.commit(p)
+		if err != nil {
+			return err
+		}
+		po, err := b.parentOrigin(o, pc)
+		if err != nil {
+			return err
+		}
+		if po == nil {
+			continue
+		}
+		if po.blob == o.blob {
+			b.move(o, po, nil)
+			return nil
+		}
+		same := false
+		for _, seen := range parents {
+			same = same || seen.blob == po.blob
+		}
+		if !same {
+			parents = append(parents, po)
+		}
+	}
+	for _, po := range parents {
+		if o.previous == nil {
+			o.previous = po
+		}
+		b.move(o, po, commonLines(po.data, o.data))
+		if len(o.suspects) == 0 {
+			break
+		}
+	}
+	return nil
+}
+
+// move hands the suspects of o that the parent has too over to it. to maps the line numbers of o to the parent's,
+// nil maps every line to itself
+func (b *blamer) move(o, parent *blameOrigin, to map[int]int) {
+	kept := o.suspects[:0]
+	for _, i := range o.suspects {
+		n, ok := b.orig[i], true
+		if to != nil {
+			n, ok = to[b.orig[i]]
+		}
+		if !ok {
+			kept = append(kept, i)
+			continue
+		}
+		b.lines[i].origin, b.orig[i] = parent, n
+		parent.suspects = append(parent.suspects, i)
+	}
+	o.suspects = kept
+}
+
+// commonLines maps each line of b that's unchanged from a to its number in a. like git, the end both have in
+// common is cut off before the diff, line for line
+func commonLines(a, b []byte) map[int]int {
+	la, lb := diff.SplitLines(string(a)), diff.SplitLines(string(b))
+	ta, tb := diff.TrimTail(la, lb)
+	common := make(map[int]int)
+	for _, op := range diff.Lines(ta, tb, diff.Options{}) {
+		if op.Kind == diff.Equal {
+			common[op.B] = op.A
+		}
+	}
+	for k := 0; len(tb)+k < len(lb); k++ {
+		common[len(tb)+k] = len(ta) + k
+	}
+	return common
+}
+
+// parentOrigin is the file in the parent: at the same path, or where it was renamed from. nil is a parent
+// that didn't have it
+func (b *blamer) parentOrigin(o *blameOrigin, parent *Comm) (*blameOrigin, error) {
+	if it, err := b.got.treeLookup(parent.treeSha, o.path); err == nil && modType(it.mode) != treefile {
+		return b.origin(parent, o.path, it.sha)
+	}
+	//the commit's files against the parent's, with the file the only one that can be renamed to, like git does
+	old := b.got.treeSides(parent.treeSha)
+	var new map[string]*diffSide
+	if o.commit.sha == (Sha1{}) {
+		idx, err := readIndexFile()
+		if err != nil {
+			return nil, err
+		}
+		new = indexSides(idx)
+	} else {
+		new = b.got.treeSides(o.commit.treeSha)
+	}
+	var pairs []filePair
+	for _, p := range pairSides(old, new, nil) {
+		if p.new == nil || p.old == nil && p.new.path == o.path {
+			pairs = append(pairs, p)
+		}
+	}
+	pairs, err := b.got.detectRenames(pairs, nil, RenameOpts{Renames: true})
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range pairs {
+		if p.renamed() && p.new.path == o.path {
+			return b.origin(parent, p.old.path, p.old.sha)
+		}
+	}
+	return nil, nil
+}
+
+// lineRange is a range of lines to show, from 1, both ends included
+type lineRange struct {
+	start, end int
+}
+
+// parseLineRange reads a range of -L: `start,end`, `start,+count`, `start,-count`, `start` to the end of the file,
+// or `,end` from its start. the ends can come in either order
+func parseLineRange(spec, path string, lines int) (lineRange, error) {
+	bad := fmt.Errorf("-L invalid line range: %s", spec)
+	num := func(s string) (int, error) {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return 0, bad
+		}
+		if n <= 0 {
+			return 0, fmt.Errorf("-L invalid line number: %d", n)
+		}
+		return n, nil
+	}
+	from, to, _ := strings.Cut(spec, ",")
+	r := lineRange{start: 1}
+	var err error
+	if from != "" {
+		if r.start, err = num(from); err != nil {
+			return r, err
+		}
+	}
+	switch {
+	case strings.HasPrefix(to, "+"):
+		n, err := num(to[1:])
+		if err != nil {
+			return r, err
+		}
+		r.end = r.start + n - 1
+	case strings.HasPrefix(to, "-"):
+		n, err := num(to[1:])
+		if err != nil {
+			return r, err
+		}
+		r.start, r.end = r.start-n+1, r.start
+		if r.start < 1 {
+			r.start = 1
+		}
+	case to != "":
+		if r.end, err = num(to); err != nil {
+			return r, err
+		}
+	}
+	//no end is the end of the file
+	if r.end != 0 && r.end < r.start {
+		r.start, r.end = r.end, r.start
+	}
+	if r.start > lines {
+		plural := "lines"
+		if lines == 1 {
+			plural = "line"
+		}
+		return r, fmt.Errorf("file %s has only %d %s", path, lines, plural)
+	}
+	if r.end == 0 || r.end > lines {
+		r.end = lines
+	}
+	return r, nil
+}
+
+// Format prints the blame of the lines in the ranges, all of them with none, the way git blame does: each line
+// after its commit, author, date and number, or for scripts with --porcelain, a header per run of lines from one
+// commit, and the commit's details the first time it shows up
+func (bl *Blame) Format(ranges []string, porcelain bool) (io.Reader, error) {
+	var lines []BlameLine
+	if len(ranges) == 0 {
+		lines = bl.Lines
+	}
+	if len(ranges) != 0 {
+		show := make([]bool, len(bl.Lines))
+		for _, spec := range ranges {
+			r, err := parseLineRange(spec, bl.Path, len(bl.Lines))
+			if err != nil {
+				return nil, err
+			}
+			for i := r.start; i <= r.end; i++ {
+				show[i-1] = true
+			}
+		}
+		for i, l := range bl.Lines {
+			if show[i] {
+				lines = append(lines, l)
+			}
+		}
+	}
+	var b bytes.Buffer
+	if porcelain {
+		bl.writePorcelain(&b, lines)
+	} else {
+		bl.writeDefault(&b, lines)
+	}
+	return &b, nil
+}
+
+// withNewline is the line as it's printed, the last line of a file without a newline gets one
+func withNewline(text string) string {
+	if strings.HasSuffix(text, "\n") {
+		return text
+	}
+	return text + "\n"
+}
+
+// writeDefault prints a line per line: the short sha, ^ in front for a boundary, the file name if any line came
+// from another one, and the author, date and line number, all in columns
+func (bl *Blame) writeDefault(w io.Writer, lines []BlameLine) {
+	abbrev, authorWidth, nameWidth, numWidth := 0, 0, 0, 0
+	showName := false
+	abbrevs := make(map[Sha1]int)
+	for _, l := range lines {
+		if _, ok := abbrevs[l.Commit]; !ok {
+			abbrevs[l.Commit] = len(bl.got.Abbrev(l.Commit, 7))
+			if abbrevs[l.Commit] > abbrev {
+				abbrev = abbrevs[l.Commit]
+			}
+		}
+		if n := utf8.RuneCountInString(l.origin.commit.author.name); n > authorWidth {
+			authorWidth = n
+		}
+		if len(l.Path) > nameWidth {
+			nameWidth = len(l.Path)
+		}
+		showName = showName || l.Path != bl.Path
+		if n := len(strconv.Itoa(l.Line)); n > numWidth {
+			numWidth = n
+		}
+	}
+	//one more digit, so a boundary's ^ doesn't cost one
+	abbrev++
+	for _, l := range lines {
+		hex := shaToString(l.Commit)[:abbrev]
+		if l.Boundary {
+			hex = "^" + hex[:abbrev-1]
+		}
+		fmt.Fprint(w, hex)
+		if showName {
+			fmt.Fprintf(w, " %-*s", nameWidth, l.Path)
+		}
+		author := l.origin.commit.author
+		date, _ := formatDate(author.time, "iso", time.Now())
+		pad := authorWidth - utf8.RuneCountInString(author.name)
+		fmt.Fprintf(w, " (%s%*s %10s %*d) %s", author.name, pad, "", date, numWidth, l.Line, withNewline(l.Text))
+	}
+}
+
+// writePorcelain prints each run of lines of one commit, that follow each other in both files, under a header:
+// the sha, the line's number in the commit and in the file, and how many lines the run has. the commit's details
+// come after the first header of a commit, then the file it had the lines in, and each line after a tab
+func (bl *Blame) writePorcelain(w io.Writer, lines []BlameLine) {
+	//a commit blamed under more than one name always says which one a run came from
+	paths := make(map[Sha1]map[string]bool)
+	for _, l := range lines {
+		if paths[l.Commit] == nil {
+			paths[l.Commit] = make(map[string]bool)
+		}
+		paths[l.Commit][l.Path] = true
+	}
+	shown := make(map[Sha1]bool)
+	for i := 0; i < len(lines); {
+		l := lines[i]
+		j := i + 1
+		for j < len(lines) && lines[j].origin == l.origin && lines[j].Line == l.Line+j-i && lines[j].OrigLine == l.OrigLine+j-i {
+			j++
+		}
+		sha := shaToString(l.Commit)
+		fmt.Fprintf(w, "%s %d %d %d\n", sha, l.OrigLine, l.Line, j-i)
+		if !shown[l.Commit] {
+			shown[l.Commit] = true
+			c := l.origin.commit
+			for _, who := range []struct {
+				role string
+				sign Sign
+			}{{"author", c.author}, {"committer", c.committer}} {
+				fmt.Fprintf(w, "%s %s\n%s-mail <%s>\n", who.role, who.sign.name, who.role, who.sign.email)
+				fmt.Fprintf(w, "%s-time %d\n%s-tz %s\n", who.role, who.sign.time.Unix(), who.role, who.sign.time.Format("-0700"))
+			}
+			summary, _, _ := strings.Cut(strings.TrimLeft(c.msg, "\n"), "\n")
+			fmt.Fprintf(w, "summary %s\n", summary)
+			if l.Boundary {
+				fmt.Fprintln(w, "boundary")
+			}
+			bl.writeFilename(w, l.origin)
+		} else if len(paths[l.Commit]) > 1 {
+			bl.writeFilename(w, l.origin)
+		}
+		for k := i; k < j; k++ {
+			if k > i {
+				fmt.Fprintf(w, "%s %d %d\n", sha, lines[k].OrigLine, lines[k].Line)
+			}
+			fmt.Fprintf(w, "\t%s", withNewline(lines[k].Text))
+		}
+		i = j
+	}
+}
+
+// writeFilename prints the file a run of lines came from, and the parent's version it was diffed against
+func (bl *Blame) writeFilename(w io.Writer, o *blameOrigin) {
+	if o.previous != nil {
+		fmt.Fprintf(w, "previous %s %s\n", shaToString(o.previous.commit.sha), o.previous.path)
+	}
+	fmt.Fprintf(w, "filename %s\n", o.path)
+}
@@ -0,0 +1,88 @@
+package pkg
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestBlame(t *testing.T) {
+	got := testRepo(t)
+	one := testCommitFiles(t, got, 1700000000, map[string]string{"f": "1\n2\n3\n4\n5\n"})
+	two := testCommitFiles(t, got, 1700000060, map[string]string{"f": "1\nTWO\n3\n4\n5\n6\n"}, one)
+	//f is renamed to g, and a line of it changed on the way
+	three := testCommitFiles(t, got, 1700000120, map[string]string{"g": "1\nTWO\n3\nFOUR\n5\n6\n"}, two)
+
+	bl, err := got.Blame("g", shaToString(three))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		commit   Sha1
+		path     string
+		orig     int
+		boundary bool
+	}{{one, "f", 1, true}, {two, "f", 2, false}, {one, "f", 3, true}, {three, "g", 4, false}, {one, "f", 5, true}, {two, "f", 6, false}}
+	if len(bl.Lines) != len(want) {
+		t.Fatalf("blame has %d lines, want %d", len(bl.Lines), len(want))
+	}
+	for i, w := range want {
+		l := bl.Lines[i]
+		if l.Commit != w.commit || l.Path != w.path || l.OrigLine != w.orig || l.Boundary != w.boundary || l.Line != i+1 {
+			t.Errorf("line %d is %s %s:%d boundary %v, want %s %s:%d boundary %v", i+1,
+				shaToString(l.Commit), l.Path, l.OrigLine, l.Boundary, shaToString(w.commit), w.path, w.orig, w.boundary)
+		}
+	}
+
+	//what git blame prints for the same commits
+	lines := []string{
+		"^0e3f8c2 f (T 2023-11-14 22:13:20 +0000 1) 1\n",
+		"56b84775 f (T 2023-11-14 22:14:20 +0000 2) TWO\n",
+		"^0e3f8c2 f (T 2023-11-14 22:13:20 +0000 3) 3\n",
+		"d1b86690 g (T 2023-11-14 22:15:20 +0000 4) FOUR\n",
+		"^0e3f8c2 f (T 2023-11-14 22:13:20 +0000 5) 5\n",
+		"56b84775 f (T 2023-11-14 22:14:20 +0000 6) 6\n",
+	}
+	tests := []struct {
+		ranges []string
+		want   string
+	}{
+		{nil, strings.Join(lines, "")},
+		{[]string{"2,+2"}, lines[1] + lines[2]},
+		{[]string{"4,-2", "6"}, lines[2] + lines[3] + lines[5]},
+		{[]string{",1"}, lines[0]},
+		//the ends can come either way round, and ranges that overlap show each line once
+		{[]string{"3,2", "2,3"}, lines[1] + lines[2]},
+	}
+	for _, tt := range tests {
+		rdr, err := bl.Format(tt.ranges, false)
+		if err != nil {
+			t.Errorf("-L %v: %v", tt.ranges, err)
+			continue
+		}
+		if b, _ := io.ReadAll(rdr); string(b) != tt.want {
+			t.Errorf("-L %v: got\n%s\nwant\n%s", tt.ranges, b, tt.want)
+		}
+	}
+	for _, spec := range []string{"9", "0,2", "x"} {
+		if _, err := bl.Format([]string{spec}, false); err == nil {
+			t.Errorf("-L %s was taken", spec)
+		}
+	}
+	if _, err := bl.Format([]string{"9"}, false); err == nil || err.Error() != "file g has only 6 lines" {
+		t.Errorf("-L past the end gave %v", err)
+	}
+
+	rdr, err := bl.Format([]string{"3,4"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	porcelain := "0e3f8c2603b4e2b8081e705ee4665e122bbe4803 3 3 1\nauthor T\nauthor-mail <t@x>\nauthor-time 1700000000\nauthor-tz +0000\n" +
+		"committer T\ncommitter-mail <t@x>\ncommitter-time 1700000000\ncommitter-tz +0000\nsummary commit at 1700000000\nboundary\nfilename f\n\t3\n" +
+		"d1b86690bccd842baa1a3b1fcb248d601e38aeba 4 4 1\nauthor T\nauthor-mail <t@x>\nauthor-time 1700000120\nauthor-tz +0000\n" +
+		"committer T\ncommitter-mail <t@x>\ncommitter-time 1700000120\ncommitter-tz +0000\nsummary commit at 1700000120\n" +
+		"previous 56b84775fa85503bb78e9d2fa962c813d7b004a6 f\nfilename g\n\tFOUR\n"
+	if b, _ := io.ReadAll(rdr); string(b) != porcelain {
+		t.Errorf("--porcelain printed\n%s\nwant\n%s", b, porcelain)
+	}
+}
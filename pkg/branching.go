@@ -1 +1,247 @@
 package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+//  |||BRANCHING|||| //
+//a branch is nothing but a ref under refs/heads that holds the sha of a commit.
+//the config keeps what the branch tracks, under branch.<name>.remote and branch.<name>.merge
+
+const branchPrefix = "refs/heads/"
+
+// Branches lists the local branches, with a star in front of the current one
+func (got *Got) Branches() (io.Reader, error) {
+	var b bytes.Buffer
+	names, err := got.listRefs("refs/heads")
+	if err != nil {
+		return nil, err
+	}
+	current, onBranch := got.headBranch()
+	if !onBranch {
+		if sha, err := got.readRef("HEAD"); err == nil {
+			fmt.Fprintf(&b, "* (HEAD detached at %s)\n", shaToString(sha)[:7])
+		}
+	}
+	for _, name := range names {
+		mark := " "
+		if onBranch && name == current {
+			mark = "*"
+		}
+		fmt.Fprintf(&b, "%s %s\n", mark, strings.TrimPrefix(name, branchPrefix))
+	}
+	return &b, nil
+}
+
+// NewBranch creates a branch pointing at start, which may be any commit-ish. an empty start means HEAD.
+// an existing branch is only overwritten when force is set, and never when it is the current branch
+func (got *Got) NewBranch(ctx context.Context, name, start string, force bool) error {
+	if err := checkBranchName(name); err != nil {
+		return err
+	}
+	if start == "" {
+		start = "HEAD"
+	}
+	sha, err := got.resolveCommitish(start)
+	if err != nil {
+		return fmt.Errorf("Could not create branch %s: %w", name, err)
+	}
+	ref := branchPrefix + name
+	old, err := got.readRef(ref)
+	if err != nil {
+		tx := got.NewRefTransaction("branch: Created from " + start)
+		tx.Create(ref, sha)
+		return tx.Commit()
+	}
+	if !force {
+		return fmt.Errorf("a branch named '%s' already exists", name)
+	}
+	if current, ok := got.headBranch(); ok && current == ref {
+		return fmt.Errorf("cannot force update the current branch")
+	}
+	tx := got.NewRefTransaction("branch: Reset to " + start)
+	tx.Update(ref, sha, old)
+	return tx.Commit()
+}
+
+// DeleteBranch deletes the branch. unless force is set, the branch must be merged into its upstream,
+// or into HEAD when it has none. the current branch can't be deleted
+func (got *Got) DeleteBranch(ctx context.Context, name string, force bool) error {
+	ref := branchPrefix + name
+	sha, err := got.readRef(ref)
+	if err != nil {
+		if errors.Is(err, RefNotFoundErr) {
+			return fmt.Errorf("branch '%s' not found", name)
+		}
+		return err
+	}
+	if current, ok := got.headBranch(); ok && current == ref {
+		return fmt.Errorf("cannot delete branch '%s' checked out at '%s'", name, got.baseDir)
+	}
+	if !force {
+		merged, err := got.branchMerged(name, sha)
+		if err != nil {
+			return err
+		}
+		if !merged {
+			return fmt.Errorf("the branch '%s' is not fully merged.\nIf you are sure you want to delete it, run 'got branch -D %s'", name, name)
+		}
+	}
+	tx := got.NewRefTransaction("")
+	tx.Delete(ref, sha)
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	conf, err := got.loadConfig(local)
+	if err != nil {
+		return err
+	}
+	if conf.removeSect(sectKey("branch", name)) {
+		return got.saveConfig(conf, local)
+	}
+	return nil
+}
+
+// branchMerged checks the tip against the upstream of the branch, or HEAD if there is no upstream
+func (got *Got) branchMerged(name string, tip Sha1) (bool, error) {
+	against, err := got.readRef("HEAD")
+	if up, ok := got.upstreamRef(name); ok {
+		if upSha, upErr := got.readRef(up); upErr == nil {
+			against, err = upSha, nil
+		}
+	}
+	if err != nil {
+		//nothing to be merged into, e.g. an unborn HEAD
+		return false, nil
+	}
+	return got.isAncestor(tip, against)
+}
+
+// RenameBranch renames old to new, taking its config along. HEAD follows when old is the current branch
+func (got *Got) RenameBranch(ctx context.Context, old, new string, force bool) error {
+	if old == "" {
+		current, ok := got.headBranch()
+		if !ok {
+			return fmt.Errorf("no branch to rename: HEAD is detached")
+		}
+		old = strings.TrimPrefix(current, branchPrefix)
+	}
+	if err := checkBranchName(new); err != nil {
+		return err
+	}
+	oldRef, newRef := branchPrefix+old, branchPrefix+new
+	sha, err := got.readRef(oldRef)
+	if err != nil {
+		return fmt.Errorf("no branch named '%s'", old)
+	}
+	if oldRef == newRef {
+		return nil
+	}
+	//the new name and the old one change together, or not at all
+	msg := fmt.Sprintf("Branch: renamed %s to %s", oldRef, newRef)
+	tx := got.NewRefTransaction(msg)
+	existing, err := got.readRef(newRef)
+	if err == nil {
+		if !force {
+			return fmt.Errorf("a branch named '%s' already exists", new)
+		}
+		tx.Update(newRef, sha, existing)
+	} else {
+		tx.Create(newRef, sha)
+	}
+	tx.Delete(oldRef, sha)
+	//the history of the branch goes with it. the log of a branch we overwrite is lost, like in git
+	s := got.refs()
+	if err == nil {
+		if err := s.deleteLog(newRef); err != nil {
+			return err
+		}
+	}
+	if err := s.renameLog(oldRef, newRef); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		s.renameLog(newRef, oldRef)
+		return err
+	}
+	if current, ok := got.headBranch(); ok && current == oldRef {
+		if err := got.refs().writeSymbolic("HEAD", newRef); err != nil {
+			return err
+		}
+		if err := got.logRef("HEAD", sha, sha, msg); err != nil {
+			return err
+		}
+	}
+	conf, err := got.loadConfig(local)
+	if err != nil {
+		return err
+	}
+	if conf.renameSect("branch", old, new) {
+		return got.saveConfig(conf, local)
+	}
+	return nil
+}
+
+// SetUpstream makes branch track upstream. a remote-tracking branch like origin/main wins over a local branch of the same name.
+// an empty branch means the current one
+func (got *Got) SetUpstream(ctx context.Context, branch, upstream string) error {
+	if branch == "" {
+		current, ok := got.headBranch()
+		if !ok {
+			return fmt.Errorf("could not set upstream of HEAD when it does not point to any branch")
+		}
+		branch = strings.TrimPrefix(current, branchPrefix)
+	}
+	if _, err := got.readRef(branchPrefix + branch); err != nil {
+		return fmt.Errorf("branch '%s' does not exist", branch)
+	}
+	remote, merge := "", ""
+	if _, err := got.readRef("refs/remotes/" + upstream); err == nil {
+		splits := strings.SplitN(upstream, "/", 2)
+		if len(splits) != 2 {
+			return fmt.Errorf("the requested upstream branch '%s' does not exist", upstream)
+		}
+		remote, merge = splits[0], branchPrefix+splits[1]
+	} else if _, err := got.readRef(branchPrefix + upstream); err == nil {
+		//a local upstream is tracked through the "." remote
+		remote, merge = ".", branchPrefix+upstream
+	} else {
+		return fmt.Errorf("the requested upstream branch '%s' does not exist", upstream)
+	}
+	conf, err := got.loadConfig(local)
+	if err != nil {
+		return err
+	}
+	if err := conf.set([]string{"branch", branch, "remote"}, remote); err != nil {
+		return err
+	}
+	if err := conf.set([]string{"branch", branch, "merge"}, merge); err != nil {
+		return err
+	}
+	return got.saveConfig(conf, local)
+}
+
+// upstreamRef reads the config of a branch and returns the ref that holds its upstream's tip
+func (got *Got) upstreamRef(branch string) (string, bool) {
+	conf, err := got.loadConfig(local)
+	if err != nil {
+		return "", false
+	}
+	remote, ok := conf.get([]string{"branch", branch, "remote"})
+	if !ok {
+		return "", false
+	}
+	merge, ok := conf.get([]string{"branch", branch, "merge"})
+	if !ok {
+		return "", false
+	}
+	if remote == "." {
+		return merge, true
+	}
+	return fmt.Sprintf("refs/remotes/%s/%s", remote, strings.TrimPrefix(merge, branchPrefix)), true
+}
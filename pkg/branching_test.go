@@ -0,0 +1,115 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestDeleteBranch(t *testing.T) {
+	got := testRepo(t)
+	ctx := context.Background()
+	one := testCommit(t, got, 1700000000)
+	two := testCommit(t, got, 1700000060, one)
+	side := testCommit(t, got, 1700000120, one)
+	for name, sha := range map[string]Sha1{"master": two, "merged": one, "side": side, "tracking": side, "up": side} {
+		if err := got.writeRef(branchPrefix+name, sha); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := got.DeleteBranch(ctx, "merged", false); err != nil {
+		t.Errorf("deleting a branch merged into HEAD: %v", err)
+	}
+	err := got.DeleteBranch(ctx, "side", false)
+	if err == nil || !strings.Contains(err.Error(), "not fully merged") {
+		t.Errorf("deleting an unmerged branch gave %v", err)
+	}
+	if !got.BranchExists("side") {
+		t.Fatal("the unmerged branch was deleted")
+	}
+	if err := got.DeleteBranch(ctx, "side", true); err != nil || got.BranchExists("side") {
+		t.Errorf("-D did not delete the unmerged branch: %v", err)
+	}
+
+	//a branch with an upstream only has to be merged there, not into HEAD
+	if err := got.SetUpstream(ctx, "tracking", "up"); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.DeleteBranch(ctx, "tracking", false); err != nil {
+		t.Errorf("deleting a branch merged into its upstream: %v", err)
+	}
+	if _, ok := got.upstreamRef("tracking"); ok {
+		t.Error("the config of the deleted branch is still there")
+	}
+
+	if err := got.DeleteBranch(ctx, "master", true); err == nil {
+		t.Error("the current branch was deleted")
+	}
+	if err := got.DeleteBranch(ctx, "missing", true); err == nil {
+		t.Error("deleting a missing branch succeeded")
+	}
+}
+
+func TestNewAndRenameBranch(t *testing.T) {
+	got := testRepo(t)
+	ctx := context.Background()
+	one := testCommit(t, got, 1700000000)
+	two := testCommit(t, got, 1700000060, one)
+	tx := got.NewRefTransaction("commit (initial): one")
+	tx.Create("refs/heads/master", two)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := got.NewBranch(ctx, "topic", "HEAD~1", false); err != nil {
+		t.Fatal(err)
+	}
+	if sha, err := got.readRef("refs/heads/topic"); err != nil || sha != one {
+		t.Errorf("topic = %s, %v", shaToString(sha), err)
+	}
+	if err := got.NewBranch(ctx, "topic", "", false); err == nil {
+		t.Error("an existing branch was overwritten without force")
+	}
+	if err := got.NewBranch(ctx, "topic", "", true); err != nil {
+		t.Error(err)
+	}
+	if err := got.NewBranch(ctx, "master", "topic~1", true); err == nil {
+		t.Error("the current branch was reset by force")
+	}
+	if err := got.NewBranch(ctx, "bad..name", "", false); err == nil {
+		t.Error("a branch with a bad name was created")
+	}
+
+	//renaming the current branch takes HEAD, its reflog and its config along
+	if err := got.SetUpstream(ctx, "master", "topic"); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.RenameBranch(ctx, "", "main", false); err != nil {
+		t.Fatal(err)
+	}
+	if branch, ok := got.headBranch(); !ok || branch != "refs/heads/main" {
+		t.Errorf("HEAD is on %q after the rename", branch)
+	}
+	if got.BranchExists("master") {
+		t.Error("the old name is still there")
+	}
+	if sha, err := got.ResolveRevision("main@{1}"); err != nil || sha != two {
+		t.Errorf("main@{1} = %s, %v", shaToString(sha), err)
+	}
+	if up, ok := got.upstreamRef("main"); !ok || up != "refs/heads/topic" {
+		t.Errorf("upstream of main = %q, %v", up, ok)
+	}
+	if err := got.RenameBranch(ctx, "main", "topic", false); err == nil {
+		t.Error("a rename overwrote an existing branch without force")
+	}
+
+	rdr, err := got.Branches()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(rdr); string(b) != "* main\n  topic\n" {
+		t.Errorf("branch printed %q", b)
+	}
+}
@@ -0,0 +1,283 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//checking out is a three-way comparison for every path: what HEAD has, what the target has, and what the index has.
+//a path that is the same in HEAD and the target is left alone, local changes and all.
+//a path that differs must have no local changes, in the index or in the working tree, before we overwrite it.
+//read: https://git-scm.com/docs/git-read-tree#_two_tree_merge
+
+// BranchExists tells whether name is a local branch
+func (got *Got) BranchExists(name string) bool {
+	_, err := got.readRef(branchPrefix + name)
+	return err == nil
+}
+
+// Checkout switches the working tree, the index and HEAD to target.
+// a branch name makes HEAD point to that branch. any other revision that names a commit detaches HEAD at it,
+// and detach forces that for branches too. force throws away local changes that would be in the way
+func (got *Got) Checkout(ctx context.Context, target string, force, detach bool) error {
+	//`-` and @{-n} name the branch we were on before, which we go back to as a branch
+	target = got.ExpandPrevious(target)
+	//a branch wins over a tag of the same name, which revision parsing would pick, so the tree matches the branch HEAD gets
+	branch := !detach && got.BranchExists(target)
+	var sha Sha1
+	var err error
+	if branch {
+		sha, err = got.readRef(branchPrefix + target)
+	} else {
+		sha, err = got.resolveCommitish(target)
+	}
+	if err != nil {
+		return err
+	}
+	from, old := got.headDesc()
+	if err := got.switchTo(sha, force); err != nil {
+		return err
+	}
+	if branch {
+		if err := got.refs().writeSymbolic("HEAD", branchPrefix+target); err != nil {
+			return err
+		}
+	} else if err := got.writeRef("HEAD", sha); err != nil {
+		return err
+	}
+	return got.logRef("HEAD", old, sha, fmt.Sprintf("checkout: moving from %s to %s", from, target))
+}
+
+// HeadSummary is HEAD's commit the way checkout reports a detached HEAD: its short sha and its subject
+func (got *Got) HeadSummary() (string, error) {
+	sha, err := got.readRef("HEAD")
+	if err != nil {
+		return "", err
+	}
+	c, err := got.readCommit(shaToString(sha))
+	if err != nil {
+		return "", err
+	}
+	subject, _ := splitMessage(c.msg)
+	return got.Abbrev(sha, 7) + " " + subject, nil
+}
+
+// headDesc names where HEAD is before a checkout, for the reflog: the branch, or the full sha when detached
+func (got *Got) headDesc() (string, Sha1) {
+	sha, _ := got.readRef("HEAD")
+	if branch, ok := got.headBranch(); ok {
+		return strings.TrimPrefix(branch, branchPrefix), sha
+	}
+	return shaToString(sha), sha
+}
+
+// CheckoutNewBranch creates branch at start (HEAD if empty) and switches to it.
+// reset lets an existing branch be moved to start, like `checkout -B`. the branch is only created once the switch succeeds
+func (got *Got) CheckoutNewBranch(ctx context.Context, branch, start string, reset, force bool) error {
+	if err := checkBranchName(branch); err != nil {
+		return err
+	}
+	if start == "" {
+		start = "HEAD"
+	}
+	sha, err := got.resolveCommitish(start)
+	if err != nil {
+		return err
+	}
+	ref := branchPrefix + branch
+	old, err := got.readRef(ref)
+	exists := err == nil
+	if exists && !reset {
+		return fmt.Errorf("a branch named '%s' already exists", branch)
+	}
+	from, headSha := got.headDesc()
+	if err := got.switchTo(sha, force); err != nil {
+		return err
+	}
+	msg := "branch: Created from " + start
+	if exists {
+		msg = "branch: Reset to " + start
+	}
+	tx := got.NewRefTransaction(msg)
+	if exists {
+		tx.Update(ref, sha, old)
+	} else {
+		tx.Create(ref, sha)
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	if err := got.refs().writeSymbolic("HEAD", ref); err != nil {
+		return err
+	}
+	return got.logRef("HEAD", headSha, sha, fmt.Sprintf("checkout: moving from %s to %s", from, branch))
+}
+
+// headTreeItems flattens the tree of the commit HEAD points to. an unborn HEAD has no items
+func (got *Got) headTreeItems() (map[string]item, error) {
+	items := make(map[string]item)
+	sha, err := got.readRef("HEAD")
+	if err != nil {
+		if errors.Is(err, RefNotFoundErr) {
+			return items, nil
+		}
+		return nil, err
+	}
+	comm, err := got.readCommit(shaToString(sha))
+	if err != nil {
+		return nil, err
+	}
+	for _, it := range got.flattenTree(comm.treeSha, "") {
+		items[it.name] = it
+	}
+	return items, nil
+}
+
+// switchTo moves the index and the working tree from HEAD's commit to the target commit, HEAD itself is left for the caller
+func (got *Got) switchTo(target Sha1, force bool) error {
+	comm, err := got.readCommit(shaToString(target))
+	if err != nil {
+		return err
+	}
+	want := make(map[string]item)
+	for _, it := range got.flattenTree(comm.treeSha, "") {
+		want[it.name] = it
+	}
+	have, err := got.headTreeItems()
+	if err != nil {
+		return err
+	}
+	idx, err := readIndexFile()
+	if err != nil {
+		return err
+	}
+	sp, err := got.readSparse()
+	if err != nil {
+		return err
+	}
+
+	paths := make(map[string]bool)
+	for p := range want {
+		paths[p] = true
+	}
+	for p := range have {
+		paths[p] = true
+	}
+	for p := range idx.cache {
+		paths[p] = true
+	}
+	sorted := make([]string, 0, len(paths))
+	for p := range paths {
+		sorted = append(sorted, p)
+	}
+	sort.Strings(sorted)
+
+	same := func(e *IdxEntry, it item, ok bool) bool {
+		if e == nil {
+			return !ok
+		}
+		return ok && e.sha == it.sha && e.mode == it.mode
+	}
+
+	var toWrite []*IdxEntry
+	var toRemove []string
+	var dirty, untracked []string
+	entries := make(map[string]*IdxEntry)
+	for _, p := range sorted {
+		e := idx.cache[p]
+		h, inHead := have[p]
+		t, inTarget := want[p]
+		full := filepath.Join(got.baseDir, p)
+
+		headSame := (!inHead && !inTarget) || (inHead && inTarget && h.sha == t.sha && h.mode == t.mode)
+		if headSame && !force {
+			//nothing to do between the commits. whatever is staged or modified stays as it is
+			if e != nil {
+				entries[p] = e
+			}
+			continue
+		}
+
+		//is the file in the working tree what the index says it is?
+		wtClean := true
+		_, statErr := os.Lstat(full)
+		if e != nil && !e.ignoreWorktree() {
+			if statErr != nil {
+				wtClean = false
+			} else if changed, err := idx.changed(e, full); err != nil || changed {
+				wtClean = false
+			}
+		}
+
+		if !force {
+			switch {
+			case e == nil && statErr == nil && inTarget:
+				//a file git doesn't know about sits where the target wants to write one
+				untracked = append(untracked, p)
+				continue
+			case same(e, t, inTarget) && (wtClean || !inTarget):
+				//already staged the way the target has it
+			case !same(e, h, inHead) || !wtClean:
+				dirty = append(dirty, p)
+				continue
+			}
+		}
+
+		if !inTarget {
+			//a path the index doesn't track is untracked, and untracked files are none of our business
+			if e != nil {
+				toRemove = append(toRemove, p)
+			}
+			continue
+		}
+		if e != nil && same(e, t, true) && wtClean && (statErr == nil || e.skipWorktree()) {
+			entries[p] = e
+			continue
+		}
+		ne := &IdxEntry{mode: t.mode, sha: t.sha, path: []byte(p)}
+		if e != nil {
+			//the flags the user set on the path survive the switch
+			ne.flags, ne.extFlags = e.flags&(flagAssumeValid|flagExtended), e.extFlags
+		}
+		if sp != nil {
+			ne.setSkipWorktree(!sp.includes(p))
+		}
+		entries[p] = ne
+		if !ne.skipWorktree() {
+			toWrite = append(toWrite, ne)
+		}
+	}
+
+	if len(untracked) != 0 {
+		return fmt.Errorf("The following untracked working tree files would be overwritten by checkout:\n\t%s\nPlease move or remove them before you switch branches", strings.Join(untracked, "\n\t"))
+	}
+	if len(dirty) != 0 {
+		return fmt.Errorf("Your local changes to the following files would be overwritten by checkout:\n\t%s\nPlease commit your changes or stash them before you switch branches", strings.Join(dirty, "\n\t"))
+	}
+
+	//removals go first, a file may be turning into a directory or the other way round
+	for _, p := range toRemove {
+		if e := idx.cache[p]; e != nil && e.skipWorktree() {
+			continue
+		}
+		if err := got.removeWorktreeFile(p); err != nil {
+			return err
+		}
+	}
+	for _, e := range toWrite {
+		if err := got.checkoutEntry(e); err != nil {
+			return err
+		}
+	}
+
+	final := make([]*IdxEntry, 0, len(entries))
+	for _, e := range entries {
+		final = append(final, e)
+	}
+	return got.UpIndexEntries(final)
+}
@@ -0,0 +1,185 @@
+package pkg
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+)
+
+// testCheckoutRepo has master at a commit with a and b, and topic at one that changes a and adds c.
+// the working tree and the index are checked out at master
+func testCheckoutRepo(t *testing.T) (*Got, Sha1, Sha1) {
+	t.Helper()
+	got := testRepo(t)
+	one := testCommitFiles(t, got, 1700000000, map[string]string{"a": "a1\n", "b": "b1\n"})
+	two := testCommitFiles(t, got, 1700000060, map[string]string{"a": "a2\n", "b": "b1\n", "dir/c": "c2\n"}, one)
+	if err := got.writeRef("refs/heads/master", one); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.writeRef("refs/heads/topic", two); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Checkout(context.Background(), "master", true, false); err != nil {
+		t.Fatal(err)
+	}
+	return got, one, two
+}
+
+func wantFile(t *testing.T, path, want string) {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil || string(b) != want {
+		t.Errorf("%s holds %q, %v, want %q", path, b, err, want)
+	}
+}
+
+func wantHead(t *testing.T, got *Got, branch string, sha Sha1) {
+	t.Helper()
+	name, ok := got.headBranch()
+	if branch == "" && ok {
+		t.Errorf("HEAD is on %s, want it detached", name)
+	} else if branch != "" && name != branchPrefix+branch {
+		t.Errorf("HEAD is on %q, want %s", name, branch)
+	}
+	if head, err := got.readRef("HEAD"); err != nil || head != sha {
+		t.Errorf("HEAD = %s, %v, want %s", shaToString(head), err, shaToString(sha))
+	}
+}
+
+func TestCheckoutBranch(t *testing.T) {
+	got, one, two := testCheckoutRepo(t)
+	ctx := context.Background()
+	//a local change to a file both commits have the same is carried over
+	if err := os.WriteFile("b", []byte("b local\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Checkout(ctx, "topic", false, false); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "topic", two)
+	wantFile(t, "a", "a2\n")
+	wantFile(t, "b", "b local\n")
+	wantFile(t, "dir/c", "c2\n")
+
+	if err := got.Checkout(ctx, "master", false, false); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "master", one)
+	wantFile(t, "a", "a1\n")
+	if _, err := os.Stat("dir/c"); !os.IsNotExist(err) {
+		t.Errorf("dir/c is still there on master: %v", err)
+	}
+}
+
+func TestCheckoutDirtyFile(t *testing.T) {
+	got, one, two := testCheckoutRepo(t)
+	ctx := context.Background()
+	if err := os.WriteFile("a", []byte("a local change\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := got.Checkout(ctx, "topic", false, false)
+	if err == nil || !strings.Contains(err.Error(), "local changes") || !strings.Contains(err.Error(), "\ta\n") {
+		t.Fatalf("checkout over a modified file gave %v", err)
+	}
+	wantHead(t, got, "master", one)
+	wantFile(t, "a", "a local change\n")
+
+	//force throws the change away
+	if err := got.Checkout(ctx, "topic", true, false); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "topic", two)
+	wantFile(t, "a", "a2\n")
+}
+
+func TestCheckoutUntrackedFile(t *testing.T) {
+	got, one, two := testCheckoutRepo(t)
+	ctx := context.Background()
+	if err := os.Mkdir("dir", 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("dir/c", []byte("mine\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	err := got.Checkout(ctx, "topic", false, false)
+	if err == nil || !strings.Contains(err.Error(), "untracked working tree files") {
+		t.Fatalf("checkout over an untracked file gave %v", err)
+	}
+	wantHead(t, got, "master", one)
+	wantFile(t, "dir/c", "mine\n")
+
+	if err := got.Checkout(ctx, "topic", true, false); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "topic", two)
+	wantFile(t, "dir/c", "c2\n")
+}
+
+func TestCheckoutDetached(t *testing.T) {
+	got, one, two := testCheckoutRepo(t)
+	ctx := context.Background()
+	if err := got.Checkout(ctx, shaToString(two), false, false); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "", two)
+	wantFile(t, "a", "a2\n")
+	//detach leaves HEAD off the branch even when given its name
+	if err := got.Checkout(ctx, "master", false, true); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "", one)
+	if head, err := got.HeadSummary(); err != nil || head != got.Abbrev(one, 7)+" commit at 1700000000" {
+		t.Errorf("HeadSummary = %q, %v", head, err)
+	}
+	if err := got.Checkout(ctx, "topic", false, false); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "topic", two)
+}
+
+func TestCheckoutNewBranch(t *testing.T) {
+	got, one, two := testCheckoutRepo(t)
+	ctx := context.Background()
+	if err := got.CheckoutNewBranch(ctx, "feature", "topic", false, false); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "feature", two)
+	wantFile(t, "a", "a2\n")
+	if err := got.CheckoutNewBranch(ctx, "feature", "master", false, false); err == nil {
+		t.Error("-b took the name of an existing branch")
+	}
+	//-B moves it
+	if err := got.CheckoutNewBranch(ctx, "feature", "master", true, false); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "feature", one)
+
+	//a switch that fails creates no branch
+	if err := os.WriteFile("a", []byte("a local change\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.CheckoutNewBranch(ctx, "other", "topic", false, false); err == nil {
+		t.Fatal("-b switched over a modified file")
+	}
+	if got.BranchExists("other") {
+		t.Error("a failed -b created its branch")
+	}
+	wantHead(t, got, "feature", one)
+}
+
+func TestCheckoutBranchOverTag(t *testing.T) {
+	got, one, two := testCheckoutRepo(t)
+	//a tag and a branch share a name. the branch is what we check out, tree and all
+	if err := got.writeRef("refs/tags/x", one); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.writeRef("refs/heads/x", two); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Checkout(context.Background(), "x", false, false); err != nil {
+		t.Fatal(err)
+	}
+	wantHead(t, got, "x", two)
+	wantFile(t, "a", "a2\n")
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -13,45 +14,43 @@ import (
 
 const RFC2822 = "Mon Jan 2 15:04:05 2006 -0700"
 
-//!!!!COMMIT OBJECT AND ITS GOTOBJECT IMPLEMENTATION!!!!!
-type commitObj struct {
-	sha  string
-	data []byte
-}
-
-func (c *commitObj) Hash(wkdir string) ([]byte, error) {
-	return nil, nil
-}
-
 type Sign struct {
 	name, email string
 	time        time.Time
 }
 
-func parseSign(b [][]byte) (Sign, error) {
+// parseSign parses the part of an author, committer or tagger line after the keyword:
+// Name <email> timestamp timezone
+func parseSign(b []byte) (Sign, error) {
 	s := Sign{}
-	s.name = string(b[0])
-	s.email = string(bytes.TrimFunc(b[1], func(r rune) bool {
-		return r == '<' || r == '>'
-	}))
+	lt, gt := bytes.IndexByte(b, '<'), bytes.LastIndexByte(b, '>')
+	if lt < 0 || gt < lt {
+		return s, fmt.Errorf("signature line faulty: %s", b)
+	}
+	s.name = string(bytes.TrimSpace(b[:lt]))
+	s.email = string(b[lt+1 : gt])
 
+	rest := bytes.Fields(b[gt+1:])
+	if len(rest) != 2 || len(rest[1]) != 5 {
+		return s, fmt.Errorf("signature time faulty: %s", b)
+	}
 	//comeback for the time parsing
-	timestamp, err := strconv.ParseInt(string(b[2]), 10, 0)
+	timestamp, err := strconv.ParseInt(string(rest[0]), 10, 0)
 	if err != nil {
 		return s, err
 	}
 
-	zoneHr, err := strconv.ParseInt(string(b[3])[0:3], 10, 0)
+	zoneHr, err := strconv.ParseInt(string(rest[1])[0:3], 10, 0)
 	if err != nil {
 		return s, err
 	}
 
-	zoneMin, err := strconv.ParseInt(string(b[3])[3:], 10, 0)
+	zoneMin, err := strconv.ParseInt(string(rest[1])[3:], 10, 0)
 	if err != nil {
 		return s, err
 	}
 
-	if zoneHr < 0 {
+	if rest[1][0] == '-' {
 		zoneMin *= -1
 	}
 
@@ -60,10 +59,57 @@ func parseSign(b [][]byte) (Sign, error) {
 	return s, nil
 }
 
+// ident builds the signature of the AUTHOR or the COMMITTER of a new commit.
+// like git, GIT_<role>_NAME and GIT_<role>_EMAIL win over user.name and user.email,
+// and the repository's config wins over the global and the system one
+func (got *Got) ident(role string) (Sign, error) {
+	s := Sign{time: time.Now()}
+	for _, where := range []int{system, global, local} {
+		conf, err := got.loadConfig(where)
+		if err != nil {
+			continue
+		}
+		if name, ok := conf.get([]string{"user", "name"}); ok {
+			s.name = name
+		}
+		if email, ok := conf.get([]string{"user", "email"}); ok {
+			s.email = email
+		}
+	}
+	if name := os.Getenv("GIT_" + role + "_NAME"); name != "" {
+		s.name = name
+	}
+	if email := os.Getenv("GIT_" + role + "_EMAIL"); email != "" {
+		s.email = email
+	}
+	if date := os.Getenv("GIT_" + role + "_DATE"); date != "" {
+		t, err := parseIdentDate(date)
+		if err != nil {
+			return s, err
+		}
+		s.time = t
+	}
+	if s.name == "" || s.email == "" {
+		return s, fmt.Errorf("Author identity unknown\n\n*** Please tell me who you are.\n\nRun\n\n  got config --global user.email \"you@example.com\"\n  got config --global user.name \"Your Name\"\n")
+	}
+	return s, nil
+}
+
+// parseIdentDate reads GIT_AUTHOR_DATE and GIT_COMMITTER_DATE. git's own "<unix> <zone>" keeps its zone,
+// anything else is taken the way a user would write a date
+func parseIdentDate(date string) (time.Time, error) {
+	if f := strings.Fields(strings.TrimPrefix(date, "@")); len(f) == 2 {
+		if s, err := parseSign([]byte("<> " + f[0] + " " + f[1])); err == nil {
+			return s.time, nil
+		}
+	}
+	return parseApproxDate(date, time.Now())
+}
+
 func (s *Sign) Format() string {
 	var str strings.Builder
-	str.WriteString(fmt.Sprintf("%s <%s>", s.name, s.email))
-	str.WriteString(fmt.Sprintf("%d %s", s.time.Unix(), s.time.Format("-7000")))
+	str.WriteString(fmt.Sprintf("%s <%s> ", s.name, s.email))
+	str.WriteString(fmt.Sprintf("%d %s", s.time.Unix(), s.time.Format("-0700")))
 	return str.String()
 }
 
@@ -96,11 +142,6 @@ func parseCommit(rdr io.Reader) (*Comm, error) {
 	var msgOn bool
 	for scanner.Scan() {
 		line := scanner.Bytes()
-		splits := bytes.FieldsFunc(line, func(r rune) bool {
-			return unicode.IsSpace(r)
-		})
-
-		prefix := string(splits[0])
 
 		if len(line) == 0 && !msgOn {
 			msgOn = true
@@ -108,30 +149,35 @@ func parseCommit(rdr io.Reader) (*Comm, error) {
 		}
 
 		if !msgOn {
+			splits := bytes.FieldsFunc(line, func(r rune) bool {
+				return unicode.IsSpace(r)
+			})
+			//continuation lines of multi-line headers (gpgsig, mergetag) start with a space. we skip them
+			if len(splits) == 0 || line[0] == ' ' {
+				continue
+			}
+			prefix := string(splits[0])
+
 			switch prefix {
 			case lineTree:
 				{
-					if len(splits) != 2 {
+					if len(splits) != 2 || len(splits[1]) != 40 {
 						return nil, fmt.Errorf("Tree line in commit object faulty")
 					}
-					treeSha := splits[1]
-					comm.treeSha = bytesToSha(treeSha)
+					comm.treeSha = strToSha(string(splits[1]))
 				}
 
 			case linePar:
 				{
-					if len(splits) == 2 || len(splits[2]) != 20 {
+					if len(splits) != 2 || len(splits[1]) != 40 {
 						return nil, fmt.Errorf("Parent line in commit faulty")
 					}
-					p := splits[1]
-					parent := (*[20]byte)(p) //comeback to this cool hack. its a tip. it panics if the lengths of slice and array are different
-					comm.parents = append(comm.parents, *parent)
+					comm.parents = append(comm.parents, strToSha(string(splits[1])))
 				}
 
 			case lineAuth:
 				{
-					rem := splits[1:]
-					auth, err := parseSign(rem)
+					auth, err := parseSign(line[len(lineAuth)+1:])
 					if err != nil {
 						return nil, err
 					}
@@ -140,8 +186,7 @@ func parseCommit(rdr io.Reader) (*Comm, error) {
 
 			case lineComm:
 				{
-					rem := splits[1:]
-					committer, err := parseSign(rem)
+					committer, err := parseSign(line[len(lineComm)+1:])
 					if err != nil {
 						return nil, err
 					}
@@ -159,9 +204,54 @@ func parseCommit(rdr io.Reader) (*Comm, error) {
 	return comm, nil
 }
 
+// readCommit reads and parses the commit with the given, possibly abbreviated, sha
+func (got *Got) readCommit(sha string) (*Comm, error) {
+	full, err := got.resolveSha(sha)
+	if err != nil {
+		return nil, err
+	}
+	_, ty, data, err := got.ReadObject(shaToString(full))
+	if err != nil {
+		return nil, err
+	}
+	if ty != "commit" {
+		return nil, fmt.Errorf("%s is a %s, not a commit", sha, ty)
+	}
+	comm, err := parseCommit(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	comm.sha = full
+	return comm, nil
+}
+
+// isAncestor tells whether commit a can be reached from commit b by following parents. a commit is its own ancestor
+func (got *Got) isAncestor(a, b Sha1) (bool, error) {
+	seen := map[Sha1]bool{b: true}
+	queue := []Sha1{b}
+	for len(queue) > 0 {
+		curr := queue[0]
+		queue = queue[1:]
+		if curr == a {
+			return true, nil
+		}
+		comm, err := got.readCommit(shaToString(curr))
+		if err != nil {
+			return false, err
+		}
+		for _, p := range comm.parents {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	return false, nil
+}
+
 func (c *Comm) Hash(wkdir string) (Sha1, error) {
 	b, err := HashObj(c.Type(), c.data, wkdir)
-	if err != nil {
+	if err == nil {
 		c.sha = b
 		return b, nil
 	}
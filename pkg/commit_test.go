@@ -0,0 +1,68 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseSign(t *testing.T) {
+	s, err := parseSign([]byte("Got Tester <tester@example.com> 1700000000 -0130"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.name != "Got Tester" || s.email != "tester@example.com" {
+		t.Errorf("parseSign read %q <%q>", s.name, s.email)
+	}
+	if _, off := s.time.Zone(); s.time.Unix() != 1700000000 || off != -90*60 {
+		t.Errorf("parseSign read the time %v", s.time)
+	}
+	if f := s.Format(); f != "Got Tester <tester@example.com> 1700000000 -0130" {
+		t.Errorf("Format() = %q", f)
+	}
+	for _, bad := range []string{
+		"Got Tester tester@example.com 1700000000 +0000",
+		"Got Tester <tester@example.com> 1700000000",
+		"Got Tester <tester@example.com> soon +0000",
+	} {
+		if _, err := parseSign([]byte(bad)); err == nil {
+			t.Errorf("parseSign(%q) did not fail", bad)
+		}
+	}
+}
+
+func TestParseCommit(t *testing.T) {
+	tree := "4b825dc642cb6eb9a060e54bf8d69288fbee4904"
+	p1 := "1111111111111111111111111111111111111111"
+	p2 := "2222222222222222222222222222222222222222"
+	raw := "tree " + tree + "\n" +
+		"parent " + p1 + "\n" +
+		"parent " + p2 + "\n" +
+		"author A U Thor <author@example.com> 1700000000 +0100\n" +
+		"committer C O Mitter <committer@example.com> 1700000100 +0000\n" +
+		"gpgsig -----BEGIN PGP SIGNATURE-----\n" +
+		" \n" +
+		" iQEzBAABCAAdFiEE\n" +
+		" -----END PGP SIGNATURE-----\n" +
+		"\n" +
+		"subject\n\nbody\n"
+	c, err := parseCommit(strings.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if c.treeSha != strToSha(tree) {
+		t.Errorf("tree = %s", shaToString(c.treeSha))
+	}
+	if len(c.parents) != 2 || c.parents[0] != strToSha(p1) || c.parents[1] != strToSha(p2) {
+		t.Errorf("parents = %v", c.parents)
+	}
+	if c.author.name != "A U Thor" || c.committer.email != "committer@example.com" {
+		t.Errorf("author %q, committer %q", c.author.name, c.committer.email)
+	}
+	if c.msg != "subject\n\nbody\n" {
+		t.Errorf("msg = %q", c.msg)
+	}
+
+	if _, err := parseCommit(strings.NewReader("tree 4b825dc6\n\nmsg\n")); err == nil {
+		t.Errorf("parseCommit took a short tree sha")
+	}
+}
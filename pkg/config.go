@@ -3,12 +3,15 @@ package pkg
 import (
 	"bufio"
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"regexp"
 	"sort"
+	"strings"
 )
 
 type User struct {
@@ -38,11 +41,12 @@ const (
 )
 
 var (
-	rCmt   = regexp.MustCompile(`(?m)^\s*(?P<cmt>[#;]\w+)$`)
+	rCmt   = regexp.MustCompile(`(?m)^\s*(?P<cmt>[#;].*)$`)
 	rEmt   = regexp.MustCompile(`(?m)^\s*$`)
-	rNumb  = regexp.MustCompile(`(?m)^\s*(?P<num>-?\d+)$`)                                                         //might not need this
-	rSectn = regexp.MustCompile(`(?im)^\s*\[(?P<sect>\w+)(\s+"(?P<subsect>\w*)\s*")?\]\s*(?P<cmt>[#;]\s*\w\s*)?$`) //may have comments in front
-	rKv    = regexp.MustCompile(`(?im)^\s*(?P<key>\w+)\s+=\s+(?P<val>\w+)\s*(?P<cmt>[#;]\s*\w\s*)?$`)
+	rNumb  = regexp.MustCompile(`(?m)^\s*(?P<num>-?\d+)$`)                                                       //might not need this
+	rSectn = regexp.MustCompile(`(?im)^\s*\[(?P<sect>[\w.-]+)(\s+"(?P<subsect>[^"]*)")?\]\s*(?P<cmt>[#;].*)?$`) //may have comments in front
+	//a key with no `=` is a boolean set to true
+	rKv = regexp.MustCompile(`(?im)^\s*(?P<key>[a-z][\w-]*)\s*(?P<eq>=\s*(?P<val>"[^"]*"|[^#;]*?))?\s*(?P<cmt>[#;].*)?$`)
 )
 
 type Config struct {
@@ -62,14 +66,16 @@ type Section struct {
 
 func (s Section) write(w io.Writer) error {
 	var err error
-	// first write title
-	_, err = w.Write(s.title.cont)
-	if err != nil {
-		return err
+	// first write title. the lines before the first section have none
+	if len(s.title.cont) != 0 {
+		_, err = fmt.Fprintf(w, "%s\n", s.title.cont)
+		if err != nil {
+			return err
+		}
 	}
 	// write everything else including empty lines and comments that precede the next section
 	for _, l := range s.subs {
-		_, err = w.Write(l.cont)
+		_, err = fmt.Fprintf(w, "%s\n", l.cont)
 		if err != nil {
 			return err
 		}
@@ -104,41 +110,71 @@ func newSect(sectCount int) Section {
 	}
 }
 
-func parseConfig(basepath string) (*Config, error) {
-	// root, err := os.UserCacheDir()
-	data, err := fs.ReadFile(os.DirFS(basepath), ".config")
+// parseConfig reads the config file at path. a missing file is just an empty config
+func parseConfig(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
 	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return &Config{sections: make(map[string]Section)}, nil
+		}
 		return nil, fmt.Errorf("Parsing Config error: %w", err)
 	}
-	scanner := bufio.NewScanner(bytes.NewReader(data))
+	return readConfig(bytes.NewReader(data))
+}
+
+func readConfig(r io.Reader) (*Config, error) {
+	scanner := bufio.NewScanner(r)
 	scanner.Split(bufio.ScanLines)
 
 	sections := make(map[string]Section)
 	sectCount := 0                 //initialize the section count. we need it when we're rearranging to save config as file.
 	currSect := newSect(sectCount) //section line that were dealing with currently. starting with the first one
+	//lines before the first section title (usually comments) live in a title-less section
+	sections[""] = currSect
+	lineCount := 0 //initialize the line count. needed when we're saving config back
 	for scanner.Scan() {
-		lineCount := 0 //initialize the line count. needed when we're saving config back
-		line := scanner.Bytes()
+		line := append([]byte{}, scanner.Bytes()...) //the scanner reuses its buffer
 		if rCmt.Match(line) {
 			currSect.subs = append(currSect.subs, parseCmt(line, lineCount))
 		} else if rSectn.Match(line) {
 			//new section discovered. wrap up old one
+			sections[currSect.key()] = currSect
 			sectCount += 1
 			currSect = newSect(sectCount) //new currSect
 			currSect.title = parseSect(line, lineCount)
-			sections[string(currSect.title.kv.k)] = currSect
-		} else if rKv.Match(line) {
-			kv := parseKv(line, lineCount)
-			//TODO check if currSect.title is nil. return error
-			currSect.subs = append(currSect.subs, kv)
-		} else if rEmt.Match(line) { //empty line comes after the other matches because i'm afraid other matches may match it
+			//a section may appear twice in a file. we fold the second into the first
+			if prev, ok := sections[currSect.key()]; ok {
+				currSect = prev
+			}
+		} else if rEmt.Match(line) { //empty line comes before kv because the kv regex allows a key with no value
 			currSect.subs = append(currSect.subs, parseEmpty(lineCount))
+		} else if rKv.Match(line) {
+			if len(currSect.title.kv.k) == 0 {
+				return nil, fmt.Errorf("key outside of a section on line: %d", lineCount+1)
+			}
+			currSect.subs = append(currSect.subs, parseKv(line, lineCount))
 		} else {
-			return nil, fmt.Errorf("Could not match line: %d", lineCount+2) // linecount +2 because, first we're indexing from zero, and second, it is the line after the last lineCount that is not being matched
+			return nil, fmt.Errorf("Could not match line: %d", lineCount+1) // linecount +1 because we're indexing from zero
 		}
 		lineCount += 1
 	}
-	return nil, nil
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	sections[currSect.key()] = currSect
+	return &Config{sections: sections}, nil
+}
+
+// sectKey is how sections are found in Config.sections. section names are case-insensitive, subsections are not
+func sectKey(sect, subsect string) string {
+	if subsect == "" {
+		return strings.ToLower(sect)
+	}
+	return strings.ToLower(sect) + "." + subsect
+}
+
+func (s Section) key() string {
+	return sectKey(string(s.title.kv.k), string(s.title.kv.v))
 }
 
 func parseSect(line []byte, count int) Line {
@@ -159,11 +195,16 @@ func parseSect(line []byte, count int) Line {
 
 func parseKv(line []byte, count int) Line {
 	match := rKv.FindSubmatch(line)
+	v := match[rKv.SubexpIndex("val")]
+	if match[rKv.SubexpIndex("eq")] == nil {
+		v = []byte("true")
+	}
+	v = bytes.Trim(v, `"`)
 	return Line{
 		count: count,
 		_type: kv,
 		cont:  line,
-		kv:    KV{k: match[rKv.SubexpIndex("key")], v: match[rKv.SubexpIndex("val")]},
+		kv:    KV{k: match[rKv.SubexpIndex("key")], v: v},
 		cmt:   InlineCmt{text: match[rKv.SubexpIndex("cmt")]},
 	}
 }
@@ -173,13 +214,14 @@ func parseCmt(line []byte, count int) Line {
 	return Line{
 		_type: comment,
 		count: count,
-		cont:  match[rCmt.SubexpIndex("cmt")],
+		cont:  line,
+		cmt:   InlineCmt{text: match[rCmt.SubexpIndex("cmt")]},
 	}
 }
 
 func parseEmpty(count int) Line {
 	return Line{
-		_type: comment,
+		_type: emt,
 		count: count,
 	}
 }
@@ -202,72 +244,137 @@ func insert[T any](slice []T, item T, i int) []T {
 	return l
 }
 
-func (conf *Config) add(path []string, v string, w io.WriteCloser) error {
-	if len(path) == 2 {
-		sect, ok := conf.sections[path[0]]
-		if ok { // section already exists
-			if len(sect.title.kv.v) != 0 { //does it have a subsection or not?
-				return fmt.Errorf("less depth provided than expected")
-			}
-			for _, l := range sect.subs {
-				if bytes.Compare(l.kv.k, []byte(path[1])) == 0 { //this is the k-v pair in question
-					l.kv.v = []byte(v)
-					l.cont = joinKV(l.kv.k, l.kv.v, l.cmt.text) // so it will be printable
-					break
-				}
-			}
-		} else {
-			// create a new line and append it
-			l := Line{} // i dont know how to specify the count for tis particular line, and i dot know if it is useful
-			l._type = kv
-			l.kv.k = []byte(path[1])
-			l.kv.v = []byte(v)
-			l.cont = joinKV(l.kv.k, l.kv.v, nil)
-			last := 0
-			for i, l := range sect.subs { // record the last known kv line inside last
-				if l._type == kv {
-					last = i
-				}
-			}
-			sect.subs = insert(sect.subs, l, last)
+// splitConfPath turns a dotted path into section, subsection and key.
+// the subsection is everything between the first and last dot, since it may have dots of its own. e.g. branch.v1.0.remote
+func splitConfPath(path []string) (string, string, string, error) {
+	if len(path) < 2 {
+		return "", "", "", fmt.Errorf("key does not contain a section: %s", strings.Join(path, "."))
+	}
+	return path[0], strings.Join(path[1:len(path)-1], "."), path[len(path)-1], nil
+}
+
+// get returns the value of the last occurence of the key, the way git does
+func (conf *Config) get(path []string) (string, bool) {
+	sect, sub, key, err := splitConfPath(path)
+	if err != nil {
+		return "", false
+	}
+	s, ok := conf.sections[sectKey(sect, sub)]
+	if !ok {
+		return "", false
+	}
+	val, found := "", false
+	for _, l := range s.subs {
+		if l._type == kv && bytes.EqualFold(l.kv.k, []byte(key)) {
+			val, found = string(l.kv.v), true
 		}
+	}
+	return val, found
+}
 
-	} else if len(path) == 3 {
-		sect, ok := conf.sections[path[0]]
-		if ok { // section already exists
-			if len(sect.title.kv.v) != 0 && bytes.Equal(sect.title.kv.v, []byte(path[1])) { // match the key exists in that path
-				for i, l := range sect.subs {
-					if bytes.Compare(l.kv.k, []byte(path[2])) == 0 { //this is the k-v pair in question
-						sect.subs[i].kv.v = []byte(v)
-						break
-					}
-				}
-			} else if !bytes.Equal(sect.title.kv.v, []byte(path[1])) { // new subsection. create it and then create a new kv
-
-			} else { //error
-				return fmt.Errorf(" depth provided is more than expected or invalid path")
-			}
+// getBool reads a boolean key the way git does. missing keys are false
+func (conf *Config) getBool(path []string) bool {
+	v, _ := conf.get(path)
+	switch strings.ToLower(v) {
+	case "true", "yes", "on", "1":
+		return true
+	}
+	return false
+}
 
-		} else {
-			// create a new line and append it
-			l := Line{} // i dont know how to specify the count for tis particular line, and i dot know if it is useful
-			l._type = kv
-			l.kv.k = []byte(path[1])
-			l.kv.v = []byte(v)
-			l.cont = joinKV(l.kv.k, l.kv.v, nil)
-			last := 0
-			for i, l := range sect.subs { // record the last known kv line inside last
-				if l._type == kv {
-					last = i
-				}
+// set adds or modifies a key, creating its section if need be
+func (conf *Config) set(path []string, v string) error {
+	sect, sub, key, err := splitConfPath(path)
+	if err != nil {
+		return err
+	}
+	k := sectKey(sect, sub)
+	s, ok := conf.sections[k]
+	if !ok {
+		// new sections go after every other one
+		count := 0
+		for _, other := range conf.sections {
+			if other.count >= count {
+				count = other.count + 1
 			}
-			sect.subs = insert(sect.subs, l, last)
 		}
-	} else {
-		return fmt.Errorf("we dont expect paths with length greater than 3 or less than 2")
+		s = newSect(count)
+		title := fmt.Sprintf("[%s]", sect)
+		if sub != "" {
+			title = fmt.Sprintf("[%s \"%s\"]", sect, sub)
+		}
+		s.title = Line{_type: section, cont: []byte(title), kv: KV{k: []byte(sect), v: []byte(sub)}}
+	}
+	val := v
+	if strings.ContainsAny(v, "#;") || strings.TrimSpace(v) != v {
+		val = fmt.Sprintf("%q", v)
+	}
+	l := Line{_type: kv, kv: KV{k: []byte(key), v: []byte(v)}}
+	l.cont = append([]byte{'\t'}, joinKV(l.kv.k, []byte(val), nil)...)
+
+	last := -1
+	for i, sl := range s.subs {
+		if sl._type != kv {
+			continue
+		}
+		if bytes.EqualFold(sl.kv.k, l.kv.k) { //this is the k-v pair in question
+			s.subs[i] = l
+			conf.sections[k] = s
+			return nil
+		}
+		last = i
+	}
+	// record the last known kv line inside last, so that comments trailing the section stay at its end
+	s.subs = insert(s.subs, l, last+1)
+	conf.sections[k] = s
+	return nil
+}
+
+// unset removes every occurence of the key. it reports whether there was anything to remove
+func (conf *Config) unset(path []string) bool {
+	sect, sub, key, err := splitConfPath(path)
+	if err != nil {
+		return false
 	}
+	k := sectKey(sect, sub)
+	s, ok := conf.sections[k]
+	if !ok {
+		return false
+	}
+	subs := s.subs[:0]
+	found := false
+	for _, l := range s.subs {
+		if l._type == kv && bytes.EqualFold(l.kv.k, []byte(key)) {
+			found = true
+			continue
+		}
+		subs = append(subs, l)
+	}
+	s.subs = subs
+	conf.sections[k] = s
+	return found
+}
+
+// removeSect drops a whole section, e.g. the config of a deleted branch
+func (conf *Config) removeSect(key string) bool {
+	if _, ok := conf.sections[key]; !ok {
+		return false
+	}
+	delete(conf.sections, key)
+	return true
+}
 
-	return conf.save(w)
+// renameSect moves a subsection, e.g. [branch "old"] to [branch "new"], keeping its lines and position
+func (conf *Config) renameSect(sect, from, to string) bool {
+	s, ok := conf.sections[sectKey(sect, from)]
+	if !ok {
+		return false
+	}
+	delete(conf.sections, sectKey(sect, from))
+	s.title.kv.v = []byte(to)
+	s.title.cont = []byte(fmt.Sprintf("[%s \"%s\"]", s.title.kv.k, to))
+	conf.sections[sectKey(sect, to)] = s
+	return true
 }
 
 func (conf *Config) save(w io.Writer) error {
@@ -299,17 +406,102 @@ const (
 	system
 )
 
-// comeback
-func (g *Got) ShowConf(path []string, where int) (io.Reader, error) {
+// confPath is where the config file for each level lives
+func (g *Got) confPath(where int) (string, error) {
+	switch where {
+	case local:
+		return filepath.Join(g.baseDir, ".git", "config"), nil
+	case global:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".gitconfig"), nil
+	case system:
+		return filepath.Join("/etc", "gitconfig"), nil
+	}
+	return "", fmt.Errorf("unknown config level: %d", where)
+}
 
-	return nil, nil
+func (g *Got) loadConfig(where int) (*Config, error) {
+	path, err := g.confPath(where)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(path)
+}
+
+// saveConfig writes to a lock file and renames it over the config, so a failed write leaves the old one intact
+func (g *Got) saveConfig(conf *Config, where int) error {
+	path, err := g.confPath(where)
+	if err != nil {
+		return err
+	}
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not lock config: %w", err)
+	}
+	w := bufio.NewWriter(f)
+	if err := conf.save(w); err != nil {
+		f.Close()
+		os.Remove(lock)
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(lock)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(lock)
+		return err
+	}
+	return os.Rename(lock, path)
+}
+
+// setConf is a shortcut for changing a single key in the repository's own config
+func (g *Got) setConf(key, value string) error {
+	conf, err := g.loadConfig(local)
+	if err != nil {
+		return err
+	}
+	if err := conf.set(strings.Split(key, "."), value); err != nil {
+		return err
+	}
+	return g.saveConfig(conf, local)
+}
+
+func (g *Got) ShowConf(path []string, where int) (io.Reader, error) {
+	conf, err := g.loadConfig(where)
+	if err != nil {
+		return nil, err
+	}
+	v, ok := conf.get(path)
+	if !ok {
+		return nil, fmt.Errorf("%s is not set", strings.Join(path, "."))
+	}
+	return strings.NewReader(v + "\n"), nil
 }
 
-// comeback
 func (g *Got) UpdateConf(path []string, value string, where int) error {
-	return nil
+	conf, err := g.loadConfig(where)
+	if err != nil {
+		return err
+	}
+	if err := conf.set(path, value); err != nil {
+		return err
+	}
+	return g.saveConfig(conf, where)
 }
 
 func (g *Got) Delete(path []string, where int) error {
-	return nil
+	conf, err := g.loadConfig(where)
+	if err != nil {
+		return err
+	}
+	if !conf.unset(path) {
+		return fmt.Errorf("%s is not set", strings.Join(path, "."))
+	}
+	return g.saveConfig(conf, where)
 }
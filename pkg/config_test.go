@@ -1,5 +1,11 @@
 package pkg
 
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
 var testConfig string = `
 [user]
 	name = Pavan Kumar Sunkara ; commenting this out
@@ -103,3 +109,119 @@ var testConfig string = `
 	
 
 `
+
+// smallConfig is what git itself writes, plus the odd things people put in by hand
+var smallConfig string = `# written by hand
+[core]
+	bare = false
+	filemode
+[remote "origin"]
+	url = https://example.com/got.git ; where we cloned from
+	fetch = +refs/heads/*:refs/remotes/origin/*
+[branch "v1.0"]
+	remote = origin
+[user]
+	name = "Got Tester"
+	email = a@b.c
+	email = tester@example.com
+`
+
+func TestReadConfig(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(smallConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		path string
+		want string
+		ok   bool
+	}{
+		{"core.bare", "false", true},
+		{"core.filemode", "true", true},
+		{"CORE.BARE", "false", true},
+		{"remote.origin.url", "https://example.com/got.git", true},
+		{"remote.origin.fetch", "+refs/heads/*:refs/remotes/origin/*", true},
+		{"branch.v1.0.remote", "origin", true},
+		{"user.name", "Got Tester", true},
+		{"user.email", "tester@example.com", true},
+		{"remote.ORIGIN.url", "", false},
+		{"core.pager", "", false},
+		{"core", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := conf.get(strings.Split(tt.path, "."))
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("get(%s) = %q, %v; want %q, %v", tt.path, got, ok, tt.want, tt.ok)
+		}
+	}
+	if !conf.getBool([]string{"core", "filemode"}) || conf.getBool([]string{"core", "bare"}) {
+		t.Errorf("getBool read core.filemode or core.bare wrong")
+	}
+}
+
+func TestReadConfigErrors(t *testing.T) {
+	for _, bad := range []string{
+		"key = value\n",
+		"[core]\n\t= value\n",
+	} {
+		if _, err := readConfig(strings.NewReader(bad)); err == nil {
+			t.Errorf("readConfig(%q) did not fail", bad)
+		}
+	}
+}
+
+func TestSetUnsetConfig(t *testing.T) {
+	conf, err := readConfig(strings.NewReader(smallConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.set([]string{"core", "bare"}, "true"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.set([]string{"core", "editor"}, "vi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.set([]string{"branch", "main", "merge"}, "refs/heads/main"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.set([]string{"core", "comment"}, "a ; b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.set([]string{"nosection"}, "x"); err == nil {
+		t.Errorf("set took a key without a section")
+	}
+	if !conf.unset([]string{"user", "email"}) {
+		t.Errorf("unset did not find user.email")
+	}
+	if conf.unset([]string{"user", "email"}) {
+		t.Errorf("unset found user.email twice")
+	}
+
+	//what we changed has to survive a round trip through the file, and what we didn't has to stay as it was
+	var b bytes.Buffer
+	if err := conf.save(&b); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "# written by hand\n[core]\n") ||
+		!strings.Contains(b.String(), "url = https://example.com/got.git ; where we cloned from\n") {
+		t.Errorf("save lost untouched lines:\n%s", b.String())
+	}
+	conf, err = readConfig(&b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for path, want := range map[string]string{
+		"core.bare":         "true",
+		"core.editor":       "vi",
+		"core.comment":      "a ; b",
+		"branch.main.merge": "refs/heads/main",
+		"user.name":         "Got Tester",
+	} {
+		if got, _ := conf.get(strings.Split(path, ".")); got != want {
+			t.Errorf("after save, %s = %q; want %q", path, got, want)
+		}
+	}
+	if _, ok := conf.get([]string{"user", "email"}); ok {
+		t.Errorf("user.email came back after unset")
+	}
+}
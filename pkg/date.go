@@ -0,0 +1,162 @@
+package pkg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//git accepts dates in many shapes wherever it asks for one: `main@{yesterday}`, `--since=2.weeks.ago`, `--expire=2023-01-01`.
+//we understand the common ones: now, yesterday, today, `<n> <unit> ago` with dots or spaces, a unix timestamp after an @,
+//and absolute dates with or without a time.
+//read: https://git-scm.com/docs/git-rev-parse#Documentation/git-rev-parse.txt-emltrefnamegtltdategtemegemmasteryesterdayememHEAD5minutesagoem
+
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	RFC2822,
+	time.RFC1123Z,
+}
+
+// dayLayouts are dates without a time. like git, they keep the time of day it is now, so --since=2024-01-02 run at
+// noon means noon that day, not midnight
+var dayLayouts = []string{
+	"2006-01-02",
+	"2006/01/02",
+}
+
+var dateUnits = map[string]time.Duration{
+	"second": time.Second,
+	"minute": time.Minute,
+	"hour":   time.Hour,
+	"day":    24 * time.Hour,
+	"week":   7 * 24 * time.Hour,
+}
+
+// parseApproxDate turns a date the way a user would write it into a time, relative to now
+func parseApproxDate(s string, now time.Time) (time.Time, error) {
+	s = strings.TrimSpace(s)
+	lower := strings.ToLower(s)
+	switch lower {
+	case "now":
+		return now, nil
+	case "yesterday":
+		return now.AddDate(0, 0, -1), nil
+	case "today", "midnight":
+		y, m, d := now.Date()
+		return time.Date(y, m, d, 0, 0, 0, 0, now.Location()), nil
+	}
+	if strings.HasPrefix(s, "@") {
+		if ts, err := strconv.ParseInt(s[1:], 10, 64); err == nil {
+			return time.Unix(ts, 0), nil
+		}
+	}
+	for _, layout := range dateLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			return t, nil
+		}
+	}
+	for _, layout := range dayLayouts {
+		if t, err := time.ParseInLocation(layout, s, now.Location()); err == nil {
+			y, m, d := t.Date()
+			return time.Date(y, m, d, now.Hour(), now.Minute(), now.Second(), 0, now.Location()), nil
+		}
+	}
+
+	//<n>.<unit>.ago, where the dots may be spaces and the unit may be plural
+	fields := strings.FieldsFunc(lower, func(r rune) bool { return r == '.' || r == ' ' || r == '_' })
+	if len(fields) == 3 && fields[2] == "ago" {
+		n, err := strconv.Atoi(fields[0])
+		if err == nil {
+			unit := strings.TrimSuffix(fields[1], "s")
+			switch unit {
+			case "month":
+				return now.AddDate(0, -n, 0), nil
+			case "year":
+				return now.AddDate(-n, 0, 0), nil
+			}
+			if d, ok := dateUnits[unit]; ok {
+				return now.Add(-time.Duration(n) * d), nil
+			}
+		}
+	}
+	return time.Time{}, fmt.Errorf("could not understand the date: %s", s)
+}
+
+// formatDate shows a date in one of git's --date formats. the empty format is git's default, and the date keeps its own zone
+func formatDate(t time.Time, format string, now time.Time) (string, error) {
+	switch format {
+	case "", "default":
+		return t.Format(RFC2822), nil
+	case "iso", "iso8601":
+		return t.Format("2006-01-02 15:04:05 -0700"), nil
+	case "iso-strict", "iso8601-strict":
+		return t.Format(time.RFC3339), nil
+	case "rfc", "rfc2822":
+		return t.Format(time.RFC1123Z), nil
+	case "short":
+		return t.Format("2006-01-02"), nil
+	case "raw":
+		return fmt.Sprintf("%d %s", t.Unix(), t.Format("-0700")), nil
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10), nil
+	case "local":
+		return t.Local().Format("Mon Jan 2 15:04:05 2006"), nil
+	case "relative":
+		return relativeDate(t, now), nil
+	}
+	return "", fmt.Errorf("unknown date format %s", format)
+}
+
+// relativeDate says how long ago t was, rounded the way git rounds it
+func relativeDate(t, now time.Time) string {
+	secs := int64(now.Sub(t) / time.Second)
+	if secs < 0 {
+		return "in the future"
+	}
+	plural := func(n int64, unit string) string {
+		if n == 1 {
+			return fmt.Sprintf("%d %s ago", n, unit)
+		}
+		return fmt.Sprintf("%d %ss ago", n, unit)
+	}
+	if secs < 90 {
+		return plural(secs, "second")
+	}
+	mins := (secs + 30) / 60
+	if mins < 90 {
+		return plural(mins, "minute")
+	}
+	hours := (mins + 30) / 60
+	if hours < 36 {
+		return plural(hours, "hour")
+	}
+	days := (hours + 12) / 24
+	if days < 14 {
+		return plural(days, "day")
+	}
+	if days < 70 {
+		return plural((days+3)/7, "week")
+	}
+	if days < 365 {
+		return plural((days+15)/30, "month")
+	}
+	if days < 1825 {
+		//under five years git still shows the months
+		totalMonths := (days*12*2 + 365) / (365 * 2)
+		years, months := totalMonths/12, totalMonths%12
+		if months == 0 {
+			return plural(years, "year")
+		}
+		y := fmt.Sprintf("%d years", years)
+		if years == 1 {
+			y = "1 year"
+		}
+		return fmt.Sprintf("%s, %s", y, plural(months, "month"))
+	}
+	return plural((days+183)/365, "year")
+}
@@ -0,0 +1,36 @@
+package pkg
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseApproxDate(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 4, 5, 0, time.UTC)
+	tests := []struct {
+		in   string
+		want time.Time
+	}{
+		{"now", now},
+		{"yesterday", time.Date(2024, 3, 9, 15, 4, 5, 0, time.UTC)},
+		{"today", time.Date(2024, 3, 10, 0, 0, 0, 0, time.UTC)},
+		//a day with no time keeps the time of day it is now, as git does
+		{"2024-01-02", time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2024/01/02", time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)},
+		{"2024-01-02 10:00", time.Date(2024, 1, 2, 10, 0, 0, 0, time.UTC)},
+		{"2024-01-02 10:00:30 +0100", time.Date(2024, 1, 2, 9, 0, 30, 0, time.UTC)},
+		{"2.weeks.ago", time.Date(2024, 2, 25, 15, 4, 5, 0, time.UTC)},
+		{"3 hours ago", time.Date(2024, 3, 10, 12, 4, 5, 0, time.UTC)},
+		{"1.month.ago", time.Date(2024, 2, 10, 15, 4, 5, 0, time.UTC)},
+		{"@1700000000", time.Unix(1700000000, 0)},
+	}
+	for _, tt := range tests {
+		when, err := parseApproxDate(tt.in, now)
+		if err != nil || !when.Equal(tt.want) {
+			t.Errorf("parseApproxDate(%q) = %v, %v, want %v", tt.in, when, err, tt.want)
+		}
+	}
+	if _, err := parseApproxDate("the day after tomorrow", now); err == nil {
+		t.Error("parseApproxDate took a date it doesn't know")
+	}
+}
@@ -0,0 +1,448 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+//  |||DESCRIBE AND NAME-REV||| //
+//describe names a commit after the nearest tag it can reach, like v1.2-3-gabc1234: three commits on top of v1.2.
+//the walk goes newest commit first, and every candidate tag counts the commits it can't reach, so the nearest
+//tag is the one with the fewest. like git, only the first ten tags found are candidates.
+//name-rev goes the other way: it walks down from every ref and names a commit by how to get to it from a ref,
+//like main~2^2.
+//read: https://git-scm.com/docs/git-describe and https://git-scm.com/docs/git-name-rev
+
+const maxDescribeCandidates = 10
+
+// a commit reached through a merge is treated as this much further away, so name-rev prefers first-parent paths
+const mergeTraversalWeight = 65535
+
+// describeName is a tag naming a commit. annotated tags always win over lightweight ones
+type describeName struct {
+	name      string
+	annotated bool
+	date      time.Time
+}
+
+type describeCandidate struct {
+	name       *describeName
+	depth      int
+	flag       uint
+	foundOrder int
+}
+
+// commitQueue keeps commits newest first by committer date, and in arrival order between equal dates, like git's
+// commit_list_insert_by_date
+type commitQueue struct {
+	items []*Comm
+}
+
+func (q *commitQueue) push(c *Comm) {
+	i := sort.Search(len(q.items), func(i int) bool {
+		return q.items[i].committer.time.Before(c.committer.time)
+	})
+	q.items = append(q.items, nil)
+	copy(q.items[i+1:], q.items[i:])
+	q.items[i] = c
+}
+
+func (q *commitQueue) pop() *Comm {
+	c := q.items[0]
+	q.items = q.items[1:]
+	return c
+}
+
+// describeNames maps each tagged commit to the tag describe would use for it
+func (got *Got) describeNames(lightweight bool) (map[Sha1]*describeName, bool, error) {
+	names := make(map[Sha1]*describeName)
+	refs, err := got.listRefs(tagPrefix)
+	if err != nil {
+		return nil, false, err
+	}
+	unannotated := false
+	for _, ref := range refs {
+		sha, err := got.readRef(ref)
+		if err != nil {
+			continue
+		}
+		n := &describeName{name: strings.TrimPrefix(ref, tagPrefix)}
+		ty, _, err := got.objectType(sha)
+		if err != nil {
+			return nil, false, err
+		}
+		if ty == "tag" {
+			t, err := got.readTag(sha)
+			if err != nil {
+				return nil, false, err
+			}
+			n.annotated, n.date = true, t.tagger.time
+			if sha, _, err = got.peel(sha); err != nil {
+				return nil, false, err
+			}
+		} else if !lightweight {
+			unannotated = true
+			continue
+		}
+		old, ok := names[sha]
+		//git keeps an annotated tag over a lightweight one, and the newer of two annotated ones
+		if !ok || (n.annotated && !old.annotated) || (n.annotated && old.annotated && n.date.After(old.date)) {
+			names[sha] = n
+		}
+	}
+	return names, unannotated, nil
+}
+
+// Describe names the commit-ish rev, HEAD when empty, after the nearest tag it can reach.
+// tags allows lightweight tags, long always shows the count and the sha, abbrev is how long the sha is (0 leaves it out),
+// and dirty, when not empty, is appended when the working tree has changes
+func (got *Got) Describe(ctx context.Context, rev string, tags, long bool, abbrev int, dirty string) (string, error) {
+	if rev == "" {
+		rev = "HEAD"
+	}
+	target, err := got.resolveCommitish(rev)
+	if err != nil {
+		return "", fmt.Errorf("Not a valid object name %s", rev)
+	}
+	suffix := ""
+	if dirty != "" {
+		isDirty, err := got.isDirty()
+		if err != nil {
+			return "", err
+		}
+		if isDirty {
+			suffix = dirty
+		}
+	}
+	names, unannotated, err := got.describeNames(tags)
+	if err != nil {
+		return "", err
+	}
+	format := func(name string, depth int) string {
+		if abbrev == 0 {
+			return name + suffix
+		}
+		return fmt.Sprintf("%s-%d-g%s%s", name, depth, got.Abbrev(target, abbrev), suffix)
+	}
+	if n, ok := names[target]; ok {
+		if long {
+			return format(n.name, 0), nil
+		}
+		return n.name + suffix, nil
+	}
+
+	start, err := got.readCommit(shaToString(target))
+	if err != nil {
+		return "", err
+	}
+	flags := map[Sha1]uint{target: 0}
+	queue := &commitQueue{}
+	queue.push(start)
+	var cands []*describeCandidate
+	var gaveUp *Comm
+	annotated, seen := 0, 0
+	for len(queue.items) > 0 {
+		c := queue.pop()
+		seen++
+		if n, ok := names[c.sha]; ok {
+			if len(cands) == maxDescribeCandidates {
+				gaveUp = c
+				break
+			}
+			t := &describeCandidate{name: n, depth: seen - 1, flag: 1 << len(cands), foundOrder: len(cands)}
+			cands = append(cands, t)
+			flags[c.sha] |= t.flag
+			if n.annotated {
+				annotated++
+			}
+		}
+		for _, t := range cands {
+			if flags[c.sha]&t.flag == 0 {
+				t.depth++
+			}
+		}
+		//nothing is left to walk, every path is covered
+		if annotated > 0 && len(queue.items) == 0 {
+			break
+		}
+		if err := got.queueParents(queue, flags, c); err != nil {
+			return "", err
+		}
+	}
+
+	if len(cands) == 0 {
+		if unannotated {
+			return "", fmt.Errorf("No annotated tags can describe '%s'.\nHowever, there were unannotated tags: try --tags.", shaToString(target))
+		}
+		return "", fmt.Errorf("No names found, cannot describe anything.")
+	}
+	sort.SliceStable(cands, func(i, j int) bool {
+		if cands[i].depth != cands[j].depth {
+			return cands[i].depth < cands[j].depth
+		}
+		return cands[i].foundOrder < cands[j].foundOrder
+	})
+	best := cands[0]
+	if gaveUp != nil {
+		queue.push(gaveUp)
+	}
+	//the best tag may still not reach commits further down the queue, they count against it too
+	for len(queue.items) > 0 {
+		c := queue.pop()
+		if flags[c.sha]&best.flag != 0 {
+			covered := true
+			for _, other := range queue.items {
+				if flags[other.sha]&best.flag == 0 {
+					covered = false
+					break
+				}
+			}
+			if covered {
+				break
+			}
+		} else {
+			best.depth++
+		}
+		if err := got.queueParents(queue, flags, c); err != nil {
+			return "", err
+		}
+	}
+	return format(best.name.name, best.depth), nil
+}
+
+// queueParents queues the parents of c that were never queued, and passes the tags that reach c on to them
+func (got *Got) queueParents(queue *commitQueue, flags map[Sha1]uint, c *Comm) error {
+	for _, p := range c.parents {
+		if _, queued := flags[p]; !queued {
+			parent, err := got.readCommit(shaToString(p))
+			if err != nil {
+				return err
+			}
+			queue.push(parent)
+		}
+		flags[p] |= flags[c.sha]
+	}
+	return nil
+}
+
+// isDirty tells whether the index or the working tree differs from HEAD. untracked files don't count
+func (got *Got) isDirty() (bool, error) {
+	have, err := got.headTreeItems()
+	if err != nil {
+		return false, err
+	}
+	idx, err := readIndexFile()
+	if err != nil {
+		return false, err
+	}
+	if len(have) != len(idx.cache) {
+		return true, nil
+	}
+	for p, e := range idx.cache {
+		it, ok := have[p]
+		if !ok || it.sha != e.sha || it.mode != e.mode {
+			return true, nil
+		}
+		if e.ignoreWorktree() {
+			continue
+		}
+		changed, err := idx.changed(e, filepath.Join(got.baseDir, p))
+		if errors.Is(err, os.ErrNotExist) || changed {
+			return true, nil
+		}
+		if err != nil {
+			return false, err
+		}
+	}
+	return false, nil
+}
+
+// revName is how name-rev reaches a commit: from the tip name, generation first parents down, at a weighted distance
+type revName struct {
+	tip        string
+	date       time.Time
+	generation int
+	distance   int
+	fromTag    bool
+}
+
+// String is the name as name-rev shows it, like tags/v1~2. the ^0 of an annotated tag goes once there is a ~
+func (n *revName) String() string {
+	if n.generation == 0 {
+		return n.tip
+	}
+	return fmt.Sprintf("%s~%d", strings.TrimSuffix(n.tip, "^0"), n.generation)
+}
+
+// better is git's is_better_name: names from older tags win, then tags over other refs, then shorter paths, then older tips
+func (n *revName) better(than *revName) bool {
+	if than == nil {
+		return true
+	}
+	if n.fromTag && than.fromTag {
+		return than.date.After(n.date) || (than.date.Equal(n.date) && than.distance > n.distance)
+	}
+	if n.fromTag != than.fromTag {
+		return n.fromTag
+	}
+	if than.distance != n.distance {
+		return than.distance > n.distance
+	}
+	return than.date.After(n.date)
+}
+
+type nameRevTip struct {
+	sha  Sha1
+	name *revName
+}
+
+// nameRevs names every commit that can be reached from a ref. with tagsOnly, only tags are used.
+// the objects the refs point at themselves, tags included, are named after the ref in exact.
+// short drops the tags/ too, which git does for --tags --name-only
+func (got *Got) nameRevs(tagsOnly, short bool) (names map[Sha1]*revName, exact map[Sha1]string, err error) {
+	refs, err := got.listRefs("refs")
+	if err != nil {
+		return nil, nil, err
+	}
+	exact = make(map[Sha1]string)
+	var tips []nameRevTip
+	for _, ref := range refs {
+		fromTag := strings.HasPrefix(ref, tagPrefix)
+		if tagsOnly && !fromTag {
+			continue
+		}
+		sha, err := got.readRef(ref)
+		if err != nil {
+			continue
+		}
+		name := strings.TrimPrefix(strings.TrimPrefix(ref, branchPrefix), "refs/")
+		if short {
+			name = strings.TrimPrefix(ref, tagPrefix)
+		}
+		if _, ok := exact[sha]; !ok {
+			exact[sha] = name
+		}
+		var date time.Time
+		deref := false
+		//the date of the outermost tag is the one that counts
+		for {
+			ty, _, err := got.objectType(sha)
+			if err != nil {
+				return nil, nil, err
+			}
+			if ty != "tag" {
+				break
+			}
+			t, err := got.readTag(sha)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !deref {
+				date = t.tagger.time
+			}
+			sha, deref = t.object, true
+		}
+		comm, err := got.readCommit(shaToString(sha))
+		if err != nil {
+			//a tag of a tree or a blob names no commit
+			continue
+		}
+		if !deref {
+			date = comm.committer.time
+		}
+		if deref {
+			name += "^0"
+		}
+		tips = append(tips, nameRevTip{sha: sha, name: &revName{tip: name, date: date, fromTag: fromTag}})
+	}
+	//tags first, older ones before newer ones, like git
+	sort.SliceStable(tips, func(i, j int) bool {
+		if tips[i].name.fromTag != tips[j].name.fromTag {
+			return tips[i].name.fromTag
+		}
+		return tips[i].name.date.Before(tips[j].name.date)
+	})
+
+	names = make(map[Sha1]*revName)
+	for _, tip := range tips {
+		if !tip.name.better(names[tip.sha]) {
+			continue
+		}
+		names[tip.sha] = tip.name
+		stack := []Sha1{tip.sha}
+		for len(stack) > 0 {
+			sha := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			name := names[sha]
+			comm, err := got.readCommit(shaToString(sha))
+			if err != nil {
+				return nil, nil, err
+			}
+			var next []Sha1
+			for i, p := range comm.parents {
+				pn := &revName{date: name.date, fromTag: name.fromTag}
+				if i == 0 {
+					pn.tip, pn.generation, pn.distance = name.tip, name.generation+1, name.distance+1
+				} else {
+					base := strings.TrimSuffix(name.tip, "^0")
+					if name.generation > 0 {
+						base = fmt.Sprintf("%s~%d", base, name.generation)
+					}
+					pn.tip, pn.distance = fmt.Sprintf("%s^%d", base, i+1), name.distance+mergeTraversalWeight
+				}
+				if pn.better(names[p]) {
+					names[p] = pn
+					next = append(next, p)
+				}
+			}
+			//the first parent has to come off the stack first
+			for i := len(next) - 1; i >= 0; i-- {
+				stack = append(stack, next[i])
+			}
+		}
+	}
+	return names, exact, nil
+}
+
+// NameRev names each revision after the nearest ref it can be reached from, like `HEAD main~2`.
+// nameOnly leaves out the revision as given, and a commit no ref reaches is undefined
+func (got *Got) NameRev(ctx context.Context, revs []string, tagsOnly, nameOnly bool) (io.Reader, error) {
+	names, exact, err := got.nameRevs(tagsOnly, tagsOnly && nameOnly)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for _, rev := range revs {
+		sha, err := got.ResolveRevision(rev)
+		if err != nil {
+			return &b, fmt.Errorf("Could not get sha1 for %s. Skipping.", rev)
+		}
+		ty, _, err := got.objectType(sha)
+		if err != nil {
+			return &b, err
+		}
+		name := "undefined"
+		//anything but a commit is only named when a ref points right at it
+		if ty != "commit" {
+			if n, ok := exact[sha]; ok {
+				name = n
+			}
+		} else if n, ok := names[sha]; ok {
+			name = n.String()
+		}
+		if nameOnly {
+			fmt.Fprintln(&b, name)
+		} else {
+			fmt.Fprintf(&b, "%s %s\n", rev, name)
+		}
+	}
+	return &b, nil
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+// testDescribeRepo builds
+//
+//	c1 (v1) - c2 - c3 (light) - m (master)
+//	   \                       /
+//	    s1 (v2) --------------
+//
+// v1 and v2 are annotated, v2 is the newer one. light is a lightweight tag
+func testDescribeRepo(t *testing.T) (*Got, map[string]Sha1) {
+	t.Helper()
+	got := testRepo(t)
+	t.Setenv("GIT_COMMITTER_NAME", "Tag Ger")
+	t.Setenv("GIT_COMMITTER_EMAIL", "tagger@example.com")
+	c := make(map[string]Sha1)
+	c["c1"] = testCommit(t, got, 1700001000)
+	c["c2"] = testCommit(t, got, 1700002000, c["c1"])
+	c["c3"] = testCommit(t, got, 1700003000, c["c2"])
+	c["s1"] = testCommit(t, got, 1700002500, c["c1"])
+	c["m"] = testCommit(t, got, 1700004000, c["c3"], c["s1"])
+	if err := got.writeRef("refs/heads/master", c["m"]); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.writeRef("refs/tags/light", c["c3"]); err != nil {
+		t.Fatal(err)
+	}
+	for _, tag := range []struct{ name, commit, date string }{{"v1", "c1", "1700001100 +0000"}, {"v2", "s1", "1700002600 +0000"}} {
+		t.Setenv("GIT_COMMITTER_DATE", tag.date)
+		if _, err := got.CreateTag(context.Background(), tag.name, shaToString(c[tag.commit]), true, tag.name, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return got, c
+}
+
+func TestDescribe(t *testing.T) {
+	got, c := testDescribeRepo(t)
+	ctx := context.Background()
+	abbrev := func(name string) string { return got.Abbrev(c[name], 7) }
+	tests := []struct {
+		rev        string
+		tags, long bool
+		abbrev     int
+		want       string
+	}{
+		//v2 leaves out m, c3 and c2. v1 leaves out s1 as well, so v2 is nearer
+		{rev: "m", abbrev: 7, want: "v2-3-g" + abbrev("m")},
+		{rev: "m", abbrev: 0, want: "v2"},
+		//light leaves out only m and s1
+		{rev: "m", tags: true, abbrev: 7, want: "light-2-g" + abbrev("m")},
+		{rev: "c3", abbrev: 7, want: "v1-2-g" + abbrev("c3")},
+		{rev: "c1", abbrev: 7, want: "v1"},
+		{rev: "c1", long: true, abbrev: 7, want: "v1-0-g" + abbrev("c1")},
+		{rev: "c3", tags: true, abbrev: 7, want: "light"},
+		{rev: "c2", abbrev: 7, want: "v1-1-g" + abbrev("c2")},
+	}
+	for _, tt := range tests {
+		name, err := got.Describe(ctx, shaToString(c[tt.rev]), tt.tags, tt.long, tt.abbrev, "")
+		if err != nil || name != tt.want {
+			t.Errorf("describe %s (tags %v, long %v, abbrev %d) = %q, %v, want %q", tt.rev, tt.tags, tt.long, tt.abbrev, name, err, tt.want)
+		}
+	}
+}
+
+func TestDescribeNoTags(t *testing.T) {
+	got := testRepo(t)
+	ctx := context.Background()
+	one := testCommit(t, got, 1700000000)
+	two := testCommit(t, got, 1700000100, one)
+	if _, err := got.Describe(ctx, shaToString(two), false, false, 7, ""); err == nil || !strings.Contains(err.Error(), "No names found") {
+		t.Errorf("describe without tags gave %v", err)
+	}
+	if err := got.writeRef("refs/tags/light", one); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.Describe(ctx, shaToString(two), false, false, 7, ""); err == nil || !strings.Contains(err.Error(), "try --tags") {
+		t.Errorf("describe with only lightweight tags gave %v", err)
+	}
+}
+
+func TestNameRev(t *testing.T) {
+	got, c := testDescribeRepo(t)
+	var revs []string
+	for _, name := range []string{"m", "c3", "s1", "c2", "c1"} {
+		revs = append(revs, shaToString(c[name]))
+	}
+	//tags win over branches, and --tags --name-only leaves out the tags/
+	for _, tt := range []struct {
+		tagsOnly bool
+		want     string
+	}{
+		{false, "master\ntags/light\ntags/v2^0\ntags/light~1\ntags/v1^0\n"},
+		{true, "undefined\nlight\nv2^0\nlight~1\nv1^0\n"},
+	} {
+		rdr, err := got.NameRev(context.Background(), revs, tt.tagsOnly, true)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if b, _ := io.ReadAll(rdr); string(b) != tt.want {
+			t.Errorf("name-rev (tags %v) printed\n%s\nwant\n%s", tt.tagsOnly, b, tt.want)
+		}
+	}
+}
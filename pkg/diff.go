@@ -1,37 +1,498 @@
 package pkg
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 
-	"github.com/hexops/gotextdiff"
-	"github.com/hexops/gotextdiff/myers"
-	"github.com/hexops/gotextdiff/span"
+	"github.com/OLUWAMUYIWA/got/pkg/diff"
+	"golang.org/x/sys/unix"
 )
 
-// diff takes 2 file paths
-func diff(a, b string) (string, error) {
-	f1, err := os.Open(a)
-	if err != nil {
-		return "", err
+//  |||FILE DIFFS||| //
+//got diff compares two of: a tree, the index, the working tree. each is a set of paths with a mode and a blob,
+//and a file pair is a path where the two sets differ. a pair prints as a git patch: the diff --git header,
+//the extended header lines for new and deleted files and mode changes, the index line, and the hunks.
+//read: https://git-scm.com/docs/git-diff#_generating_patch_text_with_p
+
+// diffSide is what one side of a diff has at a path
+type diffSide struct {
+	path string
+	mode uint32
+	sha  Sha1
+	//data is the content, when it's at hand already. a worktree file isn't in the object store
+	data     []byte
+	worktree bool
+}
+
+// filePair is a path that differs between the two sides. a nil side doesn't have the path.
+// a renamed or copied file has a different path on each side, and a score for how similar they are
+type filePair struct {
+	old, new *diffSide
+	score    int
+	copied   bool
+}
+
+// renamed tells whether the pair is a rename or a copy, as opposed to a change at one path
+func (p filePair) renamed() bool {
+	return p.old != nil && p.new != nil && p.old.path != p.new.path
+}
+
+func (p filePair) path() string {
+	if p.new != nil {
+		return p.new.path
 	}
-	f2, err := os.Open(b)
+	return p.old.path
+}
+
+// DiffOpts is what `got diff` was asked for. with no revisions the index is compared with the working tree,
+// with one the commit is compared with the working tree, and with two, or a range, the two commits.
+// Cached puts the index in place of the working tree, and HEAD in place of a missing commit.
+// Renames asks for renamed and copied files to be paired up
+type DiffOpts struct {
+	Cached  bool
+	Revs    []string
+	Paths   []string
+	Context int
+	Renames RenameOpts
+	//Algorithm and the whitespace options are how the lines of a file are compared
+	Algorithm                         diff.Algorithm
+	IgnoreSpaceChange, IgnoreAllSpace bool
+	//what's printed: the names alone leave everything else out. the stats come first, then the patch, which is
+	//printed when there are no stats, or with Patch. WordDiff shows the patch word by word
+	NameOnly, NameStatus     bool
+	Stat, Numstat, Shortstat bool
+	Patch                    bool
+	WordDiff                 diff.WordStyle
+}
+
+// lineOpts is the options the diff package takes
+func (opts DiffOpts) lineOpts() diff.Options {
+	return diff.Options{Algorithm: opts.Algorithm, IgnoreSpaceChange: opts.IgnoreSpaceChange, IgnoreAllSpace: opts.IgnoreAllSpace}
+}
+
+// Diff prints the differences the options ask for, as a patch unless they ask for something else
+func (got *Got) Diff(ctx context.Context, opts DiffOpts) (io.Reader, error) {
+	pairs, err := got.diffPairs(opts)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
-	var str1, str2 string
-	if by, err := io.ReadAll(f1); err != nil {
-		return "", err
-	} else {
-		str1 = string(by)
+	var b bytes.Buffer
+	if err := got.writeDiff(&b, pairs, opts); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// writeDiff prints the pairs the way the options ask for
+func (got *Got) writeDiff(w io.Writer, pairs []filePair, opts DiffOpts) error {
+	if opts.NameOnly || opts.NameStatus {
+		changes := make([]TreeChange, len(pairs))
+		for i, p := range pairs {
+			changes[i] = p.change()
+		}
+		format := DiffNameOnly
+		if opts.NameStatus {
+			format = DiffNameStatus
+		}
+		writeChanges(w, changes, format)
+		return nil
+	}
+	if opts.hasStats() && len(pairs) != 0 {
+		fileStats, err := got.diffStats(pairs, opts.lineOpts())
+		if err != nil {
+			return err
+		}
+		if opts.Numstat {
+			writeNumstat(w, fileStats)
+		}
+		if opts.Stat {
+			writeStat(w, fileStats, 80)
+		}
+		if opts.Shortstat {
+			writeShortstat(w, fileStats)
+		}
+		if opts.Patch {
+			fmt.Fprintln(w)
+		}
+	}
+	if opts.hasStats() && !opts.Patch {
+		return nil
+	}
+	for _, p := range pairs {
+		if err := got.writePatch(w, p, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hasStats tells whether any of the stats are asked for
+func (opts DiffOpts) hasStats() bool {
+	return opts.Stat || opts.Numstat || opts.Shortstat
+}
+
+// diffPairs works out the two sides from the options, and the pairs where they differ
+func (got *Got) diffPairs(opts DiffOpts) ([]filePair, error) {
+	tree := func(rev string) (map[string]*diffSide, error) {
+		sha, err := got.peelToTree(rev)
+		if err != nil {
+			return nil, fmt.Errorf("bad revision '%s'", rev)
+		}
+		return got.treeSides(sha), nil
+	}
+	var old, new map[string]*diffSide
+	revs := opts.Revs
+	if len(revs) == 1 {
+		if a, b, ok := strings.Cut(revs[0], "..."); ok {
+			//a...b is what b did since it forked from a
+			if a == "" {
+				a = "HEAD"
+			}
+			if b == "" {
+				b = "HEAD"
+			}
+			left, err := got.ResolveRevision(a)
+			if err != nil {
+				return nil, fmt.Errorf("bad revision '%s'", a)
+			}
+			right, err := got.ResolveRevision(b)
+			if err != nil {
+				return nil, fmt.Errorf("bad revision '%s'", b)
+			}
+			bases, err := got.mergeBases(left, right)
+			if err != nil {
+				return nil, err
+			}
+			if len(bases) == 0 {
+				return nil, fmt.Errorf("%s...%s: no merge base", a, b)
+			}
+			revs = []string{shaToString(bases[0]), b}
+		} else if a, b, ok := strings.Cut(revs[0], ".."); ok {
+			if a == "" {
+				a = "HEAD"
+			}
+			if b == "" {
+				b = "HEAD"
+			}
+			revs = []string{a, b}
+		}
+	}
+	switch {
+	case len(revs) > 2:
+		return nil, fmt.Errorf("diff takes at most two revisions")
+	case len(revs) == 2:
+		if opts.Cached {
+			return nil, fmt.Errorf("--cached compares a commit with the index, it takes one revision")
+		}
+		a, err := got.peelToTree(revs[0])
+		if err != nil {
+			return nil, fmt.Errorf("bad revision '%s'", revs[0])
+		}
+		b, err := got.peelToTree(revs[1])
+		if err != nil {
+			return nil, fmt.Errorf("bad revision '%s'", revs[1])
+		}
+		changes, err := got.TreeDiff(a, b, true, opts.Paths...)
+		if err != nil {
+			return nil, err
+		}
+		pairs := make([]filePair, len(changes))
+		for i, c := range changes {
+			pairs[i] = c.pair()
+		}
+		if opts.Renames.CopiesHarder {
+			old = got.treeSides(a)
+		}
+		return got.detectRenames(pairs, old, opts.Renames)
+	default:
+		idx, err := readIndexFile()
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case opts.Cached:
+			rev := "HEAD"
+			if len(revs) == 1 {
+				rev = revs[0]
+			}
+			if old, err = tree(rev); err != nil {
+				//an unborn branch has nothing committed, everything in the index is new
+				if len(revs) == 1 {
+					return nil, err
+				}
+				old = make(map[string]*diffSide)
+			}
+			new = indexSides(idx)
+		case len(revs) == 1:
+			if old, err = tree(revs[0]); err != nil {
+				return nil, err
+			}
+			if new, err = got.worktreeSides(idx); err != nil {
+				return nil, err
+			}
+		default:
+			old = indexSides(idx)
+			if new, err = got.worktreeSides(idx); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return got.detectRenames(pairSides(old, new, opts.Paths), old, opts.Renames)
+}
+
+// treeSides is every file of a tree
+func (got *Got) treeSides(tree Sha1) map[string]*diffSide {
+	sides := make(map[string]*diffSide)
+	for _, it := range got.flattenTree(tree, "") {
+		sides[it.name] = &diffSide{path: it.name, mode: it.mode, sha: it.sha}
+	}
+	return sides
+}
+
+// indexSides is every merged entry of the index. a conflicted path has no single side to show
+func indexSides(idx *Idx) map[string]*diffSide {
+	sides := make(map[string]*diffSide)
+	for _, e := range idx.entries {
+		if e.flags&flagStageMask != 0 {
+			continue
+		}
+		p := string(e.path)
+		sides[p] = &diffSide{path: p, mode: e.mode, sha: e.sha}
+	}
+	return sides
+}
+
+// worktreeSides is the working tree files the index tracks. a file that didn't change since it was added
+// is taken from the index, the others are read and hashed, and a missing file is left out
+func (got *Got) worktreeSides(idx *Idx) (map[string]*diffSide, error) {
+	sides := make(map[string]*diffSide)
+	for _, e := range idx.entries {
+		if e.flags&flagStageMask != 0 {
+			continue
+		}
+		p := string(e.path)
+		if e.ignoreWorktree() {
+			sides[p] = &diffSide{path: p, mode: e.mode, sha: e.sha}
+			continue
+		}
+		full := filepath.Join(got.baseDir, p)
+		changed, err := idx.changed(e, full)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if !changed {
+			sides[p] = &diffSide{path: p, mode: e.mode, sha: e.sha}
+			continue
+		}
+		side, err := readWorktreeSide(full, p)
+		if err != nil {
+			return nil, err
+		}
+		sides[p] = side
+	}
+	return sides, nil
+}
+
+// readWorktreeSide reads a file of the working tree, or the target of a symlink, and hashes it like a blob
+func readWorktreeSide(full, p string) (*diffSide, error) {
+	var st unix.Stat_t
+	if err := unix.Lstat(full, &st); err != nil {
+		return nil, err
 	}
-	if by, err := io.ReadAll(f2); err != nil {
-		return "", err
+	var data []byte
+	var err error
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		var target string
+		target, err = os.Readlink(full)
+		data = []byte(target)
 	} else {
-		str2 = string(by)
+		data, err = os.ReadFile(full)
+	}
+	if err != nil {
+		return nil, err
+	}
+	sha, err := hashWithObjFormat(data, "blob")
+	if err != nil {
+		return nil, err
+	}
+	return &diffSide{path: p, mode: normMode(st.Mode), sha: sha, data: data, worktree: true}, nil
+}
+
+// pathMatches tells whether p is one of the paths, or under one of them. no paths match everything
+func pathMatches(p string, paths []string) bool {
+	if len(paths) == 0 {
+		return true
+	}
+	for _, want := range paths {
+		want = strings.TrimSuffix(filepath.ToSlash(filepath.Clean(want)), "/")
+		if want == "." || p == want || strings.HasPrefix(p, want+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// pairSides pairs up the paths where the two sides differ, sorted by path
+func pairSides(old, new map[string]*diffSide, paths []string) []filePair {
+	var names []string
+	for p := range old {
+		names = append(names, p)
+	}
+	for p := range new {
+		if _, ok := old[p]; !ok {
+			names = append(names, p)
+		}
+	}
+	sort.Strings(names)
+	var pairs []filePair
+	for _, p := range names {
+		if !pathMatches(p, paths) {
+			continue
+		}
+		o, n := old[p], new[p]
+		if o != nil && n != nil && o.sha == n.sha && o.mode == n.mode {
+			continue
+		}
+		pairs = append(pairs, filePair{old: o, new: n})
+	}
+	return pairs
+}
+
+// sideData is the content of a side, read from the object store unless it's at hand
+func (got *Got) sideData(s *diffSide) ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	if s.data != nil {
+		return s.data, nil
+	}
+	_, ty, data, err := got.ReadObject(shaToString(s.sha))
+	if err != nil {
+		return nil, err
+	}
+	if ty != "blob" {
+		return nil, fmt.Errorf("%s: expected a blob, found %s", s.path, ty)
+	}
+	s.data = data
+	return data, nil
+}
+
+// abbrevSide is the short sha of the index line, all zeros for a missing side
+func (got *Got) abbrevSide(s *diffSide) string {
+	if s == nil {
+		return strings.Repeat("0", 7)
+	}
+	return got.Abbrev(s.sha, 7)
+}
+
+// writePatch writes one file pair as a git patch. a path that changed from a file to a symlink, or the other way,
+// is two patches: the old one deleted and the new one created
+func (got *Got) writePatch(w io.Writer, p filePair, opts DiffOpts) error {
+	if p.old != nil && p.new != nil && modType(p.old.mode) != modType(p.new.mode) {
+		if err := got.writePatch(w, filePair{old: p.old}, opts); err != nil {
+			return err
+		}
+		return got.writePatch(w, filePair{new: p.new}, opts)
+	}
+	name, oldPath := p.path(), p.path()
+	if p.renamed() {
+		oldPath = p.old.path
+	}
+	//the header waits for the hunks: a file with none is left out. in a colour word diff its lines are bold
+	var head bytes.Buffer
+	meta := func(format string, args ...interface{}) {
+		line := fmt.Sprintf(format, args...)
+		if opts.WordDiff == diff.WordsColor {
+			line = diff.ColorMeta(line)
+		}
+		fmt.Fprintln(&head, line)
+	}
+	meta("diff --git a/%s b/%s", oldPath, name)
+	switch {
+	case p.old == nil:
+		meta("new file mode %06o", p.new.mode)
+		meta("index %s..%s", got.abbrevSide(nil), got.abbrevSide(p.new))
+	case p.new == nil:
+		meta("deleted file mode %06o", p.old.mode)
+		meta("index %s..%s", got.abbrevSide(p.old), got.abbrevSide(nil))
+	default:
+		if p.old.mode != p.new.mode {
+			meta("old mode %06o", p.old.mode)
+			meta("new mode %06o", p.new.mode)
+		}
+		if p.renamed() {
+			verb := "rename"
+			if p.copied {
+				verb = "copy"
+			}
+			meta("similarity index %d%%", p.score*100/maxScore)
+			meta("%s from %s", verb, oldPath)
+			meta("%s to %s", verb, name)
+		}
+		if p.old.sha == p.new.sha {
+			_, err := head.WriteTo(w)
+			return err
+		}
+		if p.old.mode == p.new.mode {
+			meta("index %s..%s %06o", got.abbrevSide(p.old), got.abbrevSide(p.new), p.old.mode)
+		} else {
+			meta("index %s..%s", got.abbrevSide(p.old), got.abbrevSide(p.new))
+		}
+	}
+	oldName, newName := "a/"+oldPath, "b/"+name
+	if p.old == nil {
+		oldName = "/dev/null"
+	}
+	if p.new == nil {
+		newName = "/dev/null"
+	}
+	oldData, err := got.sideData(p.old)
+	if err != nil {
+		return err
+	}
+	newData, err := got.sideData(p.new)
+	if err != nil {
+		return err
+	}
+	if isBinary(oldData) || isBinary(newData) {
+		fmt.Fprintf(&head, "Binary files %s and %s differ\n", oldName, newName)
+		_, err := head.WriteTo(w)
+		return err
+	}
+	a, b := diff.SplitLines(string(oldData)), diff.SplitLines(string(newData))
+	ta, tb := a, b
+	if opts.Context == 0 {
+		ta, tb = diff.TrimTail(a, b)
+	}
+	hunks := diff.Hunks(diff.Lines(ta, tb, opts.lineOpts()), opts.Context)
+	if len(hunks) == 0 {
+		//all the changes were whitespace that's ignored. git leaves the file out, unless there's more to tell
+		if p.old == nil || p.new == nil || p.old.mode != p.new.mode || p.renamed() {
+			_, err := head.WriteTo(w)
+			return err
+		}
+		return nil
+	}
+	meta("--- %s", oldName)
+	meta("+++ %s", newName)
+	if _, err := head.WriteTo(w); err != nil {
+		return err
+	}
+	if opts.WordDiff != 0 {
+		diff.WriteWords(w, a, b, hunks, opts.WordDiff)
+		return nil
 	}
-	edits := myers.ComputeEdits(span.URIFromPath(a), str1, str2)
-	diff := fmt.Sprint(gotextdiff.ToUnified(a, b, str1, edits))
-	return diff, nil
+	diff.WriteUnified(w, a, b, hunks)
+	return nil
 }
@@ -0,0 +1,293 @@
+package diff
+
+//  |||COMPACTION||| //
+//a run of changed lines can often slide up or down: deleting the second of two equal lines is the same diff as
+//deleting the first. every algorithm picks one, so git slides each run as far down as it goes, merging it with
+//runs it bumps into, and then back up to the spot that reads best: lined up with a change on the other side if
+//there's one, otherwise where the indentation and blank lines around it say a block starts and ends.
+//read: https://github.com/git/git/blob/master/xdiff/xdiffi.c (xdl_change_compact)
+
+const (
+	maxIndent = 200
+	maxBlanks = 20
+	//indentHeuristicMaxSliding is how far up the indent heuristic looks
+	indentHeuristicMaxSliding = 100
+
+	startOfFilePenalty              = 1
+	endOfFilePenalty                = 21
+	totalBlankWeight                = -30
+	postBlankWeight                 = 6
+	relativeIndentPenalty           = -4
+	relativeIndentWithBlankPenalty  = 10
+	relativeOutdentPenalty          = 24
+	relativeOutdentWithBlankPenalty = 17
+	relativeDedentPenalty           = 23
+	relativeDedentWithBlankPenalty  = 17
+	indentWeight                    = 60
+)
+
+// group is a run of changed lines, from start up to end. a group can be empty, the unchanged lines of the two
+// sides are matched one to one, so the groups between them are too
+type group struct {
+	start, end int
+}
+
+func (s *side) firstGroup() group {
+	var g group
+	for s.isChanged(g.end) {
+		g.end++
+	}
+	return g
+}
+
+// nextGroup moves to the group after g. it tells false when g is the last one
+func (s *side) nextGroup(g *group) bool {
+	if g.end == len(s.ids) {
+		return false
+	}
+	g.start = g.end + 1
+	for g.end = g.start; s.isChanged(g.end); g.end++ {
+	}
+	return true
+}
+
+// previousGroup moves to the group before g. it tells false when g is the first one
+func (s *side) previousGroup(g *group) bool {
+	if g.start == 0 {
+		return false
+	}
+	g.end = g.start - 1
+	for g.start = g.end; s.isChanged(g.start - 1); g.start-- {
+	}
+	return true
+}
+
+// slideDown moves the group down a line, when the line after it is the same as its first one.
+// it merges with the group it runs into
+func (s *side) slideDown(g *group) bool {
+	if g.end >= len(s.ids) || s.ids[g.start] != s.ids[g.end] {
+		return false
+	}
+	s.mark(g.start, false)
+	s.mark(g.end, true)
+	g.start, g.end = g.start+1, g.end+1
+	for s.isChanged(g.end) {
+		g.end++
+	}
+	return true
+}
+
+// slideUp moves the group up a line, when the line before it is the same as its last one
+func (s *side) slideUp(g *group) bool {
+	if g.start <= 0 || s.ids[g.start-1] != s.ids[g.end-1] {
+		return false
+	}
+	g.start, g.end = g.start-1, g.end-1
+	s.mark(g.start, true)
+	s.mark(g.end, false)
+	for s.isChanged(g.start - 1) {
+		g.start--
+	}
+	return true
+}
+
+// compact slides the groups of changes of s to where they read best. o is the other side, whose groups follow.
+// without the indent heuristic, a group that doesn't line up with the other side stays as far down as it goes
+func compact(s, o *side, indentHeuristic bool) {
+	g, og := s.firstGroup(), o.firstGroup()
+	for {
+		if g.end != g.start {
+			var earliestEnd, endMatchingOther, size int
+			for {
+				size = g.end - g.start
+				//the last position where the group lines up with a change on the other side, -1 for none
+				endMatchingOther = -1
+				for s.slideUp(&g) {
+					if !o.previousGroup(&og) {
+						panic("diff: group sync broken sliding up")
+					}
+				}
+				earliestEnd = g.end
+				if og.end > og.start {
+					endMatchingOther = g.end
+				}
+				for s.slideDown(&g) {
+					if !o.nextGroup(&og) {
+						panic("diff: group sync broken sliding down")
+					}
+					if og.end > og.start {
+						endMatchingOther = g.end
+					}
+				}
+				//sliding merged groups, slide the bigger one again
+				if size == g.end-g.start {
+					break
+				}
+			}
+			//the group is as far down as it goes, all that's left is to move it up
+			switch {
+			case g.end == earliestEnd:
+				//it can't move
+			case endMatchingOther != -1:
+				for og.end == og.start {
+					if !s.slideUp(&g) {
+						panic("diff: match disappeared")
+					}
+					if !o.previousGroup(&og) {
+						panic("diff: group sync broken sliding to match")
+					}
+				}
+			case !indentHeuristic:
+			default:
+				//score each place the group can go by how the splits before and after it look, lowest wins
+				shift := earliestEnd
+				if g.end-size-1 > shift {
+					shift = g.end - size - 1
+				}
+				if g.end-indentHeuristicMaxSliding > shift {
+					shift = g.end - indentHeuristicMaxSliding
+				}
+				bestShift := -1
+				var best splitScore
+				for ; shift <= g.end; shift++ {
+					var score splitScore
+					score.add(s.measureSplit(shift))
+					score.add(s.measureSplit(shift - size))
+					if bestShift == -1 || score.cmp(best) <= 0 {
+						best, bestShift = score, shift
+					}
+				}
+				for g.end > bestShift {
+					if !s.slideUp(&g) {
+						panic("diff: best shift unreached")
+					}
+					if !o.previousGroup(&og) {
+						panic("diff: group sync broken sliding to blank line")
+					}
+				}
+			}
+		}
+		if !s.nextGroup(&g) {
+			return
+		}
+		if !o.nextGroup(&og) {
+			panic("diff: group sync broken moving to next group")
+		}
+	}
+}
+
+// indent is how far a line is indented, a tab going to the next multiple of 8. a blank line is -1
+func indent(line string) int {
+	n := 0
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		if !isSpace(c) {
+			return n
+		}
+		if c == ' ' {
+			n++
+		} else if c == '\t' {
+			n += 8 - n%8
+		}
+		if n >= maxIndent {
+			return maxIndent
+		}
+	}
+	return -1
+}
+
+// splitMeasurement is what's around the split before line split: its indent, the blank lines before and after
+// it, and the indent of the lines past those
+type splitMeasurement struct {
+	endOfFile             bool
+	indent                int
+	preBlank, preIndent   int
+	postBlank, postIndent int
+}
+
+func (s *side) measureSplit(split int) splitMeasurement {
+	var m splitMeasurement
+	if split >= len(s.lines) {
+		m.endOfFile, m.indent = true, -1
+	} else {
+		m.indent = indent(s.lines[split])
+	}
+	m.preIndent = -1
+	for i := split - 1; i >= 0; i-- {
+		if m.preIndent = indent(s.lines[i]); m.preIndent != -1 {
+			break
+		}
+		m.preBlank++
+		if m.preBlank == maxBlanks {
+			m.preIndent = 0
+			break
+		}
+	}
+	m.postIndent = -1
+	for i := split + 1; i < len(s.lines); i++ {
+		if m.postIndent = indent(s.lines[i]); m.postIndent != -1 {
+			break
+		}
+		m.postBlank++
+		if m.postBlank == maxBlanks {
+			m.postIndent = 0
+			break
+		}
+	}
+	return m
+}
+
+// splitScore is how bad a place for a group is. a lower indent is better first, then a lower penalty
+type splitScore struct {
+	effectiveIndent, penalty int
+}
+
+func (s *splitScore) add(m splitMeasurement) {
+	if m.preIndent == -1 && m.preBlank == 0 {
+		s.penalty += startOfFilePenalty
+	}
+	if m.endOfFile {
+		s.penalty += endOfFilePenalty
+	}
+	//the blank lines after the split, the line right after it included
+	postBlank := 0
+	if m.indent == -1 {
+		postBlank = 1 + m.postBlank
+	}
+	totalBlank := m.preBlank + postBlank
+	s.penalty += totalBlankWeight*totalBlank + postBlankWeight*postBlank
+	ind := m.indent
+	if ind == -1 {
+		ind = m.postIndent
+	}
+	anyBlanks := totalBlank != 0
+	s.effectiveIndent += ind
+	pick := func(withBlank, without int) int {
+		if anyBlanks {
+			return withBlank
+		}
+		return without
+	}
+	switch {
+	case ind == -1, m.preIndent == -1, ind == m.preIndent:
+	case ind > m.preIndent:
+		//indented more than the line before: inside a block
+		s.penalty += pick(relativeIndentWithBlankPenalty, relativeIndentPenalty)
+	case m.postIndent != -1 && m.postIndent > ind:
+		//indented less, and the next line more: the start of a block
+		s.penalty += pick(relativeOutdentWithBlankPenalty, relativeOutdentPenalty)
+	default:
+		//the end of a block
+		s.penalty += pick(relativeDedentWithBlankPenalty, relativeDedentPenalty)
+	}
+}
+
+func (s splitScore) cmp(o splitScore) int {
+	c := 0
+	if s.effectiveIndent > o.effectiveIndent {
+		c = 1
+	} else if s.effectiveIndent < o.effectiveIndent {
+		c = -1
+	}
+	return indentWeight*c + s.penalty - o.penalty
+}
@@ -0,0 +1,339 @@
+// Package diff compares lists of lines, the way git's xdiff does. it has git's three algorithms: myers,
+// linear in space, patience and histogram, and turns their result into the hunks of a unified diff.
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//  |||LINE DIFFS||| //
+//a line diff is a script of operations that turns the old lines into the new ones: keep a line, delete one, insert one.
+//the algorithms only mark which lines of each side changed. lines are compared by class: every distinct line gets
+//a number, once whitespace is dealt with, so two lines are the same when their numbers are.
+//the changes are then slid up and down where that doesn't change the diff, to line them up with the changes of the
+//other side, or with blank lines and indentation, like git does, so both print the same patch.
+//read: https://blog.jcoglan.com/2017/02/12/the-myers-diff-algorithm-part-1/
+//and https://github.com/git/git/tree/master/xdiff
+
+// Algorithm is how a diff is worked out
+type Algorithm int
+
+const (
+	// Myers is the classic algorithm, with git's shortcuts for big files
+	Myers Algorithm = iota
+	// Minimal is myers without the shortcuts, it finds the smallest diff whatever it takes
+	Minimal
+	// Patience matches the lines that are unique on both sides first, and diffs what's between them
+	Patience
+	// Histogram is patience with the least frequent lines instead of only unique ones
+	Histogram
+)
+
+// ParseAlgorithm reads the name of an algorithm, as --diff-algorithm takes it
+func ParseAlgorithm(name string) (Algorithm, error) {
+	switch name {
+	case "myers", "default":
+		return Myers, nil
+	case "minimal":
+		return Minimal, nil
+	case "patience":
+		return Patience, nil
+	case "histogram":
+		return Histogram, nil
+	}
+	return 0, fmt.Errorf("option diff-algorithm accepts \"myers\", \"minimal\", \"patience\" and \"histogram\", not '%s'", name)
+}
+
+// Options is how lines are compared
+type Options struct {
+	Algorithm Algorithm
+	//IgnoreSpaceChange takes runs of whitespace as one space, and whitespace at the end of a line as nothing
+	IgnoreSpaceChange bool
+	//IgnoreAllSpace leaves whitespace out altogether
+	IgnoreAllSpace bool
+}
+
+// OpKind is what an operation does to a line
+type OpKind int
+
+const (
+	Equal OpKind = iota
+	Delete
+	Insert
+)
+
+// Op is one step of a line diff. A is the index of the line in the old lines, B in the new ones.
+// a delete's B, and an insert's A, is where the line would go on the other side
+type Op struct {
+	Kind OpKind
+	A, B int
+}
+
+// SplitLines cuts text into lines that keep their newline. a last line without one stays without,
+// which is how a missing newline at the end of a file shows up as a change
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// Lines finds the operations that turn a into b. deletions come before the insertions that replace them
+func Lines(a, b []string, opts Options) []Op {
+	return lines(a, b, opts, true)
+}
+
+// lines is Lines, with or without the indent heuristic, which a word diff goes without
+func lines(a, b []string, opts Options, indentHeuristic bool) []Op {
+	ida, idb := classify(a, b, opts)
+	var ca, cb []bool
+	switch opts.Algorithm {
+	case Patience:
+		ca, cb = patience(ida, idb)
+	case Histogram:
+		ca, cb = histogram(ida, idb)
+	default:
+		ca, cb = myers(ida, idb, opts.Algorithm == Minimal)
+	}
+	sa, sb := newSide(a, ida, ca), newSide(b, idb, cb)
+	compact(sa, sb, indentHeuristic)
+	compact(sb, sa, indentHeuristic)
+	return script(sa, sb)
+}
+
+// classify numbers the lines of both sides, equal lines getting the same number
+func classify(a, b []string, opts Options) ([]int, []int) {
+	classes := make(map[string]int)
+	space := func(r rune) bool { return r < 0x80 && isSpace(byte(r)) }
+	number := func(lines []string) []int {
+		ids := make([]int, len(lines))
+		for i, l := range lines {
+			key := l
+			switch {
+			case opts.IgnoreAllSpace:
+				key = strings.Join(strings.FieldsFunc(l, space), "")
+			case opts.IgnoreSpaceChange:
+				key = strings.Join(strings.FieldsFunc(l, space), " ")
+				//a line that starts with whitespace differs from one that doesn't
+				if l != "" && isSpace(l[0]) && key != "" {
+					key = " " + key
+				}
+			}
+			id, ok := classes[key]
+			if !ok {
+				id = len(classes)
+				classes[key] = id
+			}
+			ids[i] = id
+		}
+		return ids
+	}
+	return number(a), number(b)
+}
+
+// isSpace is the whitespace of git: space, tab, newline and carriage return. not vertical tabs or form feeds
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+// side is one side of a diff with the lines that changed. changed has a false on each end,
+// so changed[i+1] is line i and looking one line past either end needs no check
+type side struct {
+	lines   []string
+	ids     []int
+	changed []bool
+}
+
+func newSide(lines []string, ids []int, changed []bool) *side {
+	s := &side{lines: lines, ids: ids, changed: make([]bool, len(lines)+2)}
+	copy(s.changed[1:], changed)
+	return s
+}
+
+func (s *side) isChanged(i int) bool {
+	return s.changed[i+1]
+}
+
+func (s *side) mark(i int, changed bool) {
+	s.changed[i+1] = changed
+}
+
+// script walks the changed lines of both sides into operations
+func script(a, b *side) []Op {
+	var ops []Op
+	i, j := 0, 0
+	for i < len(a.ids) || j < len(b.ids) {
+		switch {
+		case i < len(a.ids) && a.isChanged(i):
+			ops = append(ops, Op{Delete, i, j})
+			i++
+		case j < len(b.ids) && b.isChanged(j):
+			ops = append(ops, Op{Insert, i, j})
+			j++
+		default:
+			ops = append(ops, Op{Equal, i, j})
+			i, j = i+1, j+1
+		}
+	}
+	return ops
+}
+
+// TrimTail leaves out the lines a and b end with in common, for a diff that shows no context: git cuts the
+// common end of the files in blocks of 1024 bytes, back to a line, before it diffs them. it saves work, and it
+// changes which lines are unique, so patience and histogram, and even myers, can pick other matches without it
+func TrimTail(a, b []string) ([]string, []string) {
+	const blk = 1024
+	sa, sb := strings.Join(a, ""), strings.Join(b, "")
+	ap, bp := len(sa), len(sb)
+	trimmed := 0
+	for blk+trimmed <= len(sa) && blk+trimmed <= len(sb) && sa[ap-blk:ap] == sb[bp-blk:bp] {
+		trimmed += blk
+		ap, bp = ap-blk, bp-blk
+	}
+	//a line cut in the middle is kept whole
+	recovered := 0
+	for recovered < trimmed {
+		recovered++
+		if sa[ap+recovered-1] == '\n' {
+			break
+		}
+	}
+	n := strings.Count(sa[ap+recovered:], "\n")
+	if !strings.HasSuffix(sa, "\n") && ap+recovered < len(sa) {
+		n++
+	}
+	return a[:len(a)-n], b[:len(b)-n]
+}
+
+// Hunk is a part of a line diff with its context. OldStart and NewStart are 0-based
+type Hunk struct {
+	OldStart, OldLines int
+	NewStart, NewLines int
+	Ops                []Op
+}
+
+// Hunks groups the changes of a line diff into hunks with context unchanged lines around them.
+// two changes closer than twice the context go in the same hunk, like in git
+func Hunks(ops []Op, context int) []Hunk {
+	var hunks []Hunk
+	//each hunk is a range of ops: from the first change minus the context, to the last one plus the context
+	i := 0
+	for i < len(ops) {
+		if ops[i].Kind == Equal {
+			i++
+			continue
+		}
+		start := i - context
+		if start < 0 {
+			start = 0
+		}
+		end := i
+		for {
+			for end < len(ops) && ops[end].Kind != Equal {
+				end++
+			}
+			//the next change joins this hunk when the unchanged lines between them are at most twice the context
+			next := end
+			for next < len(ops) && ops[next].Kind == Equal {
+				next++
+			}
+			if next < len(ops) && next-end <= 2*context {
+				end = next
+				continue
+			}
+			break
+		}
+		stop := end + context
+		if stop > len(ops) {
+			stop = len(ops)
+		}
+		hunks = append(hunks, newHunk(ops[start:stop]))
+		i = end
+	}
+	return hunks
+}
+
+// newHunk counts the lines of each side in the ops
+func newHunk(ops []Op) Hunk {
+	h := Hunk{Ops: ops, OldStart: ops[0].A, NewStart: ops[0].B}
+	for _, op := range ops {
+		if op.Kind != Insert {
+			h.OldLines++
+		}
+		if op.Kind != Delete {
+			h.NewLines++
+		}
+	}
+	return h
+}
+
+// Header is the @@ line of a hunk, with the function it's in, if one is found above it in a
+func (h Hunk) Header(a []string) string {
+	header := fmt.Sprintf("@@ -%s +%s @@", hunkRange(h.OldStart, h.OldLines), hunkRange(h.NewStart, h.NewLines))
+	if f := funcLine(a, h.OldStart); f != "" {
+		header += " " + f
+	}
+	return header
+}
+
+// hunkRange is the -l,s or +l,s of a hunk header. a single line leaves out the count, and an empty side
+// names the line before it
+func hunkRange(start, lines int) string {
+	switch lines {
+	case 0:
+		return fmt.Sprintf("%d,0", start)
+	case 1:
+		return fmt.Sprintf("%d", start+1)
+	}
+	return fmt.Sprintf("%d,%d", start+1, lines)
+}
+
+// funcLine finds the line git shows after a hunk header: the last line above the hunk that starts with a letter,
+// an underscore or a dollar sign, which is most often where the function begins
+func funcLine(lines []string, before int) string {
+	for i := before - 1; i >= 0; i-- {
+		l := lines[i]
+		if l == "" {
+			continue
+		}
+		c := l[0]
+		if c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c == '_' || c == '$' {
+			l = strings.TrimRight(l, " \t\r\n")
+			if len(l) > 80 {
+				l = l[:80]
+			}
+			return l
+		}
+	}
+	return ""
+}
+
+// WriteUnified writes the hunks of a diff of a into b in the unified format. unchanged lines are taken from b,
+// like git does, which shows when whitespace is ignored
+func WriteUnified(w io.Writer, a, b []string, hunks []Hunk) {
+	line := func(prefix byte, l string) {
+		fmt.Fprintf(w, "%c%s", prefix, l)
+		if !strings.HasSuffix(l, "\n") {
+			fmt.Fprint(w, "\n\\ No newline at end of file\n")
+		}
+	}
+	for _, h := range hunks {
+		fmt.Fprintln(w, h.Header(a))
+		for _, op := range h.Ops {
+			switch op.Kind {
+			case Equal:
+				line(' ', b[op.B])
+			case Delete:
+				line('-', a[op.A])
+			case Insert:
+				line('+', b[op.B])
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package diff_test
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/OLUWAMUYIWA/got/pkg/diff"
+	"github.com/stretchr/testify/assert"
+)
+
+// the goldens in testdata were made by git itself: each case is a pair of files, name.a and name.b, and what
+// `git diff --no-index` printed for them with each option set, in name.<flags>.diff, without the header lines
+
+var flagSets = map[string]diff.Options{
+	"myers":       {Algorithm: diff.Myers},
+	"minimal":     {Algorithm: diff.Minimal},
+	"patience":    {Algorithm: diff.Patience},
+	"histogram":   {Algorithm: diff.Histogram},
+	"myers-b":     {Algorithm: diff.Myers, IgnoreSpaceChange: true},
+	"myers-w":     {Algorithm: diff.Myers, IgnoreAllSpace: true},
+	"histogram-w": {Algorithm: diff.Histogram, IgnoreAllSpace: true},
+}
+
+// gitFlags is how git is asked for what a flag set stands for
+func gitFlags(name string) []string {
+	algo, space, _ := strings.Cut(name, "-")
+	args := []string{"--diff-algorithm=" + algo}
+	if space != "" {
+		args = append(args, "-"+space)
+	}
+	return args
+}
+
+var wordStyles = map[string]diff.WordStyle{
+	"plain":     diff.WordsPlain,
+	"color":     diff.WordsColor,
+	"porcelain": diff.WordsPorcelain,
+}
+
+// unified is the hunks of the diff of a and b, as lines or, with a style, as words
+func unified(a, b string, opts diff.Options, context int, style diff.WordStyle) string {
+	la, lb := diff.SplitLines(a), diff.SplitLines(b)
+	ta, tb := la, lb
+	if context == 0 {
+		ta, tb = diff.TrimTail(la, lb)
+	}
+	var buf bytes.Buffer
+	hunks := diff.Hunks(diff.Lines(ta, tb, opts), context)
+	if style != 0 {
+		diff.WriteWords(&buf, la, lb, hunks, style)
+	} else {
+		diff.WriteUnified(&buf, la, lb, hunks)
+	}
+	return buf.String()
+}
+
+func TestGolden(t *testing.T) {
+	assert := assert.New(t)
+	cases, err := filepath.Glob(filepath.Join("testdata", "*.a"))
+	assert.NoError(err)
+	assert.NotEmpty(cases)
+	for _, c := range cases {
+		name := strings.TrimSuffix(c, ".a")
+		a, err := os.ReadFile(name + ".a")
+		assert.NoError(err)
+		b, err := os.ReadFile(name + ".b")
+		assert.NoError(err)
+		for flags, opts := range flagSets {
+			want, err := os.ReadFile(name + "." + flags + ".diff")
+			assert.NoError(err)
+			assert.Equal(string(want), unified(string(a), string(b), opts, 3, 0), "%s with %s", name, flags)
+		}
+		for mode, style := range wordStyles {
+			want, err := os.ReadFile(name + ".words-" + mode + ".diff")
+			assert.NoError(err)
+			assert.Equal(string(want), unified(string(a), string(b), diff.Options{}, 3, style), "%s with --word-diff=%s", name, mode)
+		}
+	}
+}
+
+// gitDiff runs git on the two texts, and strips what it prints before the first hunk
+func gitDiff(t *testing.T, dir, a, b string, args ...string) string {
+	pa, pb := filepath.Join(dir, "a"), filepath.Join(dir, "b")
+	if err := os.WriteFile(pa, []byte(a), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(pb, []byte(b), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command("git", append(append([]string{"diff", "--no-index", "--no-color", "--no-ext-diff"}, args...), pa, pb)...)
+	cmd.Env = append(os.Environ(), "GIT_CONFIG_NOSYSTEM=1", "HOME="+dir)
+	out, err := cmd.Output()
+	if ee, ok := err.(*exec.ExitError); err != nil && !(ok && ee.ExitCode() == 1) {
+		t.Fatalf("git diff: %v", err)
+	}
+	s := string(out)
+	for _, start := range []string{"\n@@ ", "\n\033[36m@@ "} {
+		if i := strings.Index(s, start); i >= 0 {
+			return s[i+1:]
+		}
+	}
+	return ""
+}
+
+// randomText makes a file out of few distinct lines, so there's plenty that repeats, with blank lines and
+// indentation for the compaction heuristics to work on, and whitespace that changes for -b and -w
+func randomText(r *rand.Rand, n int) string {
+	words := []string{"a", "b", "c", "d", "e", "f", "{", "}", "return x", "if y {", "x\vy z", "a b  c d"}
+	var s strings.Builder
+	for i := 0; i < n; i++ {
+		if r.Intn(6) == 0 {
+			s.WriteString("\n")
+			continue
+		}
+		s.WriteString(strings.Repeat("\t", r.Intn(3)))
+		w := words[r.Intn(len(words))]
+		if r.Intn(8) == 0 {
+			w = strings.Replace(w, " ", "  ", 1)
+		}
+		if r.Intn(8) == 0 {
+			w += " "
+		}
+		s.WriteString(w + "\n")
+	}
+	if r.Intn(5) == 0 && s.Len() > 0 {
+		return strings.TrimSuffix(s.String(), "\n")
+	}
+	return s.String()
+}
+
+// mutate edits a text the way files get edited: lines deleted, added and moved around
+func mutate(r *rand.Rand, text string) string {
+	lines := diff.SplitLines(text)
+	for n := r.Intn(8); n >= 0; n-- {
+		switch i := r.Intn(len(lines) + 1); r.Intn(3) {
+		case 0:
+			if i < len(lines) {
+				lines = append(lines[:i], lines[i+1:]...)
+			}
+		case 1:
+			more := diff.SplitLines(randomText(r, 1+r.Intn(4)))
+			lines = append(lines[:i], append(more, lines[i:]...)...)
+		default:
+			if j := r.Intn(len(lines) + 1); i < j {
+				moved := append([]string(nil), lines[i:j]...)
+				rest := append(append([]string(nil), lines[:i]...), lines[j:]...)
+				k := r.Intn(len(rest) + 1)
+				lines = append(append(rest[:k:k], moved...), rest[k:]...)
+			}
+		}
+	}
+	//a missing newline can only be at the very end
+	var out strings.Builder
+	for _, l := range lines {
+		out.WriteString(strings.TrimSuffix(l, "\n") + "\n")
+	}
+	if r.Intn(5) == 0 {
+		return strings.TrimSuffix(out.String(), "\n")
+	}
+	return out.String()
+}
+
+func TestAgainstGit(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git isn't installed")
+	}
+	if testing.Short() {
+		t.Skip("comparing with git takes a while")
+	}
+	dir := t.TempDir()
+	r := rand.New(rand.NewSource(47))
+	for i := 0; i < 150; i++ {
+		a := randomText(r, r.Intn(60))
+		b := mutate(r, a)
+		if r.Intn(4) == 0 {
+			b = randomText(r, r.Intn(60))
+		}
+		context := r.Intn(5)
+		for flags, opts := range flagSets {
+			want := gitDiff(t, dir, a, b, append(gitFlags(flags), fmt.Sprintf("-U%d", context))...)
+			if got := unified(a, b, opts, context, 0); got != want {
+				t.Fatalf("case %d with %s -U%d differs from git\n--- a\n%s\n--- b\n%s\n--- git\n%s\n--- got\n%s", i, flags, context, a, b, want, got)
+			}
+		}
+		for mode, style := range wordStyles {
+			want := gitDiff(t, dir, a, b, "--word-diff="+mode, fmt.Sprintf("-U%d", context))
+			if got := unified(a, b, diff.Options{}, context, style); got != want {
+				t.Fatalf("case %d with --word-diff=%s -U%d differs from git\n--- a\n%s\n--- b\n%s\n--- git\n%q\n--- got\n%q", i, mode, context, a, b, want, got)
+			}
+		}
+	}
+}
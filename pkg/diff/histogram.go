@@ -0,0 +1,179 @@
+package diff
+
+//  |||HISTOGRAM||| //
+//histogram diff is patience diff that makes do with rare lines when there are no unique ones. it counts how often
+//each line of the first side appears, then looks for the longest run of equal lines on both sides that has the
+//rarest lines in it. the diff is cut around that run, and both pieces are diffed the same way. a piece whose lines
+//are all too common falls back to myers. lines are numbered from 1, like in git's xhistogram.c.
+//read: https://github.com/git/git/blob/master/xdiff/xhistogram.c
+
+// maxChainLength is how many times a line may appear before it's too common to anchor a run
+const maxChainLength = 64
+
+// histRecord is a line of the first side: where it first appears, and how often
+type histRecord struct {
+	ptr, cnt int
+}
+
+// histIndex is the lines of the first side of a piece. next is the next line after each one that's the same,
+// 0 when there's none
+type histIndex struct {
+	records   map[int]*histRecord
+	lineMap   []*histRecord
+	next      []int
+	ptrShift  int
+	cnt       int
+	hasCommon bool
+}
+
+// region is a run of equal lines, from begin to end, both included
+type region struct {
+	begin1, end1 int
+	begin2, end2 int
+}
+
+type histogramEnv struct {
+	a, b   []int
+	ca, cb []bool
+}
+
+func histogram(a, b []int) ([]bool, []bool) {
+	e := &histogramEnv{a: a, b: b, ca: make([]bool, len(a)), cb: make([]bool, len(b))}
+	e.diff(1, len(a), 1, len(b))
+	return e.ca, e.cb
+}
+
+func (e *histogramEnv) cmp(line1, line2 int) bool {
+	return e.a[line1-1] == e.b[line2-1]
+}
+
+// scan counts the lines of the first side, from the last one up, so each record ends up at the first line
+func (e *histogramEnv) scan(idx *histIndex, line1, count1 int) {
+	for ptr := line1 + count1 - 1; line1 <= ptr; ptr-- {
+		id := e.a[ptr-1]
+		if rec, ok := idx.records[id]; ok {
+			idx.next[ptr-idx.ptrShift] = rec.ptr
+			rec.ptr = ptr
+			rec.cnt++
+			idx.lineMap[ptr-idx.ptrShift] = rec
+			continue
+		}
+		rec := &histRecord{ptr: ptr, cnt: 1}
+		idx.records[id] = rec
+		idx.lineMap[ptr-idx.ptrShift] = rec
+	}
+}
+
+// tryLCS grows a run around each line of the first side equal to line bPtr of the second, and keeps it if it's
+// longer, or has rarer lines, than the best so far. it returns the next line of the second side to try
+func (e *histogramEnv) tryLCS(idx *histIndex, lcs *region, bPtr, line1, count1, line2, count2 int) int {
+	bNext := bPtr + 1
+	rec := idx.records[e.b[bPtr-1]]
+	if rec == nil {
+		return bNext
+	}
+	if rec.cnt > idx.cnt {
+		idx.hasCommon = true
+		return bNext
+	}
+	as := rec.ptr
+	idx.hasCommon = true
+	for {
+		np := idx.next[as-idx.ptrShift]
+		bs, ae, be := bPtr, as, bPtr
+		rc := rec.cnt
+		for line1 < as && line2 < bs && e.cmp(as-1, bs-1) {
+			as, bs = as-1, bs-1
+			if 1 < rc {
+				rc = min(rc, idx.lineMap[as-idx.ptrShift].cnt)
+			}
+		}
+		for ae < line1+count1-1 && be < line2+count2-1 && e.cmp(ae+1, be+1) {
+			ae, be = ae+1, be+1
+			if 1 < rc {
+				rc = min(rc, idx.lineMap[ae-idx.ptrShift].cnt)
+			}
+		}
+		if bNext <= be {
+			bNext = be + 1
+		}
+		if lcs.end1-lcs.begin1 < ae-as || rc < idx.cnt {
+			*lcs = region{begin1: as, end1: ae, begin2: bs, end2: be}
+			idx.cnt = rc
+		}
+		if np == 0 {
+			return bNext
+		}
+		for np <= ae {
+			if np = idx.next[np-idx.ptrShift]; np == 0 {
+				return bNext
+			}
+		}
+		as = np
+	}
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+// findLCS looks for the best run of the piece. it tells whether the piece has to fall back to myers
+func (e *histogramEnv) findLCS(lcs *region, line1, count1, line2, count2 int) bool {
+	idx := &histIndex{
+		records:  make(map[int]*histRecord),
+		lineMap:  make([]*histRecord, count1),
+		next:     make([]int, count1),
+		ptrShift: line1,
+	}
+	e.scan(idx, line1, count1)
+	idx.cnt = maxChainLength + 1
+	for bPtr := line2; bPtr <= line2+count2-1; {
+		bPtr = e.tryLCS(idx, lcs, bPtr, line1, count1, line2, count2)
+	}
+	return idx.hasCommon && maxChainLength < idx.cnt
+}
+
+// diff marks the changes between the count1 lines from line1 and the count2 lines from line2
+func (e *histogramEnv) diff(line1, count1, line2, count2 int) {
+	for {
+		if count1 <= 0 && count2 <= 0 {
+			return
+		}
+		if count1 == 0 {
+			for i := 0; i < count2; i++ {
+				e.cb[line2-1+i] = true
+			}
+			return
+		}
+		if count2 == 0 {
+			for i := 0; i < count1; i++ {
+				e.ca[line1-1+i] = true
+			}
+			return
+		}
+		var lcs region
+		if e.findLCS(&lcs, line1, count1, line2, count2) {
+			fallBack(e.a, e.b, e.ca, e.cb, line1, count1, line2, count2)
+			return
+		}
+		if lcs.begin1 == 0 && lcs.begin2 == 0 {
+			//nothing in common
+			for i := 0; i < count1; i++ {
+				e.ca[line1-1+i] = true
+			}
+			for i := 0; i < count2; i++ {
+				e.cb[line2-1+i] = true
+			}
+			return
+		}
+		e.diff(line1, lcs.begin1-line1, line2, lcs.begin2-line2)
+		//what's after the run is diffed in this loop
+		count1 = line1 + count1 - 1 - lcs.end1
+		line1 = lcs.end1 + 1
+		count2 = line2 + count2 - 1 - lcs.end2
+		line2 = lcs.end2 + 1
+	}
+}
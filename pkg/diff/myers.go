@@ -0,0 +1,364 @@
+package diff
+
+import "math"
+
+//  |||MYERS||| //
+//myers finds the shortest edit script by following the diagonals of the edit graph from both ends at once, until
+//the two searches meet in the middle snake. the halves on either side of it are diffed the same way, so only two
+//rows of diagonals are ever kept: linear space. git adds shortcuts: lines the other side doesn't have are changes
+//before any search starts, and a search that costs too much settles for a good enough split.
+//read: http://www.xmailserver.org/diff2.pdf and https://github.com/git/git/blob/master/xdiff/xdiffi.c
+
+const (
+	//maxEqLimit caps the occurrences a line may have before it counts as too common to anchor anything
+	maxEqLimit = 1024
+	//simscanWindow is how far around a too common line the scan for unmatched lines goes
+	simscanWindow = 100
+	//kpdisRun is how many unmatched lines per too common one get it discarded
+	kpdisRun = 4
+	//maxCostMin is the least edit cost the search goes to before settling for the furthest reaching path
+	maxCostMin = 256
+	//heurMinCost is the edit cost above which a long enough snake is taken as a split
+	heurMinCost = 256
+	//snakeCnt is how long a snake has to be to be a good split
+	snakeCnt = 20
+	kHeur    = 4
+)
+
+// bogosqrt is git's rough square root, a power of two
+func bogosqrt(n int) int {
+	i := 1
+	for ; n > 0; n >>= 2 {
+		i <<= 1
+	}
+	return i
+}
+
+// myers marks the lines of a and b that changed. minimal turns off the shortcuts of the search
+func myers(a, b []int, minimal bool) ([]bool, []bool) {
+	ca, cb := make([]bool, len(a)), make([]bool, len(b))
+	//the common lines at both ends can't be changes
+	start, lim := 0, len(a)
+	if len(b) < lim {
+		lim = len(b)
+	}
+	for start < lim && a[start] == b[start] {
+		start++
+	}
+	end := 0
+	for end < lim-start && a[len(a)-1-end] == b[len(b)-1-end] {
+		end++
+	}
+	counts := func(ids []int) map[int]int {
+		n := make(map[int]int)
+		for _, id := range ids {
+			n[id]++
+		}
+		return n
+	}
+	countA, countB := counts(a), counts(b)
+	ha, ia := discard(a, start, len(a)-end, countB, ca)
+	hb, ib := discard(b, start, len(b)-end, countA, cb)
+
+	n := len(ha) + len(hb) + 3
+	e := &myersEnv{
+		a: ha, b: hb, ia: ia, ib: ib, ca: ca, cb: cb,
+		kvdf: make([]int, n), kvdb: make([]int, n), koff: len(hb) + 1,
+		mxcost: bogosqrt(n),
+	}
+	if e.mxcost < maxCostMin {
+		e.mxcost = maxCostMin
+	}
+	e.compare(0, len(ha), 0, len(hb), minimal)
+	return ca, cb
+}
+
+// discard leaves out of the search the lines between start and end that the other side doesn't have, which are
+// changes anyway, and the ones it has too many of when they sit among unmatched lines. those are marked changed.
+// what's left is returned, with where each line was
+func discard(ids []int, start, end int, other map[int]int, changed []bool) ([]int, []int) {
+	mlim := bogosqrt(len(ids))
+	if mlim > maxEqLimit {
+		mlim = maxEqLimit
+	}
+	dis := make([]byte, len(ids)+1)
+	for i := start; i < end; i++ {
+		switch nm := other[ids[i]]; {
+		case nm == 0:
+			dis[i] = 0
+		case nm >= mlim:
+			dis[i] = 2
+		default:
+			dis[i] = 1
+		}
+	}
+	var kept, index []int
+	for i := start; i < end; i++ {
+		if dis[i] == 1 || dis[i] == 2 && !cleanMultimatch(dis, i, start, end-1) {
+			kept = append(kept, ids[i])
+			index = append(index, i)
+		} else {
+			changed[i] = true
+		}
+	}
+	return kept, index
+}
+
+// cleanMultimatch tells whether a too common line at i is surrounded by enough unmatched lines to be left out
+func cleanMultimatch(dis []byte, i, s, e int) bool {
+	if i-s > simscanWindow {
+		s = i - simscanWindow
+	}
+	if e-i > simscanWindow {
+		e = i + simscanWindow
+	}
+	rdis0, rpdis0 := 0, 1
+	for r := 1; i-r >= s; r++ {
+		if dis[i-r] == 0 {
+			rdis0++
+		} else if dis[i-r] == 2 {
+			rpdis0++
+		} else {
+			break
+		}
+	}
+	//only too common lines before it: keep it
+	if rdis0 == 0 {
+		return false
+	}
+	rdis1, rpdis1 := 0, 1
+	for r := 1; i+r <= e; r++ {
+		if dis[i+r] == 0 {
+			rdis1++
+		} else if dis[i+r] == 2 {
+			rpdis1++
+		} else {
+			break
+		}
+	}
+	if rdis1 == 0 {
+		return false
+	}
+	rdis1 += rdis0
+	rpdis1 += rpdis0
+	return rpdis1*kpdisRun < rpdis1+rdis1
+}
+
+// myersEnv is the state of a search: the lines left after discarding, where they came from, the changes found,
+// and the furthest reaching points of the forward and backward searches, by diagonal
+type myersEnv struct {
+	a, b       []int
+	ia, ib     []int
+	ca, cb     []bool
+	kvdf, kvdb []int
+	//koff is the index of diagonal 0 in kvdf and kvdb, diagonals go below zero
+	koff   int
+	mxcost int
+}
+
+// split is where a box is cut in two, and whether each half must be diffed without shortcuts
+type split struct {
+	i1, i2       int
+	minLo, minHi bool
+}
+
+// compare diffs a[off1:lim1] with b[off2:lim2]
+func (e *myersEnv) compare(off1, lim1, off2, lim2 int, minimal bool) {
+	//shrink the box by the common lines at both ends
+	for off1 < lim1 && off2 < lim2 && e.a[off1] == e.b[off2] {
+		off1, off2 = off1+1, off2+1
+	}
+	for off1 < lim1 && off2 < lim2 && e.a[lim1-1] == e.b[lim2-1] {
+		lim1, lim2 = lim1-1, lim2-1
+	}
+	//with one side empty, everything on the other changed
+	switch {
+	case off1 == lim1:
+		for ; off2 < lim2; off2++ {
+			e.cb[e.ib[off2]] = true
+		}
+	case off2 == lim2:
+		for ; off1 < lim1; off1++ {
+			e.ca[e.ia[off1]] = true
+		}
+	default:
+		spl := e.split(off1, lim1, off2, lim2, minimal)
+		e.compare(off1, spl.i1, off2, spl.i2, spl.minLo)
+		e.compare(spl.i1, lim1, spl.i2, lim2, spl.minHi)
+	}
+}
+
+// split looks for the middle snake of the box: the forward search runs from the top left corner, the backward one
+// from the bottom right, one more edit each round, until a diagonal of one reaches past the other's
+func (e *myersEnv) split(off1, lim1, off2, lim2 int, minimal bool) split {
+	a, b, k := e.a, e.b, e.koff
+	kvdf, kvdb := e.kvdf, e.kvdb
+	dmin, dmax := off1-lim2, lim1-off2
+	fmid, bmid := off1-off2, lim1-lim2
+	odd := (fmid-bmid)&1 != 0
+	fmin, fmax := fmid, fmid
+	bmin, bmax := bmid, bmid
+	kvdf[k+fmid] = off1
+	kvdb[k+bmid] = lim1
+
+	for ec := 1; ; ec++ {
+		gotSnake := false
+		//widen the diagonals searched by one on each side, or shrink at the edges of the box
+		if fmin > dmin {
+			fmin--
+			kvdf[k+fmin-1] = -1
+		} else {
+			fmin++
+		}
+		if fmax < dmax {
+			fmax++
+			kvdf[k+fmax+1] = -1
+		} else {
+			fmax--
+		}
+		for d := fmax; d >= fmin; d -= 2 {
+			var i1 int
+			if kvdf[k+d-1] >= kvdf[k+d+1] {
+				i1 = kvdf[k+d-1] + 1
+			} else {
+				i1 = kvdf[k+d+1]
+			}
+			prev1 := i1
+			i2 := i1 - d
+			for i1 < lim1 && i2 < lim2 && a[i1] == b[i2] {
+				i1, i2 = i1+1, i2+1
+			}
+			if i1-prev1 > snakeCnt {
+				gotSnake = true
+			}
+			kvdf[k+d] = i1
+			if odd && bmin <= d && d <= bmax && kvdb[k+d] <= i1 {
+				return split{i1, i2, true, true}
+			}
+		}
+
+		if bmin > dmin {
+			bmin--
+			kvdb[k+bmin-1] = math.MaxInt
+		} else {
+			bmin++
+		}
+		if bmax < dmax {
+			bmax++
+			kvdb[k+bmax+1] = math.MaxInt
+		} else {
+			bmax--
+		}
+		for d := bmax; d >= bmin; d -= 2 {
+			var i1 int
+			if kvdb[k+d-1] < kvdb[k+d+1] {
+				i1 = kvdb[k+d-1]
+			} else {
+				i1 = kvdb[k+d+1] - 1
+			}
+			prev1 := i1
+			i2 := i1 - d
+			for i1 > off1 && i2 > off2 && a[i1-1] == b[i2-1] {
+				i1, i2 = i1-1, i2-1
+			}
+			if prev1-i1 > snakeCnt {
+				gotSnake = true
+			}
+			kvdb[k+d] = i1
+			if !odd && fmin <= d && d <= fmax && i1 <= kvdf[k+d] {
+				return split{i1, i2, true, true}
+			}
+		}
+
+		if minimal {
+			continue
+		}
+
+		//past the trigger cost, a long snake far enough from both corners, and not too far off the middle
+		//diagonal, is a good enough split
+		if gotSnake && ec > heurMinCost {
+			best := 0
+			var spl split
+			for d := fmax; d >= fmin; d -= 2 {
+				dd := d - fmid
+				if dd < 0 {
+					dd = -dd
+				}
+				i1 := kvdf[k+d]
+				i2 := i1 - d
+				v := (i1 - off1) + (i2 - off2) - dd
+				if v > kHeur*ec && v > best && off1+snakeCnt <= i1 && i1 < lim1 && off2+snakeCnt <= i2 && i2 < lim2 {
+					for n := 1; a[i1-n] == b[i2-n]; n++ {
+						if n == snakeCnt {
+							best = v
+							spl.i1, spl.i2 = i1, i2
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				spl.minLo = true
+				return spl
+			}
+			for d := bmax; d >= bmin; d -= 2 {
+				dd := d - bmid
+				if dd < 0 {
+					dd = -dd
+				}
+				i1 := kvdb[k+d]
+				i2 := i1 - d
+				v := (lim1 - i1) + (lim2 - i2) - dd
+				if v > kHeur*ec && v > best && off1 < i1 && i1 <= lim1-snakeCnt && off2 < i2 && i2 <= lim2-snakeCnt {
+					for n := 0; a[i1+n] == b[i2+n]; n++ {
+						if n == snakeCnt-1 {
+							best = v
+							spl.i1, spl.i2 = i1, i2
+							break
+						}
+					}
+				}
+			}
+			if best > 0 {
+				spl.minHi = true
+				return spl
+			}
+		}
+
+		//enough is enough: take the furthest reaching path of either search
+		if ec >= e.mxcost {
+			fbest, fbest1 := -1, -1
+			for d := fmax; d >= fmin; d -= 2 {
+				i1 := kvdf[k+d]
+				if i1 > lim1 {
+					i1 = lim1
+				}
+				i2 := i1 - d
+				if lim2 < i2 {
+					i1, i2 = lim2+d, lim2
+				}
+				if fbest < i1+i2 {
+					fbest, fbest1 = i1+i2, i1
+				}
+			}
+			bbest, bbest1 := math.MaxInt, math.MaxInt
+			for d := bmax; d >= bmin; d -= 2 {
+				i1 := kvdb[k+d]
+				if i1 < off1 {
+					i1 = off1
+				}
+				i2 := i1 - d
+				if i2 < off2 {
+					i1, i2 = off2+d, off2
+				}
+				if i1+i2 < bbest {
+					bbest, bbest1 = i1+i2, i1
+				}
+			}
+			if (lim1+lim2)-bbest < fbest-(off1+off2) {
+				return split{i1: fbest1, i2: fbest - fbest1, minLo: true}
+			}
+			return split{i1: bbest1, i2: bbest - bbest1, minHi: true}
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package diff
+
+//  |||PATIENCE||| //
+//patience diff takes the lines that appear exactly once on each side, and keeps the longest run of them that is
+//in the same order on both sides. those are sure to be the same line, so the diff is cut at each of them and the
+//pieces in between are diffed the same way. a piece without unique lines falls back to myers.
+//lines are numbered from 1 here, like in git's xpatience.c, so 0 is no line.
+//read: https://bramcohen.livejournal.com/73318.html and https://github.com/git/git/blob/master/xdiff/xpatience.c
+
+// nonUnique is the line2 of a line that isn't unique on one side or the other
+const nonUnique = -1
+
+// patienceEntry is a line of the first side, by class, in the order they first appear
+type patienceEntry struct {
+	line1, line2   int
+	next, previous *patienceEntry
+}
+
+// patienceMap is the unique lines of a piece of the diff
+type patienceMap struct {
+	entries     map[int]*patienceEntry
+	first, last *patienceEntry
+	hasMatches  bool
+}
+
+type patienceEnv struct {
+	a, b   []int
+	ca, cb []bool
+}
+
+func patience(a, b []int) ([]bool, []bool) {
+	e := &patienceEnv{a: a, b: b, ca: make([]bool, len(a)), cb: make([]bool, len(b))}
+	e.diff(1, len(a), 1, len(b))
+	return e.ca, e.cb
+}
+
+func (e *patienceEnv) match(line1, line2 int) bool {
+	return e.a[line1-1] == e.b[line2-1]
+}
+
+// fill finds the lines of the piece that are unique on both sides
+func (e *patienceEnv) fill(line1, count1, line2, count2 int) *patienceMap {
+	m := &patienceMap{entries: make(map[int]*patienceEntry)}
+	for i := line1; i < line1+count1; i++ {
+		id := e.a[i-1]
+		if entry, ok := m.entries[id]; ok {
+			entry.line2 = nonUnique
+			continue
+		}
+		entry := &patienceEntry{line1: i}
+		m.entries[id] = entry
+		if m.first == nil {
+			m.first = entry
+		}
+		if m.last != nil {
+			m.last.next = entry
+			entry.previous = m.last
+		}
+		m.last = entry
+	}
+	for i := line2; i < line2+count2; i++ {
+		entry, ok := m.entries[e.b[i-1]]
+		if !ok {
+			continue
+		}
+		m.hasMatches = true
+		if entry.line2 != 0 {
+			entry.line2 = nonUnique
+		} else {
+			entry.line2 = i
+		}
+	}
+	return m
+}
+
+// longestSequence is the longest run of unique lines in the same order on both sides. it's a patience sort:
+// each line goes on the leftmost pile whose top is after it on the second side, pointing at the top of the pile
+// before. the chain from the top of the last pile is the run
+func (m *patienceMap) longestSequence() *patienceEntry {
+	var sequence []*patienceEntry
+	for entry := m.first; entry != nil; entry = entry.next {
+		if entry.line2 == 0 || entry.line2 == nonUnique {
+			continue
+		}
+		left, right := -1, len(sequence)
+		for left+1 < right {
+			middle := left + (right-left)/2
+			if sequence[middle].line2 > entry.line2 {
+				right = middle
+			} else {
+				left = middle
+			}
+		}
+		entry.previous = nil
+		if left >= 0 {
+			entry.previous = sequence[left]
+		}
+		if left+1 == len(sequence) {
+			sequence = append(sequence, entry)
+		} else {
+			sequence[left+1] = entry
+		}
+	}
+	if len(sequence) == 0 {
+		return nil
+	}
+	entry := sequence[len(sequence)-1]
+	entry.next = nil
+	for entry.previous != nil {
+		entry.previous.next = entry
+		entry = entry.previous
+	}
+	return entry
+}
+
+// walk diffs the pieces between the lines of the run, growing the run by the equal lines around it
+func (e *patienceEnv) walk(first *patienceEntry, line1, count1, line2, count2 int) {
+	end1, end2 := line1+count1, line2+count2
+	for {
+		var next1, next2 int
+		if first != nil {
+			next1, next2 = first.line1, first.line2
+			for next1 > line1 && next2 > line2 && e.match(next1-1, next2-1) {
+				next1, next2 = next1-1, next2-1
+			}
+		} else {
+			next1, next2 = end1, end2
+		}
+		for line1 < next1 && line2 < next2 && e.match(line1, line2) {
+			line1, line2 = line1+1, line2+1
+		}
+		if next1 > line1 || next2 > line2 {
+			e.diff(line1, next1-line1, line2, next2-line2)
+		}
+		if first == nil {
+			return
+		}
+		for first.next != nil && first.next.line1 == first.line1+1 && first.next.line2 == first.line2+1 {
+			first = first.next
+		}
+		line1, line2 = first.line1+1, first.line2+1
+		first = first.next
+	}
+}
+
+// diff marks the changes between the count1 lines from line1 and the count2 lines from line2
+func (e *patienceEnv) diff(line1, count1, line2, count2 int) {
+	if count1 == 0 {
+		for ; count2 > 0; count2, line2 = count2-1, line2+1 {
+			e.cb[line2-1] = true
+		}
+		return
+	}
+	if count2 == 0 {
+		for ; count1 > 0; count1, line1 = count1-1, line1+1 {
+			e.ca[line1-1] = true
+		}
+		return
+	}
+	m := e.fill(line1, count1, line2, count2)
+	if !m.hasMatches {
+		for i := 0; i < count1; i++ {
+			e.ca[line1-1+i] = true
+		}
+		for i := 0; i < count2; i++ {
+			e.cb[line2-1+i] = true
+		}
+		return
+	}
+	if first := m.longestSequence(); first != nil {
+		e.walk(first, line1, count1, line2, count2)
+		return
+	}
+	fallBack(e.a, e.b, e.ca, e.cb, line1, count1, line2, count2)
+}
+
+// fallBack diffs a piece with myers, as if it were two whole files. lines are numbered from 1
+func fallBack(a, b []int, ca, cb []bool, line1, count1, line2, count2 int) {
+	sa, sb := myers(a[line1-1:line1-1+count1], b[line2-1:line2-1+count2], false)
+	copy(ca[line1-1:], sa)
+	copy(cb[line2-1:], sb)
+}
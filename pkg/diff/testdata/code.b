@@ -0,0 +1,24 @@
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func add(a, b int) int {
+	return a + b
+}
+
+func sub(a, b int) int {
+	return a - b
+}
+
+func main() {
+	x := add(1, 2)
+	y := sub(x, 1)
+	fmt.Println(x, y)
+	if x > 2 {
+		fmt.Println("big")
+		os.Exit(1)
+	}
+}
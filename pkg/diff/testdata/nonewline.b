@@ -0,0 +1,10 @@
+a
+b
+c
+d
+E
+f
+g
+h
+i
+j
\ No newline at end of file
@@ -0,0 +1,7 @@
+one
+  two words
+three
+    four
+five
+six
+Seven
@@ -0,0 +1,235 @@
+package diff
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+//  |||WORD DIFFS||| //
+//a word diff shows the hunks of a line diff with the changes inside the lines. the removed and added lines of each
+//run of changes are cut into words, at whitespace, and the words are diffed like lines. what's the same is printed
+//once, from the new lines, and the words that changed are marked: [-removed-]{+added+}, or in colour, or one per
+//line for scripts. git diffs the words with plain myers and no context, and so does this.
+//read: https://git-scm.com/docs/git-diff#Documentation/git-diff.txt---word-diffltmodegt
+//and diff_words_show in https://github.com/git/git/blob/master/diff.c
+
+// WordStyle is how a word diff marks the words that changed
+type WordStyle int
+
+const (
+	// WordsPlain marks words [-removed-] and {+added+}
+	WordsPlain WordStyle = iota + 1
+	// WordsColor marks them with colours only
+	WordsColor
+	// WordsPorcelain prints a line per run of words, starting with a space, - or +, and ~ for a newline
+	WordsPorcelain
+)
+
+// ParseWordStyle reads the mode of --word-diff
+func ParseWordStyle(name string) (WordStyle, error) {
+	switch name {
+	case "plain":
+		return WordsPlain, nil
+	case "color":
+		return WordsColor, nil
+	case "porcelain":
+		return WordsPorcelain, nil
+	}
+	return 0, fmt.Errorf("bad --word-diff argument: %s", name)
+}
+
+const (
+	colorMeta  = "\033[1m"
+	colorFrag  = "\033[36m"
+	colorOld   = "\033[31m"
+	colorNew   = "\033[32m"
+	colorReset = "\033[m"
+)
+
+// ColorMeta wraps a line of the header of a patch in the colour git gives it, bold
+func ColorMeta(line string) string {
+	return colorMeta + line + colorReset
+}
+
+// wordMark is how a run of words of one kind is written: each line of it between prefix and suffix, in color
+type wordMark struct {
+	prefix, suffix, color string
+}
+
+type wordStyle struct {
+	added, removed, context wordMark
+	//newline is what a newline in the text turns into
+	newline string
+}
+
+var wordStyles = map[WordStyle]wordStyle{
+	WordsPlain:     {wordMark{"{+", "+}", ""}, wordMark{"[-", "-]", ""}, wordMark{}, "\n"},
+	WordsColor:     {wordMark{color: colorNew}, wordMark{color: colorOld}, wordMark{}, "\n"},
+	WordsPorcelain: {wordMark{"+", "\n", ""}, wordMark{"-", "\n", ""}, wordMark{" ", "\n", ""}, "~\n"},
+}
+
+// wordText is the removed, or added, lines of a run of changes, and where each of its words is in it.
+// words[0] is an empty word at the start, like in git, so an empty range of words still has an end
+type wordText struct {
+	text  string
+	words [][2]int
+}
+
+// split cuts the text into words, and returns them as lines to diff
+func (t *wordText) split() []string {
+	t.words = [][2]int{{0, 0}}
+	var lines []string
+	for i := 0; i < len(t.text); {
+		for i < len(t.text) && isSpace(t.text[i]) {
+			i++
+		}
+		if i == len(t.text) {
+			break
+		}
+		j := i + 1
+		for j < len(t.text) && !isSpace(t.text[j]) {
+			j++
+		}
+		t.words = append(t.words, [2]int{i, j})
+		lines = append(lines, t.text[i:j]+"\n")
+		i = j
+	}
+	return lines
+}
+
+// span is the text of the count words from first, numbered like in a hunk header: an empty span is right after
+// word first
+func (t *wordText) span(first, count int) (int, int) {
+	if count == 0 {
+		return t.words[first][1], t.words[first][1]
+	}
+	return t.words[first][0], t.words[first+count-1][1]
+}
+
+// wordWriter writes the hunks of a word diff
+type wordWriter struct {
+	w              io.Writer
+	style          wordStyle
+	minus, plus    wordText
+	colored        bool
+	porcelainLines bool
+}
+
+// write writes text marked as m. each line of it is marked on its own, and its newlines become the style's
+func (ww *wordWriter) write(m wordMark, text string) {
+	for text != "" {
+		line, rest, nl := strings.Cut(text, "\n")
+		if line != "" {
+			reset := ""
+			if m.color != "" {
+				reset = colorReset
+			}
+			fmt.Fprintf(ww.w, "%s%s%s%s%s", m.color, m.prefix, line, m.suffix, reset)
+		}
+		if !nl {
+			return
+		}
+		fmt.Fprint(ww.w, ww.style.newline)
+		text = rest
+	}
+}
+
+// flush writes the run of changes gathered so far
+func (ww *wordWriter) flush() {
+	minus, plus := &ww.minus, &ww.plus
+	defer func() { minus.text, plus.text = "", "" }()
+	if minus.text == "" && plus.text == "" {
+		return
+	}
+	if plus.text == "" {
+		ww.write(ww.style.removed, minus.text)
+		return
+	}
+	a, b := minus.split(), plus.split()
+	ta, tb := TrimTail(a, b)
+	current := 0
+	for _, h := range Hunks(lines(ta, tb, Options{}, false), 0) {
+		minusFirst, plusFirst := h.OldStart, h.NewStart
+		if h.OldLines != 0 {
+			minusFirst++
+		}
+		if h.NewLines != 0 {
+			plusFirst++
+		}
+		minusBegin, minusEnd := minus.span(minusFirst, h.OldLines)
+		plusBegin, plusEnd := plus.span(plusFirst, h.NewLines)
+		if current != plusBegin {
+			ww.write(ww.style.context, plus.text[current:plusBegin])
+		}
+		if minusBegin != minusEnd {
+			ww.write(ww.style.removed, minus.text[minusBegin:minusEnd])
+		}
+		if plusBegin != plusEnd {
+			ww.write(ww.style.added, plus.text[plusBegin:plusEnd])
+		}
+		current = plusEnd
+	}
+	if current != len(plus.text) {
+		ww.write(ww.style.context, plus.text[current:])
+	}
+}
+
+// header writes the hunk header. in colour the @@ part is cyan, what follows is plain
+func (ww *wordWriter) header(h string) {
+	if !ww.colored {
+		fmt.Fprintln(ww.w, h)
+		return
+	}
+	end := strings.LastIndex(h, "@@") + 2
+	fmt.Fprint(ww.w, colorFrag+h[:end]+colorReset)
+	funcName := strings.TrimLeft(h[end:], " \t")
+	if blanks := h[end : len(h)-len(funcName)]; blanks != "" {
+		fmt.Fprint(ww.w, blanks+colorReset)
+	}
+	if funcName != "" {
+		fmt.Fprint(ww.w, funcName+colorReset)
+	}
+	fmt.Fprintln(ww.w)
+}
+
+// contextLine writes an unchanged line the way git does in each style: as it is, with a reset after it in
+// colour, or after a space and followed by a newline mark for porcelain
+func (ww *wordWriter) contextLine(line string) {
+	line = strings.TrimSuffix(line, "\n")
+	switch {
+	case ww.porcelainLines:
+		fmt.Fprintf(ww.w, " %s\n%s", line, ww.style.newline)
+	case ww.colored && line != "":
+		fmt.Fprintf(ww.w, "%s%s\n", line, colorReset)
+	default:
+		fmt.Fprintln(ww.w, line)
+	}
+}
+
+// WriteWords writes the hunks of a diff of a into b as a word diff
+func WriteWords(w io.Writer, a, b []string, hunks []Hunk, style WordStyle) {
+	ww := &wordWriter{w: w, style: wordStyles[style], colored: style == WordsColor, porcelainLines: style == WordsPorcelain}
+	//a line without a newline at the end of the file is taken as if it had one
+	withNewline := func(l string) string {
+		if strings.HasSuffix(l, "\n") {
+			return l
+		}
+		return l + "\n"
+	}
+	for _, h := range hunks {
+		ww.header(h.Header(a))
+		for _, op := range h.Ops {
+			switch op.Kind {
+			case Equal:
+				ww.flush()
+				ww.contextLine(b[op.B])
+			case Delete:
+				ww.minus.text += withNewline(a[op.A])
+			case Insert:
+				ww.plus.text += withNewline(b[op.B])
+			}
+		}
+		ww.flush()
+	}
+}
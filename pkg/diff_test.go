@@ -1 +1,89 @@
 package pkg
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/OLUWAMUYIWA/got/pkg/diff"
+)
+
+// with whitespace ignored, a change that was only whitespace is left out of a diffstat,
+// but a rename or a mode change still shows, as a file with no lines changed
+func TestDiffStatsIgnoredWhitespace(t *testing.T) {
+	side := func(path string, mode uint32, data string) *diffSide {
+		return &diffSide{path: path, mode: mode, sha: justhash([]byte(data)), data: []byte(data)}
+	}
+	pairs := []filePair{
+		{old: side("space", 0100644, "a\nb\n"), new: side("space", 0100644, "a \nb\n")},
+		{old: side("mode", 0100644, "a\nb\n"), new: side("mode", 0100755, "a \nb\n")},
+		{old: side("old", 0100644, "a\nb\n"), new: side("new", 0100644, "a \nb\n")},
+		{old: side("edit", 0100644, "a\nb\n"), new: side("edit", 0100644, "a\nc\n")},
+	}
+	stats, err := (&Got{}).diffStats(pairs, diff.Options{IgnoreAllSpace: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []fileStat{{name: "mode"}, {name: "old => new"}, {name: "edit", added: 1, deleted: 1}}
+	if len(stats) != len(want) {
+		t.Fatalf("diffStats gave %+v", stats)
+	}
+	for i := range want {
+		if stats[i] != want[i] {
+			t.Errorf("stat %d is %+v, want %+v", i, stats[i], want[i])
+		}
+	}
+}
+
+func TestDiffOutput(t *testing.T) {
+	got := testRepo(t)
+	ctx := context.Background()
+	one := testCommitFiles(t, got, 1700000000, map[string]string{"a": "1\n2\n3\n", "b": "b\n", "d/x": "x\n"})
+	two := testCommitFiles(t, got, 1700000060, map[string]string{"a": "1\nTWO\n3\n", "c": "c\n", "d/x": "x\n"}, one)
+	if err := got.writeRef("refs/heads/master", two); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Checkout(ctx, "master", true, false); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("a", []byte("1\nTWO\n3\nfour\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	commits := []string{shaToString(one), shaToString(two)}
+	//what git diff prints for the same commits
+	patch := "diff --git a/a b/a\nindex 01e79c3..230b143 100644\n--- a/a\n+++ b/a\n@@ -1,3 +1,3 @@\n 1\n-2\n+TWO\n 3\n" +
+		"diff --git a/b b/b\ndeleted file mode 100644\nindex 6178079..0000000\n--- a/b\n+++ /dev/null\n@@ -1 +0,0 @@\n-b\n" +
+		"diff --git a/c b/c\nnew file mode 100644\nindex 0000000..f2ad6c7\n--- /dev/null\n+++ b/c\n@@ -0,0 +1 @@\n+c\n"
+	tests := []struct {
+		name string
+		opts DiffOpts
+		want string
+	}{
+		{name: "two commits", opts: DiffOpts{Context: 3, Revs: commits}, want: patch},
+		{name: "a range", opts: DiffOpts{Context: 3, Revs: []string{commits[0] + ".." + commits[1]}}, want: patch},
+		{name: "-U0", opts: DiffOpts{Revs: commits, Paths: []string{"a"}},
+			want: "diff --git a/a b/a\nindex 01e79c3..230b143 100644\n--- a/a\n+++ b/a\n@@ -2 +2 @@\n-2\n+TWO\n"},
+		{name: "name-status", opts: DiffOpts{Context: 3, Revs: commits, NameStatus: true}, want: "M\ta\nD\tb\nA\tc\n"},
+		{name: "name-only", opts: DiffOpts{Context: 3, Revs: commits, NameOnly: true}, want: "a\nb\nc\n"},
+		{name: "stat", opts: DiffOpts{Context: 3, Revs: commits, Stat: true},
+			want: " a | 2 +-\n b | 1 -\n c | 1 +\n 3 files changed, 2 insertions(+), 2 deletions(-)\n"},
+		{name: "numstat", opts: DiffOpts{Context: 3, Revs: commits, Numstat: true}, want: "1\t1\ta\n0\t1\tb\n1\t0\tc\n"},
+		{name: "shortstat", opts: DiffOpts{Context: 3, Revs: commits, Shortstat: true}, want: " 3 files changed, 2 insertions(+), 2 deletions(-)\n"},
+		//the index against the working tree
+		{name: "worktree", opts: DiffOpts{Context: 3},
+			want: "diff --git a/a b/a\nindex 230b143..f2e4f6d 100644\n--- a/a\n+++ b/a\n@@ -1,3 +1,4 @@\n 1\n TWO\n 3\n+four\n"},
+		{name: "commit against the worktree", opts: DiffOpts{Context: 3, Revs: commits[:1], NameStatus: true}, want: "M\ta\nD\tb\nA\tc\n"},
+		{name: "cached", opts: DiffOpts{Context: 3, Cached: true}},
+	}
+	for _, tt := range tests {
+		rdr, err := got.Diff(ctx, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b, _ := io.ReadAll(rdr); string(b) != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b, tt.want)
+		}
+	}
+}
@@ -0,0 +1,251 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/OLUWAMUYIWA/got/pkg/diff"
+)
+
+//  |||DIFFSTAT||| //
+//a diffstat sums a diff up: a line per file with the number of lines that changed and a bar of +s and -s,
+//then a line with the totals. the bars are scaled down to fit 80 columns, the longest one taking what's left
+//after the names, and the names are cut from the left when they don't fit either.
+//read: https://git-scm.com/docs/git-diff#Documentation/git-diff.txt---statltwidthgtltname-widthgtltcountgt
+
+// fileStat is how much one file of a diff changed. a binary file counts bytes instead of lines
+type fileStat struct {
+	name           string
+	added, deleted int
+	binary         bool
+}
+
+// diffStats counts the lines added and deleted in each pair. a file whose changes were all ignored whitespace
+// is left out, but not a rename or a mode change that didn't touch the lines
+func (got *Got) diffStats(pairs []filePair, opts diff.Options) ([]fileStat, error) {
+	stats := make([]fileStat, 0, len(pairs))
+	for _, p := range pairs {
+		oldData, err := got.sideData(p.old)
+		if err != nil {
+			return nil, err
+		}
+		newData, err := got.sideData(p.new)
+		if err != nil {
+			return nil, err
+		}
+		s := fileStat{name: p.path()}
+		if p.renamed() {
+			s.name = renameName(p.old.path, p.new.path)
+		}
+		sameContent := p.old != nil && p.new != nil && p.old.sha == p.new.sha
+		if isBinary(oldData) || isBinary(newData) {
+			s.binary = true
+			if !sameContent {
+				s.deleted, s.added = len(oldData), len(newData)
+			}
+		} else if !sameContent {
+			//like git, the stat is a diff without context
+			a, b := diff.TrimTail(diff.SplitLines(string(oldData)), diff.SplitLines(string(newData)))
+			for _, op := range diff.Lines(a, b, opts) {
+				switch op.Kind {
+				case diff.Insert:
+					s.added++
+				case diff.Delete:
+					s.deleted++
+				}
+			}
+			if p.old != nil && p.new != nil && s.added == 0 && s.deleted == 0 && !p.renamed() && p.old.mode == p.new.mode {
+				continue
+			}
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// renameName is how a rename shows in a diffstat: the part the two paths have in common is written once,
+// `src/{a => b}/file`, cut at slashes. with nothing in common it's `a => b`
+func renameName(a, b string) string {
+	at := func(s string, i int) byte {
+		if i >= len(s) {
+			return 0
+		}
+		return s[i]
+	}
+	pfx := 0
+	for i := 0; i < len(a) && i < len(b) && a[i] == b[i]; i++ {
+		if a[i] == '/' {
+			pfx = i + 1
+		}
+	}
+	//the common suffix may reach back into the prefix by its slash, but not further
+	sfx := 0
+	adjust := 0
+	if pfx > 0 {
+		adjust = 1
+	}
+	for i, j := len(a), len(b); pfx-adjust <= i && pfx-adjust <= j && at(a, i) == at(b, j); i, j = i-1, j-1 {
+		if at(a, i) == '/' {
+			sfx = len(a) - i
+		}
+		if i == 0 || j == 0 {
+			break
+		}
+	}
+	if pfx+sfx == 0 {
+		return a + " => " + b
+	}
+	aMid, bMid := len(a)-pfx-sfx, len(b)-pfx-sfx
+	if aMid < 0 {
+		aMid = 0
+	}
+	if bMid < 0 {
+		bMid = 0
+	}
+	return a[:pfx] + "{" + a[pfx:pfx+aMid] + " => " + b[pfx:pfx+bMid] + "}" + a[len(a)-sfx:]
+}
+
+// scaleLinear scales a change count down to a bar of width columns, the biggest change being max.
+// anything that changed gets at least one column
+func scaleLinear(n, width, max int) int {
+	if n == 0 {
+		return 0
+	}
+	return 1 + n*(width-1)/max
+}
+
+// writeStat prints the diffstat of the files, in width columns, and the summary line
+func writeStat(w io.Writer, stats []fileStat, width int) {
+	maxLen, maxChange, numberWidth, binWidth := 0, 0, 0, 0
+	for _, s := range stats {
+		if len(s.name) > maxLen {
+			maxLen = len(s.name)
+		}
+		if s.binary {
+			//"Bin XXX -> YYY bytes"
+			if bw := 14 + len(fmt.Sprint(s.added)) + len(fmt.Sprint(s.deleted)); bw > binWidth {
+				binWidth = bw
+			}
+			numberWidth = 3
+			continue
+		}
+		if c := s.added + s.deleted; c > maxChange {
+			maxChange = c
+		}
+	}
+	if n := len(fmt.Sprint(maxChange)); n > numberWidth {
+		numberWidth = n
+	}
+	if width < 16+6+numberWidth {
+		width = 16 + 6 + numberWidth
+	}
+	graphWidth := maxChange
+	if maxChange+4 <= binWidth {
+		graphWidth = binWidth - 4
+	}
+	nameWidth := maxLen
+	//" name | NNN graph": the name gets what it needs, unless the graph would be squeezed below 3/8 of the width
+	if nameWidth+numberWidth+6+graphWidth > width {
+		if graphWidth > width*3/8-numberWidth-6 {
+			graphWidth = width*3/8 - numberWidth - 6
+			if graphWidth < 6 {
+				graphWidth = 6
+			}
+		}
+		if nameWidth > width-numberWidth-6-graphWidth {
+			nameWidth = width - numberWidth - 6 - graphWidth
+		} else {
+			graphWidth = width - numberWidth - 6 - nameWidth
+		}
+	}
+
+	added, deleted := 0, 0
+	for _, s := range stats {
+		name, prefix, nameLen := s.name, "", nameWidth
+		if len(name) > nameWidth {
+			//too long, the end of it is kept, from a slash if there's one
+			prefix, nameLen = "...", nameWidth-3
+			name = name[len(name)-nameLen:]
+			if i := strings.IndexByte(name, '/'); i >= 0 {
+				name = name[i:]
+			}
+		}
+		fmt.Fprintf(w, " %s%-*s |", prefix, nameLen, name)
+		if s.binary {
+			fmt.Fprintf(w, " %*s", numberWidth, "Bin")
+			if s.added != 0 || s.deleted != 0 {
+				fmt.Fprintf(w, " %d -> %d bytes", s.deleted, s.added)
+			}
+			fmt.Fprintln(w)
+			continue
+		}
+		added, deleted = added+s.added, deleted+s.deleted
+		plus, minus := s.added, s.deleted
+		if graphWidth <= maxChange {
+			total := scaleLinear(plus+minus, graphWidth, maxChange)
+			if total < 2 && plus != 0 && minus != 0 {
+				total = 2
+			}
+			if plus < minus {
+				plus = scaleLinear(plus, graphWidth, maxChange)
+				minus = total - plus
+			} else {
+				minus = scaleLinear(minus, graphWidth, maxChange)
+				plus = total - minus
+			}
+		}
+		fmt.Fprintf(w, " %*d", numberWidth, s.added+s.deleted)
+		if s.added+s.deleted != 0 {
+			fmt.Fprint(w, " ")
+		}
+		fmt.Fprintf(w, "%s%s\n", strings.Repeat("+", plus), strings.Repeat("-", minus))
+	}
+	writeStatSummary(w, len(stats), added, deleted)
+}
+
+// writeNumstat prints the added and deleted lines of each file in numbers, tab separated, for scripts.
+// a binary file has dashes for both
+func writeNumstat(w io.Writer, stats []fileStat) {
+	for _, s := range stats {
+		if s.binary {
+			fmt.Fprintf(w, "-\t-\t%s\n", s.name)
+			continue
+		}
+		fmt.Fprintf(w, "%d\t%d\t%s\n", s.added, s.deleted, s.name)
+	}
+}
+
+// writeShortstat prints the summary line of the diffstat alone
+func writeShortstat(w io.Writer, stats []fileStat) {
+	added, deleted := 0, 0
+	for _, s := range stats {
+		if !s.binary {
+			added, deleted = added+s.added, deleted+s.deleted
+		}
+	}
+	writeStatSummary(w, len(stats), added, deleted)
+}
+
+// writeStatSummary prints the totals line, ` 2 files changed, 3 insertions(+), 1 deletion(-)`.
+// a diff with only insertions, or only deletions, leaves the other out
+func writeStatSummary(w io.Writer, files, added, deleted int) {
+	plural := func(n int, one, many string) string {
+		if n == 1 {
+			return one
+		}
+		return many
+	}
+	if files == 0 {
+		fmt.Fprintln(w, " 0 files changed")
+		return
+	}
+	fmt.Fprintf(w, " %d %s changed", files, plural(files, "file", "files"))
+	if added != 0 || deleted == 0 {
+		fmt.Fprintf(w, ", %d %s(+)", added, plural(added, "insertion", "insertions"))
+	}
+	if deleted != 0 || added == 0 {
+		fmt.Fprintf(w, ", %d %s(-)", deleted, plural(deleted, "deletion", "deletions"))
+	}
+	fmt.Fprintln(w)
+}
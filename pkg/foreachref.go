@@ -0,0 +1,524 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//  |||FOR-EACH-REF||| //
+//for-each-ref lists refs, loose and packed alike, and shows each one through a format.
+//a format is text with atoms in it, like `%(refname:short) %(objectname)`. every atom is filled from the ref,
+//the object it names, or with a * in front, the object an annotated tag finally points to.
+//the refs can be filtered by pattern and by history, sorted by any atom, and cut to a count.
+//read: https://git-scm.com/docs/git-for-each-ref
+
+const defaultRefFormat = "%(objectname) %(objecttype)\t%(refname)"
+
+// refObject is an object a ref leads to, read once and parsed when it is a commit or a tag
+type refObject struct {
+	sha  Sha1
+	ty   string
+	data []byte
+	comm *Comm
+	tag  *Tag
+}
+
+type refItem struct {
+	name string
+	sha  Sha1
+	obj  *refObject
+	//the object under the tags, nil until asked for
+	deref *refObject
+}
+
+func (got *Got) readRefObject(sha Sha1) (*refObject, error) {
+	_, ty, data, err := got.ReadObject(shaToString(sha))
+	if err != nil {
+		return nil, err
+	}
+	o := &refObject{sha: sha, ty: ty, data: data}
+	switch ty {
+	case "commit":
+		if o.comm, err = parseCommit(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		o.comm.sha = sha
+	case "tag":
+		if o.tag, err = parseTag(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		o.tag.sha = sha
+	}
+	return o, nil
+}
+
+// refMatches tells whether the ref matches one of the patterns. a pattern with no wildcard matches the ref itself,
+// or any ref below it, so refs/heads matches every branch
+func refMatches(name string, patterns []string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if strings.ContainsAny(p, "*?[") {
+			if ok, _ := path.Match(p, name); ok {
+				return true
+			}
+			continue
+		}
+		if name == p || strings.HasPrefix(name, strings.TrimSuffix(p, "/")+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// ForEachRef shows every ref that matches patterns through format. sorts are atoms, with a leading - for descending order,
+// and the last one decides first, like git. count limits the output when it is above zero.
+// contains and merged, when given, keep only the refs whose commit contains that commit, or is merged into it
+func (got *Got) ForEachRef(ctx context.Context, patterns []string, format string, sorts []string, count int, contains, merged string) (io.Reader, error) {
+	if format == "" {
+		format = defaultRefFormat
+	}
+	names, err := got.listRefs("refs")
+	if err != nil {
+		return nil, err
+	}
+	var containsSha, mergedSha Sha1
+	if contains != "" {
+		if containsSha, err = got.resolveCommitish(contains); err != nil {
+			return nil, fmt.Errorf("malformed object name %s", contains)
+		}
+	}
+	if merged != "" {
+		if mergedSha, err = got.resolveCommitish(merged); err != nil {
+			return nil, fmt.Errorf("malformed object name %s", merged)
+		}
+	}
+
+	var items []*refItem
+	for _, name := range names {
+		if !refMatches(name, patterns) {
+			continue
+		}
+		sha, err := got.readRef(name)
+		if err != nil {
+			//a dangling symbolic ref has nothing to show
+			continue
+		}
+		item := &refItem{name: name, sha: sha}
+		if contains != "" || merged != "" {
+			tip, err := got.peelTo(sha, "commit")
+			if err != nil {
+				continue
+			}
+			if contains != "" {
+				if ok, err := got.isAncestor(containsSha, tip); err != nil || !ok {
+					continue
+				}
+			}
+			if merged != "" {
+				if ok, err := got.isAncestor(tip, mergedSha); err != nil || !ok {
+					continue
+				}
+			}
+		}
+		items = append(items, item)
+	}
+
+	if err := got.sortRefItems(items, sorts); err != nil {
+		return nil, err
+	}
+	if count > 0 && count < len(items) {
+		items = items[:count]
+	}
+
+	var b bytes.Buffer
+	for _, item := range items {
+		line, err := got.expandRefFormat(item, format)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return &b, nil
+}
+
+// sortRefItems sorts by each key in turn. the sort is stable and the refs come in sorted by name,
+// so the last key wins and ties fall back on the keys before it, and then on the name
+func (got *Got) sortRefItems(items []*refItem, sorts []string) error {
+	for _, key := range sorts {
+		desc := strings.HasPrefix(key, "-")
+		atom := strings.TrimPrefix(key, "-")
+		name, _, _ := strings.Cut(atom, ":")
+		numeric := strings.HasSuffix(name, "date") || name == "objectsize" || name == "numparent"
+		if strings.HasSuffix(name, "date") {
+			atom = name + ":unix"
+		}
+		vals := make(map[*refItem]string, len(items))
+		nums := make(map[*refItem]int64, len(items))
+		for _, item := range items {
+			v, err := got.refAtom(item, atom)
+			if err != nil {
+				return err
+			}
+			vals[item] = v
+			if numeric {
+				nums[item], _ = strconv.ParseInt(v, 10, 64)
+			}
+		}
+		sort.SliceStable(items, func(i, j int) bool {
+			a, b := items[i], items[j]
+			if desc {
+				a, b = b, a
+			}
+			if numeric {
+				return nums[a] < nums[b]
+			}
+			return vals[a] < vals[b]
+		})
+	}
+	return nil
+}
+
+// expandRefFormat fills in the atoms of the format, and the %% and %xx escapes
+func (got *Got) expandRefFormat(item *refItem, format string) (string, error) {
+	var out strings.Builder
+	for i := 0; i < len(format); i++ {
+		c := format[i]
+		if c != '%' || i+1 == len(format) {
+			out.WriteByte(c)
+			continue
+		}
+		switch next := format[i+1]; {
+		case next == '%':
+			out.WriteByte('%')
+			i++
+		case next == '(':
+			end := strings.IndexByte(format[i:], ')')
+			if end < 0 {
+				return "", fmt.Errorf("malformed format string %s", format[i:])
+			}
+			v, err := got.refAtom(item, format[i+2:i+end])
+			if err != nil {
+				return "", err
+			}
+			out.WriteString(v)
+			i += end
+		case i+2 < len(format) && isHex(format[i+1:i+3]):
+			h, _ := hex.DecodeString(format[i+1 : i+3])
+			out.Write(h)
+			i += 2
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.String(), nil
+}
+
+// refAtom gives the value of one atom, with its modifier, for the ref. atoms that don't apply to the object are empty
+func (got *Got) refAtom(item *refItem, atom string) (string, error) {
+	name, mod, _ := strings.Cut(atom, ":")
+	switch name {
+	case "refname":
+		return formatRefName(item.name, mod)
+	case "HEAD":
+		if current, ok := got.headBranch(); ok && current == item.name {
+			return "*", nil
+		}
+		return " ", nil
+	case "symref":
+		target, ok, err := got.refs().readSymbolic(item.name)
+		if err != nil || !ok {
+			return "", nil
+		}
+		return formatRefName(target, mod)
+	case "upstream":
+		if !strings.HasPrefix(item.name, branchPrefix) {
+			return "", nil
+		}
+		up, ok := got.upstreamRef(strings.TrimPrefix(item.name, branchPrefix))
+		if !ok {
+			return "", nil
+		}
+		if mod == "track" || mod == "trackshort" {
+			return got.trackInfo(item.sha, up, mod == "trackshort")
+		}
+		return formatRefName(up, mod)
+	}
+
+	var err error
+	if item.obj == nil {
+		if item.obj, err = got.readRefObject(item.sha); err != nil {
+			return "", err
+		}
+	}
+	obj := item.obj
+	if strings.HasPrefix(name, "*") {
+		if obj.ty != "tag" {
+			return "", nil
+		}
+		if item.deref == nil {
+			sha, _, err := got.peel(item.sha)
+			if err != nil {
+				return "", err
+			}
+			if item.deref, err = got.readRefObject(sha); err != nil {
+				return "", err
+			}
+		}
+		obj, name = item.deref, name[1:]
+	}
+	return got.objectAtom(obj, name, mod)
+}
+
+// objectAtom gives the atoms that come from the object itself
+func (got *Got) objectAtom(obj *refObject, name, mod string) (string, error) {
+	switch name {
+	case "objectname":
+		return got.formatObjectName(obj.sha, mod)
+	case "objecttype":
+		return obj.ty, nil
+	case "objectsize":
+		return strconv.Itoa(len(obj.data)), nil
+	case "tree":
+		if obj.comm == nil {
+			return "", nil
+		}
+		return got.formatObjectName(obj.comm.treeSha, mod)
+	case "parent":
+		if obj.comm == nil {
+			return "", nil
+		}
+		var parents []string
+		for _, p := range obj.comm.parents {
+			s, err := got.formatObjectName(p, mod)
+			if err != nil {
+				return "", err
+			}
+			parents = append(parents, s)
+		}
+		return strings.Join(parents, " "), nil
+	case "numparent":
+		if obj.comm == nil {
+			return "", nil
+		}
+		return strconv.Itoa(len(obj.comm.parents)), nil
+	case "object", "type", "tag":
+		if obj.tag == nil {
+			return "", nil
+		}
+		switch name {
+		case "object":
+			return shaToString(obj.tag.object), nil
+		case "type":
+			return obj.tag.objType, nil
+		}
+		return obj.tag.name, nil
+	case "subject", "body", "contents":
+		msg := ""
+		switch {
+		case obj.comm != nil:
+			msg = obj.comm.msg
+		case obj.tag != nil:
+			msg = obj.tag.msg
+		}
+		subject, body := splitMessage(msg)
+		switch {
+		case name == "subject" || mod == "subject":
+			return subject, nil
+		case name == "body" || mod == "body":
+			return body, nil
+		}
+		return msg, nil
+	}
+
+	//what is left are the people: author, committer, tagger and creator, each with name, email or date
+	who, field := name, ""
+	for _, f := range []string{"name", "email", "date"} {
+		if strings.HasSuffix(name, f) && len(name) > len(f) {
+			who, field = strings.TrimSuffix(name, f), f
+			break
+		}
+	}
+	var sign *Sign
+	switch {
+	case who == "author" && obj.comm != nil:
+		sign = &obj.comm.author
+	case who == "committer" && obj.comm != nil:
+		sign = &obj.comm.committer
+	case who == "tagger" && obj.tag != nil && obj.tag.hasTagger:
+		sign = &obj.tag.tagger
+	case who == "creator" && obj.comm != nil:
+		sign = &obj.comm.committer
+	case who == "creator" && obj.tag != nil && obj.tag.hasTagger:
+		sign = &obj.tag.tagger
+	case who == "author" || who == "committer" || who == "tagger" || who == "creator":
+		return "", nil
+	default:
+		return "", fmt.Errorf("unknown field name: %s", name)
+	}
+	switch field {
+	case "name":
+		return sign.name, nil
+	case "email":
+		if mod == "trim" {
+			return sign.email, nil
+		}
+		return "<" + sign.email + ">", nil
+	case "date":
+		return formatDate(sign.time, mod, time.Now())
+	}
+	return sign.Format(), nil
+}
+
+// splitMessage splits a message into its subject, the first paragraph on one line, and the body after it
+func splitMessage(msg string) (string, string) {
+	msg = strings.TrimLeft(msg, "\n")
+	subject, body, _ := strings.Cut(msg, "\n\n")
+	return strings.ReplaceAll(strings.TrimSpace(subject), "\n", " "), strings.TrimLeft(body, "\n")
+}
+
+func (got *Got) formatObjectName(sha Sha1, mod string) (string, error) {
+	switch {
+	case mod == "":
+		return shaToString(sha), nil
+	case mod == "short":
+		return got.Abbrev(sha, 7), nil
+	case strings.HasPrefix(mod, "short="):
+		n, err := strconv.Atoi(strings.TrimPrefix(mod, "short="))
+		if err != nil {
+			return "", fmt.Errorf("positive value expected '%s' in %%(objectname)", mod)
+		}
+		return got.Abbrev(sha, n), nil
+	}
+	return "", fmt.Errorf("unrecognized %%(objectname) argument: %s", mod)
+}
+
+// formatRefName applies :short, :lstrip=N (or :strip=N) and :rstrip=N. a negative N keeps that many components instead
+func formatRefName(name, mod string) (string, error) {
+	if mod == "" {
+		return name, nil
+	}
+	if mod == "short" {
+		return shortRef(name), nil
+	}
+	kind, arg, _ := strings.Cut(mod, "=")
+	n, err := strconv.Atoi(arg)
+	if err != nil {
+		return "", fmt.Errorf("unrecognized %%(refname) argument: %s", mod)
+	}
+	parts := strings.Split(name, "/")
+	strip := n
+	if n < 0 {
+		strip = len(parts) + n
+		if strip < 0 {
+			strip = 0
+		}
+	}
+	if strip > len(parts) {
+		strip = len(parts)
+	}
+	switch kind {
+	case "lstrip", "strip":
+		return strings.Join(parts[strip:], "/"), nil
+	case "rstrip":
+		return strings.Join(parts[:len(parts)-strip], "/"), nil
+	}
+	return "", fmt.Errorf("unrecognized %%(refname) argument: %s", mod)
+}
+
+// trackInfo says how far the branch and its upstream have moved apart, like `[ahead 1, behind 2]`, or `<>` when short
+func (got *Got) trackInfo(tip Sha1, upstream string, short bool) (string, error) {
+	up, err := got.readRef(upstream)
+	if errors.Is(err, RefNotFoundErr) {
+		if short {
+			return "", nil
+		}
+		return "[gone]", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	ahead, behind, err := got.aheadBehind(tip, up)
+	if err != nil {
+		return "", err
+	}
+	if short {
+		switch {
+		case ahead > 0 && behind > 0:
+			return "<>", nil
+		case ahead > 0:
+			return ">", nil
+		case behind > 0:
+			return "<", nil
+		}
+		return "=", nil
+	}
+	switch {
+	case ahead > 0 && behind > 0:
+		return fmt.Sprintf("[ahead %d, behind %d]", ahead, behind), nil
+	case ahead > 0:
+		return fmt.Sprintf("[ahead %d]", ahead), nil
+	case behind > 0:
+		return fmt.Sprintf("[behind %d]", behind), nil
+	}
+	return "", nil
+}
+
+// aheadBehind counts the commits only a has, and those only b has
+func (got *Got) aheadBehind(a, b Sha1) (int, int, error) {
+	count := func(push, hide Sha1) (int, error) {
+		w := got.NewRevWalk()
+		if err := w.Push(push); err != nil {
+			return 0, err
+		}
+		if err := w.Hide(hide); err != nil {
+			return 0, err
+		}
+		n := 0
+		err := w.Walk(func(*Comm) error {
+			n++
+			return nil
+		})
+		return n, err
+	}
+	ahead, err := count(a, b)
+	if err != nil {
+		return 0, 0, err
+	}
+	behind, err := count(b, a)
+	if err != nil {
+		return 0, 0, err
+	}
+	return ahead, behind, nil
+}
+
+// reachable is every commit that can be reached from sha, sha included
+func (got *Got) reachable(sha Sha1) (map[Sha1]bool, error) {
+	seen := map[Sha1]bool{sha: true}
+	queue := []Sha1{sha}
+	for len(queue) > 0 {
+		comm, err := got.readCommit(shaToString(queue[0]))
+		if err != nil {
+			return nil, err
+		}
+		queue = queue[1:]
+		for _, p := range comm.parents {
+			if !seen[p] {
+				seen[p] = true
+				queue = append(queue, p)
+			}
+		}
+	}
+	return seen, nil
+}
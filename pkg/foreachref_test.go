@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func forEachRef(t *testing.T, got *Got, patterns []string, format string, sorts []string, count int, contains, merged string) string {
+	t.Helper()
+	rdr, err := got.ForEachRef(context.Background(), patterns, format, sorts, count, contains, merged)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(rdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestForEachRef(t *testing.T) {
+	got := testRepo(t)
+	t.Setenv("GIT_COMMITTER_NAME", "Tag Ger")
+	t.Setenv("GIT_COMMITTER_EMAIL", "tagger@example.com")
+	t.Setenv("GIT_COMMITTER_DATE", "1700000500 +0000")
+	one := testCommit(t, got, 1700000000)
+	two := testCommit(t, got, 1700000200, one)
+	three := testCommit(t, got, 1700000100, one)
+	for name, sha := range map[string]Sha1{"refs/heads/master": two, "refs/heads/a": three, "refs/heads/b": one, "refs/tags/light": two} {
+		if err := got.writeRef(name, sha); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := got.CreateTag(context.Background(), "v1", shaToString(one), true, "version one\n\nthe body", false); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.SetUpstream(context.Background(), "a", "master"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		patterns []string
+		format   string
+		sorts    []string
+		count    int
+		contains string
+		merged   string
+		want     string
+	}{
+		{name: "default", patterns: []string{"refs/heads/b"},
+			want: shaToString(one) + " commit\trefs/heads/b\n"},
+		{name: "names", patterns: []string{"refs/heads"}, format: "%(HEAD) %(refname:short) %(refname:lstrip=-1) %(refname:rstrip=2)",
+			want: "  a a refs\n  b b refs\n* master master refs\n"},
+		{name: "commit atoms", patterns: []string{"refs/heads/master"}, format: "%(numparent) %(parent) %(subject) %(authorname) %(authoremail:trim) %(committerdate:unix)",
+			want: "1 " + shaToString(one) + " commit at 1700000200 T t@x 1700000200\n"},
+		{name: "tag atoms", patterns: []string{"refs/tags"}, format: "%(refname:short) %(objecttype) %(*objecttype) %(tag) %(taggername) %(contents:subject)|%(contents:body)",
+			want: "light commit    commit at 1700000200|\n" + "v1 tag commit v1 Tag Ger version one|the body\n\n"},
+		{name: "object and escapes", patterns: []string{"refs/tags/v1"}, format: "%(object) %(type)%%%41%(*objectname:short=4)",
+			want: shaToString(one) + " commit%A" + got.Abbrev(one, 4) + "\n"},
+		{name: "upstream", patterns: []string{"refs/heads/a"}, format: "%(upstream:short) %(upstream:track) %(upstream:trackshort)",
+			want: "master [ahead 1, behind 1] <>\n"},
+		//the last key decides first, and ties fall back on the keys before it
+		{name: "sort by date", patterns: []string{"refs/heads"}, format: "%(refname:short)", sorts: []string{"-committerdate"},
+			want: "master\na\nb\n"},
+		{name: "sort by type then name", patterns: []string{"refs"}, format: "%(refname:short)", sorts: []string{"-refname", "objecttype"},
+			want: "light\nmaster\nb\na\nv1\n"},
+		{name: "count", patterns: []string{"refs/heads"}, format: "%(refname:short)", sorts: []string{"refname"}, count: 2,
+			want: "a\nb\n"},
+		{name: "contains", format: "%(refname:short)", contains: shaToString(three), want: "a\n"},
+		{name: "merged", patterns: []string{"refs/heads"}, format: "%(refname:short)", merged: "master", want: "b\nmaster\n"},
+	}
+	for _, tt := range tests {
+		if out := forEachRef(t, got, tt.patterns, tt.format, tt.sorts, tt.count, tt.contains, tt.merged); out != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, out, tt.want)
+		}
+	}
+
+	if _, err := got.ForEachRef(context.Background(), nil, "%(nosuchatom)", nil, 0, "", ""); err == nil || !strings.Contains(err.Error(), "unknown field name") {
+		t.Errorf("an unknown atom gave %v", err)
+	}
+}
@@ -3,7 +3,6 @@ package pkg
 import (
 	"errors"
 	"io"
-	"io/fs"
 	"log"
 	"os"
 	"path/filepath"
@@ -38,7 +37,7 @@ func NewGot() *Got {
 	baseDir := wd
 
 	//we need the head here
-	head, err := RefFromSym(filepath.Join(baseDir, "HEAD"), 0)
+	head, err := RefFromSym(filepath.Join(baseDir, ".git", "HEAD"), 0)
 	if err != nil {
 		if errors.Is(err, NotDefinedErr) {
 			log.Fatalf("Could not create head ref because this is not a working git directory")
@@ -46,12 +45,12 @@ func NewGot() *Got {
 		log.Fatalf("Error while reading the HEAD file: %s\n", err)
 	}
 
-	logger := log.New(os.Stdout, "GOT library: ", log.Ldate|log.Ltime)
-	logger.Println("Got Library: ")
+	//warnings go to stderr, stdout is for what the commands print
+	logger := log.New(os.Stderr, "GOT library: ", log.Ldate|log.Ltime)
 	return &Got{baseDir: baseDir, logger: logger, head: head}
 }
 
-func (g *Got) Log(rdr io.Reader) error {
+func (g *Got) WriteLog(rdr io.Reader) error {
 	_, err := io.Copy(g.logger.Writer(), rdr)
 	return err
 }
@@ -79,16 +78,12 @@ func (got *Got) GotErr(err error) {
 //great, now I can remove every damn wkdir, I think.
 //TsGit checks if this is a working git directory. simply: is there a ".git" directory inside iu
 func IsGit() (bool, error) {
-	is := false
-	err := fs.WalkDir(os.DirFS("."), ".", func(path string, d fs.DirEntry, err error) error {
-		if d.IsDir() && d.Name() == ".git" {
-			is = true
-			return errors.New("done")
+	info, err := os.Stat(".git")
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, nil
 		}
-		return nil
-	})
-	if errors.Is(err, errors.New("'done'")) {
-		err = nil
+		return false, err
 	}
-	return is, err
+	return info.IsDir(), nil
 }
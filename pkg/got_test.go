@@ -0,0 +1,78 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// testRepo makes an empty repository in a temp dir and moves into it, since got runs from the root of the working tree.
+// the old working directory is back when the test ends
+func testRepo(t *testing.T) *Got {
+	t.Helper()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(t.TempDir()); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	if err := Init(context.Background(), ""); err != nil {
+		t.Fatal(err)
+	}
+	return NewGot()
+}
+
+// testCommit writes a commit of the empty tree with the given parents, committed at the given unix time
+func testCommit(t *testing.T, got *Got, when int64, parents ...Sha1) Sha1 {
+	t.Helper()
+	return testCommitFiles(t, got, when, nil, parents...)
+}
+
+// testCommitFiles writes a commit of a tree holding the files, path to content, like testCommit
+func testCommitFiles(t *testing.T, got *Got, when int64, files map[string]string, parents ...Sha1) Sha1 {
+	t.Helper()
+	var b strings.Builder
+	fmt.Fprintf(&b, "tree %s\n", shaToString(testTree(t, got, files)))
+	for _, p := range parents {
+		fmt.Fprintf(&b, "parent %s\n", shaToString(p))
+	}
+	fmt.Fprintf(&b, "author T <t@x> %d +0000\ncommitter T <t@x> %d +0000\n\ncommit at %d\n", when, when, when)
+	sha, err := HashObj("commit", []byte(b.String()), got.baseDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return sha
+}
+
+// testTree writes the files as blobs and the trees that hold them, and returns the top tree
+func testTree(t *testing.T, got *Got, files map[string]string) Sha1 {
+	t.Helper()
+	if len(files) == 0 {
+		tree, err := HashObj("tree", nil, got.baseDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return tree
+	}
+	var entries []*IdxEntry
+	for path, data := range files {
+		sha, err := HashObj("blob", []byte(data), got.baseDir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, &IdxEntry{mode: 0100644, sha: sha, path: []byte(path)})
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return string(entries[i].path) < string(entries[j].path)
+	})
+	tree, err := got.writeTreeLevel(entries, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
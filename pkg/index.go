@@ -9,9 +9,11 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"io/fs"
 	"math"
 	"os"
 	"path/filepath"
+	"sort"
 	"time"
 
 	"golang.org/x/sys/unix"
@@ -22,6 +24,8 @@ import (
 type Idx struct {
 	entries []*IdxEntry
 	cache   map[string]*IdxEntry
+	// mTime is the modification time of the index file itself. it is what we measure racy entries against
+	mTime time.Time
 }
 
 type IdxEntry struct {
@@ -30,7 +34,60 @@ type IdxEntry struct {
 	dev, inode, mode, uid, gid, fsize uint32
 	sha                               [20]byte
 	flags                             uint16
-	path                              []byte
+	// extFlags only exists on disk in version 3 and up, and only for entries with flagExtended set
+	extFlags uint16
+	path     []byte
+}
+
+//the 16-bit flags field of an entry
+const (
+	flagAssumeValid uint16 = 0x8000
+	flagExtended    uint16 = 0x4000
+	flagStageMask   uint16 = 0x3000
+	flagNameMask    uint16 = 0x0fff
+)
+
+//the extra 16-bit flags field that version 3 adds after the flags of extended entries
+const (
+	extFlagSkipWorktree uint16 = 0x4000
+	extFlagIntentToAdd  uint16 = 0x2000
+)
+
+// assumeUnchanged is git's "assume valid" bit. the user promised the file won't change, so we don't look at it
+func (e *IdxEntry) assumeUnchanged() bool {
+	return e.flags&flagAssumeValid != 0
+}
+
+// skipWorktree means the entry is tracked but its file is deliberately absent or ignored in the working tree
+func (e *IdxEntry) skipWorktree() bool {
+	return e.flags&flagExtended != 0 && e.extFlags&extFlagSkipWorktree != 0
+}
+
+// ignoreWorktree tells status and friends to trust the index and not the working tree for this entry
+func (e *IdxEntry) ignoreWorktree() bool {
+	return e.assumeUnchanged() || e.skipWorktree()
+}
+
+func (e *IdxEntry) setAssumeUnchanged(on bool) {
+	if on {
+		e.flags |= flagAssumeValid
+	} else {
+		e.flags &^= flagAssumeValid
+	}
+}
+
+func (e *IdxEntry) setSkipWorktree(on bool) {
+	if on {
+		e.extFlags |= extFlagSkipWorktree
+	} else {
+		e.extFlags &^= extFlagSkipWorktree
+	}
+	//the extended bit must only be set when there's something in the extended flags
+	if e.extFlags != 0 {
+		e.flags |= flagExtended
+	} else {
+		e.flags &^= flagExtended
+	}
 }
 
 // read: https://mincong.io/2018/04/28/git-index/
@@ -41,30 +98,38 @@ type IdxEntry struct {
 // 160-bit SHA-1 over the content of the index file before this checksum.
 
 func readIndexFile() (*Idx, error) {
-	i := &Idx{}
+	i := &Idx{cache: make(map[string]*IdxEntry)}
 	if is, _ := IsGit(); !is {
 		return nil, errors.New("Not a valid git directory\n")
 	}
-	f, err := os.Open(".git/index")
-	p_err, ok := err.(*os.PathError)
-	if ok {
-		temp_err := errors.New("no such file or directory")
-		if p_err.Unwrap() == temp_err {
-			return nil, fmt.Errorf("You have not indexed any file\n")
+	f, err := os.Open(filepath.Join(".git", "index"))
+	if err != nil {
+		// no index yet simply means nothing has been staged
+		if errors.Is(err, os.ErrNotExist) {
+			return i, nil
 		}
-	} else {
 		return nil, err
 	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	i.mTime = info.ModTime()
 
 	data, err := io.ReadAll(f)
 	if err != nil {
 		return nil, err
 	}
+	if len(data) < 32 {
+		return nil, fmt.Errorf("index file too short to be valid")
+	}
 
 	hash := justhash(data[:len(data)-20])
 	//the index file has the lst 160 bits (i.e. 20 bytes) as the sha-1 checksum of all the bits tat come before it
 	//we need to ensure that it matches before considering the data valid
-	if bytes.Compare(hash[:], data[:(len(data)-20)]) != 0 {
+	if !bytes.Equal(hash[:], data[len(data)-20:]) {
 		return nil, errors.New("Checksum is not equal to file digest. File has been tampered with")
 	}
 	hdr := data[:12]
@@ -75,14 +140,15 @@ func readIndexFile() (*Idx, error) {
 	if !bytes.Equal(sign, []byte{'D', 'I', 'R', 'C'}) {
 		return nil, fmt.Errorf("bad index file sha1 signature: %s", sign)
 	}
-	if version != 2 {
-		return nil, fmt.Errorf("Version number must be at least 2, got %d", version)
+	// version 4 prefix-compresses the paths, which we don't support
+	if version != 2 && version != 3 {
+		return nil, fmt.Errorf("Version number must be 2 or 3, got %d", version)
 	}
 	//now for the index entries :
 	//we need to use the unix fstat
 	//the index files are listed between the 12-byte header and the 20-byte checksum
 	indEntries := data[12:(len(data) - 20)]
-	indexes, err := unmarshal(indEntries)
+	indexes, err := unmarshal(indEntries, int(numEntries))
 	if err != nil {
 		return nil, err
 	}
@@ -90,22 +156,31 @@ func readIndexFile() (*Idx, error) {
 		return nil, fmt.Errorf("Number of enteries does not equal to what the head specified")
 	}
 	i.entries = indexes
+	for _, e := range indexes {
+		i.cache[string(e.path)] = e
+	}
 	return i, nil
 }
 
-func unmarshal(data []byte) ([]*IdxEntry, error) {
+// unmarshal reads exactly n entries. whatever follows them is extension data (TREE, REUC, ...), which we don't use
+func unmarshal(data []byte, n int) ([]*IdxEntry, error) {
 	//length of deterministic bytes = 64
 	var indexEntries []*IdxEntry
 	b := bufio.NewReader(bytes.NewReader(data))
-	for {
+	for len(indexEntries) < n {
 		//the pre-path length is 62 bytes
 		buf := make([]byte, 62)
 		_, err := io.ReadFull(b, buf)
-		if err == io.ErrUnexpectedEOF {
+		if err != nil {
 			return nil, err
 		}
-		if err == io.EOF {
-			break
+		//extended entries carry two more bytes of flags before the path
+		if binary.BigEndian.Uint16(buf[60:62])&flagExtended != 0 {
+			ext := make([]byte, 2)
+			if _, err := io.ReadFull(b, ext); err != nil {
+				return nil, err
+			}
+			buf = append(buf, ext...)
 		}
 		if p, err := b.ReadBytes(Sep); err != nil {
 			return nil, err
@@ -128,7 +203,7 @@ func unmarshal(data []byte) ([]*IdxEntry, error) {
 }
 
 func destructure(b []byte) *IdxEntry {
-	var e *IdxEntry
+	e := &IdxEntry{}
 	e.cTime = time.Unix(int64(binary.BigEndian.Uint32(b[0:4])), int64(binary.BigEndian.Uint32(b[4:8])))
 	e.mTime = time.Unix(int64(binary.BigEndian.Uint32(b[8:12])), int64(binary.BigEndian.Uint32(b[12:16])))
 	e.dev = binary.BigEndian.Uint32(b[16:20])
@@ -139,7 +214,12 @@ func destructure(b []byte) *IdxEntry {
 	e.fsize = binary.BigEndian.Uint32(b[36:40])
 	e.sha = *(*[20]byte)(b[40:60]) // trick converting slice to array pointer
 	e.flags = binary.BigEndian.Uint16(b[60:62])
-	e.path = b[62:]
+	if e.flags&flagExtended != 0 {
+		e.extFlags = binary.BigEndian.Uint16(b[62:64])
+		e.path = b[64:]
+	} else {
+		e.path = b[62:]
+	}
 	return e
 }
 
@@ -150,12 +230,12 @@ func encodeNewIdxEntry(path string) (io.Reader, error) {
 	}
 	blob, err := io.ReadAll(f)
 	sha1 := justhash(blob)
-	var stat *unix.Stat_t
-	err = unix.Stat(path, stat)
+	var stat unix.Stat_t
+	err = unix.Stat(path, &stat)
 	if err != nil {
 		return nil, err
 	}
-	entry := mapStatToEntry(stat, path, sha1)
+	entry := mapStatToEntry(&stat, path, sha1)
 	return entry.marshall(), nil
 }
 
@@ -166,12 +246,12 @@ func newIdxEntry(path string) (*IdxEntry, error) {
 	}
 	blob, err := io.ReadAll(f)
 	sha1 := justhash(blob)
-	var stat *unix.Stat_t
-	err = unix.Stat(path, stat)
+	var stat unix.Stat_t
+	err = unix.Stat(path, &stat)
 	if err != nil {
 		return nil, err
 	}
-	return mapStatToEntry(stat, path, sha1), nil
+	return mapStatToEntry(&stat, path, sha1), nil
 }
 
 func mapStatToEntry(stat *unix.Stat_t, path string, sha1 Sha1) *IdxEntry {
@@ -180,7 +260,7 @@ func mapStatToEntry(stat *unix.Stat_t, path string, sha1 Sha1) *IdxEntry {
 		mTime: time.Unix(int64(stat.Mtim.Sec), int64(stat.Mtim.Nsec)),
 		dev:   uint32(stat.Dev),
 		inode: uint32(stat.Ino),
-		mode:  stat.Mode,
+		mode:  normMode(stat.Mode),
 		uid:   stat.Uid,
 		gid:   stat.Gid,
 		fsize: uint32(stat.Size),
@@ -194,6 +274,162 @@ func mapStatToEntry(stat *unix.Stat_t, path string, sha1 Sha1) *IdxEntry {
 	return &e
 }
 
+// normMode turns a raw st_mode into one of the few modes git records: regular (644 or 755), symlink or gitlink
+func normMode(m uint32) uint32 {
+	switch m & unix.S_IFMT {
+	case unix.S_IFLNK:
+		return 0120000
+	case unix.S_IFDIR:
+		return 0160000
+	}
+	if m&0100 != 0 {
+		return 0100755
+	}
+	return 0100644
+}
+
+// refreshStat replaces the cached stat data of the entry, leaving its mode, sha and flags alone
+func (e *IdxEntry) refreshStat(st *unix.Stat_t) {
+	e.cTime = time.Unix(int64(st.Ctim.Sec), int64(st.Ctim.Nsec))
+	e.mTime = time.Unix(int64(st.Mtim.Sec), int64(st.Mtim.Nsec))
+	e.dev = uint32(st.Dev)
+	e.inode = uint32(st.Ino)
+	e.uid = st.Uid
+	e.gid = st.Gid
+	e.fsize = uint32(st.Size)
+}
+
+// checkoutEntry writes the blob of an index entry into the working tree, then refreshes the entry's stat data.
+// the mode of the entry decides what we write: a plain file, an executable, or a symlink to the blob's content
+func (got *Got) checkoutEntry(e *IdxEntry) error {
+	full := filepath.Join(got.baseDir, string(e.path))
+	//we don't do submodules. like git with an uninitialized one, we leave an empty directory
+	if modType(e.mode) == gitlinkfile {
+		return os.MkdirAll(full, 0777)
+	}
+	_, ty, data, err := got.ReadObject(shaToString(e.sha))
+	if err != nil {
+		return err
+	}
+	if ty != "blob" {
+		return fmt.Errorf("%s: expected a blob, found %s", e.path, ty)
+	}
+	if err := os.MkdirAll(filepath.Dir(full), 0777); err != nil {
+		return err
+	}
+	//whatever is there goes first. writing through a symlink would change the file it points to
+	if err := os.Remove(full); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	switch modType(e.mode) {
+	case symlinkfile:
+		err = os.Symlink(string(data), full)
+	case blobfile:
+		//only the owner's executable bit is recorded. 100755 or 100644, nothing else
+		if e.mode&0100 != 0 {
+			err = os.WriteFile(full, data, 0777)
+		} else {
+			err = os.WriteFile(full, data, 0666)
+		}
+	default:
+		return fmt.Errorf("%s: can't check out an entry of mode %o", e.path, e.mode)
+	}
+	if err != nil {
+		return err
+	}
+	var st unix.Stat_t
+	if err := unix.Lstat(full, &st); err != nil {
+		return err
+	}
+	e.refreshStat(&st)
+	return nil
+}
+
+// removeWorktreeFile deletes the file of an entry, and then every directory that deleting it left empty
+func (got *Got) removeWorktreeFile(p string) error {
+	full := filepath.Join(got.baseDir, p)
+	if err := os.Remove(full); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for dir := filepath.Dir(full); dir != got.baseDir && len(dir) > len(got.baseDir); dir = filepath.Dir(dir) {
+		//Remove refuses to delete a directory that still has something in it, which is exactly what we want
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// matchStat compares the stat data cached in the entry against a fresh lstat of the file.
+// a match means the file is most likely unchanged; a mismatch means we have to look at the content to be sure
+func (e *IdxEntry) matchStat(st *unix.Stat_t) bool {
+	//the index only has room for 32 bits of each of these, so we truncate the same way we did when writing
+	if uint32(st.Mtim.Sec) != uint32(e.mTime.Unix()) || uint32(st.Mtim.Nsec) != uint32(e.mTime.Nanosecond()) {
+		return false
+	}
+	if uint32(st.Ctim.Sec) != uint32(e.cTime.Unix()) || uint32(st.Ctim.Nsec) != uint32(e.cTime.Nanosecond()) {
+		return false
+	}
+	if uint32(st.Ino) != e.inode || uint32(st.Dev) != e.dev {
+		return false
+	}
+	if st.Uid != e.uid || st.Gid != e.gid {
+		return false
+	}
+	if uint32(st.Size) != e.fsize {
+		return false
+	}
+	return normMode(st.Mode) == e.mode
+}
+
+// isRacy reports whether the entry's stat data can't be trusted.
+// the filesystem timestamp granularity means a file modified in the same instant the index was written
+// would still match its cached stat data. such entries must be rehashed.
+// read: https://github.com/git/git/blob/master/Documentation/technical/racy-git.txt
+func (i *Idx) isRacy(e *IdxEntry) bool {
+	if i.mTime.IsZero() {
+		return false
+	}
+	return !e.mTime.Before(i.mTime)
+}
+
+// changed decides whether the file at path differs from what the index has for it.
+// a stat match on a non-racy entry is taken as is. anything else costs us a rehash of the file
+func (i *Idx) changed(e *IdxEntry, path string) (bool, error) {
+	var st unix.Stat_t
+	if err := unix.Lstat(path, &st); err != nil {
+		return false, err
+	}
+	if e.matchStat(&st) && !i.isRacy(e) {
+		return false, nil
+	}
+	// a mode change (e.g. chmod +x) is a change even when the content is the same
+	if normMode(st.Mode) != e.mode {
+		return true, nil
+	}
+	// the size is cheap to check and a mismatch is conclusive, unless a racy write smudged the size to zero
+	if e.fsize != 0 && uint32(st.Size) != e.fsize {
+		return true, nil
+	}
+	var cont []byte
+	var err error
+	if st.Mode&unix.S_IFMT == unix.S_IFLNK {
+		var target string
+		target, err = os.Readlink(path)
+		cont = []byte(target)
+	} else {
+		cont, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return false, err
+	}
+	raw, err := hashWithObjFormat(cont, "blob")
+	if err != nil {
+		return false, err
+	}
+	return raw != e.sha, nil
+}
+
 func (e *IdxEntry) marshall() io.Reader {
 	var b bytes.Buffer
 	b.Grow(70) // i expect the buffer t be greater than 62. the extra 8 on top is for the path string
@@ -219,76 +455,102 @@ func (e *IdxEntry) marshall() io.Reader {
 	binary.BigEndian.PutUint32(slice, e.fsize)
 	b.Write(slice)
 	b.Write(e.sha[:])
-	flags := encodeFlags(string(e.path))
+	flags := encodeFlags(e.flags, string(e.path))
 	b.Write(flags[:])
+	if e.flags&flagExtended != 0 {
+		binary.BigEndian.PutUint16(slice, e.extFlags)
+		b.Write(slice[:2])
+	}
+	b.Write(e.path)
 	buf := b.Bytes()
+	// at least one NUL ends the path, the rest pad the entry to a multiple of eight
 	pad := 8 - (len(buf) % 8)
 	buf = append(buf, bytes.Repeat([]byte{'\x00'}, pad)...)
 	return bytes.NewReader(buf)
 }
 
-//1-bit assume-valid flag (false); 1-bit extended flag (must be zero in version 2); 2-bit stage (during merge);
+//1-bit assume-valid flag; 1-bit extended flag (must be zero in version 2); 2-bit stage (during merge);
 //12-bit name length if the length is less than 0xFFF, otherwise 0xFFF is stored in this field.
-//comeback: check
-//The value is 9 in decimal, or 0x9.
-func encodeFlags(name string) [2]byte {
-	i := int16(0)
-	l := len(name)
-	if l > 0xFFF {
-		i = 0xFFF
-	}
-	var b []byte
-	binary.BigEndian.PutUint16(b, uint16(i))
+//we keep the top four bits of whatever flags the entry already has and recompute the length
+func encodeFlags(flags uint16, name string) [2]byte {
 	var ret [2]byte
-	ret[0] = b[0]
-	ret[1] = b[1]
+	binary.BigEndian.PutUint16(ret[:], flags&^flagNameMask|setFlags(name))
 	return ret
 }
 
 func setFlags(name string) uint16 {
-	i := uint16(0)
 	l := len(name)
 	if l > 0xFFF {
-		i = 0xFFF
+		return 0xFFF
 	}
-	return i
+	return uint16(l)
 }
 
 //write the index file, given a slice of index
-//this is the function that stages files
+//this is the function that stages files. the slice is the whole index, not just the changes
 //IndexEntry file integers in git are written in NE.
 func (got *Got) UpIndexEntries(entries []*IdxEntry) error {
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].path, entries[j].path) < 0
+	})
+	//version 2 has no room for extended flags, so we only go up to 3 when one of the entries needs it
+	version := uint32(2)
+	for _, entry := range entries {
+		if entry.flags&flagExtended != 0 {
+			version = 3
+			break
+		}
+	}
+	//write to a lock file first and rename it over the index, so a reader never sees half an index
+	lock := filepath.Join(got.baseDir, ".git", "index.lock")
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not lock the index: %w", err)
+	}
+	//the lock file's mtime is the new index's timestamp, as near as we can tell before writing it
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		os.Remove(lock)
+		return err
+	}
+	stamp := info.ModTime()
 	var hdr []byte
 	hdr = append(hdr, []byte("DIRC")...)
-	//buf is apparently reusable
-	var buf []byte
-	binary.BigEndian.PutUint32(buf, 2)
-	hdr = append(hdr, buf[:4]...)
-	//use the same buffer, since the buffer does not keep its state. It starts over
+	buf := make([]byte, 4)
+	binary.BigEndian.PutUint32(buf, version)
+	hdr = append(hdr, buf...)
 	binary.BigEndian.PutUint32(buf, uint32(len(entries)))
-	hdr = append(hdr, buf[:4]...)
+	hdr = append(hdr, buf...)
 	var b bytes.Buffer
 	hasher := sha1.New()
 	w := io.MultiWriter(&b, hasher)
 	w.Write(hdr) // write the header
 	// now write the entries
 	for _, entry := range entries {
+		//a racy entry could have been edited in the same instant it was staged, without its stat data changing.
+		//once the index gets older than the file that can't be seen anymore, so we smudge its size to zero now.
+		//that forces whoever reads it later to look at the content
+		if !entry.mTime.Before(stamp) {
+			smudged := *entry
+			smudged.fsize = 0
+			entry = &smudged
+		}
 		io.Copy(w, entry.marshall())
 	}
-	// version 2 has empty extensions, so the only thing we need to write here is the 160-bit Sha1 checksum
-	checksum := hasher.Sum(nil)
-	f, err := os.OpenFile(filepath.Join(".git", "index"), os.O_APPEND|os.O_WRONLY|os.O_CREATE, 0644)
-	defer f.Close()
-	if err != nil {
-		return err
-	}
+	// we write no extensions, so the only thing we need to write here is the 160-bit Sha1 checksum
+	b.Write(hasher.Sum(nil))
+
 	if _, err := io.Copy(f, &b); err != nil {
+		f.Close()
+		os.Remove(lock)
 		return err
 	}
-	if _, err := f.Write(checksum); err != nil {
+	if err := f.Close(); err != nil {
+		os.Remove(lock)
 		return err
 	}
-	return nil
+	return os.Rename(lock, filepath.Join(got.baseDir, ".git", "index"))
 }
 
 // comeback
@@ -296,6 +558,38 @@ func (got *Got) UpdateIndex(ctx context.Context, all, remove bool) error {
 	return nil
 }
 
+// IdxFlag names the per-entry bits update-index can toggle
+type IdxFlag int
+
+const (
+	AssumeUnchanged IdxFlag = iota
+	SkipWorktree
+)
+
+// SetIndexFlag sets or clears flag on the index entries of the given paths.
+// every path must already be tracked. the index is written once, after all entries are updated
+func (got *Got) SetIndexFlag(ctx context.Context, flag IdxFlag, on bool, paths ...string) error {
+	idx, err := readIndexFile()
+	if err != nil {
+		return err
+	}
+	for _, p := range paths {
+		e, ok := idx.cache[filepath.ToSlash(filepath.Clean(p))]
+		if !ok {
+			return fmt.Errorf("Unable to mark file %s: not in the index", p)
+		}
+		switch flag {
+		case AssumeUnchanged:
+			e.setAssumeUnchanged(on)
+		case SkipWorktree:
+			e.setSkipWorktree(on)
+		default:
+			return fmt.Errorf("unknown index flag: %d", flag)
+		}
+	}
+	return got.UpIndexEntries(idx.entries)
+}
+
 func (i *Idx) Append(path string) {
 
 }
@@ -1 +1,159 @@
 package pkg
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// a file edited in the same instant it was staged keeps stat data that matches the index.
+// it has to show up as changed even after the index is written again later, when it is no longer racy
+func TestRacyEntrySmudged(t *testing.T) {
+	got := testRepo(t)
+	if err := os.WriteFile("racy", []byte("one\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile("old", []byte("old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	staged := justhash([]byte("one\n"))
+	//the edit: same size, and an mtime no older than the index we are about to write
+	if err := os.WriteFile("racy", []byte("two\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	future := time.Now().Add(time.Hour)
+	if err := os.Chtimes("racy", future, future); err != nil {
+		t.Fatal(err)
+	}
+	past := time.Now().Add(-time.Hour)
+	if err := os.Chtimes("old", past, past); err != nil {
+		t.Fatal(err)
+	}
+	var st unix.Stat_t
+	if err := unix.Lstat("racy", &st); err != nil {
+		t.Fatal(err)
+	}
+	racy := mapStatToEntry(&st, "racy", staged)
+	old, err := newIdxEntry("old")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := got.UpIndexEntries([]*IdxEntry{racy, old}); err != nil {
+		t.Fatal(err)
+	}
+	if racy.fsize != 4 {
+		t.Errorf("UpIndexEntries smudged the caller's entry")
+	}
+
+	//the index gets rewritten later, so it is newer than the file and the entry isn't racy anymore
+	later := future.Add(time.Hour)
+	if err := os.Chtimes(filepath.Join(".git", "index"), later, later); err != nil {
+		t.Fatal(err)
+	}
+	idx, err := readIndexFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := idx.cache["racy"]
+	if e == nil || e.fsize != 0 {
+		t.Fatalf("racy entry was not smudged: %+v", e)
+	}
+	if idx.isRacy(e) {
+		t.Fatalf("entry is still racy against the newer index")
+	}
+	if changed, err := idx.changed(e, "racy"); err != nil || !changed {
+		t.Errorf("changed(racy) = %v, %v; want true", changed, err)
+	}
+	if e := idx.cache["old"]; e == nil || e.fsize != 4 {
+		t.Errorf("an entry older than the index was smudged: %+v", e)
+	}
+	if changed, err := idx.changed(idx.cache["old"], "old"); err != nil || changed {
+		t.Errorf("changed(old) = %v, %v; want false", changed, err)
+	}
+}
+
+func indexVersion(t *testing.T) uint32 {
+	t.Helper()
+	b, err := os.ReadFile(filepath.Join(".git", "index"))
+	if err != nil || len(b) < 8 {
+		t.Fatalf("reading the index: %v", err)
+	}
+	return binary.BigEndian.Uint32(b[4:8])
+}
+
+func lsFiles(t *testing.T, got *Got, cached, deleted, modified bool) string {
+	t.Helper()
+	rdr, err := got.LsFiles(context.Background(), false, cached, deleted, modified, false, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(rdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestSetIndexFlag(t *testing.T) {
+	got := testRepo(t)
+	ctx := context.Background()
+	one := testCommitFiles(t, got, 1700000000, map[string]string{"a": "a\n", "b": "b\n", "c": "c\n"})
+	if err := got.writeRef("refs/heads/master", one); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Checkout(ctx, "master", true, false); err != nil {
+		t.Fatal(err)
+	}
+	if v := indexVersion(t); v != 2 {
+		t.Errorf("index version %d without extended flags, want 2", v)
+	}
+	if err := got.SetIndexFlag(ctx, AssumeUnchanged, true, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.SetIndexFlag(ctx, SkipWorktree, true, "b"); err != nil {
+		t.Fatal(err)
+	}
+	//skip-worktree lives in the extended flags, which only version 3 has room for
+	if v := indexVersion(t); v != 3 {
+		t.Errorf("index version %d with skip-worktree set, want 3", v)
+	}
+	if out := lsFiles(t, got, true, false, false); out != "h a\nS b\nH c\n" {
+		t.Errorf("ls-files -v printed %q", out)
+	}
+
+	//the flagged files are not looked at, whatever happens to them
+	for _, p := range []string{"a", "c"} {
+		if err := os.WriteFile(p, []byte("changed, and longer\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Remove("b"); err != nil {
+		t.Fatal(err)
+	}
+	if out := lsFiles(t, got, false, true, true); out != "C c\n" {
+		t.Errorf("ls-files -d -m with flags set printed %q", out)
+	}
+
+	if err := got.SetIndexFlag(ctx, AssumeUnchanged, false, "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.SetIndexFlag(ctx, SkipWorktree, false, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if out := lsFiles(t, got, false, true, true); out != "C a\nR b\nC b\nC c\n" {
+		t.Errorf("ls-files -d -m with flags cleared printed %q", out)
+	}
+	if v := indexVersion(t); v != 2 {
+		t.Errorf("index version %d after the extended flags are gone, want 2", v)
+	}
+
+	if err := got.SetIndexFlag(ctx, SkipWorktree, true, "missing"); err == nil {
+		t.Error("SetIndexFlag marked a path that isn't tracked")
+	}
+}
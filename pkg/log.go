@@ -0,0 +1,695 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/OLUWAMUYIWA/got/pkg/diff"
+)
+
+//  |||LOG||| //
+//log shows the commits of a rev walk. it takes the walk's revisions, ranges, order and paths, filters commits
+//by author, message and date, and prints them in one of git's pretty formats or a format of your own.
+//with --graph, a column drawing on the left shows how the commits hang together.
+//read: https://git-scm.com/docs/git-log and https://git-scm.com/docs/pretty-formats
+
+// LogOpts is what `got log` was asked for. the zero value logs HEAD, newest first, in the medium format
+type LogOpts struct {
+	//Revs are revisions and ranges: a, a..b, a...b, ^a
+	Revs  []string
+	Paths []string
+	//Order is date (the default), topo or date-order
+	Order   string
+	Reverse bool
+	//MaxCount limits the number of commits shown when above zero
+	MaxCount int
+	//FirstParent follows only the first parent of merges. Merges and NoMerges show only merges, or none of them
+	FirstParent, Merges, NoMerges bool
+	//Author and Grep are regular expressions matched against the author and the message
+	Author, Grep string
+	//Since and Until are dates, in any form parseApproxDate takes
+	Since, Until string
+	//Pretty is oneline, short, medium, full, fuller, or format:/tformat: followed by placeholders
+	Pretty string
+	//Oneline is --oneline: the short sha and the subject
+	Oneline bool
+	//Date is the --date format for the dates shown
+	Date     string
+	Graph    bool
+	Decorate bool
+	//Stat adds a diffstat of each commit against its parent, renames detected
+	Stat bool
+}
+
+// Log prints the history the options ask for
+func (got *Got) Log(ctx context.Context, opts LogOpts) (io.Reader, error) {
+	w := got.NewRevWalk()
+	revs := opts.Revs
+	if len(revs) == 0 {
+		revs = []string{"HEAD"}
+	}
+	for _, rev := range revs {
+		if err := w.PushRev(rev); err != nil {
+			return nil, err
+		}
+	}
+	switch opts.Order {
+	case "", "date":
+		//--graph draws lines of history, which only works when they are kept together
+		if opts.Graph {
+			w.Sorting(SortTopo, opts.Reverse)
+		} else {
+			w.Sorting(SortDate, opts.Reverse)
+		}
+	case "topo":
+		w.Sorting(SortTopo, opts.Reverse)
+	case "date-order":
+		w.Sorting(SortTopoDate, opts.Reverse)
+	default:
+		return nil, fmt.Errorf("unknown order %s", opts.Order)
+	}
+	if len(opts.Paths) != 0 {
+		paths := make([]string, len(opts.Paths))
+		for i, p := range opts.Paths {
+			paths[i] = path.Clean(p)
+		}
+		w.Paths(paths)
+	}
+	w.MaxCount(opts.MaxCount)
+	if opts.FirstParent {
+		w.FirstParent()
+	}
+	switch {
+	case opts.Merges:
+		w.ParentCount(2, -1)
+	case opts.NoMerges:
+		w.ParentCount(0, 1)
+	}
+	if err := got.logFilters(w, opts); err != nil {
+		return nil, err
+	}
+
+	pretty := opts.Pretty
+	if opts.Oneline {
+		pretty = "oneline-abbrev"
+	}
+	if pretty == "" {
+		pretty = "medium"
+	}
+	var decor map[Sha1][]string
+	if opts.Decorate || strings.Contains(pretty, "%d") || strings.Contains(pretty, "%D") {
+		var err error
+		if decor, err = got.decorations(); err != nil {
+			return nil, err
+		}
+	}
+
+	var entries [][]string
+	var commits []*Comm
+	err := w.Walk(func(c *Comm) error {
+		lines, err := got.prettyCommit(c, pretty, opts.Date, decor, opts.Decorate)
+		if err != nil {
+			return err
+		}
+		entries = append(entries, lines)
+		commits = append(commits, c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	//the full formats are separated by a blank line, format: puts a newline between entries and tformat: after each one
+	separated := pretty == "short" || pretty == "medium" || pretty == "full" || pretty == "fuller"
+	var b bytes.Buffer
+	g := &logGraph{}
+	for i, lines := range entries {
+		if !opts.Graph {
+			if opts.Stat {
+				stat, err := got.commitStat(commits[i], opts, 80)
+				if err != nil {
+					return nil, err
+				}
+				lines = append(lines, stat...)
+			}
+			if separated && i > 0 {
+				b.WriteString("\n")
+			}
+			if strings.HasPrefix(pretty, "format:") && i > 0 {
+				b.WriteString("\n")
+			}
+			b.WriteString(strings.Join(lines, "\n"))
+			if !strings.HasPrefix(pretty, "format:") {
+				b.WriteString("\n")
+			}
+			continue
+		}
+		//the blank line between two commits is drawn as part of the next one, as wide as it gets
+		separator, graphLines, padding := g.commit(commits[i].sha, w.Parents(commits[i].sha))
+		if opts.Stat {
+			//the stat fits in what the graph leaves of the 80 columns
+			stat, err := got.commitStat(commits[i], opts, 80-len(padding))
+			if err != nil {
+				return nil, err
+			}
+			//and starts once the graph rows of the commit are all drawn
+			for len(stat) > 0 && len(lines) < len(graphLines) {
+				lines = append(lines, "")
+			}
+			lines = append(lines, stat...)
+		}
+		if separated && i > 0 {
+			b.WriteString(separator + "\n")
+		}
+		n := len(lines)
+		if len(graphLines) > n {
+			n = len(graphLines)
+		}
+		for j := 0; j < n; j++ {
+			prefix, text := padding, ""
+			if j < len(graphLines) {
+				prefix = graphLines[j]
+			}
+			if j < len(lines) {
+				text = lines[j]
+			}
+			b.WriteString(prefix + text)
+			if j < n-1 || i < len(entries)-1 || !strings.HasPrefix(pretty, "format:") {
+				b.WriteString("\n")
+			}
+		}
+	}
+	return &b, nil
+}
+
+// commitStat is the diffstat log shows under a commit: against its parent, or the empty tree for a root commit.
+// a merge has none, unless only first parents are followed. the full formats and tformat: put a blank line
+// before it, format: one after it. width is the columns it has
+func (got *Got) commitStat(c *Comm, opts LogOpts, width int) ([]string, error) {
+	var parent Sha1
+	switch {
+	case len(c.parents) > 1 && !opts.FirstParent:
+		return nil, nil
+	case len(c.parents) > 0:
+		p, err := got.readCommit(shaToString(c.parents[0]))
+		if err != nil {
+			return nil, err
+		}
+		parent = p.treeSha
+	}
+	changes, err := got.TreeDiff(parent, c.treeSha, true, opts.Paths...)
+	if err != nil || len(changes) == 0 {
+		return nil, err
+	}
+	pairs := make([]filePair, len(changes))
+	for i, ch := range changes {
+		pairs[i] = ch.pair()
+	}
+	if pairs, err = got.detectRenames(pairs, nil, RenameOpts{Renames: true}); err != nil {
+		return nil, err
+	}
+	stats, err := got.diffStats(pairs, diff.Options{})
+	if err != nil {
+		return nil, err
+	}
+	var b strings.Builder
+	writeStat(&b, stats, width)
+	lines := strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n")
+	switch {
+	case opts.Oneline || opts.Pretty == "oneline":
+	case strings.HasPrefix(opts.Pretty, "format:"):
+		lines = append(lines, "")
+	default:
+		lines = append([]string{""}, lines...)
+	}
+	return lines, nil
+}
+
+// logFilters turns --author, --grep, --since and --until into filters of the walk
+func (got *Got) logFilters(w *RevWalk, opts LogOpts) error {
+	if opts.Author != "" {
+		re, err := regexp.Compile(opts.Author)
+		if err != nil {
+			return err
+		}
+		w.Filter(func(c *Comm) bool {
+			return re.MatchString(fmt.Sprintf("%s <%s>", c.author.name, c.author.email))
+		})
+	}
+	if opts.Grep != "" {
+		re, err := regexp.Compile(opts.Grep)
+		if err != nil {
+			return err
+		}
+		w.Filter(func(c *Comm) bool {
+			return re.MatchString(c.msg)
+		})
+	}
+	now := time.Now()
+	if opts.Since != "" {
+		since, err := parseApproxDate(opts.Since, now)
+		if err != nil {
+			return err
+		}
+		w.Filter(func(c *Comm) bool {
+			return !c.committer.time.Before(since)
+		})
+	}
+	if opts.Until != "" {
+		until, err := parseApproxDate(opts.Until, now)
+		if err != nil {
+			return err
+		}
+		w.Filter(func(c *Comm) bool {
+			return !c.committer.time.After(until)
+		})
+	}
+	return nil
+}
+
+// decorations are the names of the refs pointing at each commit, the way log shows them: HEAD -> main, tag: v1, origin/main
+func (got *Got) decorations() (map[Sha1][]string, error) {
+	names, err := got.listRefs("refs")
+	if err != nil {
+		return nil, err
+	}
+	decor := make(map[Sha1][]string)
+	head, onBranch := got.headBranch()
+	headSha, headErr := got.readRef("HEAD")
+	//git lists a commit's refs newest name first, from the end of the sorted list
+	sort.Sort(sort.Reverse(sort.StringSlice(names)))
+	for _, name := range names {
+		sha, err := got.readRef(name)
+		if err != nil {
+			continue
+		}
+		if peeled, _, err := got.peel(sha); err == nil {
+			sha = peeled
+		}
+		label := shortRef(name)
+		if strings.HasPrefix(name, tagPrefix) {
+			label = "tag: " + label
+		}
+		if onBranch && name == head {
+			continue
+		}
+		decor[sha] = append(decor[sha], label)
+	}
+	if headErr == nil {
+		label := "HEAD"
+		if onBranch {
+			label = "HEAD -> " + shortRef(head)
+		}
+		decor[headSha] = append([]string{label}, decor[headSha]...)
+	}
+	return decor, nil
+}
+
+// prettyCommit renders one commit in a pretty format, as lines without their newlines
+func (got *Got) prettyCommit(c *Comm, pretty, dateFormat string, decor map[Sha1][]string, decorate bool) ([]string, error) {
+	now := time.Now()
+	date := func(s Sign) (string, error) {
+		return formatDate(s.time, dateFormat, now)
+	}
+	decoration := ""
+	if decorate && len(decor[c.sha]) != 0 {
+		decoration = " (" + strings.Join(decor[c.sha], ", ") + ")"
+	}
+	subject, _ := splitMessage(c.msg)
+
+	if kind, f, ok := strings.Cut(pretty, ":"); ok && (kind == "format" || kind == "tformat") {
+		s, err := got.expandCommitFormat(c, f, dateFormat, decor)
+		return strings.Split(s, "\n"), err
+	}
+	switch pretty {
+	case "oneline-abbrev":
+		return []string{got.Abbrev(c.sha, 7) + decoration + " " + subject}, nil
+	case "oneline":
+		return []string{shaToString(c.sha) + decoration + " " + subject}, nil
+	case "short", "medium", "full", "fuller":
+	default:
+		//anything with a placeholder is taken as a format, like git does
+		if strings.Contains(pretty, "%") {
+			s, err := got.expandCommitFormat(c, pretty, dateFormat, decor)
+			return strings.Split(s, "\n"), err
+		}
+		return nil, fmt.Errorf("invalid --pretty format: %s", pretty)
+	}
+
+	lines := []string{"commit " + shaToString(c.sha) + decoration}
+	if len(c.parents) > 1 {
+		var ps []string
+		for _, p := range c.parents {
+			ps = append(ps, got.Abbrev(p, 7))
+		}
+		lines = append(lines, "Merge: "+strings.Join(ps, " "))
+	}
+	author := fmt.Sprintf("%s <%s>", c.author.name, c.author.email)
+	committer := fmt.Sprintf("%s <%s>", c.committer.name, c.committer.email)
+	switch pretty {
+	case "short":
+		lines = append(lines, "Author: "+author)
+	case "medium":
+		d, err := date(c.author)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, "Author: "+author, "Date:   "+d)
+	case "full":
+		lines = append(lines, "Author: "+author, "Commit: "+committer)
+	case "fuller":
+		ad, err := date(c.author)
+		if err != nil {
+			return nil, err
+		}
+		cd, err := date(c.committer)
+		if err != nil {
+			return nil, err
+		}
+		lines = append(lines, "Author:     "+author, "AuthorDate: "+ad, "Commit:     "+committer, "CommitDate: "+cd)
+	}
+	lines = append(lines, "")
+	if pretty == "short" {
+		return append(lines, "    "+subject), nil
+	}
+	msg := strings.TrimRight(strings.TrimLeft(c.msg, "\n"), "\n ")
+	for _, l := range strings.Split(msg, "\n") {
+		lines = append(lines, "    "+l)
+	}
+	return lines, nil
+}
+
+var formatColors = map[string]string{
+	"red": "\033[31m", "green": "\033[32m", "blue": "\033[34m", "yellow": "\033[33m", "reset": "\033[m",
+}
+
+// expandCommitFormat fills in the placeholders of a --pretty=format: string
+func (got *Got) expandCommitFormat(c *Comm, format, dateFormat string, decor map[Sha1][]string) (string, error) {
+	now := time.Now()
+	subject, body := splitMessage(c.msg)
+	person := func(s Sign, what byte) (string, bool, error) {
+		switch what {
+		case 'n':
+			return s.name, true, nil
+		case 'e':
+			return s.email, true, nil
+		case 'd':
+			d, err := formatDate(s.time, dateFormat, now)
+			return d, true, err
+		case 'D':
+			d, err := formatDate(s.time, "rfc", now)
+			return d, true, err
+		case 'r':
+			return relativeDate(s.time, now), true, nil
+		case 't':
+			d, err := formatDate(s.time, "unix", now)
+			return d, true, err
+		case 'i':
+			d, err := formatDate(s.time, "iso", now)
+			return d, true, err
+		case 'I':
+			d, err := formatDate(s.time, "iso-strict", now)
+			return d, true, err
+		case 's':
+			d, err := formatDate(s.time, "short", now)
+			return d, true, err
+		}
+		return "", false, nil
+	}
+	var out strings.Builder
+	for i := 0; i < len(format); i++ {
+		if format[i] != '%' || i+1 == len(format) {
+			out.WriteByte(format[i])
+			continue
+		}
+		rest := format[i+1:]
+		consumed := 1
+		switch rest[0] {
+		case '%':
+			out.WriteByte('%')
+		case 'n':
+			out.WriteByte('\n')
+		case 'H':
+			out.WriteString(shaToString(c.sha))
+		case 'h':
+			out.WriteString(got.Abbrev(c.sha, 7))
+		case 'T':
+			out.WriteString(shaToString(c.treeSha))
+		case 't':
+			out.WriteString(got.Abbrev(c.treeSha, 7))
+		case 'P', 'p':
+			var ps []string
+			for _, p := range c.parents {
+				if rest[0] == 'P' {
+					ps = append(ps, shaToString(p))
+				} else {
+					ps = append(ps, got.Abbrev(p, 7))
+				}
+			}
+			out.WriteString(strings.Join(ps, " "))
+		case 's':
+			out.WriteString(subject)
+		case 'b':
+			out.WriteString(body)
+		case 'B':
+			out.WriteString(strings.TrimLeft(c.msg, "\n"))
+		case 'd':
+			if len(decor[c.sha]) != 0 {
+				out.WriteString(" (" + strings.Join(decor[c.sha], ", ") + ")")
+			}
+		case 'D':
+			out.WriteString(strings.Join(decor[c.sha], ", "))
+		case 'a', 'c':
+			if len(rest) < 2 {
+				out.WriteString("%" + rest[:1])
+				break
+			}
+			s := c.author
+			if rest[0] == 'c' {
+				s = c.committer
+			}
+			v, ok, err := person(s, rest[1])
+			if err != nil {
+				return "", err
+			}
+			if !ok {
+				out.WriteString("%" + rest[:2])
+			}
+			out.WriteString(v)
+			consumed = 2
+		case 'x':
+			if len(rest) >= 3 && isHex(rest[1:3]) {
+				var h [1]byte
+				fmt.Sscanf(rest[1:3], "%02x", &h[0])
+				out.WriteByte(h[0])
+				consumed = 3
+			} else {
+				out.WriteString("%x")
+			}
+		case 'C':
+			name := ""
+			for color := range formatColors {
+				if strings.HasPrefix(rest[1:], color) {
+					name = color
+				}
+			}
+			if name == "" {
+				out.WriteString("%C")
+				break
+			}
+			out.WriteString(formatColors[name])
+			consumed = 1 + len(name)
+		default:
+			out.WriteString("%" + rest[:1])
+		}
+		i += consumed
+	}
+	return out.String(), nil
+}
+
+// logGraph draws the --graph columns. every column waits for a commit: the next commit of that line of history
+type logGraph struct {
+	columns []Sha1
+}
+
+func indexOf(list []Sha1, sha Sha1) int {
+	for i, s := range list {
+		if s == sha {
+			return i
+		}
+	}
+	return -1
+}
+
+// drawRow lays the glyphs out at their character positions and pads the row to width
+func drawRow(glyphs map[int]byte, width int) string {
+	row := make([]byte, width)
+	for i := range row {
+		row[i] = ' '
+	}
+	for pos, g := range glyphs {
+		if pos < width {
+			row[pos] = g
+		}
+	}
+	return string(row)
+}
+
+// commit draws the commit's row, then the rows that move the columns to where its parents are.
+// it also returns the columns as they were, to go in front of the blank line before the commit,
+// and the padding that goes in front of any further text lines of the commit
+func (g *logGraph) commit(sha Sha1, parents []Sha1) (string, []string, string) {
+	pipes := func(n, width int) string {
+		glyphs := make(map[int]byte)
+		for k := 0; k < n; k++ {
+			glyphs[2*k] = '|'
+		}
+		return drawRow(glyphs, width)
+	}
+	col := indexOf(g.columns, sha)
+	if col < 0 {
+		g.columns = append(g.columns, sha)
+		col = len(g.columns) - 1
+	}
+	before := len(g.columns)
+
+	//the first parent takes over the commit's column, a merge's other parents get new columns right after it
+	var next []Sha1
+	next = append(next, g.columns[:col]...)
+	added := 0
+	if len(parents) > 0 {
+		next = append(next, parents[0])
+		for _, p := range parents[1:] {
+			if indexOf(g.columns, p) < 0 && indexOf(next, p) < 0 {
+				next = append(next, p)
+				added++
+			}
+		}
+	}
+	right := g.columns[col+1:]
+	next = append(next, right...)
+
+	width := 2 * before
+	if 2*len(next) > width {
+		width = 2 * len(next)
+	}
+	separator := pipes(len(g.columns), width)
+	glyphs := make(map[int]byte)
+	for k := 0; k < before; k++ {
+		glyphs[2*k] = '|'
+	}
+	glyphs[2*col] = '*'
+	rows := []string{drawRow(glyphs, width)}
+
+	switch {
+	case added > 0:
+		//the new columns open to the right of the merge, and the columns that were there move over
+		glyphs = make(map[int]byte)
+		for k := 0; k <= col; k++ {
+			glyphs[2*k] = '|'
+		}
+		for k := 0; k < added; k++ {
+			glyphs[2*(col+k)+1] = '\\'
+		}
+		for k := range right {
+			glyphs[2*(col+1+k)+1] = '\\'
+		}
+		rows = append(rows, drawRow(glyphs, width))
+	case len(parents) == 0 && len(right) > 0:
+		//a root closes its column, the columns right of it move over
+		glyphs = make(map[int]byte)
+		for k := 0; k < col; k++ {
+			glyphs[2*k] = '|'
+		}
+		for k := range right {
+			glyphs[2*(col+k)+1] = '/'
+		}
+		rows = append(rows, drawRow(glyphs, width))
+	}
+
+	//two columns waiting for the same commit join into the left one
+	for {
+		j := -1
+		for k := range next {
+			if indexOf(next[:k], next[k]) >= 0 {
+				j = k
+				break
+			}
+		}
+		if j < 0 {
+			break
+		}
+		w := 2 * len(next)
+		glyphs = make(map[int]byte)
+		for k := 0; k < j; k++ {
+			glyphs[2*k] = '|'
+		}
+		for k := j; k < len(next); k++ {
+			glyphs[2*k-1] = '/'
+		}
+		rows = append(rows, drawRow(glyphs, w))
+		next = append(next[:j], next[j+1:]...)
+	}
+	g.columns = next
+
+	//the padding keeps the width of the widest row
+	padWidth := 2
+	for _, r := range rows {
+		if len(r) > padWidth {
+			padWidth = len(r)
+		}
+	}
+	return separator, rows, pipes(len(g.columns), padWidth)
+}
+
+// LogArgs splits log's arguments into revisions and paths. everything after -- is a path, and before it,
+// an argument that is no revision but a file is taken as a path too
+func (got *Got) LogArgs(args []string) (revs, paths []string, err error) {
+	for i, a := range args {
+		if a == "--" {
+			return revs, append(paths, args[i+1:]...), nil
+		}
+		if len(paths) == 0 && got.isRevArg(a) {
+			revs = append(revs, a)
+			continue
+		}
+		//with a -- further on, this can only be a revision
+		for _, rest := range args[i+1:] {
+			if rest == "--" {
+				return nil, nil, fmt.Errorf("bad revision '%s'", a)
+			}
+		}
+		if _, statErr := os.Lstat(a); statErr != nil {
+			return nil, nil, fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree.\nUse '--' to separate paths from revisions, like this:\n'got <command> [<revision>...] -- [<file>...]'", a)
+		}
+		paths = append(paths, a)
+	}
+	return revs, paths, nil
+}
+
+// isRevArg tells whether the argument names revisions, ranges included
+func (got *Got) isRevArg(a string) bool {
+	parts := []string{strings.TrimPrefix(a, "^")}
+	if l, r, ok := strings.Cut(a, "..."); ok {
+		parts = []string{l, r}
+	} else if l, r, ok := strings.Cut(a, ".."); ok {
+		parts = []string{l, r}
+	}
+	for _, p := range parts {
+		if p == "" {
+			continue
+		}
+		if _, err := got.ResolveRevision(p); err != nil {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,50 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestLogRanges(t *testing.T) {
+	got, c := testDescribeRepo(t)
+	sha := func(name string) string { return shaToString(c[name]) }
+	when := map[string]string{"c1": "1700001000", "c2": "1700002000", "c3": "1700003000", "s1": "1700002500", "m": "1700004000"}
+	tests := []struct {
+		name string
+		opts LogOpts
+		want []string
+	}{
+		{name: "a..b", opts: LogOpts{Revs: []string{sha("c3") + ".." + sha("m")}}, want: []string{"m", "s1"}},
+		{name: "^a b", opts: LogOpts{Revs: []string{"^" + sha("c2"), "master"}}, want: []string{"m", "c3", "s1"}},
+		{name: "a...b", opts: LogOpts{Revs: []string{sha("s1") + "..." + sha("c3")}}, want: []string{"c3", "s1", "c2"}},
+		{name: "..b is HEAD..b", opts: LogOpts{Revs: []string{"..master"}}},
+		{name: "a.. is a..HEAD", opts: LogOpts{Revs: []string{"light.."}}, want: []string{"m", "s1"}},
+		{name: "topo", opts: LogOpts{Order: "topo"}, want: []string{"m", "s1", "c3", "c2", "c1"}},
+		{name: "reverse", opts: LogOpts{Revs: []string{"v1..master"}, Reverse: true}, want: []string{"c2", "s1", "c3", "m"}},
+		{name: "first parent", opts: LogOpts{FirstParent: true}, want: []string{"m", "c3", "c2", "c1"}},
+		{name: "max count", opts: LogOpts{MaxCount: 2}, want: []string{"m", "c3"}},
+		{name: "merges", opts: LogOpts{Merges: true}, want: []string{"m"}},
+		{name: "no merges", opts: LogOpts{Revs: []string{"v2..master"}, NoMerges: true}, want: []string{"c3", "c2"}},
+		{name: "dates", opts: LogOpts{Since: "@1700002400", Until: "@1700003500"}, want: []string{"c3", "s1"}},
+	}
+	for _, tt := range tests {
+		tt.opts.Pretty = "tformat:%s"
+		rdr, err := got.Log(context.Background(), tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var want string
+		for _, name := range tt.want {
+			want += "commit at " + when[name] + "\n"
+		}
+		if b, _ := io.ReadAll(rdr); string(b) != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b, want)
+		}
+	}
+
+	if _, err := got.Log(context.Background(), LogOpts{Revs: []string{"nosuch..master"}}); err == nil {
+		t.Error("a range with a bad end was logged")
+	}
+}
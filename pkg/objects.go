@@ -85,6 +85,10 @@ func HashObj(ty string, data []byte, base string) (Sha1, error) {
 		return h, &ObjectErr{}
 	}
 	fPath := filepath.Join(path, hash_str[2:])
+	//objects never change. one that is already there is left alone, git makes them read-only anyway
+	if _, err := os.Stat(fPath); err == nil {
+		return h, nil
+	}
 	f, err := os.Create(fPath)
 	if err != nil {
 		return h, &ObjectErr{}
@@ -137,6 +141,7 @@ func (got *Got) Object(sha string, ty ObjectType) (GotObject, error) {
 	if err != nil {
 		return nil, err
 	}
+	defer f.Close()
 	objRdr, err := zlib.NewReader(f)
 	if err != nil {
 		return nil, err
@@ -159,7 +164,7 @@ func (got *Got) Object(sha string, ty ObjectType) (GotObject, error) {
 
 	case tag:
 		{
-			parseTag(objRdr, got)
+			return parseTag(objRdr)
 		}
 
 	default:
@@ -167,9 +172,6 @@ func (got *Got) Object(sha string, ty ObjectType) (GotObject, error) {
 			return nil, fmt.Errorf("invalid object type")
 		}
 	}
-	f.Close()
-
-	return nil, err
 }
 
 type gotObject struct {
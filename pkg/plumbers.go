@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -20,6 +21,9 @@ import (
 //This exception is because plumbers can be used directly by the user too
 //TODO:Check all the endianness in this code
 
+// AmbiguousObjectErr is returned when a sha1 prefix is the start of more than one object's name
+var AmbiguousObjectErr = errors.New("ambiguous object name")
+
 //FindObject takes a sha1 prefix. It returns the path to the object file. It doesn't care t open it
 //another method does that
 //we want to have findObject such that even though we do not have
@@ -36,8 +40,11 @@ func (got *Got) FindObject(prefix string) (string, error) {
 		return "", fmt.Errorf("way too long. How did you come about a string longer than 40? darn it! This is sha-1")
 	}
 	path := filepath.Join(".git/objects", prefix[:2])
+	//no directory for the first two digits just means no object starts with them
 	entries, err := os.ReadDir(path)
-	got.GotErr(err)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return "", err
+	}
 	location := ""
 	//we also need to be sure that our given prefix is unique too. If it isn't we may be return ing the wrong file
 	num := 0
@@ -47,7 +54,7 @@ func (got *Got) FindObject(prefix string) (string, error) {
 			num += 1
 			location = entry.Name()
 			if num > 1 {
-				return "", fmt.Errorf("%s matches more than one blob", prefix)
+				return "", fmt.Errorf("%w: %s matches more than one object", AmbiguousObjectErr, prefix)
 			}
 		}
 	}
@@ -58,6 +65,22 @@ func (got *Got) FindObject(prefix string) (string, error) {
 	}
 }
 
+// resolveSha expands a sha1 prefix to the full name of the object it identifies
+func (got *Got) resolveSha(prefix string) (Sha1, error) {
+	location, err := got.FindObject(prefix)
+	if err != nil {
+		return Sha1{}, err
+	}
+	//the object lives at objects/xx/yyyy..., the directory and file names together make up the sha
+	dir, file := filepath.Split(location)
+	full := filepath.Base(dir) + file
+	h, err := hex.DecodeString(full)
+	if err != nil || len(h) != 20 {
+		return Sha1{}, fmt.Errorf("%s is not a valid object name", full)
+	}
+	return bytesToSha(h), nil
+}
+
 func (got *Got) ReadObjectStream(prefix string) (string, string, io.Reader, error) {
 	location, err := got.FindObject(prefix)
 	if err != nil {
@@ -141,7 +164,11 @@ func (got *Got) ReadObject(prefix string) (string, string, []byte, error) {
 
 //CatFile displays the file info using the git logger (set as os.Stdout). It uses flags to determine what it displays
 func (got *Got) CatFile(ctx context.Context, prefix string, mode int) (io.Reader, error) {
-
+	sha, err := got.ResolveRevision(prefix)
+	if err != nil {
+		return nil, err
+	}
+	prefix = shaToString(sha)
 	f_name, dType, data, err := got.ReadObject(prefix)
 	//this error should just cause the program to exit.
 	if err != nil {
@@ -156,7 +183,14 @@ func (got *Got) CatFile(ctx context.Context, prefix string, mode int) (io.Reader
 		_, err := io.WriteString(&b, fmt.Sprintf("File %s Type: %s\n", f_name, dType))
 		return &b, err
 	case 2: //pretty
-		if dType == "commit" || dType == "blob" {
+		if dType == "tag" {
+			t, err := parseTag(bytes.NewReader(data))
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.WriteString(&b, fmt.Sprintf("Content %s: \n%s", f_name, t))
+			return &b, err
+		} else if dType == "commit" || dType == "blob" {
 			_, err := io.WriteString(&b, fmt.Sprintf("Content %s: \n%s", f_name, string(data)))
 			return &b, err
 		} else if dType == "tree" {
@@ -183,40 +217,82 @@ func (got *Got) CatFile(ctx context.Context, prefix string, mode int) (io.Reader
 	return nil, nil
 }
 
-//LsFiles prints to stdOut the state of staged files, i.e. the index files
+//LsFiles lists the state of staged files, i.e. the index files
 //After a Commit, it is clean
-// comeback
-func (got *Got) LsFiles(ctx context.Context, stage, cached, deleted, modified, others bool) error {
-
+//verbose tags each line like `git ls-files -v`: H cached, S skip-worktree, R removed, C modified, ? untracked.
+//assume-unchanged entries get their tag in lowercase
+func (got *Got) LsFiles(ctx context.Context, stage, cached, deleted, modified, others, verbose bool) (io.Reader, error) {
+	var b bytes.Buffer
 	idx, err := readIndexFile()
 	if err != nil {
-		return err
-	}
-
-	if len(idx.entries) != 0 {
-		got.logger.Printf("No staged files\n")
-		return fmt.Errorf("No files staged")
+		return nil, err
 	}
 
-	for _, ind := range idx.entries {
-		path := string(ind.path)
-		if stage {
+	write := func(tag string, ind *IdxEntry, path string) {
+		if verbose {
+			if ind != nil && ind.assumeUnchanged() {
+				tag = strings.ToLower(tag)
+			}
+			fmt.Fprintf(&b, "%s ", tag)
+		}
+		if stage && ind != nil {
 			//eating the mode and sha1 is easy. As for the mode, we'll octal-format it later with fmt, and the sha too
-			mode := ind.mode
-			sha1 := ind.sha[:]
 			//stage number is the 3rd and 4th bit in the 16-bit flag
 			//so first, we >> by 12 top put the first four bits on the rightmost
 			//then, our mask will be 0b00000011, i.e. 3, we & against (or 0000000000000011) so that we'll keep only
 			//the values of the last two bits intact, and cancel every othher before it, namely third and fourth to the last
 			stage := (ind.flags >> 12) & 3
-			s := fmt.Sprintf("Mode: %o sha1: %x  stage: %d path: %s\n", mode, sha1, stage, path)
-			got.logger.Printf(s)
-		} else {
-			got.logger.Printf("%s\n", path)
+			fmt.Fprintf(&b, "%06o %x %d\t", ind.mode, ind.sha[:], stage)
 		}
+		fmt.Fprintf(&b, "%s\n", path)
 	}
 
-	return nil
+	for _, ind := range idx.entries {
+		path := string(ind.path)
+		if cached || stage {
+			tag := "H"
+			if ind.skipWorktree() {
+				tag = "S"
+			}
+			write(tag, ind, path)
+		}
+		//skip-worktree and assume-unchanged entries are never reported as deleted or modified
+		if (!deleted && !modified) || ind.ignoreWorktree() {
+			continue
+		}
+		full := filepath.Join(got.WkDir(), path)
+		if _, err := os.Lstat(full); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				return nil, err
+			}
+			if deleted {
+				write("R", ind, path)
+			}
+			//git lists deleted files as modified too
+			if modified {
+				write("C", ind, path)
+			}
+			continue
+		}
+		if modified {
+			changed, err := idx.changed(ind, full)
+			if err != nil {
+				return nil, err
+			}
+			if changed {
+				write("C", ind, path)
+			}
+		}
+	}
+
+	if others {
+		untracked, _, _ := got.get_status()
+		for _, path := range untracked {
+			write("?", nil, path)
+		}
+	}
+
+	return &b, nil
 }
 
 // we want to know the files that were changed, the ones that were deleted, and the ones that were added
@@ -234,6 +310,9 @@ func (got *Got) get_status() ([]string, []string, map[string]string) {
 	//do two things at the same time: ensure .git is not inncludesd in files, and fill up files with all cleaned paths to non-dir files
 	//comeback check if DrFS should be this `.`
 	fs.WalkDir(os.DirFS(got.WkDir()), ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
 		if d.IsDir() && d.Name() == ".git" {
 			return fs.SkipDir
 		}
@@ -243,7 +322,6 @@ func (got *Got) get_status() ([]string, []string, map[string]string) {
 		}
 		return nil
 	})
-	fmt.Println(files)
 	//sort the file paths
 	sort.Slice(files, func(i, j int) bool {
 		return files[i] < files[j]
@@ -253,24 +331,25 @@ func (got *Got) get_status() ([]string, []string, map[string]string) {
 	if err != nil {
 		got.FatalErr(err)
 	}
-	var index_map map[string]*IdxEntry
+	index_map := make(map[string]*IdxEntry)
 	for _, ind := range idx.entries {
 		index_map[string(ind.path)] = ind
 	}
 	//stored hash in index differs from the new hash, these ones have been modified. They are being tracked
-	// comeback ensure the file names are absolute
+	//we only rehash when the stat data tells us to, or when the entry is racy. see Idx.changed
 	modified := func(files []string) {
 		for _, f_path := range files {
 			if ind, ok := index_map[f_path]; ok {
-				f, err := os.Open(f_path)
-				got.GotErr(err)
-				cont, err := io.ReadAll(f)
-				got.GotErr(err)
-				raw, err := hashWithObjFormat(cont, "blob")
+				//the user told us not to look at these
+				if ind.ignoreWorktree() {
+					continue
+				}
+				changed, err := idx.changed(ind, filepath.Join(got.WkDir(), f_path))
 				if err != nil {
-					return
+					got.GotErr(err)
+					continue
 				}
-				if hex.EncodeToString(raw[:]) != hex.EncodeToString(ind.sha[:]) {
+				if changed {
 					mod[string(ind.path)] = f_path
 				}
 			}
@@ -293,7 +372,10 @@ func (got *Got) get_status() ([]string, []string, map[string]string) {
 				delete(index_map, string(ind.path))
 			}
 		}
-		for p := range index_map {
+		for p, ind := range index_map {
+			if ind.ignoreWorktree() {
+				continue
+			}
 			del = append(del, p)
 		}
 	}
@@ -320,12 +402,15 @@ func (got *Got) status() {
 	if len(mod) != 0 {
 		s.WriteString("Modified files\n")
 	}
-	for k, v := range mod {
-		d, err := diff(k, v)
-		if err != nil {
-			break
+	paths := make([]string, 0, len(mod))
+	for k := range mod {
+		paths = append(paths, k)
+	}
+	sort.Strings(paths)
+	if len(paths) != 0 {
+		if d, err := got.Diff(context.Background(), DiffOpts{Paths: paths, Context: 3}); err == nil {
+			io.Copy(&s, d)
 		}
-		s.WriteString(fmt.Sprintf("%s\n", d))
 	}
 	_, err := fmt.Fprintf(os.Stdout, "%s\n", s.String())
 	got.GotErr(err)
@@ -333,8 +418,8 @@ func (got *Got) status() {
 }
 
 //to write a tree, we need to stage the files first i.e. index them, then from the indexed files, we write the tree
-//TODO: for now, we support only root-level files
-//WriteTree just takes the current values in the index (i.e. the staged files) and writes as tree object
+//WriteTree just takes the current values in the index (i.e. the staged files) and writes as tree object.
+//every directory in the index becomes a tree of its own, written before the tree that holds it
 func (got *Got) WriteTree(ctx context.Context) (string, error) {
 	// we need the mode, the path from root, and the sha1
 	idx, err := readIndexFile()
@@ -344,16 +429,7 @@ func (got *Got) WriteTree(ctx context.Context) (string, error) {
 	if len(idx.entries) == 0 {
 		return "", fmt.Errorf("No files staged \n")
 	}
-	var b bytes.Buffer
-	for _, ind := range idx.entries {
-		mode := ind.mode
-		s := fmt.Sprintf("%o %s%v%v", mode, ind.path, Sep, ind.sha)
-		b.Write([]byte(s))
-	}
-	tree := Tree{
-		data: b.Bytes(),
-	}
-	hash, err := tree.Hash(got.baseDir)
+	hash, err := got.writeTreeLevel(idx.entries, "")
 	if err != nil {
 		return "", fmt.Errorf("Writing Tree: %w", err)
 	}
@@ -361,6 +437,52 @@ func (got *Got) WriteTree(ctx context.Context) (string, error) {
 	return hash_s, nil
 }
 
+// writeTreeLevel writes the tree of the directory base. entries are sorted by path,
+// so the ones inside a subdirectory always sit next to each other
+func (got *Got) writeTreeLevel(entries []*IdxEntry, base string) (Sha1, error) {
+	var items []item
+	for i := 0; i < len(entries); {
+		rest := strings.TrimPrefix(string(entries[i].path), base)
+		slash := strings.IndexByte(rest, '/')
+		if slash < 0 {
+			items = append(items, item{mode: entries[i].mode, name: rest, sha: entries[i].sha})
+			i++
+			continue
+		}
+		dir := rest[:slash]
+		j := i
+		for j < len(entries) && strings.HasPrefix(string(entries[j].path), base+dir+"/") {
+			j++
+		}
+		sha, err := got.writeTreeLevel(entries[i:j], base+dir+"/")
+		if err != nil {
+			return Sha1{}, err
+		}
+		items = append(items, item{mode: 040000, name: dir, sha: sha})
+		i = j
+	}
+	//git sorts a tree as if the name of every subtree ended with a slash
+	sortName := func(it item) string {
+		if it.mode == 040000 {
+			return it.name + "/"
+		}
+		return it.name
+	}
+	sort.Slice(items, func(a, b int) bool {
+		return sortName(items[a]) < sortName(items[b])
+	})
+	var b bytes.Buffer
+	for _, it := range items {
+		fmt.Fprintf(&b, "%o %s", it.mode, it.name)
+		b.WriteByte(Sep)
+		b.Write(it.sha[:])
+	}
+	tree := Tree{
+		data: b.Bytes(),
+	}
+	return tree.Hash(got.baseDir)
+}
+
 //return all the objects (subtrees and blobs) inside a tree
 func (got *Got) deserTree(sha string) []item {
 	if is, _ := IsGit(); !is {
@@ -380,7 +502,7 @@ func (got *Got) deserTree(sha string) []item {
 	start := 0
 	for {
 		d := data[start:]
-		if len(data) == 0 {
+		if len(d) == 0 {
 			break
 		}
 		split := bytes.SplitN(d, []byte(" "), 2)
@@ -399,92 +521,36 @@ func (got *Got) deserTree(sha string) []item {
 
 //TODO: readTree() -> read the contents of a tree into the staging area
 
-func (got *Got) findTreeObjs(sha1 string) []string {
-	var objs []string
-	for _, obj := range got.deserTree(sha1) {
-		if fs.FileMode(obj.mode) == os.ModeDir {
-			objs = append(objs, got.findTreeObjs(shaToString(obj.sha))...)
-		} else {
-			objs = append(objs, shaToString(obj.sha))
-		}
-	}
-
-	return objs
-}
-
 // comeback
 func (got *Got) LsTree(ctx context.Context, path string) (io.Reader, error) {
 	return nil, nil
 }
 
-func (got *Got) findCommitObjs(sha1 string) []string {
-	var objs []string
-	_, ty, data, err := got.ReadObject(sha1)
-	got.GotErr(err)
-	if ty != "commit" {
-		got.GotErr(fmt.Errorf("object is not a commit object"))
-	}
-	//one tree and 0 or more parents
-	//find tree
-	data_s := strings.Split(string(data), "\n")
-	tree := ""
-	parents := []string{}
-	for _, item := range data_s {
-		if strings.HasPrefix(item, "tree") {
-			tree = strings.TrimPrefix(item, "tree ")
-			objs = append(objs, got.findTreeObjs(tree)...)
-		} else if strings.HasPrefix(item, "parent") {
-			parents = append(parents, strings.TrimPrefix(item, "parent "))
-		}
-	}
-	if len(parents) != 0 {
-		for _, par := range parents {
-			objs = append(objs, got.findCommitObjs(par)...)
-		}
+// missingObjs lists the objects the remote needs to have localSha, when it has remoteSha: every commit, tree and blob
+// reachable from localSha that remoteSha doesn't have. a remote commit we don't have ourselves can't tell us anything
+func (got *Got) missingObjs(localSha string, remoteSha string) ([]string, error) {
+	w := got.NewRevWalk()
+	local, err := got.ResolveRevision(strings.TrimSpace(localSha))
+	if err != nil {
+		return nil, err
 	}
-	return objs
-}
-
-func (got *Got) missingObjs(localSha string, remoteSha string) []string {
-	lo := got.findCommitObjs(localSha)
-	if remoteSha == "" {
-		return lo
+	if err := w.Push(local); err != nil {
+		return nil, err
 	}
-	ro := got.findCommitObjs(remoteSha)
-	var ret []string
-	ro_all := strings.Join(ro, "")
-	for _, obj := range lo {
-		if !strings.Contains(ro_all, obj) {
-			ret = append(ret, obj)
+	if remote, err := got.ResolveRevision(remoteSha); remoteSha != "" && err == nil {
+		if err := w.Hide(remote); err != nil {
+			return nil, err
 		}
 	}
-	return ret
+	var ret []string
+	err = w.Objects(func(sha Sha1, _, _ string) error {
+		ret = append(ret, shaToString(sha))
+		return nil
+	})
+	return ret, err
 }
 
-//TODO
-//func (got *Got) Log() {}
-//output the commit info starting from the latest commit
-
-//  |||BRANCHING|||| //
 // comeback to implement
-
 func (got *Got) Merge(ctx context.Context, comm string) error {
 	return nil
 }
-func (got *Got) Branches() (io.Reader, error) {
-	var b bytes.Buffer
-	return &b, nil
-}
-
-// comeback to implement
-func (got *Got) NewBranch(ctx context.Context, name string) error {
-	return nil
-}
-
-func (got *Got) DeleteBranch(ctx context.Context, name string) error {
-	return nil
-}
-
-func (got *Got) Checkout(ctx context.Context, name string) error {
-	return nil
-}
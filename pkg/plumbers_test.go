@@ -1,9 +1,63 @@
 package pkg
 
 import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
 func TestFindObject(t *testing.T) {
+	got := testRepo(t)
+	dir := filepath.Join(".git", "objects", "ab")
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"cdef" + strings.Repeat("1", 34), "cdef" + strings.Repeat("2", 34)} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0444); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := got.FindObject("abcdef"); !errors.Is(err, AmbiguousObjectErr) {
+		t.Errorf("FindObject(abcdef) = %v, want an ambiguous object error", err)
+	}
+	if p, err := got.FindObject("abcdef1"); err != nil || filepath.Base(p) != "cdef"+strings.Repeat("1", 34) {
+		t.Errorf("FindObject(abcdef1) = %s, %v", p, err)
+	}
+	//no objects/cd at all is no match, not a failure to read
+	if _, err := got.FindObject("cdef"); err == nil || errors.Is(err, AmbiguousObjectErr) || errors.Is(err, os.ErrNotExist) {
+		t.Errorf("FindObject(cdef) = %v, want no match", err)
+	}
+	if _, err := got.ResolveRevision("abcdef"); err == nil || !strings.Contains(err.Error(), "ambiguous") {
+		t.Errorf("ResolveRevision(abcdef) = %v", err)
+	}
+}
 
+func TestCatFileTag(t *testing.T) {
+	got := testRepo(t)
+	t.Setenv("GIT_COMMITTER_NAME", "Tag Ger")
+	t.Setenv("GIT_COMMITTER_EMAIL", "tagger@example.com")
+	t.Setenv("GIT_COMMITTER_DATE", "1700000000 +0100")
+	one := testCommit(t, got, 1700000000)
+	if err := got.writeRef("refs/heads/master", one); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.CreateTag(context.Background(), "v1", "", true, "release one", false); err != nil {
+		t.Fatal(err)
+	}
+	rdr, err := got.CatFile(context.Background(), "v1", 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(rdr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "object " + shaToString(one) + "\ntype commit\ntag v1\ntagger Tag Ger <tagger@example.com> 1700000000 +0100\n\nrelease one\n"
+	if !strings.HasSuffix(string(b), want) {
+		t.Errorf("cat-file -p v1 printed\n%s\nwant it to end with\n%s", b, want)
+	}
 }
@@ -1,9 +1,9 @@
 package pkg
 
 import (
-	"bufio"
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
@@ -12,7 +12,6 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
-	"time"
 
 	"github.com/OLUWAMUYIWA/got/pkg/proto"
 )
@@ -59,20 +58,39 @@ func Init(ctx context.Context, name string) error {
 	return nil
 }
 
-//comeback
-func (g *Got) Diff(cached bool, output, arg string) error {
-	return nil
-}
-
 func (got *Got) Status(ctx context.Context) io.Reader {
 	var w bytes.Buffer
 	if is, _ := IsGit(); !is {
 		got.logger.Fatalf("Not a valid git directory\n")
 	}
-	added, modified, deleted := got.get_status()
-	if len(added) != 0 || len(modified) != 0 || len(deleted) != 0 {
-		got.logger.Printf("Changes to be committed:\n\n")
+	//what the index has against HEAD is what the next commit holds. a file moved with rm and add is paired up again
+	staged, err := got.diffPairs(DiffOpts{Cached: true, Renames: RenameOpts{Renames: true}})
+	if err != nil {
+		got.FatalErr(err)
+	}
+	if len(staged) != 0 {
+		fmt.Fprintf(&w, "Changes to be committed:\n")
+		for _, p := range staged {
+			c := p.change()
+			label, name := "modified:", c.Path
+			switch c.Kind {
+			case Added:
+				label = "new file:"
+			case Deleted:
+				label = "deleted:"
+			case TypeChanged:
+				label = "typechange:"
+			case Renamed, Copied:
+				label, name = "renamed:", c.OldPath+" -> "+c.Path
+				if c.Kind == Copied {
+					label = "copied:"
+				}
+			}
+			fmt.Fprintf(&w, "\t%-12s%s\n", label, name)
+		}
+		fmt.Fprintln(&w)
 	}
+	added, deleted, modified := got.get_status()
 	if len(modified) != 0 {
 		_, _ = fmt.Fprintf(&w, "Modified files: %v\n To stage changes 'git add <filenale>'\n", modified)
 	}
@@ -168,7 +186,7 @@ func (got *Got) addPaths(paths []string) error {
 		sort.Slice(new_inds, func(i, j int) bool {
 			return string(new_inds[i].path) < string(new_inds[j].path)
 		})
-		err = got.UpIndexEntries(new_inds)
+		err = got.UpIndexEntries(append(indexes.entries, new_inds...))
 		if err != nil {
 			return err
 		}
@@ -205,9 +223,10 @@ func (g *Got) rmPaths(cached bool, paths ...string) error {
 
 //https://github.com/git/git/blob/master/Documentation/technical/http-protocol.txt
 //https://github.com/git/git/blob/master/Documentation/technical/pack-protocol.txt
-//Commit first writes the tree from the set of staged objects
-//we have no  commit-tree method
-//comeback to fix the method. we need a way to writ the committed changes to stdout
+//Commit first writes the tree from the set of staged objects, then the commit object on top of it,
+//and finally moves the current branch (or a detached HEAD) to the new commit.
+//the move goes through a ref transaction that expects the branch to still be at the parent we used,
+//so a commit made in the meantime by another process is never lost
 // comeback `all` determines whether all known files (already in index) is automatically committed
 func (got *Got) Commit(ctx context.Context, msg string, all bool) (string, error) {
 	if is, _ := IsGit(); !is {
@@ -218,48 +237,47 @@ func (got *Got) Commit(ctx context.Context, msg string, all bool) (string, error
 	if err != nil {
 		return "", fmt.Errorf("Could not commit because: %w", err)
 	}
-	//get the parent sha from the HEAD file
-	parent, err := got.head.ReadCont()
-	if err != nil {
+	//the parent is whatever HEAD resolves to. an unborn branch has none, and must still be unborn when we are done
+	parent, err := got.readRef("HEAD")
+	unborn := errors.Is(err, RefNotFoundErr)
+	if err != nil && !unborn {
 		return "", fmt.Errorf("Commit error: %w", err)
 	}
-	//get uname and email from configuration file
-	uname, email, err := getConfig()
+	author, err := got.ident("AUTHOR")
+	if err != nil {
+		return "", err
+	}
+	committer, err := got.ident("COMMITTER")
 	if err != nil {
-		got.logger.Fatalf("during commit: %s \n", err)
+		return "", err
 	}
-	author := fmt.Sprintf("%s <%s>", uname, email)
-	//TODO check if formatting is correct
-	//format the time
-	t := time.Now()
-	commmit_time := t.Format(TIME_FORMAT)
 	var s strings.Builder
 	//write it in a commit format. specified in the progit book
 	s.WriteString(fmt.Sprintf("tree %s\n", tree))
-	s.WriteString(fmt.Sprintf("parent %s\n", parent))
-	s.WriteString(fmt.Sprintf("author %s %s\n", author, commmit_time))
-	s.WriteString(fmt.Sprintf("committer %s %s\n", author, commmit_time))
+	if !unborn {
+		s.WriteString(fmt.Sprintf("parent %s\n", shaToString(parent)))
+	}
+	s.WriteString(fmt.Sprintf("author %s\n", author.Format()))
+	s.WriteString(fmt.Sprintf("committer %s\n", committer.Format()))
 	s.WriteString(fmt.Sprintln())
-	s.WriteString(msg)
+	s.WriteString(strings.TrimSpace(msg))
 	s.WriteString(fmt.Sprintln())
 	//write the commit object
-	commit := &commitObj{data: []byte(s.String())}
-	_, err = commit.Hash(got.baseDir)
+	sha, err := HashObj("commit", []byte(s.String()), got.baseDir)
 	if err != nil {
-		//TODO: handle error
-	}
-	path := filepath.Join(".git", "refs", "head", "master")
-	//write the commit to refs/head/master. replace, no append
-	//this becomes the latest commit in the master branch.
-	//the refs/head/master is a symbolic to the
-	f, err := os.OpenFile(path, os.O_RDWR, 0666)
-	got.GotErr(err)
-	buf_f := bufio.NewWriter(f)
-	_, err = buf_f.WriteString(commit.sha)
-	buf_f.Flush()
-	got.GotErr(err)
-	os.Stdout.WriteString("Commit succeded")
-	return commit.sha, nil
+		return "", fmt.Errorf("Could not write commit object: %w", err)
+	}
+	//HEAD is followed to the branch it points at. a zero parent means the branch must not exist yet
+	logMsg := "commit: "
+	if unborn {
+		logMsg = "commit (initial): "
+	}
+	tx := got.NewRefTransaction(logMsg + strings.SplitN(strings.TrimSpace(msg), "\n", 2)[0])
+	tx.Update("HEAD", sha, parent)
+	if err := tx.Commit(); err != nil {
+		return "", fmt.Errorf("Could not update HEAD: %w", err)
+	}
+	return shaToString(sha), nil
 }
 
 //after refs and capabilities discovery, client may send the flush packet to tell the server it has ended
@@ -290,7 +308,10 @@ func (got *Got) Push(ctx context.Context, url string) (string, error) {
 	if err != nil {
 		return "", err
 	}
-	missings := got.missingObjs(string(localSha), remoteSha)
+	missings, err := got.missingObjs(string(localSha), remoteSha)
+	if err != nil {
+		return "", err
+	}
 	//TODO: inform the user
 	var s strings.Builder
 	s.WriteString(fmt.Sprintf("%s %s refs/heads/master%x report-status", remoteSha, localSha, 0))
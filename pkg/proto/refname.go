@@ -0,0 +1,63 @@
+package proto
+
+import (
+	"fmt"
+	"strings"
+)
+
+//  |||REF NAMES||| //
+//git is strict about what a ref may be called, because names end up as paths on disk, inside revisions and inside refspecs.
+//read: https://git-scm.com/docs/git-check-ref-format
+
+// CheckRefFormat checks a full ref name, like refs/heads/main, against git's rules.
+// allowOneLevel lets a name without a slash through, like HEAD
+func CheckRefFormat(name string, allowOneLevel bool) error {
+	return checkRefFormat(name, allowOneLevel, false)
+}
+
+// checkRefFormat is CheckRefFormat for refspecs too. allowPattern lets a single * through, like refs/heads/*
+func checkRefFormat(name string, allowOneLevel, allowPattern bool) error {
+	bad := func(why string) error {
+		return fmt.Errorf("'%s' is not a valid ref name: %s", name, why)
+	}
+	switch {
+	case name == "":
+		return bad("it is empty")
+	case name == "@":
+		return bad("it is the single character @")
+	case strings.HasPrefix(name, "/") || strings.HasSuffix(name, "/"):
+		return bad("it begins or ends with a slash")
+	case strings.HasSuffix(name, "."):
+		return bad("it ends with a dot")
+	case strings.Contains(name, ".."):
+		return bad("it contains two consecutive dots")
+	case strings.Contains(name, "@{"):
+		return bad("it contains @{")
+	case !allowOneLevel && !strings.Contains(name, "/"):
+		return bad("it has only one level")
+	}
+	for _, r := range name {
+		if r < 0x20 || r == 0x7f {
+			return bad("it contains a control character")
+		}
+		if r == '*' && allowPattern {
+			//one star is a pattern, a second one isn't
+			allowPattern = false
+			continue
+		}
+		if strings.ContainsRune(" ~^:?*[\\", r) {
+			return bad(fmt.Sprintf("it contains '%c'", r))
+		}
+	}
+	for _, part := range strings.Split(name, "/") {
+		switch {
+		case part == "":
+			return bad("it contains consecutive slashes")
+		case strings.HasPrefix(part, "."):
+			return bad("a component begins with a dot")
+		case strings.HasSuffix(part, ".lock"):
+			return bad("a component ends with .lock")
+		}
+	}
+	return nil
+}
@@ -13,44 +13,56 @@ type RefSpec struct {
 	Src, Dst        string
 }
 
+// Parse reads [+]<src>:<dst>. a refspec with no <src>, like :refs/heads/old, deletes <dst>,
+// and one with no colon, like refs/heads/main, has no <dst>
 func (r *RefSpecRaw) Parse() (*RefSpec, error) {
 
-	//split with the seperator ':'
-	splits := strings.FieldsFunc(string(*r), func(r rune) bool {
-		return r == ':'
-	})
+	//split with the seperator ':'. an empty side stays in as an empty string
+	splits := strings.Split(string(*r), ":")
 
 	//if more than two seperators exist, refspec is bad
 	if len(splits) > 2 {
-		return nil, fmt.Errorf("Bad Refspec")
+		return nil, fmt.Errorf("Bad Refspec: %s", string(*r))
 	}
 
 	res := &RefSpec{}
 
-	src, dst := "", ""
-
-	// if splits is just one, then `src` does not exist, and refspec is delete
+	src, dst := splits[0], ""
 	if len(splits) == 2 {
-		src = splits[0]
-		dst = splits[2]
-	} else {
 		dst = splits[1]
-		res.Delete = true
 	}
 
 	forceUp := false
 
-	if src[0] == '+' {
+	if len(src) != 0 && src[0] == '+' {
 		forceUp = true
-		src = strings.TrimLeft(src, "+")
+		src = src[1:]
+	}
+
+	// if src is empty, the refspec deletes dst
+	if src == "" {
+		if dst == "" {
+			return nil, fmt.Errorf("Bad Refspec: %s", string(*r))
+		}
+		res.Delete = true
 	}
 
 	wildCarsS := strings.Count(src, "*")
 	wildCardD := strings.Count(dst, "*")
-	if wildCardD != wildCarsS || wildCardD > 1 || wildCarsS > 1 {
+	if wildCardD > 1 || wildCarsS > 1 || (dst != "" && !res.Delete && wildCardD != wildCarsS) || (res.Delete && wildCardD != 0) {
 		return nil, fmt.Errorf("Wildcard in refspec error")
 	}
 
+	//both sides are ref names, or patterns of them. a src may also be a short name like main
+	for _, name := range []string{src, dst} {
+		if name == "" {
+			continue
+		}
+		if err := checkRefFormat(name, true, true); err != nil {
+			return nil, fmt.Errorf("Bad Refspec: %w", err)
+		}
+	}
+
 	res.Src = src
 	res.Dst = dst
 	res.ForceUp = forceUp
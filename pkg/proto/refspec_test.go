@@ -0,0 +1,46 @@
+package proto_test
+
+import (
+	"testing"
+
+	"github.com/OLUWAMUYIWA/got/pkg/proto"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRefSpec(t *testing.T) {
+	assert := assert.New(t)
+	tests := []struct {
+		raw  string
+		want proto.RefSpec
+	}{
+		{"+refs/heads/*:refs/remotes/origin/*", proto.RefSpec{ForceUp: true, Src: "refs/heads/*", Dst: "refs/remotes/origin/*"}},
+		{"refs/heads/main:refs/heads/main", proto.RefSpec{Src: "refs/heads/main", Dst: "refs/heads/main"}},
+		{"main", proto.RefSpec{Src: "main"}},
+		{":refs/heads/old", proto.RefSpec{Delete: true, Dst: "refs/heads/old"}},
+		{"refs/heads/feat*:refs/remotes/origin/feat*", proto.RefSpec{Src: "refs/heads/feat*", Dst: "refs/remotes/origin/feat*"}},
+	}
+	for _, tt := range tests {
+		raw := proto.RefSpecRaw(tt.raw)
+		spec, err := raw.Parse()
+		if assert.NoError(err, tt.raw) {
+			assert.Equal(tt.want, *spec, tt.raw)
+		}
+	}
+
+	for _, bad := range []string{
+		"",
+		":",
+		"+",
+		"a:b:c",
+		"refs/heads/*:refs/remotes/origin/main",
+		"refs/heads/*/*:refs/remotes/origin/*/*",
+		":refs/heads/*",
+		"refs/heads/ma..in:refs/heads/main",
+		"refs/heads/main:refs/heads/main.lock",
+		"refs/heads/a b:refs/heads/a",
+	} {
+		raw := proto.RefSpecRaw(bad)
+		_, err := raw.Parse()
+		assert.Error(err, bad)
+	}
+}
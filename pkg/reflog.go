@@ -0,0 +1,385 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//  |||REFLOG||| //
+//every time a ref moves, a line is appended to .git/logs/<ref>:
+//<old sha> <new sha> <committer> <timestamp> <timezone>\t<message>
+//the log of HEAD records every move of HEAD, whether HEAD itself changed or the branch it points to.
+//that is what lets a user find a commit again after a bad reset, through `got reflog` or HEAD@{n}.
+//read: https://git-scm.com/docs/git-reflog
+
+type reflogEntry struct {
+	old, new Sha1
+	who      Sign
+	msg      string
+}
+
+func (e reflogEntry) format() string {
+	return fmt.Sprintf("%s %s %s\t%s\n", shaToString(e.old), shaToString(e.new), e.who.Format(), e.msg)
+}
+
+func (s refStore) logFile(name string) string {
+	return filepath.Join(s.dir, "logs", filepath.FromSlash(name))
+}
+
+// shouldLog tells whether updates to the ref are recorded. a ref with a log keeps getting one.
+// otherwise, like core.logAllRefUpdates=true in git, only HEAD, branches, remote-tracking branches and notes are logged
+func (s refStore) shouldLog(name string) bool {
+	if _, err := os.Stat(s.logFile(name)); err == nil {
+		return true
+	}
+	if conf, err := parseConfig(filepath.Join(s.dir, "config")); err == nil {
+		if v, ok := conf.get([]string{"core", "logAllRefUpdates"}); ok {
+			switch strings.ToLower(v) {
+			case "false", "no", "off", "0":
+				return false
+			case "always":
+				return true
+			}
+		}
+	}
+	if name == "HEAD" {
+		return true
+	}
+	for _, prefix := range []string{"refs/heads/", "refs/remotes/", "refs/notes/"} {
+		if strings.HasPrefix(name, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// appendLog adds an entry to the log of the ref, if the ref is logged at all
+func (s refStore) appendLog(name string, e reflogEntry) error {
+	if !s.shouldLog(name) {
+		return nil
+	}
+	path := s.logFile(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not open reflog of %s: %w", name, err)
+	}
+	defer f.Close()
+	_, err = f.WriteString(e.format())
+	return err
+}
+
+// readLog returns the entries of the log of the ref, oldest first. a ref without a log has no entries
+func (s refStore) readLog(name string) ([]reflogEntry, error) {
+	data, err := os.ReadFile(s.logFile(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []reflogEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if len(line) < 83 || line[40] != ' ' || line[81] != ' ' {
+			//git skips lines it can't make sense of, so do we
+			continue
+		}
+		e := reflogEntry{old: strToSha(line[:40]), new: strToSha(line[41:81])}
+		ident := line[82:]
+		if tab := strings.IndexByte(ident, '\t'); tab >= 0 {
+			e.msg = ident[tab+1:]
+			ident = ident[:tab]
+		}
+		who, err := parseSign([]byte(ident))
+		if err != nil {
+			continue
+		}
+		e.who = who
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// writeLog replaces the log of the ref, through a lock file like the refs themselves
+func (s refStore) writeLog(name string, entries []reflogEntry) error {
+	var b bytes.Buffer
+	for _, e := range entries {
+		b.WriteString(e.format())
+	}
+	path := s.logFile(name)
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not lock reflog of %s: %w", name, err)
+	}
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		os.Remove(lock)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(lock)
+		return err
+	}
+	return os.Rename(lock, path)
+}
+
+// deleteLog removes the log of a deleted ref, along with the directories it leaves empty
+func (s refStore) deleteLog(name string) error {
+	path := s.logFile(name)
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	stop := s.logFile("refs")
+	for dir := filepath.Dir(path); len(dir) > len(stop); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+	return nil
+}
+
+// renameLog moves the log of a renamed ref along with it
+func (s refStore) renameLog(from, to string) error {
+	if _, err := os.Stat(s.logFile(from)); err != nil {
+		return nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.logFile(to)), 0777); err != nil {
+		return err
+	}
+	if err := os.Rename(s.logFile(from), s.logFile(to)); err != nil {
+		return err
+	}
+	return s.deleteLog(from)
+}
+
+// reflogIdent is who the entries we log are by. unlike a commit, a missing identity is no reason to fail,
+// so we fall back to the user of the system, the way git does
+func (got *Got) reflogIdent() Sign {
+	who, err := got.ident("COMMITTER")
+	if err == nil {
+		return who
+	}
+	who.time = time.Now()
+	if who.name == "" || who.email == "" {
+		name := "unknown"
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+		host, _ := os.Hostname()
+		if who.name == "" {
+			who.name = name
+		}
+		if who.email == "" {
+			who.email = fmt.Sprintf("%s@%s", name, host)
+		}
+	}
+	return who
+}
+
+// logRef records a move of a ref that didn't go through a transaction, like HEAD switching branches
+func (got *Got) logRef(name string, old, new Sha1, msg string) error {
+	return got.refs().appendLog(name, reflogEntry{old: old, new: new, who: got.reflogIdent(), msg: msg})
+}
+
+// splitReflogSpec splits `main@{2}` into main and 2. it reports false when there is no @{...} at the end
+func splitReflogSpec(rev string) (string, string, bool) {
+	at := strings.LastIndex(rev, "@{")
+	if at < 0 || !strings.HasSuffix(rev, "}") {
+		return "", "", false
+	}
+	return rev[:at], rev[at+2 : len(rev)-1], true
+}
+
+// reflogRef finds the ref whose log a reflog spec reads. an empty name means the current branch, or HEAD when detached
+func (got *Got) reflogRef(name string) (string, error) {
+	if name == "" {
+		if branch, ok := got.headBranch(); ok {
+			return branch, nil
+		}
+		return "HEAD", nil
+	}
+	if full, ok := got.dwimRef(name); ok {
+		return full, nil
+	}
+	return "", fmt.Errorf("%w: %s", RefNotFoundErr, name)
+}
+
+// resolveReflog resolves `<ref>@{<n>}` to the value the ref had n moves ago,
+// and `<ref>@{<date>}` to the value it had at that date
+func (got *Got) resolveReflog(rev string) (Sha1, error) {
+	name, spec, ok := splitReflogSpec(rev)
+	if !ok {
+		return Sha1{}, fmt.Errorf("not a reflog spec: %s", rev)
+	}
+	ref, err := got.reflogRef(name)
+	if err != nil {
+		return Sha1{}, err
+	}
+	entries, err := got.refs().readLog(ref)
+	if err != nil {
+		return Sha1{}, err
+	}
+	if len(entries) == 0 {
+		return Sha1{}, fmt.Errorf("log for '%s' is empty", strings.TrimPrefix(ref, branchPrefix))
+	}
+	if n, err := strconv.Atoi(spec); err == nil && n >= 0 {
+		if n >= len(entries) {
+			return Sha1{}, fmt.Errorf("log for '%s' only has %d entries", strings.TrimPrefix(ref, branchPrefix), len(entries))
+		}
+		return entries[len(entries)-1-n].new, nil
+	}
+	at, err := parseApproxDate(spec, time.Now())
+	if err != nil {
+		return Sha1{}, err
+	}
+	//the newest entry made at or before the date holds the value the ref had then
+	for i := len(entries) - 1; i >= 0; i-- {
+		if !entries[i].who.time.After(at) {
+			return entries[i].new, nil
+		}
+	}
+	//the date is older than the log. the best we know is where the ref was before its first recorded move
+	//a ref that didn't exist before its first entry has nothing older than that entry to offer
+	got.logger.Printf("warning: log for '%s' only goes back to %s\n", strings.TrimPrefix(ref, branchPrefix), entries[0].who.time.Format(RFC2822))
+	if entries[0].old == (Sha1{}) {
+		return entries[0].new, nil
+	}
+	return entries[0].old, nil
+}
+
+// Reflog shows the log of a ref, newest first, the way `git reflog show` does. an empty ref means HEAD
+func (got *Got) Reflog(ctx context.Context, ref string) (io.Reader, error) {
+	if ref == "" {
+		ref = "HEAD"
+	}
+	full := ref
+	if ref != "HEAD" {
+		var ok bool
+		if full, ok = got.dwimRef(ref); !ok {
+			return nil, fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree", ref)
+		}
+	}
+	entries, err := got.refs().readLog(full)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for i := len(entries) - 1; i >= 0; i-- {
+		fmt.Fprintf(&b, "%s %s@{%d}: %s\n", shaToString(entries[i].new)[:7], ref, len(entries)-1-i, entries[i].msg)
+	}
+	return &b, nil
+}
+
+// ReflogExpire drops the entries older than expire from the logs of refs, or of every ref with a log when all is set.
+// expire is a date like 90.days.ago, or never to keep everything
+func (got *Got) ReflogExpire(ctx context.Context, refs []string, all bool, expire string) error {
+	if expire == "never" || expire == "false" {
+		return nil
+	}
+	cutoff, err := parseApproxDate(expire, time.Now())
+	if expire == "all" {
+		cutoff, err = time.Now().Add(time.Hour), nil
+	}
+	if err != nil {
+		return err
+	}
+	s := got.refs()
+	if all {
+		refs = refs[:0]
+		if _, err := os.Stat(s.logFile("HEAD")); err == nil {
+			refs = append(refs, "HEAD")
+		}
+		err := filepath.WalkDir(s.logFile("refs"), func(path string, d os.DirEntry, err error) error {
+			if err != nil {
+				if errors.Is(err, os.ErrNotExist) {
+					return nil
+				}
+				return err
+			}
+			if d.IsDir() || strings.HasSuffix(d.Name(), ".lock") {
+				return nil
+			}
+			rel, err := filepath.Rel(filepath.Join(s.dir, "logs"), path)
+			if err != nil {
+				return err
+			}
+			refs = append(refs, filepath.ToSlash(rel))
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for _, ref := range refs {
+		full := ref
+		if ref != "HEAD" && !strings.HasPrefix(ref, "refs/") {
+			var ok bool
+			if full, ok = got.dwimRef(ref); !ok {
+				return fmt.Errorf("%w: %s", RefNotFoundErr, ref)
+			}
+		}
+		entries, err := s.readLog(full)
+		if err != nil {
+			return err
+		}
+		kept := entries[:0]
+		for _, e := range entries {
+			if !e.who.time.Before(cutoff) {
+				kept = append(kept, e)
+			}
+		}
+		if len(kept) == len(entries) {
+			continue
+		}
+		if err := s.writeLog(full, kept); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReflogDelete removes single entries, named like HEAD@{2}, from their logs
+func (got *Got) ReflogDelete(ctx context.Context, specs []string) error {
+	s := got.refs()
+	for _, spec := range specs {
+		name, n, ok := splitReflogSpec(spec)
+		idx, err := strconv.Atoi(n)
+		if !ok || err != nil || idx < 0 {
+			return fmt.Errorf("not a reflog: %s", spec)
+		}
+		ref := "HEAD"
+		if name != "HEAD" {
+			if ref, err = got.reflogRef(name); err != nil {
+				return err
+			}
+		}
+		entries, err := s.readLog(ref)
+		if err != nil {
+			return err
+		}
+		if idx >= len(entries) {
+			return fmt.Errorf("no reflog entry for %s", spec)
+		}
+		pos := len(entries) - 1 - idx
+		entries = append(entries[:pos], entries[pos+1:]...)
+		if err := s.writeLog(ref, entries); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,18 +2,26 @@ package pkg
 
 import (
 	"bytes"
+	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"io"
+	"io/fs"
 	"os"
+	"path/filepath"
+	"sort"
 	"strings"
 	"unicode/utf8"
+
+	"github.com/OLUWAMUYIWA/got/pkg/proto"
 )
 
 type RefType int
 
 var HeadNotINitErr = fmt.Errorf("Head not yet initialized")
 
+var RefNotFoundErr = fmt.Errorf("No such ref")
+
 const (
 	heads RefType = 0
 	remotes
@@ -23,15 +31,19 @@ const (
 type Ref struct {
 	path  string
 	_type RefType
+	//dir is the .git directory the ref belongs to. path is relative to it
+	dir string
 }
 
 //initialize a ref
 //this initializer does nothing except storing the values
 //it expects a full path because it does not have access to the working directory
 func InitRef(fullpath string, _type RefType) *Ref {
-	return &Ref{fullpath, _type}
+	return &Ref{fullpath, _type, ""}
 
 }
+// RefFromSym reads a symbolic ref file, like .git/HEAD, and returns the ref it points to.
+// a detached HEAD holds a sha instead, and the ref is then HEAD itself
 func RefFromSym(path string, _type RefType) (*Ref, error) {
 	b, err := os.ReadFile(path)
 	if err != nil {
@@ -41,36 +53,34 @@ func RefFromSym(path string, _type RefType) (*Ref, error) {
 		return nil, fmt.Errorf("Could not open HEAD file: %w\n", err)
 	}
 	pre := []byte("ref: ")
+	b = bytes.TrimSpace(b)
 	if len(b) == 0 {
 		return nil, HeadNotINitErr
 	}
 	if bytes.HasPrefix(b, pre) {
 		b = bytes.TrimPrefix(b, pre)
+	} else if h, err := hex.DecodeString(string(b)); err == nil && len(h) == 20 {
+		return &Ref{filepath.Base(path), _type, filepath.Dir(path)}, nil
 	} else {
-		return nil, fmt.Errorf("HEAD file is not well formatted")
+		return nil, fmt.Errorf("%s is not well formatted", filepath.Base(path))
 	}
 	p := string(b)
 
 	if utf8.ValidString(p) {
-		return &Ref{p, _type}, nil
+		return &Ref{p, _type, filepath.Dir(path)}, nil
 	} else {
 		return nil, fmt.Errorf("Invalid utf-8 in ref symlink")
 	}
 }
 
 //contents of refs are commits
+//we return the hex sha the ref resolves to, whether it is loose or packed
 func (r *Ref) ReadCont() ([]byte, error) {
-	f, err := os.OpenFile(r.path, os.O_RDONLY, 0)
+	sha, err := refStore{r.dir}.read(r.path)
 	if err != nil {
-		return nil, fmt.Errorf("Could not open ref: %s because: %w\n", r.path, err)
-	}
-
-	defer f.Close()
-	if b, err := io.ReadAll(f); err != nil {
-		return nil, fmt.Errorf("Could not read from ref: %s because: %w\n", r.path, err)
-	} else {
-		return b, nil
+		return nil, err
 	}
+	return []byte(shaToString(sha)), nil
 }
 
 // //sha-1 of the last commit (or shall we say latest?)
@@ -88,7 +98,612 @@ func (r *Ref) ReadCont() ([]byte, error) {
 // }
 
 //comeback
-func (r *Ref) UpdateCont(path string) {
-	pre := "ref: "
-	r.path = strings.Join([]string{pre, path}, "")
+// UpdateCont moves the ref from oldSha to newSha in a ref transaction, so it fails if the ref has moved in the meantime.
+// a zero oldSha means the ref must not exist yet
+func (r *Ref) UpdateCont(newSha, oldSha Sha1, msg string) error {
+	got := &Got{baseDir: filepath.Dir(r.dir)}
+	tx := got.NewRefTransaction(msg)
+	tx.Update(r.path, newSha, oldSha)
+	return tx.Commit()
+}
+
+//  |||REF STORE||| //
+//git keeps a ref in one of two places: a loose file under .git (e.g. .git/refs/heads/master) holding the sha,
+//or a line in .git/packed-refs. a loose ref always wins over a packed one of the same name.
+//read: https://git-scm.com/docs/git-pack-refs
+
+// refStore reads and writes the refs of the .git directory dir
+type refStore struct {
+	dir string
+}
+
+// packedRef is one line of packed-refs, plus the `^` line after it if the ref is an annotated tag
+type packedRef struct {
+	name      string
+	sha       Sha1
+	peeled    Sha1
+	hasPeeled bool
+}
+
+const packedRefsHeader = "# pack-refs with: peeled fully-peeled sorted \n"
+
+func (got *Got) refs() refStore {
+	return refStore{filepath.Join(got.baseDir, ".git")}
+}
+
+// file is where a loose ref lives on disk. names are always slash-separated and relative to .git
+func (s refStore) file(name string) string {
+	return filepath.Join(s.dir, filepath.FromSlash(name))
+}
+
+// readLoose returns the trimmed content of a loose ref file
+func (s refStore) readLoose(name string) (string, error) {
+	b, err := os.ReadFile(s.file(name))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", fmt.Errorf("%w: %s", RefNotFoundErr, name)
+		}
+		//a directory where we expected a file means the name is a prefix of other refs
+		if info, statErr := os.Stat(s.file(name)); statErr == nil && info.IsDir() {
+			return "", fmt.Errorf("%w: %s", RefNotFoundErr, name)
+		}
+		return "", fmt.Errorf("Could not read ref: %s because: %w", name, err)
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// packed parses packed-refs. a missing file just means nothing has been packed
+func (s refStore) packed() ([]packedRef, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, "packed-refs"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var refs []packedRef
+	for i, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		//a peeled line belongs to the ref right above it
+		if strings.HasPrefix(line, "^") {
+			h, err := hex.DecodeString(line[1:])
+			if err != nil || len(h) != 20 || len(refs) == 0 {
+				return nil, fmt.Errorf("packed-refs: bad peeled line %d", i+1)
+			}
+			refs[len(refs)-1].peeled = bytesToSha(h)
+			refs[len(refs)-1].hasPeeled = true
+			continue
+		}
+		splits := strings.SplitN(line, " ", 2)
+		h, err := hex.DecodeString(splits[0])
+		if len(splits) != 2 || err != nil || len(h) != 20 {
+			return nil, fmt.Errorf("packed-refs: bad line %d", i+1)
+		}
+		refs = append(refs, packedRef{name: splits[1], sha: bytesToSha(h)})
+	}
+	return refs, nil
+}
+
+// writePacked replaces packed-refs with refs. like every other write, it goes through a lock file
+func (s refStore) writePacked(refs []packedRef) error {
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].name < refs[j].name
+	})
+	var b bytes.Buffer
+	b.WriteString(packedRefsHeader)
+	for _, r := range refs {
+		fmt.Fprintf(&b, "%s %s\n", shaToString(r.sha), r.name)
+		if r.hasPeeled {
+			fmt.Fprintf(&b, "^%s\n", shaToString(r.peeled))
+		}
+	}
+	path := filepath.Join(s.dir, "packed-refs")
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not lock packed-refs: %w", err)
+	}
+	if _, err := f.Write(b.Bytes()); err != nil {
+		f.Close()
+		os.Remove(lock)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(lock)
+		return err
+	}
+	return os.Rename(lock, path)
+}
+
+// findPacked looks a single name up in packed-refs
+func (s refStore) findPacked(name string) (packedRef, bool, error) {
+	refs, err := s.packed()
+	if err != nil {
+		return packedRef{}, false, err
+	}
+	for _, r := range refs {
+		if r.name == name {
+			return r, true, nil
+		}
+	}
+	return packedRef{}, false, nil
+}
+
+// maxSymrefDepth bounds how many symbolic refs we follow. a chain longer than this is almost certainly a loop
+const maxSymrefDepth = 5
+
+// NotSymrefErr is returned when a symbolic ref was expected, but the ref holds a sha
+var NotSymrefErr = errors.New("not a symbolic ref")
+
+// readSymbolic returns the ref a symbolic ref points to. a ref holding a sha is not symbolic.
+// only loose refs can be symbolic, packed-refs holds nothing but shas
+func (s refStore) readSymbolic(name string) (string, bool, error) {
+	cont, err := s.readLoose(name)
+	if err != nil {
+		return "", false, err
+	}
+	if !strings.HasPrefix(cont, "ref: ") {
+		return "", false, nil
+	}
+	return strings.TrimSpace(strings.TrimPrefix(cont, "ref: ")), true, nil
+}
+
+// resolve follows the chain of symbolic refs that starts at name. it returns the ref at the end of the chain,
+// the one that holds a sha, along with that sha. when that ref doesn't exist, like the branch of an unborn HEAD,
+// its name still comes back, with a RefNotFoundErr
+func (s refStore) resolve(name string) (string, Sha1, error) {
+	for depth := 0; depth <= maxSymrefDepth; depth++ {
+		cont, err := s.readLoose(name)
+		if errors.Is(err, RefNotFoundErr) {
+			r, ok, perr := s.findPacked(name)
+			if perr != nil {
+				return name, Sha1{}, perr
+			}
+			if !ok {
+				return name, Sha1{}, err
+			}
+			return name, r.sha, nil
+		}
+		if err != nil {
+			return name, Sha1{}, err
+		}
+		if strings.HasPrefix(cont, "ref: ") {
+			name = strings.TrimSpace(strings.TrimPrefix(cont, "ref: "))
+			continue
+		}
+		h, err := hex.DecodeString(cont)
+		if err != nil || len(h) != 20 {
+			return name, Sha1{}, fmt.Errorf("ref %s is not well formatted", name)
+		}
+		return name, bytesToSha(h), nil
+	}
+	return name, Sha1{}, fmt.Errorf("ref %s: too many levels of symbolic refs", name)
+}
+
+// read resolves a ref to the sha it points at, following symbolic refs along the way
+func (s refStore) read(name string) (Sha1, error) {
+	_, sha, err := s.resolve(name)
+	return sha, err
+}
+
+// writeSymbolic points the symbolic ref name (e.g. HEAD) to another ref, through a lock file
+func (s refStore) writeSymbolic(name, target string) error {
+	if err := checkRefName(name); err != nil {
+		return err
+	}
+	if err := checkRefName(target); err != nil {
+		return err
+	}
+	path := s.file(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not lock %s: %w", name, err)
+	}
+	if _, err := f.WriteString("ref: " + target + "\n"); err != nil {
+		f.Close()
+		os.Remove(lock)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(lock)
+		return err
+	}
+	return os.Rename(lock, path)
+}
+
+// write points the loose ref at sha, without following symbolic refs and without checking what it held.
+// it writes a lock file first and renames it over the ref. a packed copy of the ref may stay behind, the loose one shadows it.
+// anything that moves a ref from one commit to another should go through a RefTransaction instead
+func (s refStore) write(name string, sha Sha1) error {
+	if err := checkRefName(name); err != nil {
+		return err
+	}
+	path := s.file(name)
+	if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+		return err
+	}
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not lock ref %s: %w", name, err)
+	}
+	if _, err := f.WriteString(shaToString(sha) + "\n"); err != nil {
+		f.Close()
+		os.Remove(lock)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(lock)
+		return err
+	}
+	return os.Rename(lock, path)
+}
+
+// delete removes a ref from both places it may live. it is only an error if it was in neither
+func (s refStore) delete(name string) error {
+	found := false
+	path := s.file(name)
+	if err := os.Remove(path); err == nil {
+		found = true
+		s.pruneDirs(filepath.Dir(path))
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	refs, err := s.packed()
+	if err != nil {
+		return err
+	}
+	kept := refs[:0]
+	for _, r := range refs {
+		if r.name != name {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) != len(refs) {
+		found = true
+		if err := s.writePacked(kept); err != nil {
+			return err
+		}
+	}
+	if !found {
+		return fmt.Errorf("%w: %s", RefNotFoundErr, name)
+	}
+	return nil
+}
+
+// listLoose lists the names of the loose refs under prefix
+func (s refStore) listLoose(prefix string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(s.file(prefix), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() || strings.HasSuffix(d.Name(), ".lock") {
+			return nil
+		}
+		rel, err := filepath.Rel(s.dir, path)
+		if err != nil {
+			return err
+		}
+		names = append(names, filepath.ToSlash(rel))
+		return nil
+	})
+	return names, err
+}
+
+// list lists the names of the refs under prefix, e.g. refs/heads, loose and packed together, sorted
+func (s refStore) list(prefix string) ([]string, error) {
+	loose, err := s.listLoose(prefix)
+	if err != nil {
+		return nil, err
+	}
+	packed, err := s.packed()
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var names []string
+	for _, n := range loose {
+		seen[n] = true
+		names = append(names, n)
+	}
+	for _, r := range packed {
+		if !seen[r.name] && strings.HasPrefix(r.name, strings.TrimSuffix(prefix, "/")+"/") {
+			seen[r.name] = true
+			names = append(names, r.name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// refFile is where a loose ref of this repository lives on disk
+func (got *Got) refFile(name string) string {
+	return got.refs().file(name)
+}
+
+func (got *Got) readRef(name string) (Sha1, error) {
+	return got.refs().read(name)
+}
+
+func (got *Got) writeRef(name string, sha Sha1) error {
+	return got.refs().write(name, sha)
+}
+
+func (got *Got) deleteRef(name string) error {
+	return got.refs().delete(name)
+}
+
+func (got *Got) listRefs(prefix string) ([]string, error) {
+	return got.refs().list(prefix)
+}
+
+// peel follows annotated tags down to the object they finally point to. it reports whether there was anything to peel
+func (got *Got) peel(sha Sha1) (Sha1, bool, error) {
+	peeled := false
+	for {
+		_, ty, data, err := got.ReadObject(shaToString(sha))
+		if err != nil {
+			return Sha1{}, false, err
+		}
+		if ty != "tag" {
+			return sha, peeled, nil
+		}
+		t, err := parseTag(bytes.NewReader(data))
+		if err != nil {
+			return Sha1{}, false, fmt.Errorf("tag %s is not well formatted", shaToString(sha))
+		}
+		sha, peeled = t.object, true
+	}
+}
+
+// PackRefs moves loose refs into packed-refs and deletes the loose files.
+// like git, only tags are packed unless all is set. symbolic refs are never packed
+func (got *Got) PackRefs(ctx context.Context, all bool) error {
+	s := got.refs()
+	packed, err := s.packed()
+	if err != nil {
+		return err
+	}
+	byName := make(map[string]packedRef)
+	for _, r := range packed {
+		byName[r.name] = r
+	}
+	prefix := "refs/tags"
+	if all {
+		prefix = "refs"
+	}
+	loose, err := s.listLoose(prefix)
+	if err != nil {
+		return err
+	}
+	var pruned []string
+	for _, name := range loose {
+		cont, err := s.readLoose(name)
+		if err != nil {
+			return err
+		}
+		if strings.HasPrefix(cont, "ref: ") {
+			continue
+		}
+		h, err := hex.DecodeString(cont)
+		if err != nil || len(h) != 20 {
+			return fmt.Errorf("ref %s is not well formatted", name)
+		}
+		byName[name] = packedRef{name: name, sha: bytesToSha(h)}
+		pruned = append(pruned, name)
+	}
+	refs := make([]packedRef, 0, len(byName))
+	for _, r := range byName {
+		//we write a fully-peeled file, so every tag gets its peeled line again
+		if strings.HasPrefix(r.name, "refs/tags/") {
+			p, ok, err := got.peel(r.sha)
+			if err != nil {
+				return fmt.Errorf("Could not peel %s: %w", r.name, err)
+			}
+			if ok {
+				r.peeled, r.hasPeeled = p, true
+			}
+		}
+		refs = append(refs, r)
+	}
+	if err := s.writePacked(refs); err != nil {
+		return err
+	}
+	//a loose ref that moved while we packed is newer than its packed copy and stays. it shadows the packed one
+	for _, name := range pruned {
+		if err := s.prune(name, byName[name].sha); err != nil {
+			got.logger.Printf("warning: could not prune %s: %s\n", name, err)
+		}
+	}
+	//the directories the loose refs lived in are left empty. refs/heads and refs/tags themselves stay
+	for _, dir := range []string{"refs/heads", "refs/tags", "refs/remotes"} {
+		removeEmptyDirs(s.file(dir))
+	}
+	return nil
+}
+
+// prune deletes the loose copy of a ref that was just packed. it takes the ref's lock and deletes it
+// only if it still holds sha
+func (s refStore) prune(name string, sha Sha1) error {
+	path := s.file(name)
+	lock := path + ".lock"
+	f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("Could not lock ref %s: %w", name, err)
+	}
+	f.Close()
+	defer os.Remove(lock)
+	cont, err := s.readLoose(name)
+	if errors.Is(err, RefNotFoundErr) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if cont != shaToString(sha) {
+		return nil
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// removeEmptyDirs removes every empty directory below root, but not root itself
+func removeEmptyDirs(root string) {
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() {
+			sub := filepath.Join(root, e.Name())
+			removeEmptyDirs(sub)
+			os.Remove(sub)
+		}
+	}
+}
+
+// headBranch reads HEAD afresh and returns the full name of the branch it points to.
+// it reports false for a detached HEAD
+func (got *Got) headBranch() (string, bool) {
+	target, ok, err := got.refs().readSymbolic("HEAD")
+	if err != nil || !ok {
+		return "", false
+	}
+	return target, true
+}
+
+// SymbolicRef reads the ref a symbolic ref points to, shortened when short is set. a detached HEAD gives NotSymrefErr
+func (got *Got) SymbolicRef(name string, short bool) (string, error) {
+	target, ok, err := got.refs().readSymbolic(name)
+	if err != nil {
+		return "", err
+	}
+	if !ok {
+		return "", fmt.Errorf("ref %s is %w", name, NotSymrefErr)
+	}
+	if short {
+		return shortRef(target), nil
+	}
+	return target, nil
+}
+
+// SetSymbolicRef points name to target. like git, HEAD may only point inside refs/.
+// a non-empty msg is recorded in the reflog of name
+func (got *Got) SetSymbolicRef(ctx context.Context, name, target, msg string) error {
+	if name == "HEAD" && !strings.HasPrefix(target, "refs/") {
+		return fmt.Errorf("Refusing to point HEAD outside of refs/")
+	}
+	s := got.refs()
+	old, _ := s.read(name)
+	if err := s.writeSymbolic(name, target); err != nil {
+		return err
+	}
+	if msg == "" {
+		return nil
+	}
+	new, _ := s.read(name)
+	return s.appendLog(name, reflogEntry{old: old, new: new, who: got.reflogIdent(), msg: msg})
+}
+
+// DeleteSymbolicRef removes a symbolic ref, never the ref it points to. HEAD can't be deleted
+func (got *Got) DeleteSymbolicRef(ctx context.Context, name string) error {
+	if name == "HEAD" {
+		return fmt.Errorf("deleting '%s' is not allowed", name)
+	}
+	s := got.refs()
+	if _, ok, err := s.readSymbolic(name); err != nil {
+		return err
+	} else if !ok {
+		return fmt.Errorf("ref %s is %w", name, NotSymrefErr)
+	}
+	if err := os.Remove(s.file(name)); err != nil {
+		return err
+	}
+	s.pruneDirs(filepath.Dir(s.file(name)))
+	return s.deleteLog(name)
+}
+
+//  |||REF NAMES||| //
+//the rules themselves live in proto, because refspecs have to follow them too.
+//read: https://git-scm.com/docs/git-check-ref-format
+
+// CheckRefFormat checks a full ref name, like refs/heads/main, against git's rules.
+// allowOneLevel lets a name without a slash through, like HEAD
+func CheckRefFormat(name string, allowOneLevel bool) error {
+	return proto.CheckRefFormat(name, allowOneLevel)
+}
+
+// NormalizeRefName collapses runs of slashes and drops the leading one, like `check-ref-format --normalize`
+func NormalizeRefName(name string) string {
+	parts := strings.Split(name, "/")
+	kept := parts[:0]
+	for _, p := range parts {
+		if p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, "/")
+}
+
+// checkRefName is what every path that writes a ref goes through. names outside refs/ must be
+// one-level pseudo refs like HEAD or ORIG_HEAD
+func checkRefName(name string) error {
+	if strings.HasPrefix(name, "refs/") {
+		return CheckRefFormat(name, false)
+	}
+	for _, r := range name {
+		if !(r >= 'A' && r <= 'Z') && r != '_' {
+			return fmt.Errorf("'%s' is not a valid ref name: refs outside refs/ are all uppercase, like HEAD", name)
+		}
+	}
+	return CheckRefFormat(name, true)
+}
+
+// CheckBranchName checks a short branch name, after expanding @{-n} the way `check-ref-format --branch` does.
+// it returns the name the branch would have
+func (got *Got) CheckBranchName(name string) (string, error) {
+	name = got.ExpandPrevious(name)
+	if err := checkBranchName(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// checkBranchName is what a new branch name has to pass. on top of the rules for refs, it can't look like an option or be HEAD
+func checkBranchName(name string) error {
+	if strings.HasPrefix(name, "-") || name == "HEAD" || CheckRefFormat(branchPrefix+name, false) != nil {
+		return fmt.Errorf("'%s' is not a valid branch name", name)
+	}
+	return nil
+}
+
+// dwimRef finds the ref a short name means. git looks in this order:
+// the name as is, then under refs/, refs/tags/, refs/heads/, refs/remotes/ and finally refs/remotes/<name>/HEAD
+func (got *Got) dwimRef(name string) (string, bool) {
+	for _, format := range []string{"%s", "refs/%s", "refs/tags/%s", "refs/heads/%s", "refs/remotes/%s", "refs/remotes/%s/HEAD"} {
+		full := fmt.Sprintf(format, name)
+		if _, err := got.readRef(full); err == nil {
+			return full, true
+		}
+	}
+	return "", false
+}
+
+// resolveCommitish turns any revision into the sha of the commit it names, peeling tags on the way
+func (got *Got) resolveCommitish(name string) (Sha1, error) {
+	return got.peelRev(name, "commit")
 }
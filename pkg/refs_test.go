@@ -0,0 +1,180 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestPackRefs(t *testing.T) {
+	got := testRepo(t)
+	a, b := strToSha("1111111111111111111111111111111111111111"), strToSha("2222222222222222222222222222222222222222")
+	if err := got.writeRef("refs/heads/a", a); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.writeRef("refs/heads/b", b); err != nil {
+		t.Fatal(err)
+	}
+	//another process holds the lock on b. it gets packed, but its loose file has to stay
+	lock := got.refFile("refs/heads/b") + ".lock"
+	if err := os.WriteFile(lock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.PackRefs(context.Background(), true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(got.refFile("refs/heads/a")); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("loose refs/heads/a was not pruned: %v", err)
+	}
+	if _, err := os.Stat(got.refFile("refs/heads/b")); err != nil {
+		t.Errorf("locked refs/heads/b was pruned: %v", err)
+	}
+	if _, err := os.Stat(lock); err != nil {
+		t.Errorf("somebody else's lock was removed: %v", err)
+	}
+	packed, err := got.refs().packed()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(packed) != 2 {
+		t.Errorf("packed-refs holds %d refs, want 2", len(packed))
+	}
+	for name, want := range map[string]Sha1{"refs/heads/a": a, "refs/heads/b": b} {
+		if sha, err := got.readRef(name); err != nil || sha != want {
+			t.Errorf("readRef(%s) = %s, %v", name, shaToString(sha), err)
+		}
+	}
+}
+
+func TestPackRefsPeelError(t *testing.T) {
+	got := testRepo(t)
+	//a tag pointing at an object we don't have can't be peeled, and packing it must say so
+	if err := got.writeRef("refs/tags/v1", strToSha("3333333333333333333333333333333333333333")); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.PackRefs(context.Background(), false); err == nil {
+		t.Fatal("PackRefs packed a tag it could not peel")
+	}
+	if _, err := os.Stat(got.refFile("refs/tags/v1")); err != nil {
+		t.Errorf("loose tag was pruned after a failed pack: %v", err)
+	}
+}
+
+func TestPackedAndLooseRefs(t *testing.T) {
+	got := testRepo(t)
+	a, b, c := strToSha("1111111111111111111111111111111111111111"), strToSha("2222222222222222222222222222222222222222"),
+		strToSha("3333333333333333333333333333333333333333")
+	s := got.refs()
+	if err := s.writePacked([]packedRef{{name: "refs/heads/a", sha: a}, {name: "refs/heads/b", sha: b}}); err != nil {
+		t.Fatal(err)
+	}
+	//a loose ref is newer than the packed copy of it
+	if err := got.writeRef("refs/heads/b", c); err != nil {
+		t.Fatal(err)
+	}
+	for name, want := range map[string]Sha1{"refs/heads/a": a, "refs/heads/b": c} {
+		if sha, err := got.readRef(name); err != nil || sha != want {
+			t.Errorf("readRef(%s) = %s, %v", name, shaToString(sha), err)
+		}
+	}
+	names, err := got.listRefs("refs/heads")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(names, " ") != "refs/heads/a refs/heads/b" {
+		t.Errorf("listRefs = %v", names)
+	}
+	//a symref can point at a ref that is only packed
+	if err := s.writeSymbolic("HEAD", "refs/heads/a"); err != nil {
+		t.Fatal(err)
+	}
+	if target, sha, err := s.resolve("HEAD"); err != nil || target != "refs/heads/a" || sha != a {
+		t.Errorf("resolve(HEAD) = %s %s, %v", target, shaToString(sha), err)
+	}
+	//deleting b takes both copies, so the packed one doesn't show through
+	if err := got.deleteRef("refs/heads/b"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.readRef("refs/heads/b"); !errors.Is(err, RefNotFoundErr) {
+		t.Errorf("refs/heads/b is still there: %v", err)
+	}
+	if sha, err := got.readRef("refs/heads/a"); err != nil || sha != a {
+		t.Errorf("readRef(refs/heads/a) = %s, %v", shaToString(sha), err)
+	}
+}
+
+func TestSymrefChain(t *testing.T) {
+	got := testRepo(t)
+	ctx := context.Background()
+	one := strToSha("1111111111111111111111111111111111111111")
+	s := got.refs()
+	if err := got.writeRef("refs/heads/main", one); err != nil {
+		t.Fatal(err)
+	}
+	//HEAD -> refs/s1 -> ... -> refs/s<n> -> refs/heads/main
+	chain := func(n int) {
+		t.Helper()
+		target := "refs/heads/main"
+		for i := n; i >= 1; i-- {
+			name := fmt.Sprintf("refs/s%d", i)
+			if err := s.writeSymbolic(name, target); err != nil {
+				t.Fatal(err)
+			}
+			target = name
+		}
+		if err := s.writeSymbolic("HEAD", target); err != nil {
+			t.Fatal(err)
+		}
+	}
+	//five symbolic refs in a row are followed, a sixth is one too many
+	chain(maxSymrefDepth - 1)
+	if target, sha, err := s.resolve("HEAD"); err != nil || target != "refs/heads/main" || sha != one {
+		t.Errorf("resolve(HEAD) through %d symrefs = %s %s, %v", maxSymrefDepth, target, shaToString(sha), err)
+	}
+	chain(maxSymrefDepth)
+	if _, _, err := s.resolve("HEAD"); err == nil || !strings.Contains(err.Error(), "too many levels") {
+		t.Errorf("resolve(HEAD) through %d symrefs gave %v", maxSymrefDepth+1, err)
+	}
+	//a loop ends the same way
+	if err := s.writeSymbolic("refs/s1", "refs/s2"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.writeSymbolic("refs/s2", "refs/s1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.readRef("refs/s1"); err == nil {
+		t.Error("a symref loop resolved")
+	}
+
+	//an update through a symref moves the ref at the end of the chain, and deleting the symref leaves that alone
+	two := strToSha("2222222222222222222222222222222222222222")
+	if err := s.writeSymbolic("refs/link", "refs/heads/main"); err != nil {
+		t.Fatal(err)
+	}
+	tx := got.NewRefTransaction("test")
+	tx.Update("refs/link", two, one)
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	if sha, err := got.readRef("refs/heads/main"); err != nil || sha != two {
+		t.Errorf("refs/heads/main = %s, %v", shaToString(sha), err)
+	}
+	if target, err := got.SymbolicRef("refs/link", true); err != nil || target != "main" {
+		t.Errorf("SymbolicRef(refs/link) = %s, %v", target, err)
+	}
+	if err := got.DeleteSymbolicRef(ctx, "refs/link"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.readRef("refs/heads/main"); err != nil {
+		t.Errorf("deleting the symref deleted its target: %v", err)
+	}
+	if _, err := got.SymbolicRef("refs/heads/main", false); !errors.Is(err, NotSymrefErr) {
+		t.Errorf("SymbolicRef of a plain ref gave %v", err)
+	}
+	if err := got.SetSymbolicRef(ctx, "HEAD", "main", ""); err == nil {
+		t.Error("HEAD was pointed outside of refs/")
+	}
+}
@@ -0,0 +1,298 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"sort"
+)
+
+//  |||RENAME DETECTION||| //
+//git doesn't record renames. a moved file is a deleted path and an added one, and diff pairs them up afterwards
+//by how much content they share. each file is cut into chunks, a line or 64 bytes, whichever ends first,
+//and the similarity of two files is the bytes of chunks they have in common over the size of the bigger one.
+//an added file takes the most similar deleted one, if it's similar enough: a rename. with copy detection,
+//files that stayed can be the source too, and then it's a copy.
+//read: https://git-scm.com/docs/git-diff#Documentation/git-diff.txt---find-renamesltngt
+//and https://github.com/git/git/blob/master/diffcore-delta.c
+
+// maxScore is a perfect similarity. scores are out of it, like in git
+const maxScore = 60000
+
+// defaultRenameScore is the 50% a file must share with another to be taken as its rename or copy
+const defaultRenameScore = maxScore / 2
+
+// RenameOpts asks for rename and copy detection. a zero score is the default 50%
+type RenameOpts struct {
+	Renames bool
+	//Copies also looks for copies of modified files, and CopiesHarder of every file of the old side
+	Copies, CopiesHarder bool
+	//Score is the minimum similarity, out of 60000 like ParseRenameScore gives it
+	Score int
+}
+
+// ParseRenameScore reads the n of -M<n> and -C<n> the way git does: 50% is a percentage,
+// and digits alone are a fraction, so 5 and 50 are both half
+func ParseRenameScore(s string) (int, error) {
+	if s == "" {
+		return defaultRenameScore, nil
+	}
+	num, scale, dot := 0, 1, false
+	for i, c := range s {
+		switch {
+		case c == '.' && !dot:
+			scale, dot = 1, true
+		case c == '%' && i == len(s)-1:
+			if dot {
+				scale *= 100
+			} else {
+				scale = 100
+			}
+		case c >= '0' && c <= '9':
+			if scale < 100000 {
+				scale *= 10
+				num = num*10 + int(c-'0')
+			}
+		default:
+			return 0, fmt.Errorf("invalid similarity score '%s'", s)
+		}
+	}
+	if num >= scale {
+		return maxScore, nil
+	}
+	return maxScore * num / scale, nil
+}
+
+// spanCounts are the chunks of a file, by hash, with the bytes each hash covers
+type spanCounts map[uint32]int
+
+// hashChunks cuts data into chunks and counts them, like git's hash_chars. a CR before a LF doesn't count in text,
+// so a file with windows line endings stays similar to itself
+func hashChunks(data []byte) spanCounts {
+	const hashBase = 107927
+	text := !isBinary(data)
+	counts := make(spanCounts)
+	var accum1, accum2 uint32
+	n := 0
+	for i := 0; i < len(data); i++ {
+		c := uint32(data[i])
+		old1 := accum1
+		if text && c == '\r' && i+1 < len(data) && data[i+1] == '\n' {
+			continue
+		}
+		accum1 = (accum1 << 7) ^ (accum2 >> 25)
+		accum2 = (accum2 << 7) ^ (old1 >> 25)
+		accum1 += c
+		n++
+		if n < 64 && c != '\n' {
+			continue
+		}
+		counts[(accum1+accum2*0x61)%hashBase] += n
+		n, accum1, accum2 = 0, 0, 0
+	}
+	if n > 0 {
+		counts[(accum1+accum2*0x61)%hashBase] += n
+	}
+	return counts
+}
+
+// isBinary is git's guess: a file with a NUL byte in its first 8000 bytes is binary
+func isBinary(data []byte) bool {
+	if len(data) > 8000 {
+		data = data[:8000]
+	}
+	return bytes.IndexByte(data, 0) >= 0
+}
+
+// similarity scores how much of dst came from src. files too different in size to reach minScore aren't read
+func similarity(src, dst []byte, srcSpans, dstSpans spanCounts, minScore int) int {
+	maxSize, baseSize := len(src), len(dst)
+	if maxSize < baseSize {
+		maxSize, baseSize = baseSize, maxSize
+	}
+	if maxSize == 0 {
+		return maxScore
+	}
+	if maxSize*(maxScore-minScore) < (maxSize-baseSize)*maxScore {
+		return 0
+	}
+	copied := 0
+	for h, s := range srcSpans {
+		if d, ok := dstSpans[h]; ok {
+			if d < s {
+				s = d
+			}
+			copied += s
+		}
+	}
+	return copied * maxScore / maxSize
+}
+
+// renameCandidate is a scored pairing of an added file with a source
+type renameCandidate struct {
+	dst, src int
+	score    int
+	sameBase bool
+}
+
+// detectRenames pairs the added files of a diff with the deleted ones they were renamed from, and with copy
+// detection, with the files they were copied from. old is everything on the old side, for CopiesHarder.
+// the pairs stay sorted by path, a rename or copy taking the place of the file it added
+func (got *Got) detectRenames(pairs []filePair, old map[string]*diffSide, opts RenameOpts) ([]filePair, error) {
+	if !opts.Renames && !opts.Copies && !opts.CopiesHarder {
+		return pairs, nil
+	}
+	copies := opts.Copies || opts.CopiesHarder
+	minScore := opts.Score
+	if minScore == 0 {
+		minScore = defaultRenameScore
+	}
+
+	//sources are the deleted files, and for copies the modified ones, or with CopiesHarder all of the old side
+	type source struct {
+		side    *diffSide
+		deleted bool
+		used    int
+		spans   spanCounts
+		//renamed is a deleted source that found a taker, its deletion goes away
+		renamed bool
+	}
+	var srcs []*source
+	var dsts []int
+	inDiff := make(map[string]bool)
+	for i, p := range pairs {
+		switch {
+		case p.old == nil && modType(p.new.mode) != treefile:
+			dsts = append(dsts, i)
+		case p.new == nil && modType(p.old.mode) != treefile:
+			srcs = append(srcs, &source{side: p.old, deleted: true})
+		case p.old != nil && p.new != nil && copies:
+			srcs = append(srcs, &source{side: p.old})
+		}
+		if p.old != nil {
+			inDiff[p.old.path] = true
+		}
+	}
+	if opts.CopiesHarder {
+		var names []string
+		for name := range old {
+			if !inDiff[name] {
+				names = append(names, name)
+			}
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			srcs = append(srcs, &source{side: old[name]})
+		}
+	}
+	if len(dsts) == 0 || len(srcs) == 0 {
+		return pairs, nil
+	}
+	sort.SliceStable(srcs, func(i, j int) bool { return srcs[i].side.path < srcs[j].side.path })
+
+	matched := make(map[int]*source)
+	scores := make(map[int]int)
+	take := func(d int, s *source, score int) {
+		matched[d], scores[d] = s, score
+		s.used++
+		s.renamed = s.deleted
+	}
+	//exact matches first, preferring a source nobody took yet, and one with the same file name
+	for _, d := range dsts {
+		dst := pairs[d].new
+		var best *source
+		bestScore := -1
+		for _, s := range srcs {
+			if s.side.sha != dst.sha || modType(s.side.mode) != modType(dst.mode) || s.used > 0 && !copies {
+				continue
+			}
+			score := 0
+			if s.used == 0 {
+				score++
+			}
+			if path.Base(s.side.path) == path.Base(dst.path) {
+				score++
+			}
+			if score > bestScore {
+				best, bestScore = s, score
+			}
+		}
+		if best != nil {
+			take(d, best, maxScore)
+		}
+	}
+
+	//then by similarity, the best scores first
+	var candidates []renameCandidate
+	for _, d := range dsts {
+		if matched[d] != nil {
+			continue
+		}
+		dst := pairs[d].new
+		dstData, err := got.sideData(dst)
+		if err != nil {
+			return nil, err
+		}
+		dstSpans := hashChunks(dstData)
+		for si, s := range srcs {
+			if !copies && s.used > 0 || modType(s.side.mode) != modType(dst.mode) {
+				continue
+			}
+			srcData, err := got.sideData(s.side)
+			if err != nil {
+				return nil, err
+			}
+			if s.spans == nil {
+				s.spans = hashChunks(srcData)
+			}
+			score := similarity(srcData, dstData, s.spans, dstSpans, minScore)
+			if score < minScore {
+				continue
+			}
+			candidates = append(candidates, renameCandidate{dst: d, src: si, score: score, sameBase: path.Base(s.side.path) == path.Base(dst.path)})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if candidates[i].score != candidates[j].score {
+			return candidates[i].score > candidates[j].score
+		}
+		return candidates[i].sameBase && !candidates[j].sameBase
+	})
+	//renames take a source once, then copies may take any source again
+	for pass := 0; pass < 2; pass++ {
+		for _, c := range candidates {
+			s := srcs[c.src]
+			if matched[c.dst] != nil {
+				continue
+			}
+			if pass == 0 && s.used > 0 || pass == 1 && !copies {
+				continue
+			}
+			take(c.dst, s, c.score)
+		}
+	}
+
+	//a deleted source goes away into its last rename, the pairs before that are copies
+	renamed := make(map[string]bool)
+	for _, s := range srcs {
+		if s.renamed {
+			renamed[s.side.path] = true
+		}
+	}
+	var out []filePair
+	for i, p := range pairs {
+		if s, ok := matched[i]; ok {
+			s.used--
+			p.old = s.side
+			p.score = scores[i]
+			p.copied = !s.deleted || s.used > 0
+			out = append(out, p)
+			continue
+		}
+		if p.new == nil && renamed[p.old.path] {
+			continue
+		}
+		out = append(out, p)
+	}
+	return out, nil
+}
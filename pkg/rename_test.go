@@ -0,0 +1,89 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestParseRenameScore(t *testing.T) {
+	for _, tt := range []struct {
+		in   string
+		want int
+	}{
+		{"", maxScore / 2}, {"5", maxScore / 2}, {"50", maxScore / 2}, {"50%", maxScore / 2},
+		{"75%", maxScore * 3 / 4}, {"0.9", maxScore * 9 / 10}, {"100%", maxScore}, {"1", maxScore / 10},
+	} {
+		if score, err := ParseRenameScore(tt.in); err != nil || score != tt.want {
+			t.Errorf("ParseRenameScore(%q) = %d, %v, want %d", tt.in, score, err, tt.want)
+		}
+	}
+	if _, err := ParseRenameScore("5x"); err == nil {
+		t.Error("a bad score was taken")
+	}
+}
+
+func TestRenameDetection(t *testing.T) {
+	got := testRepo(t)
+	lines := func(format string, changed ...int) string {
+		var b strings.Builder
+		for i := 1; i <= 10; i++ {
+			if len(changed) > 0 && i <= changed[0] {
+				b.WriteString("changed\n")
+				continue
+			}
+			fmt.Fprintf(&b, format+"\n", i)
+		}
+		return b.String()
+	}
+	keep := strings.Replace(lines("keep %d"), "keep 1\n", "KEEP\n", 1)
+	one := testCommitFiles(t, got, 1700000000, map[string]string{"a": lines("line %d"), "keep": lines("keep %d"), "same": lines("same %d")})
+	//a moves to b with three of its ten lines changed, keep changes one line and is copied, and same is copied as it is
+	two := testCommitFiles(t, got, 1700000060, map[string]string{"b": lines("line %d", 3), "keep": keep, "copy": keep,
+		"same": lines("same %d"), "same2": lines("same %d")}, one)
+	revs := []string{shaToString(one), shaToString(two)}
+	score := func(s string) int {
+		n, err := ParseRenameScore(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return n
+	}
+	//what git diff-tree -r --name-status prints with the same options
+	tests := []struct {
+		name string
+		opts RenameOpts
+		want string
+	}{
+		{name: "none", want: "D\ta\nA\tb\nA\tcopy\nM\tkeep\nA\tsame2\n"},
+		{name: "-M", opts: RenameOpts{Renames: true}, want: "R067\ta\tb\nA\tcopy\nM\tkeep\nA\tsame2\n"},
+		{name: "-M65%", opts: RenameOpts{Renames: true, Score: score("65%")}, want: "R067\ta\tb\nA\tcopy\nM\tkeep\nA\tsame2\n"},
+		{name: "-M70%", opts: RenameOpts{Renames: true, Score: score("70%")}, want: "D\ta\nA\tb\nA\tcopy\nM\tkeep\nA\tsame2\n"},
+		{name: "-C", opts: RenameOpts{Renames: true, Copies: true}, want: "R067\ta\tb\nC090\tkeep\tcopy\nM\tkeep\nA\tsame2\n"},
+		{name: "-C95%", opts: RenameOpts{Renames: true, Copies: true, Score: score("95%")}, want: "D\ta\nA\tb\nA\tcopy\nM\tkeep\nA\tsame2\n"},
+		{name: "-C -C", opts: RenameOpts{Renames: true, Copies: true, CopiesHarder: true},
+			want: "R067\ta\tb\nC090\tkeep\tcopy\nM\tkeep\nC100\tsame\tsame2\n"},
+	}
+	for _, tt := range tests {
+		rdr, err := got.DiffTree(context.Background(), revs, nil, true, false, tt.opts, DiffNameStatus)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b, _ := io.ReadAll(rdr); string(b) != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b, tt.want)
+		}
+	}
+
+	//the raw format has both shas and modes of a rename
+	rdr, err := got.DiffTree(context.Background(), revs, []string{"a", "b"}, true, false, RenameOpts{Renames: true}, DiffRaw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ":100644 100644 fa2da6e55caa540725b55c04d13f1e42b4c725ce c747319818538569dd8d84462f9ecc484c5e0fe8 R067\ta\tb\n"
+	if b, _ := io.ReadAll(rdr); string(b) != want {
+		t.Errorf("raw rename: got\n%s\nwant\n%s", b, want)
+	}
+}
@@ -0,0 +1,478 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//  |||REVISIONS||| //
+//a revision names an object. it starts with a base, then any number of suffixes walk from there:
+//  <sha prefix>, <ref>, @ (HEAD), <ref>@{<n>|<date>|upstream|u|push}, @{-<n>} (the branch checked out n switches ago)
+//  ~<n> (n-th first-parent ancestor), ^<n> (n-th parent), ^{<type>}, ^{} (peel tags), ^{/<regex>}
+//and a few forms stand on their own:
+//  <rev>:<path> (an entry of the tree of rev), :<path> and :<stage>:<path> (a blob in the index), :/<regex> (a commit message)
+//read: https://git-scm.com/docs/gitrevisions
+
+// ResolveRevision turns a revision into the sha of the object it names
+func (got *Got) ResolveRevision(rev string) (Sha1, error) {
+	if rev == "" {
+		return Sha1{}, fmt.Errorf("empty revision")
+	}
+	if strings.HasPrefix(rev, ":/") {
+		return got.searchMessage(rev[2:], nil)
+	}
+	if strings.HasPrefix(rev, ":") {
+		return got.indexPath(rev[1:])
+	}
+	if colon := topLevelIndex(rev, ":"); colon >= 0 {
+		treeSha, err := got.peelRev(rev[:colon], "tree")
+		if err != nil {
+			return Sha1{}, err
+		}
+		it, err := got.treeLookup(treeSha, rev[colon+1:])
+		if err != nil {
+			return Sha1{}, fmt.Errorf("path '%s' does not exist in '%s'", rev[colon+1:], rev[:colon])
+		}
+		return it.sha, nil
+	}
+
+	i := topLevelIndex(rev, "^~")
+	if i < 0 {
+		i = len(rev)
+	}
+	sha, err := got.resolveBase(rev[:i])
+	if err != nil {
+		return Sha1{}, err
+	}
+	rest := rev[i:]
+	for rest != "" {
+		op := rest[0]
+		rest = rest[1:]
+		if op == '^' && strings.HasPrefix(rest, "{") {
+			end := strings.IndexByte(rest, '}')
+			if end < 0 {
+				return Sha1{}, fmt.Errorf("bad revision '%s'", rev)
+			}
+			what := rest[1:end]
+			rest = rest[end+1:]
+			if strings.HasPrefix(what, "/") {
+				if sha, err = got.searchMessage(what[1:], []Sha1{sha}); err != nil {
+					return Sha1{}, err
+				}
+				continue
+			}
+			if sha, err = got.peelTo(sha, what); err != nil {
+				return Sha1{}, fmt.Errorf("%s: %w", rev, err)
+			}
+			continue
+		}
+		n, width := 1, 0
+		for width < len(rest) && rest[width] >= '0' && rest[width] <= '9' {
+			width++
+		}
+		if width > 0 {
+			n, _ = strconv.Atoi(rest[:width])
+			rest = rest[width:]
+		}
+		if op != '^' && op != '~' {
+			return Sha1{}, fmt.Errorf("bad revision '%s'", rev)
+		}
+		if sha, err = got.walkParents(sha, op, n); err != nil {
+			return Sha1{}, fmt.Errorf("%s: %w", rev, err)
+		}
+	}
+	return sha, nil
+}
+
+// topLevelIndex is strings.IndexAny that skips whatever is inside braces, where dates and regexes may hold anything
+func topLevelIndex(s, chars string) int {
+	depth := 0
+	for i, r := range s {
+		switch {
+		case r == '{':
+			depth++
+		case r == '}' && depth > 0:
+			depth--
+		case depth == 0 && strings.ContainsRune(chars, r):
+			return i
+		}
+	}
+	return -1
+}
+
+// resolveBase resolves the part of a revision before any ^ or ~
+func (got *Got) resolveBase(base string) (Sha1, error) {
+	if base == "@" || base == "" {
+		base = "HEAD"
+	}
+	if name, spec, ok := splitReflogSpec(base); ok {
+		switch {
+		case strings.HasPrefix(spec, "-"):
+			n, err := strconv.Atoi(spec[1:])
+			if err != nil || n < 1 || name != "" {
+				return Sha1{}, fmt.Errorf("bad revision '%s'", base)
+			}
+			prev, err := got.previousCheckout(n)
+			if err != nil {
+				return Sha1{}, err
+			}
+			return got.resolveBase(prev)
+		case isUpstreamSpec(spec):
+			up, err := got.upstreamOf(name)
+			if err != nil {
+				return Sha1{}, err
+			}
+			return got.readRef(up)
+		}
+		return got.resolveReflog(base)
+	}
+	if full, ok := got.dwimRef(base); ok {
+		return got.readRef(full)
+	}
+	if isHex(base) && len(base) >= 4 {
+		if sha, err := got.resolveSha(base); err == nil {
+			return sha, nil
+		} else if errors.Is(err, AmbiguousObjectErr) {
+			return Sha1{}, fmt.Errorf("short object ID %s is ambiguous", base)
+		}
+	}
+	return Sha1{}, fmt.Errorf("ambiguous argument '%s': unknown revision or path not in the working tree", base)
+}
+
+func isHex(s string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune("0123456789abcdef", r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isUpstreamSpec(spec string) bool {
+	switch strings.ToLower(spec) {
+	case "u", "upstream", "push":
+		return true
+	}
+	return false
+}
+
+// upstreamOf finds the ref a branch tracks. an empty branch means the current one
+func (got *Got) upstreamOf(branch string) (string, error) {
+	if branch == "" || branch == "HEAD" {
+		current, ok := got.headBranch()
+		if !ok {
+			return "", fmt.Errorf("HEAD does not point to a branch")
+		}
+		branch = current
+	}
+	branch = strings.TrimPrefix(branch, branchPrefix)
+	up, ok := got.upstreamRef(branch)
+	if !ok {
+		return "", fmt.Errorf("no upstream configured for branch '%s'", branch)
+	}
+	return up, nil
+}
+
+// previousCheckout finds the branch, or the commit, HEAD was on n checkouts ago, from the reflog of HEAD
+func (got *Got) previousCheckout(n int) (string, error) {
+	entries, err := got.refs().readLog("HEAD")
+	if err != nil {
+		return "", err
+	}
+	const prefix = "checkout: moving from "
+	for i := len(entries) - 1; i >= 0; i-- {
+		msg := entries[i].msg
+		if !strings.HasPrefix(msg, prefix) {
+			continue
+		}
+		if n--; n > 0 {
+			continue
+		}
+		from := strings.TrimPrefix(msg, prefix)
+		if to := strings.LastIndex(from, " to "); to >= 0 {
+			from = from[:to]
+		}
+		return from, nil
+	}
+	return "", fmt.Errorf("no previous checkout in the reflog of HEAD")
+}
+
+// ExpandPrevious turns @{-n} into the name of the branch it stands for, so checking it out attaches HEAD again.
+// anything else comes back as it is
+func (got *Got) ExpandPrevious(rev string) string {
+	name, spec, ok := splitReflogSpec(rev)
+	if rev == "-" {
+		name, spec, ok = "", "-1", true
+	}
+	if !ok || name != "" || !strings.HasPrefix(spec, "-") {
+		return rev
+	}
+	n, err := strconv.Atoi(spec[1:])
+	if err != nil || n < 1 {
+		return rev
+	}
+	if prev, err := got.previousCheckout(n); err == nil {
+		return prev
+	}
+	return rev
+}
+
+// objectType reads just enough of an object to know what it is
+func (got *Got) objectType(sha Sha1) (string, []byte, error) {
+	_, ty, data, err := got.ReadObject(shaToString(sha))
+	return ty, data, err
+}
+
+// peelTo follows tags, and commits to their trees, until it reaches an object of the type. an empty type peels tags only
+func (got *Got) peelTo(sha Sha1, want string) (Sha1, error) {
+	if want == "object" {
+		return sha, nil
+	}
+	for {
+		ty, data, err := got.objectType(sha)
+		if err != nil {
+			return Sha1{}, err
+		}
+		if ty == want || (want == "" && ty != "tag") {
+			return sha, nil
+		}
+		switch {
+		case ty == "tag":
+			t, err := parseTag(bytes.NewReader(data))
+			if err != nil {
+				return Sha1{}, fmt.Errorf("tag %s is not well formatted", shaToString(sha))
+			}
+			sha = t.object
+		case ty == "commit" && want == "tree":
+			comm, err := got.readCommit(shaToString(sha))
+			if err != nil {
+				return Sha1{}, err
+			}
+			return comm.treeSha, nil
+		default:
+			return Sha1{}, fmt.Errorf("expected %s type, but the object dereferences to %s type", want, ty)
+		}
+	}
+}
+
+// peelRev resolves a revision and peels it to the type
+func (got *Got) peelRev(rev, want string) (Sha1, error) {
+	sha, err := got.ResolveRevision(rev)
+	if err != nil {
+		return Sha1{}, err
+	}
+	return got.peelTo(sha, want)
+}
+
+// walkParents does ^n, the n-th parent (^0 is the commit itself), and ~n, n generations of first parents
+func (got *Got) walkParents(sha Sha1, op byte, n int) (Sha1, error) {
+	sha, err := got.peelTo(sha, "commit")
+	if err != nil {
+		return Sha1{}, err
+	}
+	if op == '^' {
+		if n == 0 {
+			return sha, nil
+		}
+		comm, err := got.readCommit(shaToString(sha))
+		if err != nil {
+			return Sha1{}, err
+		}
+		if n > len(comm.parents) {
+			return Sha1{}, fmt.Errorf("commit %s has no parent %d", shaToString(sha)[:7], n)
+		}
+		return comm.parents[n-1], nil
+	}
+	for ; n > 0; n-- {
+		comm, err := got.readCommit(shaToString(sha))
+		if err != nil {
+			return Sha1{}, err
+		}
+		if len(comm.parents) == 0 {
+			return Sha1{}, fmt.Errorf("commit %s has no parent", shaToString(sha)[:7])
+		}
+		sha = comm.parents[0]
+	}
+	return sha, nil
+}
+
+// treeLookup finds the entry at p, a slash-separated path, under the tree. an empty path is the tree itself
+func (got *Got) treeLookup(tree Sha1, p string) (item, error) {
+	curr := item{mode: 040000, sha: tree}
+	for _, part := range strings.Split(strings.Trim(p, "/"), "/") {
+		if part == "" {
+			continue
+		}
+		if modType(curr.mode) != treefile {
+			return item{}, fmt.Errorf("%s is not a tree", curr.name)
+		}
+		found := false
+		for _, it := range got.deserTree(shaToString(curr.sha)) {
+			if it.name == part {
+				curr, found = it, true
+				break
+			}
+		}
+		if !found {
+			return item{}, fmt.Errorf("path '%s' does not exist", p)
+		}
+	}
+	return curr, nil
+}
+
+// indexPath resolves `path` and `<stage>:path`, the part after the leading colon, to a blob in the index
+func (got *Got) indexPath(spec string) (Sha1, error) {
+	stage := uint16(0)
+	if len(spec) > 2 && spec[1] == ':' && spec[0] >= '0' && spec[0] <= '3' {
+		stage = uint16(spec[0] - '0')
+		spec = spec[2:]
+	}
+	idx, err := readIndexFile()
+	if err != nil {
+		return Sha1{}, err
+	}
+	for _, e := range idx.entries {
+		if string(e.path) == spec && (e.flags&flagStageMask)>>12 == stage {
+			return e.sha, nil
+		}
+	}
+	return Sha1{}, fmt.Errorf("path '%s' does not exist (neither on disk nor in the index)", spec)
+}
+
+// searchMessage finds the youngest commit whose message matches the regex, reachable from the starting commits,
+// or from every ref when there are none
+func (got *Got) searchMessage(pattern string, from []Sha1) (Sha1, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return Sha1{}, err
+	}
+	if from == nil {
+		names, err := got.listRefs("refs")
+		if err != nil {
+			return Sha1{}, err
+		}
+		for _, name := range append([]string{"HEAD"}, names...) {
+			if sha, err := got.readRef(name); err == nil {
+				if c, err := got.peelTo(sha, "commit"); err == nil {
+					from = append(from, c)
+				}
+			}
+		}
+	}
+	//newest first, by committer date
+	seen := make(map[Sha1]bool)
+	var queue []*Comm
+	push := func(sha Sha1) error {
+		if seen[sha] {
+			return nil
+		}
+		seen[sha] = true
+		comm, err := got.readCommit(shaToString(sha))
+		if err != nil {
+			return err
+		}
+		i := len(queue)
+		for i > 0 && queue[i-1].committer.time.Before(comm.committer.time) {
+			i--
+		}
+		queue = insert(queue, comm, i)
+		return nil
+	}
+	for _, sha := range from {
+		if err := push(sha); err != nil {
+			return Sha1{}, err
+		}
+	}
+	for len(queue) > 0 {
+		comm := queue[0]
+		queue = queue[1:]
+		if re.MatchString(comm.msg) {
+			return comm.sha, nil
+		}
+		for _, p := range comm.parents {
+			if err := push(p); err != nil {
+				return Sha1{}, err
+			}
+		}
+	}
+	return Sha1{}, fmt.Errorf("no commit message matches '%s'", pattern)
+}
+
+// shortRef drops the part of a full ref name git leaves out when it shows it
+func shortRef(full string) string {
+	for _, prefix := range []string{"refs/heads/", "refs/tags/", "refs/remotes/", "refs/"} {
+		if strings.HasPrefix(full, prefix) {
+			return strings.TrimPrefix(full, prefix)
+		}
+	}
+	return full
+}
+
+// AbbrevRef gives the short name of the ref a revision names, like `rev-parse --abbrev-ref`. a detached HEAD stays HEAD
+func (got *Got) AbbrevRef(rev string) (string, error) {
+	if rev == "@" {
+		rev = "HEAD"
+	}
+	if name, spec, ok := splitReflogSpec(rev); ok {
+		switch {
+		case strings.HasPrefix(spec, "-") && name == "":
+			prev := got.ExpandPrevious(rev)
+			if prev == rev {
+				return "", fmt.Errorf("no previous checkout in the reflog of HEAD")
+			}
+			return prev, nil
+		case isUpstreamSpec(spec):
+			up, err := got.upstreamOf(name)
+			if err != nil {
+				return "", err
+			}
+			return shortRef(up), nil
+		}
+	}
+	//a symbolic ref is shown as the ref it ends up at, like origin for origin/main. a detached HEAD stays HEAD
+	if full, ok := got.dwimRef(rev); ok {
+		if target, _, err := got.refs().resolve(full); err == nil {
+			full = target
+		}
+		return shortRef(full), nil
+	}
+	sha, err := got.ResolveRevision(rev)
+	if err != nil {
+		return "", err
+	}
+	return shaToString(sha), nil
+}
+
+// Abbrev shortens the sha to at least n digits, and more if that's what it takes to be unique
+func (got *Got) Abbrev(sha Sha1, n int) string {
+	full := shaToString(sha)
+	if n < 4 {
+		n = 4
+	}
+	for ; n < 40; n++ {
+		if _, err := got.FindObject(full[:n]); !errors.Is(err, AmbiguousObjectErr) {
+			return full[:n]
+		}
+	}
+	return full
+}
+
+// RevParse resolves a revision like `git rev-parse`: the full sha, a unique abbreviation of it when short isn't 0,
+// or the short name of the ref when abbrevRef is set
+func (got *Got) RevParse(rev string, short int, abbrevRef bool) (string, error) {
+	if abbrevRef {
+		return got.AbbrevRef(rev)
+	}
+	sha, err := got.ResolveRevision(rev)
+	if err != nil {
+		return "", err
+	}
+	if short > 0 {
+		return got.Abbrev(sha, short), nil
+	}
+	return shaToString(sha), nil
+}
@@ -0,0 +1,63 @@
+package pkg
+
+import "testing"
+
+func TestReflogRevisions(t *testing.T) {
+	got := testRepo(t)
+	one := testCommit(t, got, 1000)
+	two := testCommit(t, got, 2000, one)
+	three := testCommit(t, got, 3000, two)
+	topic := testCommit(t, got, 4000, one)
+	//each transaction on master writes its reflog, and HEAD's since HEAD is on master
+	for _, move := range [][2]Sha1{{{}, one}, {one, two}, {two, three}} {
+		tx := got.NewRefTransaction("test")
+		if move[0] == (Sha1{}) {
+			tx.Create("refs/heads/master", move[1])
+		} else {
+			tx.Update("refs/heads/master", move[1], move[0])
+		}
+		if err := tx.Commit(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := got.writeRef("refs/heads/topic", topic); err != nil {
+		t.Fatal(err)
+	}
+	//checkout master -> topic -> master, the way checkout logs it
+	if err := got.logRef("HEAD", three, topic, "checkout: moving from master to topic"); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.logRef("HEAD", topic, three, "checkout: moving from topic to master"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rev  string
+		want Sha1
+	}{
+		{"master@{0}", three},
+		{"master@{1}", two},
+		{"master@{2}", one},
+		{"@{1}", two},
+		{"master@{1}~1", one},
+		{"HEAD@{1}", topic},
+		{"@{-1}", topic},
+		{"@{-2}", three},
+		{"@{-1}~1", one},
+	}
+	for _, tt := range tests {
+		if sha, err := got.ResolveRevision(tt.rev); err != nil || sha != tt.want {
+			t.Errorf("ResolveRevision(%s) = %s, %v, want %s", tt.rev, shaToString(sha), err, shaToString(tt.want))
+		}
+	}
+	for _, rev := range []string{"master@{3}", "@{-3}", "topic@{0}"} {
+		if _, err := got.ResolveRevision(rev); err == nil {
+			t.Errorf("ResolveRevision(%s) succeeded", rev)
+		}
+	}
+	for rev, want := range map[string]string{"@{-1}": "topic", "@{-2}": "master", "-": "topic", "@{-3}": "@{-3}"} {
+		if name := got.ExpandPrevious(rev); name != want {
+			t.Errorf("ExpandPrevious(%s) = %s, want %s", rev, name, want)
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+)
+
+//  |||REV-LIST||| //
+//rev-list is the plumbing under log: it lists the commits of a walk, one sha per line, newest first.
+//with --objects it also lists the trees and blobs those commits need, with their paths, which is what pack-objects
+//takes to build a pack. --objects-edge adds the hidden commits the walk stopped at, prefixed with a dash,
+//so a thin pack can leave out what the other side has.
+//read: https://git-scm.com/docs/git-rev-list
+
+// RevListOpts is what `got rev-list` was asked for
+type RevListOpts struct {
+	//Revs are revisions and ranges, like log takes them. at least one is needed
+	Revs  []string
+	Paths []string
+	//MaxCount limits the number of commits listed when above zero
+	MaxCount             int
+	FirstParent          bool
+	Merges, NoMerges     bool
+	Count                bool
+	Objects, ObjectsEdge bool
+}
+
+// RevList lists the commits, and maybe the objects, the options ask for
+func (got *Got) RevList(ctx context.Context, opts RevListOpts) (io.Reader, error) {
+	if len(opts.Revs) == 0 {
+		return nil, fmt.Errorf("rev-list needs at least one revision")
+	}
+	w := got.NewRevWalk()
+	for _, rev := range opts.Revs {
+		if err := w.PushRev(rev); err != nil {
+			return nil, err
+		}
+	}
+	if len(opts.Paths) != 0 {
+		w.Paths(opts.Paths)
+	}
+	w.MaxCount(opts.MaxCount)
+	if opts.FirstParent {
+		w.FirstParent()
+	}
+	switch {
+	case opts.Merges:
+		w.ParentCount(2, -1)
+	case opts.NoMerges:
+		w.ParentCount(0, 1)
+	}
+
+	var b bytes.Buffer
+	if opts.Count {
+		n := 0
+		if err := w.Walk(func(*Comm) error {
+			n++
+			return nil
+		}); err != nil {
+			return nil, err
+		}
+		fmt.Fprintln(&b, n)
+		return &b, nil
+	}
+	if !opts.Objects && !opts.ObjectsEdge {
+		err := w.Walk(func(c *Comm) error {
+			fmt.Fprintln(&b, shaToString(c.sha))
+			return nil
+		})
+		return &b, err
+	}
+
+	if opts.ObjectsEdge {
+		edges, err := w.Edges()
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range edges {
+			fmt.Fprintf(&b, "-%s\n", shaToString(e))
+		}
+	}
+	//commits come without a path, trees and blobs with theirs. the root tree's path is empty but still follows a space
+	err := w.Objects(func(sha Sha1, objType, path string) error {
+		if objType == "commit" {
+			fmt.Fprintln(&b, shaToString(sha))
+		} else {
+			fmt.Fprintf(&b, "%s %s\n", shaToString(sha), path)
+		}
+		return nil
+	})
+	return &b, err
+}
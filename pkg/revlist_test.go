@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestRevListObjects(t *testing.T) {
+	got := testRepo(t)
+	one := testCommitFiles(t, got, 1700000000, map[string]string{"a": "a1\n", "d/b": "b1\n"})
+	two := testCommitFiles(t, got, 1700000060, map[string]string{"a": "a2\n", "d/b": "b1\n"}, one)
+	//what git rev-list prints for the same two commits
+	const (
+		commit1 = "22126743845ae518413bf7e1421527bf99bb4419"
+		tree1   = "d94d182c91853193b324e49a6e765f763d633057"
+		a1      = "da0f8ed91a8f2f0f067b3bdf26265d5ca48cf82c"
+		commit2 = "3069e29346e04cbbaea7d17abc3a7eb199980574"
+		tree2   = "232d236cd9ddd1e989284e4051949119120cd006"
+		a2      = "c1827f07e114c20547dc6a7296588870a4b5b62c"
+		treeD   = "313803691e703993f55684973040f5d6f36542fb"
+		b1      = "c9c6af7f78bc47490dbf3e822cf2f3c24d4b9061"
+	)
+	if shaToString(one) != commit1 || shaToString(two) != commit2 {
+		t.Fatalf("the commits are %s and %s, not the ones git makes", shaToString(one), shaToString(two))
+	}
+	tests := []struct {
+		name string
+		opts RevListOpts
+		want string
+	}{
+		{name: "commits", opts: RevListOpts{Revs: []string{commit2}},
+			want: commit2 + "\n" + commit1 + "\n"},
+		{name: "count", opts: RevListOpts{Revs: []string{commit2}, Count: true}, want: "2\n"},
+		//every object once, the first time a commit needs it
+		{name: "objects", opts: RevListOpts{Revs: []string{commit2}, Objects: true},
+			want: commit2 + "\n" + commit1 + "\n" + tree2 + " \n" + a2 + " a\n" + treeD + " d\n" + b1 + " d/b\n" + tree1 + " \n" + a1 + " a\n"},
+		//nothing the excluded commit has
+		{name: "objects of a range", opts: RevListOpts{Revs: []string{commit1 + ".." + commit2}, Objects: true},
+			want: commit2 + "\n" + tree2 + " \n" + a2 + " a\n"},
+		{name: "objects-edge", opts: RevListOpts{Revs: []string{commit1 + ".." + commit2}, ObjectsEdge: true},
+			want: "-" + commit1 + "\n" + commit2 + "\n" + tree2 + " \n" + a2 + " a\n"},
+	}
+	for _, tt := range tests {
+		rdr, err := got.RevList(context.Background(), tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b, _ := io.ReadAll(rdr); string(b) != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b, tt.want)
+		}
+	}
+	if _, err := got.RevList(context.Background(), RevListOpts{}); err == nil {
+		t.Error("rev-list ran without a revision")
+	}
+}
@@ -0,0 +1,664 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+)
+
+//  |||REVISION WALKS||| //
+//a rev walk lists the commits reachable from the pushed commits, leaving out everything reachable from the hidden ones.
+//that is what `main..topic` means: push topic, hide main. `a...b` pushes both and hides what they have in common.
+//commits come newest first by committer date, like git's default, or in topological order: never a parent before
+//one of its children, and the commits of one line of history kept together.
+//with paths, the walk simplifies history like git does: a commit that changed none of the paths is not shown,
+//and at a merge that took the paths unchanged from one parent, only that parent is followed.
+//log, rev-list, push and merge-base all walk history through here: with a callback (Walk), an iterator (Next),
+//or for every object the commits bring along (Objects), the way pack-objects needs them.
+//a plain date-order walk hands out commits as they come off the queue, and stops reading history at --max-count.
+//sorting, reversing and hidden commits need the walk done first, but the hidden commits are walked in the same queue,
+//passing their mark on to their parents, and the walk ends once nothing but hidden commits is left in it.
+//read: https://git-scm.com/docs/git-rev-list#_commit_limiting and https://git-scm.com/docs/git-log#_history_simplification
+
+// RevSort picks the order a RevWalk hands out commits in
+type RevSort int
+
+const (
+	// SortDate is newest committer date first, following parents as they come. it is git's default
+	SortDate RevSort = iota
+	// SortTopo shows no parent before all its children, and keeps lines of history together, like --topo-order
+	SortTopo
+	// SortTopoDate shows no parent before all its children, and otherwise goes by date, like --date-order
+	SortTopoDate
+)
+
+// RevWalk walks the history between the pushed and the hidden commits
+type RevWalk struct {
+	got         *Got
+	pushed      []Sha1
+	hidden      []Sha1
+	sorting     RevSort
+	reverse     bool
+	firstParent bool
+	paths       []string
+	filters     []func(*Comm) bool
+	max         int
+	//minParents and maxParents keep the commits with that many parents. a negative maxParents is no limit
+	minParents, maxParents int
+
+	//the walk, once it started: the commits waiting newest first, every commit read into the queue,
+	//the ones hidden so far, and the parents each walked commit leads to
+	prepared      bool
+	queue         *commitQueue
+	queued        map[Sha1]*Comm
+	uninteresting map[Sha1]bool
+	follows       map[Sha1][]Sha1
+	//whole is set when the caller needs every commit at once, like Objects does
+	whole bool
+	//a walk done whole is kept in list, in order, with the next one Next hands out,
+	//parents of each shown commit rewritten to the nearest shown commits, and the hidden commits right below the walk.
+	//a streamed one counts what it handed out in handed
+	list    []*Comm
+	next    int
+	handed  int
+	parents map[Sha1][]Sha1
+	edges   []Sha1
+}
+
+// NewRevWalk starts a walk with nothing pushed yet
+func (got *Got) NewRevWalk() *RevWalk {
+	return &RevWalk{got: got, maxParents: -1}
+}
+
+// Push adds a starting point. tags are peeled to their commit
+func (w *RevWalk) Push(sha Sha1) error {
+	c, err := w.got.peelTo(sha, "commit")
+	if err != nil {
+		return err
+	}
+	w.pushed = append(w.pushed, c)
+	return nil
+}
+
+// Hide leaves out the commit and everything it can reach
+func (w *RevWalk) Hide(sha Sha1) error {
+	c, err := w.got.peelTo(sha, "commit")
+	if err != nil {
+		return err
+	}
+	w.hidden = append(w.hidden, c)
+	return nil
+}
+
+// PushRev reads a revision the way log and rev-list take them: `a..b`, `a...b`, `^x` or a single revision.
+// an empty side of a range is HEAD
+func (w *RevWalk) PushRev(rev string) error {
+	resolve := func(r string) (Sha1, error) {
+		if r == "" {
+			r = "HEAD"
+		}
+		sha, err := w.got.ResolveRevision(r)
+		if err != nil {
+			return Sha1{}, fmt.Errorf("bad revision '%s'", r)
+		}
+		return sha, nil
+	}
+	if a, b, ok := strings.Cut(rev, "..."); ok {
+		left, err := resolve(a)
+		if err != nil {
+			return err
+		}
+		right, err := resolve(b)
+		if err != nil {
+			return err
+		}
+		if err := w.Push(left); err != nil {
+			return err
+		}
+		if err := w.Push(right); err != nil {
+			return err
+		}
+		bases, err := w.got.mergeBases(w.pushed[len(w.pushed)-2], w.pushed[len(w.pushed)-1])
+		if err != nil {
+			return err
+		}
+		w.hidden = append(w.hidden, bases...)
+		return nil
+	}
+	if a, b, ok := strings.Cut(rev, ".."); ok {
+		hide, err := resolve(a)
+		if err != nil {
+			return err
+		}
+		push, err := resolve(b)
+		if err != nil {
+			return err
+		}
+		if err := w.Hide(hide); err != nil {
+			return err
+		}
+		return w.Push(push)
+	}
+	if strings.HasPrefix(rev, "^") {
+		sha, err := resolve(rev[1:])
+		if err != nil {
+			return err
+		}
+		return w.Hide(sha)
+	}
+	sha, err := resolve(rev)
+	if err != nil {
+		return err
+	}
+	return w.Push(sha)
+}
+
+// Sorting picks the order, and whether to hand the commits out oldest first
+func (w *RevWalk) Sorting(s RevSort, reverse bool) {
+	w.sorting, w.reverse = s, reverse
+}
+
+// Paths limits the walk to commits that changed one of the paths, simplifying history like git does
+func (w *RevWalk) Paths(paths []string) {
+	w.paths = paths
+}
+
+// Filter only shows the commits f keeps. the walk still goes through the others
+func (w *RevWalk) Filter(f func(*Comm) bool) {
+	w.filters = append(w.filters, f)
+}
+
+// MaxCount stops after n commits. it counts in the walk's order, before a reverse
+func (w *RevWalk) MaxCount(n int) {
+	w.max = n
+}
+
+// FirstParent only follows the first parent of merges, the line of history of the branch the merges went into
+func (w *RevWalk) FirstParent() {
+	w.firstParent = true
+}
+
+// ParentCount only shows commits with at least min parents, and at most max of them unless max is negative.
+// 2 and -1 are --merges, 0 and 1 are --no-merges
+func (w *RevWalk) ParentCount(min, max int) {
+	w.minParents, w.maxParents = min, max
+}
+
+// Edges are the hidden commits that are parents of commits of the walk, the boundary a thin pack can build on
+func (w *RevWalk) Edges() ([]Sha1, error) {
+	w.whole = true
+	if err := w.prepare(); err != nil {
+		return nil, err
+	}
+	return w.edges, nil
+}
+
+// Parents are the parents of a commit Walk handed out, among the commits it handed out. they differ from the real parents
+// when paths or filters left commits out. they are only known for a walk done whole, like a sorted one
+func (w *RevWalk) Parents(sha Sha1) []Sha1 {
+	return w.parents[sha]
+}
+
+// mergeBases finds the best common ancestors of a and b: the common ancestors no other common ancestor descends from
+func (got *Got) mergeBases(a, b Sha1) ([]Sha1, error) {
+	fromA, err := got.reachable(a)
+	if err != nil {
+		return nil, err
+	}
+	//the commits of b's history that a has too, newest first. none of them hides another yet
+	var common []*Comm
+	w := got.NewRevWalk()
+	if err := w.Push(b); err != nil {
+		return nil, err
+	}
+	w.Filter(func(c *Comm) bool { return fromA[c.sha] })
+	if err := w.Walk(func(c *Comm) error {
+		common = append(common, c)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	if len(common) == 0 {
+		return nil, nil
+	}
+	//a common ancestor below another one is no best one
+	below := got.NewRevWalk()
+	for _, c := range common {
+		for _, p := range c.parents {
+			if err := below.Push(p); err != nil {
+				return nil, err
+			}
+		}
+	}
+	notBest := make(map[Sha1]bool)
+	if err := below.Walk(func(c *Comm) error {
+		notBest[c.sha] = true
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	var bases []Sha1
+	for _, c := range common {
+		if !notBest[c.sha] {
+			bases = append(bases, c.sha)
+		}
+	}
+	return bases, nil
+}
+
+// treeSame tells whether the two trees hold the same thing at every path of the walk. a missing tree is empty
+func (w *RevWalk) treeSame(a, b *Sha1) bool {
+	for _, p := range w.paths {
+		var ia, ib item
+		if a != nil {
+			ia, _ = w.got.treeLookup(*a, p)
+		}
+		if b != nil {
+			ib, _ = w.got.treeLookup(*b, p)
+		}
+		if ia.sha != ib.sha || ia.mode != ib.mode {
+			return false
+		}
+	}
+	return true
+}
+
+// Walk hands every commit of the walk to fn, in the walk's order. fn can stop the walk by returning an error
+func (w *RevWalk) Walk(fn func(*Comm) error) error {
+	for {
+		c, err := w.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(c); err != nil {
+			return err
+		}
+	}
+}
+
+// Next hands out the next commit of the walk, and io.EOF when there are no more
+func (w *RevWalk) Next() (*Comm, error) {
+	if err := w.prepare(); err != nil {
+		return nil, err
+	}
+	if w.limited() {
+		if w.next == len(w.list) {
+			return nil, io.EOF
+		}
+		w.next++
+		return w.list[w.next-1], nil
+	}
+	for len(w.queue.items) > 0 && (w.max <= 0 || w.handed < w.max) {
+		c, show, err := w.step()
+		if err != nil {
+			return nil, err
+		}
+		if show {
+			w.handed++
+			return c, nil
+		}
+	}
+	return nil, io.EOF
+}
+
+// limited tells whether the walk has to be done whole before it hands out the first commit: to sort it,
+// to reverse it, or to be sure a commit isn't hidden by a commit further down the queue
+func (w *RevWalk) limited() bool {
+	return w.whole || w.sorting != SortDate || w.reverse || len(w.hidden) != 0
+}
+
+// revSlop is how many more commits a limited walk takes off the queue once only hidden ones are left in it.
+// a commit with a clock behind its parents' could otherwise end the walk too early, like in git
+const revSlop = 5
+
+// prepare starts the walk, the first time it is asked for a commit. what was pushed and hidden can't change after that.
+// a limited walk is done whole right here
+func (w *RevWalk) prepare() error {
+	if w.prepared {
+		return nil
+	}
+	w.prepared = true
+	w.queue = &commitQueue{}
+	w.queued = make(map[Sha1]*Comm)
+	w.uninteresting = make(map[Sha1]bool)
+	w.follows = make(map[Sha1][]Sha1)
+	w.parents = make(map[Sha1][]Sha1)
+	for _, sha := range w.hidden {
+		w.uninteresting[sha] = true
+		if err := w.enqueue(sha); err != nil {
+			return err
+		}
+	}
+	for _, sha := range w.pushed {
+		if err := w.enqueue(sha); err != nil {
+			return err
+		}
+	}
+	if !w.limited() {
+		return nil
+	}
+
+	//first the whole walk, newest first, remembering what each commit leads to
+	var order []*Comm
+	shown := make(map[Sha1]bool)
+	slop := revSlop
+	var date time.Time
+	for len(w.queue.items) > 0 {
+		c, show, err := w.step()
+		if err != nil {
+			return err
+		}
+		if w.uninteresting[c.sha] {
+			if slop = w.stillInteresting(date, slop); slop == 0 {
+				break
+			}
+			continue
+		}
+		date = c.committer.time
+		order = append(order, c)
+		shown[c.sha] = show
+	}
+	//a commit can be hidden after it was walked, when the hidden line of history reaches it late
+	edge := make(map[Sha1]bool)
+	for _, c := range order {
+		if w.uninteresting[c.sha] {
+			continue
+		}
+		for _, p := range w.follows[c.sha] {
+			if w.uninteresting[p] && !edge[p] {
+				edge[p] = true
+				w.edges = append(w.edges, p)
+			}
+		}
+	}
+
+	//the parents of a shown commit become the nearest shown commits down the followed parents
+	var nearest func(sha Sha1, seen map[Sha1]bool) []Sha1
+	nearest = func(sha Sha1, seen map[Sha1]bool) []Sha1 {
+		if seen[sha] || w.uninteresting[sha] {
+			return nil
+		}
+		seen[sha] = true
+		if shown[sha] {
+			return []Sha1{sha}
+		}
+		var out []Sha1
+		for _, p := range w.follows[sha] {
+			out = append(out, nearest(p, seen)...)
+		}
+		return out
+	}
+	list := []*Comm{}
+	for _, c := range order {
+		if !shown[c.sha] || w.uninteresting[c.sha] {
+			continue
+		}
+		list = append(list, c)
+		seen := make(map[Sha1]bool)
+		for _, p := range w.follows[c.sha] {
+			w.parents[c.sha] = append(w.parents[c.sha], nearest(p, seen)...)
+		}
+	}
+
+	if w.sorting != SortDate {
+		list = w.topoSort(list)
+	}
+	if w.max > 0 && len(list) > w.max {
+		list = list[:w.max]
+	}
+	if w.reverse {
+		for i, j := 0, len(list)-1; i < j; i, j = i+1, j-1 {
+			list[i], list[j] = list[j], list[i]
+		}
+	}
+	w.list = list
+	return nil
+}
+
+// enqueue reads a commit into the queue, once
+func (w *RevWalk) enqueue(sha Sha1) error {
+	if w.queued[sha] != nil {
+		return nil
+	}
+	c, err := w.got.readCommit(shaToString(sha))
+	if err != nil {
+		return err
+	}
+	w.queued[sha] = c
+	w.queue.push(c)
+	return nil
+}
+
+// step takes the newest commit off the queue and queues the parents the walk follows from it.
+// it reports whether the commit is shown. a hidden commit never is, and it hides all its parents
+func (w *RevWalk) step() (*Comm, bool, error) {
+	c := w.queue.pop()
+	if w.uninteresting[c.sha] {
+		for _, p := range c.parents {
+			if err := w.enqueue(p); err != nil {
+				return nil, false, err
+			}
+			w.hide(p)
+		}
+		return c, false, nil
+	}
+	parents, show := w.simplify(c)
+	n := len(c.parents)
+	show = show && n >= w.minParents && (w.maxParents < 0 || n <= w.maxParents)
+	for _, f := range w.filters {
+		show = show && f(c)
+	}
+	w.follows[c.sha] = parents
+	for _, p := range parents {
+		if err := w.enqueue(p); err != nil {
+			return nil, false, err
+		}
+	}
+	return c, show, nil
+}
+
+// hide marks a commit hidden, and what it reaches among the commits read so far
+func (w *RevWalk) hide(sha Sha1) {
+	stack := []Sha1{sha}
+	for len(stack) > 0 {
+		sha := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if w.uninteresting[sha] {
+			continue
+		}
+		w.uninteresting[sha] = true
+		if c, ok := w.queued[sha]; ok {
+			stack = append(stack, c.parents...)
+		}
+	}
+}
+
+// stillInteresting counts down the hidden commits a limited walk takes off the queue before it stops. it starts over
+// while the queue has a commit that isn't hidden, or one no older than the last commit walked, date
+func (w *RevWalk) stillInteresting(date time.Time, slop int) int {
+	if len(w.queue.items) == 0 {
+		return 0
+	}
+	if !w.queue.items[0].committer.time.Before(date) {
+		return revSlop
+	}
+	for _, c := range w.queue.items {
+		if !w.uninteresting[c.sha] {
+			return revSlop
+		}
+	}
+	return slop - 1
+}
+
+// simplify decides which parents of c the walk follows, and whether c is shown. without paths, that's all of them
+// and always. with paths, a parent that has the same content at the paths is the only one followed, and c isn't shown
+func (w *RevWalk) simplify(c *Comm) ([]Sha1, bool) {
+	parents := c.parents
+	if w.firstParent && len(parents) > 1 {
+		parents = parents[:1]
+	}
+	if len(w.paths) == 0 {
+		return parents, true
+	}
+	if len(parents) == 0 {
+		return nil, !w.treeSame(&c.treeSha, nil)
+	}
+	for _, p := range parents {
+		pc, err := w.got.readCommit(shaToString(p))
+		if err != nil {
+			continue
+		}
+		if w.treeSame(&c.treeSha, &pc.treeSha) {
+			return []Sha1{p}, false
+		}
+	}
+	return parents, true
+}
+
+// topoSort orders the commits so no parent comes before its children. with SortTopo the commits of one line of history
+// stay together, and with SortTopoDate the newest ready commit goes first
+func (w *RevWalk) topoSort(list []*Comm) []*Comm {
+	indegree := make(map[Sha1]int)
+	byName := make(map[Sha1]*Comm)
+	for _, c := range list {
+		byName[c.sha] = c
+	}
+	for _, c := range list {
+		for _, p := range w.parents[c.sha] {
+			if _, ok := byName[p]; ok {
+				indegree[p]++
+			}
+		}
+	}
+	var ready []*Comm
+	for _, c := range list {
+		if indegree[c.sha] == 0 {
+			ready = append(ready, c)
+		}
+	}
+	//topo order takes the last ready commit first, a stack, so the tips are pushed the other way round
+	if w.sorting == SortTopo {
+		for i, j := 0, len(ready)-1; i < j; i, j = i+1, j-1 {
+			ready[i], ready[j] = ready[j], ready[i]
+		}
+	}
+	dated := &commitQueue{}
+	if w.sorting == SortTopoDate {
+		for _, c := range ready {
+			dated.push(c)
+		}
+	}
+	var out []*Comm
+	for {
+		var c *Comm
+		if w.sorting == SortTopoDate {
+			if len(dated.items) == 0 {
+				break
+			}
+			c = dated.pop()
+		} else {
+			if len(ready) == 0 {
+				break
+			}
+			c = ready[len(ready)-1]
+			ready = ready[:len(ready)-1]
+		}
+		out = append(out, c)
+		for _, p := range w.parents[c.sha] {
+			pc, ok := byName[p]
+			if !ok {
+				continue
+			}
+			indegree[p]--
+			if indegree[p] == 0 {
+				if w.sorting == SortTopoDate {
+					dated.push(pc)
+				} else {
+					ready = append(ready, pc)
+				}
+			}
+		}
+	}
+	return out
+}
+
+// Objects hands fn every object the commits of the walk need, with its type: commits first, then for each commit
+// the trees and blobs of its tree, with their paths. objects the hidden commits right below the walk have are left out, like
+// `rev-list --objects` does, and each object is handed out once
+func (w *RevWalk) Objects(fn func(sha Sha1, objType, path string) error) error {
+	w.whole = true
+	if err := w.prepare(); err != nil {
+		return err
+	}
+	seen := make(map[Sha1]bool)
+	for _, e := range w.edges {
+		c, err := w.got.readCommit(shaToString(e))
+		if err != nil {
+			return err
+		}
+		w.markTree(c.treeSha, seen)
+	}
+	for _, c := range w.list {
+		if err := fn(c.sha, "commit", ""); err != nil {
+			return err
+		}
+	}
+	for _, c := range w.list {
+		if err := w.walkTree(c.treeSha, "", seen, fn); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// markTree marks the tree and everything in it as seen
+func (w *RevWalk) markTree(tree Sha1, seen map[Sha1]bool) {
+	if seen[tree] {
+		return
+	}
+	seen[tree] = true
+	for _, it := range w.got.deserTree(shaToString(tree)) {
+		switch modType(it.mode) {
+		case treefile:
+			w.markTree(it.sha, seen)
+		case gitlinkfile:
+		default:
+			seen[it.sha] = true
+		}
+	}
+}
+
+// walkTree hands out the tree, then what's in it, depth first. a submodule's commit is not ours to hand out
+func (w *RevWalk) walkTree(tree Sha1, base string, seen map[Sha1]bool, fn func(Sha1, string, string) error) error {
+	if seen[tree] {
+		return nil
+	}
+	seen[tree] = true
+	if err := fn(tree, "tree", base); err != nil {
+		return err
+	}
+	for _, it := range w.got.deserTree(shaToString(tree)) {
+		p := path.Join(base, it.name)
+		switch modType(it.mode) {
+		case treefile:
+			if err := w.walkTree(it.sha, p, seen, fn); err != nil {
+				return err
+			}
+		case gitlinkfile:
+		default:
+			if seen[it.sha] {
+				continue
+			}
+			seen[it.sha] = true
+			if err := fn(it.sha, "blob", p); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// testChain makes a line of n commits, a minute apart, oldest first
+func testChain(t *testing.T, got *Got, n int) []Sha1 {
+	t.Helper()
+	var chain, parents []Sha1
+	for i := 0; i < n; i++ {
+		c := testCommit(t, got, int64(1700000000+60*i), parents...)
+		chain, parents = append(chain, c), []Sha1{c}
+	}
+	return chain
+}
+
+func walkAll(t *testing.T, w *RevWalk) []Sha1 {
+	t.Helper()
+	var out []Sha1
+	if err := w.Walk(func(c *Comm) error {
+		out = append(out, c.sha)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+// a date-order walk hands commits out as it goes, so a max count stops it before it reads the rest of history
+func TestRevWalkStopsAtMax(t *testing.T) {
+	got := testRepo(t)
+	chain := testChain(t, got, 5)
+	//the oldest commits are gone. reading them would fail the walk
+	for _, sha := range chain[:2] {
+		hex := shaToString(sha)
+		if err := os.Remove(filepath.Join(".git", "objects", hex[:2], hex[2:])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	w := got.NewRevWalk()
+	if err := w.Push(chain[4]); err != nil {
+		t.Fatal(err)
+	}
+	w.MaxCount(2)
+	if out := walkAll(t, w); len(out) != 2 || out[0] != chain[4] || out[1] != chain[3] {
+		t.Errorf("walk gave %v", out)
+	}
+}
+
+func TestRevWalkHidden(t *testing.T) {
+	got := testRepo(t)
+	chain := testChain(t, got, 5)
+	w := got.NewRevWalk()
+	if err := w.PushRev(shaToString(chain[1]) + ".." + shaToString(chain[4])); err != nil {
+		t.Fatal(err)
+	}
+	if out := walkAll(t, w); len(out) != 3 || out[0] != chain[4] || out[2] != chain[2] {
+		t.Errorf("walk gave %v", out)
+	}
+	edges, err := w.Edges()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(edges) != 1 || edges[0] != chain[1] {
+		t.Errorf("edges are %v", edges)
+	}
+}
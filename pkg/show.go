@@ -0,0 +1,252 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+//  |||SHOW||| //
+//show prints objects the way people read them. a commit is its log entry and its patch against its parent, or
+//against nothing for a root commit. an annotated tag is its header and message, then whatever it tags. a tree is
+//the list of its entries, a directory with a slash after it, and a blob is its content.
+//a merge gets no patch: git shows it as a combined diff against all its parents, which isn't done here.
+//read: https://git-scm.com/docs/git-show
+
+// ShowOpts is what `got show` was asked for
+type ShowOpts struct {
+	//Objects are revisions, <rev>:<path> included. none is HEAD
+	Objects []string
+	//Paths limit the patches of commits
+	Paths []string
+	//Pretty, Oneline and Date are the log's, for commits. tags use Pretty and Date too
+	Pretty  string
+	Oneline bool
+	Date    string
+	//Diff is how the patches of commits are made and printed. NoPatch leaves them out
+	Diff    DiffOpts
+	NoPatch bool
+}
+
+// Show prints the objects
+func (got *Got) Show(ctx context.Context, opts ShowOpts) (io.Reader, error) {
+	objects := opts.Objects
+	if len(objects) == 0 {
+		objects = []string{"HEAD"}
+	}
+	pretty := opts.Pretty
+	if opts.Oneline {
+		pretty = "oneline-abbrev"
+	}
+	if pretty == "" {
+		pretty = "medium"
+	}
+	s := &shower{got: got, opts: opts, pretty: pretty, shown: make(map[Sha1]bool)}
+	for _, name := range objects {
+		sha, err := got.ResolveRevision(name)
+		if err != nil {
+			return nil, err
+		}
+		if err := s.show(name, sha); err != nil {
+			return nil, err
+		}
+	}
+	return &s.b, nil
+}
+
+// shower prints the objects one after another. shownOne is set once something that takes a blank line before
+// the next thing is printed: a commit, a tag or a tree, but not a blob
+type shower struct {
+	got      *Got
+	opts     ShowOpts
+	pretty   string
+	b        bytes.Buffer
+	shownOne bool
+	//shown are the commits printed already, a tag of one of them doesn't print it again
+	shown map[Sha1]bool
+}
+
+func (s *shower) show(name string, sha Sha1) error {
+	ty, data, err := s.got.objectType(sha)
+	if err != nil {
+		return err
+	}
+	switch ty {
+	case "blob":
+		s.b.Write(data)
+	case "tree":
+		if s.shownOne {
+			s.b.WriteString("\n")
+		}
+		fmt.Fprintf(&s.b, "tree %s\n\n", name)
+		for _, it := range s.got.deserTree(shaToString(sha)) {
+			if modType(it.mode) == treefile {
+				it.name += "/"
+			}
+			s.b.WriteString(it.name + "\n")
+		}
+		s.shownOne = true
+	case "tag":
+		t, err := parseTag(bytes.NewReader(data))
+		if err != nil {
+			return fmt.Errorf("tag %s is not well formatted: %w", shaToString(sha), err)
+		}
+		if s.shownOne {
+			s.b.WriteString("\n")
+		}
+		fmt.Fprintf(&s.b, "tag %s\n", t.name)
+		if t.hasTagger {
+			if err := s.tagger(t.tagger); err != nil {
+				return err
+			}
+		}
+		//the message is printed as it is stored, from the blank line that ends the header
+		if i := bytes.Index(data, []byte("\n\n")); i >= 0 {
+			s.b.Write(data[i+1:])
+		}
+		s.shownOne = true
+		return s.show(name, t.object)
+	case "commit":
+		if s.shown[sha] {
+			return nil
+		}
+		s.shown[sha] = true
+		return s.commit(sha)
+	default:
+		return fmt.Errorf("unknown object type %s", ty)
+	}
+	return nil
+}
+
+// tagger prints who made a tag, and when, the way the format prints the author of a commit
+func (s *shower) tagger(sign Sign) error {
+	who := fmt.Sprintf("%s <%s>", sign.name, sign.email)
+	date := func() (string, error) {
+		return formatDate(sign.time, s.opts.Date, time.Now())
+	}
+	switch s.pretty {
+	case "oneline", "oneline-abbrev":
+	case "medium":
+		d, err := date()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&s.b, "Tagger: %s\nDate:   %s\n", who, d)
+	case "fuller":
+		d, err := date()
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&s.b, "Tagger:     %s\nTaggerDate: %s\n", who, d)
+	default:
+		fmt.Fprintf(&s.b, "Tagger: %s\n", who)
+	}
+	return nil
+}
+
+// commit prints a commit's log entry and its patch. the full formats and format: are separated from what came
+// before by a blank line, oneline and tformat: end each entry with a newline instead
+func (s *shower) commit(sha Sha1) error {
+	c, err := s.got.readCommit(shaToString(sha))
+	if err != nil {
+		return err
+	}
+	pairs, err := s.changes(c)
+	if err != nil {
+		return err
+	}
+	//like log, a commit that doesn't touch the paths is left out, and so is a merge that took them from a parent
+	if len(s.opts.Paths) != 0 {
+		touched, err := s.touches(c, pairs)
+		if err != nil || !touched {
+			return err
+		}
+	}
+	terminated := strings.HasPrefix(s.pretty, "oneline") || strings.HasPrefix(s.pretty, "tformat:") ||
+		strings.Contains(s.pretty, "%") && !strings.HasPrefix(s.pretty, "format:")
+	if s.shownOne && !terminated {
+		s.b.WriteString("\n")
+	}
+	s.shownOne = true
+	//an empty format prints nothing of the commit, not even a blank line under it
+	empty := s.pretty == "format:" || s.pretty == "tformat:"
+	if !empty {
+		lines, err := s.got.prettyCommit(c, s.pretty, s.opts.Date, nil, false)
+		if err != nil {
+			return err
+		}
+		s.b.WriteString(strings.Join(lines, "\n"))
+		if !strings.HasPrefix(s.pretty, "format:") {
+			s.b.WriteString("\n")
+		}
+	}
+	if s.opts.NoPatch {
+		return nil
+	}
+	if len(c.parents) > 1 {
+		if !empty {
+			s.b.WriteString("\n")
+		}
+		return nil
+	}
+	if len(pairs) == 0 {
+		return nil
+	}
+	//the log entry and the diff are kept apart by a blank line, or by --- when there's a stat and a patch
+	if !empty && !strings.HasPrefix(s.pretty, "oneline") {
+		if s.opts.Diff.Stat && s.opts.Diff.Patch {
+			s.b.WriteString("---")
+		}
+		s.b.WriteString("\n")
+	}
+	return s.got.writeDiff(&s.b, pairs, s.opts.Diff)
+}
+
+// changes are the files a commit changed from its parent, with renames found. a merge has none here
+func (s *shower) changes(c *Comm) ([]filePair, error) {
+	if len(c.parents) > 1 {
+		return nil, nil
+	}
+	var parent Sha1
+	if len(c.parents) == 1 {
+		p, err := s.got.readCommit(shaToString(c.parents[0]))
+		if err != nil {
+			return nil, err
+		}
+		parent = p.treeSha
+	}
+	changes, err := s.got.TreeDiff(parent, c.treeSha, true, s.opts.Paths...)
+	if err != nil {
+		return nil, err
+	}
+	pairs := make([]filePair, len(changes))
+	for i, ch := range changes {
+		pairs[i] = ch.pair()
+	}
+	var old map[string]*diffSide
+	if s.opts.Diff.Renames.CopiesHarder {
+		old = s.got.treeSides(parent)
+	}
+	return s.got.detectRenames(pairs, old, s.opts.Diff.Renames)
+}
+
+// touches tells whether a commit changed the paths. a merge did if it differs there from each of its parents
+func (s *shower) touches(c *Comm, pairs []filePair) (bool, error) {
+	if len(c.parents) < 2 {
+		return len(pairs) != 0, nil
+	}
+	for _, p := range c.parents {
+		parent, err := s.got.readCommit(shaToString(p))
+		if err != nil {
+			return false, err
+		}
+		changes, err := s.got.TreeDiff(parent.treeSha, c.treeSha, true, s.opts.Paths...)
+		if err != nil || len(changes) == 0 {
+			return false, err
+		}
+	}
+	return true, nil
+}
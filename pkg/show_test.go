@@ -0,0 +1,54 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestShow(t *testing.T) {
+	got := testRepo(t)
+	root := testCommitFiles(t, got, 1700000000, map[string]string{"a": "a\n", "d/c": "c\n"})
+	side := testCommitFiles(t, got, 1700000060, map[string]string{"a": "a\n", "b": "b\n", "d/c": "c\n"}, root)
+	main := testCommitFiles(t, got, 1700000120, map[string]string{"a": "a2\n", "d/c": "c\n"}, root)
+	merge := testCommitFiles(t, got, 1700000180, map[string]string{"a": "a2\n", "b": "b\n", "d/c": "c\n"}, main, side)
+	if err := got.writeRef("refs/heads/master", merge); err != nil {
+		t.Fatal(err)
+	}
+	//what git show --date=unix prints for the same commits
+	tests := []struct {
+		name string
+		opts ShowOpts
+		want string
+	}{
+		//a root commit is a patch against nothing
+		{name: "root commit", opts: ShowOpts{Objects: []string{shaToString(root)}},
+			want: "commit " + shaToString(root) + "\nAuthor: T <t@x>\nDate:   1700000000\n\n    commit at 1700000000\n\n" +
+				"diff --git a/a b/a\nnew file mode 100644\nindex 0000000..7898192\n--- /dev/null\n+++ b/a\n@@ -0,0 +1 @@\n+a\n" +
+				"diff --git a/d/c b/d/c\nnew file mode 100644\nindex 0000000..f2ad6c7\n--- /dev/null\n+++ b/d/c\n@@ -0,0 +1 @@\n+c\n"},
+		//a merge has no patch, and HEAD is what's shown when nothing is asked for
+		{name: "merge", opts: ShowOpts{},
+			want: "commit " + shaToString(merge) + "\nMerge: " + got.Abbrev(main, 7) + " " + got.Abbrev(side, 7) +
+				"\nAuthor: T <t@x>\nDate:   1700000180\n\n    commit at 1700000180\n\n"},
+		{name: "oneline", opts: ShowOpts{Objects: []string{"master^1"}, Oneline: true},
+			want: got.Abbrev(main, 7) + " commit at 1700000120\ndiff --git a/a b/a\nindex 7898192..c1827f0 100644\n--- a/a\n+++ b/a\n@@ -1 +1 @@\n-a\n+a2\n"},
+		{name: "no patch", opts: ShowOpts{Objects: []string{"master", shaToString(root)}, Oneline: true, NoPatch: true},
+			want: got.Abbrev(merge, 7) + " commit at 1700000180\n" + got.Abbrev(root, 7) + " commit at 1700000000\n"},
+		{name: "tree and blob", opts: ShowOpts{Objects: []string{"master^1", "master~1^{tree}", "master:a"}},
+			want: "commit " + shaToString(main) + "\nAuthor: T <t@x>\nDate:   1700000120\n\n    commit at 1700000120\n\n" +
+				"diff --git a/a b/a\nindex 7898192..c1827f0 100644\n--- a/a\n+++ b/a\n@@ -1 +1 @@\n-a\n+a2\n" +
+				"\ntree master~1^{tree}\n\na\nd/\na2\n"},
+	}
+	for _, tt := range tests {
+		tt.opts.Date = "unix"
+		tt.opts.Diff.Context = 3
+		rdr, err := got.Show(context.Background(), tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b, _ := io.ReadAll(rdr); string(b) != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b, tt.want)
+		}
+	}
+}
@@ -0,0 +1,322 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//sparse checkout lets a user keep only a few directories of a big repository in the working tree.
+//everything else stays in the index, marked skip-worktree, so status and commit carry it along untouched.
+//read: https://git-scm.com/docs/git-sparse-checkout
+//the patterns live in .git/info/sparse-checkout. they use the gitignore syntax, but say what to keep instead of what to ignore.
+//in cone mode, we only ever write directory patterns, so the directories can be recovered from the file for `list`
+
+type sparseSpec struct {
+	cone     bool
+	patterns []string
+}
+
+func (got *Got) sparseFile() string {
+	return filepath.Join(got.baseDir, ".git", "info", "sparse-checkout")
+}
+
+// readSparse returns a nil spec when sparse checkout is turned off
+func (got *Got) readSparse() (*sparseSpec, error) {
+	conf, err := got.loadConfig(local)
+	if err != nil {
+		return nil, err
+	}
+	if !conf.getBool([]string{"core", "sparseCheckout"}) {
+		return nil, nil
+	}
+	sp := &sparseSpec{cone: conf.getBool([]string{"core", "sparseCheckoutCone"})}
+	data, err := os.ReadFile(got.sparseFile())
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return sp, nil
+		}
+		return nil, err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sp.patterns = append(sp.patterns, line)
+	}
+	return sp, nil
+}
+
+func (got *Got) writeSparse(sp *sparseSpec) error {
+	if err := os.MkdirAll(filepath.Dir(got.sparseFile()), 0777); err != nil {
+		return err
+	}
+	var b bytes.Buffer
+	for _, p := range sp.patterns {
+		fmt.Fprintf(&b, "%s\n", p)
+	}
+	return os.WriteFile(got.sparseFile(), b.Bytes(), 0644)
+}
+
+// includes tells whether a path belongs in the working tree. like gitignore, the last pattern to match wins
+func (sp *sparseSpec) includes(p string) bool {
+	in := false
+	for _, pattern := range sp.patterns {
+		neg := strings.HasPrefix(pattern, "!")
+		if matchSparse(strings.TrimPrefix(pattern, "!"), p) {
+			in = !neg
+		}
+	}
+	return in
+}
+
+// matchSparse matches a single pattern against the path and every directory leading to it.
+// a pattern starting with a slash is anchored at the root, one ending with a slash only matches directories
+func matchSparse(pattern, p string) bool {
+	anchored := strings.HasPrefix(pattern, "/") || strings.Contains(strings.Trim(pattern, "/"), "/")
+	dirOnly := strings.HasSuffix(pattern, "/")
+	pattern = strings.Trim(pattern, "/")
+	parts := strings.Split(p, "/")
+	for i := 1; i <= len(parts); i++ {
+		//the last part is the file itself, which a directory pattern can't match
+		if dirOnly && i == len(parts) {
+			break
+		}
+		cand := parts[i-1]
+		if anchored {
+			cand = strings.Join(parts[:i], "/")
+		}
+		if ok, _ := path.Match(pattern, cand); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// coneDirs recovers the directories a cone-mode file was written for.
+// a directory that is there only because it is the parent of a chosen one is followed by a negation of its subdirectories
+func (sp *sparseSpec) coneDirs() []string {
+	parents := make(map[string]bool)
+	var dirs []string
+	for _, p := range sp.patterns {
+		if strings.HasPrefix(p, "!/") && strings.HasSuffix(p, "/*/") {
+			parents[strings.TrimSuffix(strings.TrimPrefix(p, "!/"), "/*/")] = true
+		}
+	}
+	for _, p := range sp.patterns {
+		if p == "/*" || strings.HasPrefix(p, "!") || !strings.HasSuffix(p, "/") {
+			continue
+		}
+		d := strings.Trim(p, "/")
+		if !parents[d] {
+			dirs = append(dirs, d)
+		}
+	}
+	sort.Strings(dirs)
+	return dirs
+}
+
+// conePatterns writes the patterns for a set of directories the way git does in cone mode:
+// every file at the root, every file inside the chosen directories,
+// and for each of their parents, only the files directly inside it
+func conePatterns(dirs []string) []string {
+	chosen := make(map[string]bool)
+	for _, d := range dirs {
+		chosen[d] = true
+	}
+	//a directory inside another chosen one adds nothing
+	under := func(d string) bool {
+		for p := path.Dir(d); p != "."; p = path.Dir(p) {
+			if chosen[p] {
+				return true
+			}
+		}
+		return false
+	}
+	all := make(map[string]bool)
+	for d := range chosen {
+		if under(d) {
+			delete(chosen, d)
+		}
+	}
+	for d := range chosen {
+		all[d] = true
+		for p := path.Dir(d); p != "."; p = path.Dir(p) {
+			all[p] = true
+		}
+	}
+	sorted := make([]string, 0, len(all))
+	for d := range all {
+		sorted = append(sorted, d)
+	}
+	sort.Strings(sorted)
+
+	patterns := []string{"/*", "!/*/"}
+	for _, d := range sorted {
+		patterns = append(patterns, fmt.Sprintf("/%s/", d))
+		if !chosen[d] {
+			patterns = append(patterns, fmt.Sprintf("!/%s/*/", d))
+		}
+	}
+	return patterns
+}
+
+// cleanSparseDirs turns user input into the slash-separated, root-relative directories cone mode works with
+func cleanSparseDirs(dirs []string) []string {
+	var ret []string
+	for _, d := range dirs {
+		d = path.Clean(strings.Trim(filepath.ToSlash(d), "/"))
+		if d == "." || d == "" {
+			continue
+		}
+		ret = append(ret, d)
+	}
+	return ret
+}
+
+// applySparse brings the skip-worktree bits and the working tree in line with the spec.
+// a nil spec includes everything. files with local changes are never removed, they stay tracked as usual
+func (got *Got) applySparse(sp *sparseSpec) error {
+	idx, err := readIndexFile()
+	if err != nil {
+		return err
+	}
+	for _, e := range idx.entries {
+		p := string(e.path)
+		full := filepath.Join(got.baseDir, p)
+		if sp == nil || sp.includes(p) {
+			if !e.skipWorktree() {
+				continue
+			}
+			e.setSkipWorktree(false)
+			if _, err := os.Lstat(full); errors.Is(err, fs.ErrNotExist) {
+				if err := got.checkoutEntry(e); err != nil {
+					return err
+				}
+			}
+			continue
+		}
+		if e.skipWorktree() {
+			continue
+		}
+		if _, err := os.Lstat(full); err == nil {
+			changed, err := idx.changed(e, full)
+			if err != nil {
+				return err
+			}
+			if changed {
+				got.logger.Printf("warning: %s has local changes and was left in the working tree\n", p)
+				continue
+			}
+			if err := got.removeWorktreeFile(p); err != nil {
+				return err
+			}
+		}
+		e.setSkipWorktree(true)
+	}
+	return got.UpIndexEntries(idx.entries)
+}
+
+// SparseInit turns sparse checkout on. a repository with no patterns yet starts with only the files at its root
+func (got *Got) SparseInit(ctx context.Context, cone bool) error {
+	if err := got.setConf("core.sparseCheckout", "true"); err != nil {
+		return err
+	}
+	if err := got.setConf("core.sparseCheckoutCone", fmt.Sprintf("%t", cone)); err != nil {
+		return err
+	}
+	sp, err := got.readSparse()
+	if err != nil {
+		return err
+	}
+	if len(sp.patterns) == 0 {
+		sp.patterns = conePatterns(nil)
+		if err := got.writeSparse(sp); err != nil {
+			return err
+		}
+	}
+	return got.applySparse(sp)
+}
+
+// SparseSet replaces the patterns. in cone mode they are directories. sparse checkout is turned on if it wasn't
+func (got *Got) SparseSet(ctx context.Context, patterns []string) error {
+	sp, err := got.readSparse()
+	if err != nil {
+		return err
+	}
+	if sp == nil {
+		//like git, a repository that never ran init gets cone mode
+		if err := got.SparseInit(ctx, true); err != nil {
+			return err
+		}
+		if sp, err = got.readSparse(); err != nil {
+			return err
+		}
+	}
+	if sp.cone {
+		sp.patterns = conePatterns(cleanSparseDirs(patterns))
+	} else {
+		sp.patterns = patterns
+	}
+	if err := got.writeSparse(sp); err != nil {
+		return err
+	}
+	return got.applySparse(sp)
+}
+
+// SparseAdd adds to the existing patterns
+func (got *Got) SparseAdd(ctx context.Context, patterns []string) error {
+	sp, err := got.readSparse()
+	if err != nil {
+		return err
+	}
+	if sp == nil {
+		return fmt.Errorf("sparse checkout is not enabled. run `sparse-checkout init` first")
+	}
+	if sp.cone {
+		sp.patterns = conePatterns(append(sp.coneDirs(), cleanSparseDirs(patterns)...))
+	} else {
+		sp.patterns = append(sp.patterns, patterns...)
+	}
+	if err := got.writeSparse(sp); err != nil {
+		return err
+	}
+	return got.applySparse(sp)
+}
+
+// SparseList shows the directories in cone mode, and the raw patterns otherwise
+func (got *Got) SparseList(ctx context.Context) (io.Reader, error) {
+	sp, err := got.readSparse()
+	if err != nil {
+		return nil, err
+	}
+	if sp == nil {
+		return nil, fmt.Errorf("sparse checkout is not enabled")
+	}
+	list := sp.patterns
+	if sp.cone {
+		list = sp.coneDirs()
+	}
+	var b bytes.Buffer
+	for _, l := range list {
+		fmt.Fprintf(&b, "%s\n", l)
+	}
+	return &b, nil
+}
+
+// SparseDisable brings every file back into the working tree and turns sparse checkout off. the patterns are kept
+func (got *Got) SparseDisable(ctx context.Context) error {
+	if err := got.applySparse(nil); err != nil {
+		return err
+	}
+	return got.setConf("core.sparseCheckout", "false")
+}
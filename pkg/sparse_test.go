@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+)
+
+func wantSparse(t *testing.T, in, out []string) {
+	t.Helper()
+	idx, err := readIndexFile()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range in {
+		if _, err := os.Stat(p); err != nil {
+			t.Errorf("%s is not in the working tree: %v", p, err)
+		}
+		if e := idx.cache[p]; e == nil || e.skipWorktree() {
+			t.Errorf("%s is not tracked in the working tree: %+v", p, e)
+		}
+	}
+	for _, p := range out {
+		if _, err := os.Stat(p); !os.IsNotExist(err) {
+			t.Errorf("%s is still in the working tree: %v", p, err)
+		}
+		if e := idx.cache[p]; e == nil || !e.skipWorktree() {
+			t.Errorf("%s is not marked skip-worktree: %+v", p, e)
+		}
+	}
+}
+
+func TestSparseCone(t *testing.T) {
+	got := testRepo(t)
+	ctx := context.Background()
+	files := map[string]string{
+		"top": "top\n", "src/a": "a\n", "src/lib/b": "b\n",
+		"docs/c": "c\n", "docs/deep/d": "d\n", "docs/other/e": "e\n",
+	}
+	one := testCommitFiles(t, got, 1700000000, files)
+	if err := got.writeRef("refs/heads/master", one); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.Checkout(ctx, "master", true, false); err != nil {
+		t.Fatal(err)
+	}
+
+	//the files at the root are always in, and so is everything under a chosen directory
+	if err := got.SparseSet(ctx, []string{"src"}); err != nil {
+		t.Fatal(err)
+	}
+	wantSparse(t, []string{"top", "src/a", "src/lib/b"}, []string{"docs/c", "docs/deep/d", "docs/other/e"})
+
+	//the parents of a chosen directory bring only the files directly inside them
+	if err := got.SparseAdd(ctx, []string{"docs/deep"}); err != nil {
+		t.Fatal(err)
+	}
+	wantSparse(t, []string{"top", "src/a", "docs/c", "docs/deep/d"}, []string{"docs/other/e"})
+	rdr, err := got.SparseList(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := io.ReadAll(rdr); string(b) != "docs/deep\nsrc\n" {
+		t.Errorf("sparse-checkout list printed %q", b)
+	}
+
+	//a file with local changes stays where it is, and stays tracked
+	if err := os.WriteFile("src/a", []byte("a local change\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.SparseSet(ctx, []string{"docs/deep"}); err != nil {
+		t.Fatal(err)
+	}
+	wantSparse(t, []string{"src/a", "docs/deep/d"}, []string{"src/lib/b", "docs/other/e"})
+	wantFile(t, "src/a", "a local change\n")
+
+	if err := got.SparseDisable(ctx); err != nil {
+		t.Fatal(err)
+	}
+	wantSparse(t, []string{"top", "src/a", "src/lib/b", "docs/c", "docs/deep/d", "docs/other/e"}, nil)
+	wantFile(t, "src/lib/b", "b\n")
+	if sp, err := got.readSparse(); err != nil || sp != nil {
+		t.Errorf("sparse checkout is still on: %+v, %v", sp, err)
+	}
+}
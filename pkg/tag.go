@@ -1,24 +1,315 @@
 package pkg
 
-import "io"
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path"
+	"strings"
+)
 
-type tagRaw struct {
-	name string
-	data []byte
-}
+//  |||TAGS||| //
+//a lightweight tag is only a ref under refs/tags that names an object, usually a commit.
+//an annotated tag is an object of its own, with a header saying what it tags and who tagged it, and a message.
+//the ref then names the tag object, and peeling the tag gets you to the object it tags.
+//read: https://git-scm.com/book/en/v2/Git-Basics-Tagging
+
+const (
+	tagPrefix    = "refs/tags/"
+	pgpSignature = "-----BEGIN PGP SIGNATURE-----"
+)
 
 type Tag struct {
-	id string
+	sha Sha1
+	//the object this tag points to, and its type
+	object  Sha1
+	objType string
+	name    string
+	tagger  Sign
+	//very old tags have no tagger line
+	hasTagger bool
+	msg       string
+	data      []byte
 }
 
-func (t *Tag) Hash(wkdir string) ([]byte, error) {
-	return nil, nil
+func (t *Tag) Hash(wkdir string) (Sha1, error) {
+	b, err := HashObj(t.Type(), t.data, wkdir)
+	if err != nil {
+		return Sha1{}, fmt.Errorf("Could not hash tag object: %w", err)
+	}
+	t.sha = b
+	return b, nil
 }
 
 func (t *Tag) Type() string {
 	return "tag"
 }
 
-func parseTag(r io.Reader, g *Got) (*Tag, error) {
-	return nil, nil
+// Encode writes the tag object the way git stores it
+func (t *Tag) Encode(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "object %s\ntype %s\ntag %s\n", shaToString(t.object), t.objType, t.name); err != nil {
+		return err
+	}
+	if t.hasTagger {
+		if _, err := fmt.Fprintf(w, "tagger %s\n", t.tagger.Format()); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "\n%s", t.msg)
+	return err
+}
+
+// String is the tag the way cat-file -p shows it
+func (t *Tag) String() string {
+	var b strings.Builder
+	t.Encode(&b)
+	return b.String()
+}
+
+// signature splits a signed tag's message into what was signed and the signature
+func (t *Tag) signature() (payload, sig []byte, ok bool) {
+	i := bytes.Index(t.data, []byte(pgpSignature))
+	if i < 0 {
+		return nil, nil, false
+	}
+	return t.data[:i], t.data[i:], true
+}
+
+func parseTag(r io.Reader) (*Tag, error) {
+	var b bytes.Buffer
+	scanner := bufio.NewScanner(io.TeeReader(r, &b))
+	t := &Tag{}
+	var msg bytes.Buffer
+	msgOn := false
+	for scanner.Scan() {
+		line := scanner.Text()
+		if msgOn {
+			msg.WriteString(line + "\n")
+			continue
+		}
+		if line == "" {
+			msgOn = true
+			continue
+		}
+		key, val, _ := strings.Cut(line, " ")
+		switch key {
+		case "object":
+			if len(val) != 40 || !isHex(val) {
+				return nil, fmt.Errorf("Object line in tag faulty")
+			}
+			t.object = strToSha(val)
+		case "type":
+			t.objType = val
+		case "tag":
+			t.name = val
+		case "tagger":
+			tagger, err := parseSign([]byte(val))
+			if err != nil {
+				return nil, err
+			}
+			t.tagger, t.hasTagger = tagger, true
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if t.objType == "" || t.name == "" {
+		return nil, fmt.Errorf("tag object is missing its type or name")
+	}
+	t.msg = msg.String()
+	t.data = b.Bytes()
+	return t, nil
+}
+
+// readTag reads and parses the tag object with the given sha
+func (got *Got) readTag(sha Sha1) (*Tag, error) {
+	_, ty, data, err := got.ReadObject(shaToString(sha))
+	if err != nil {
+		return nil, err
+	}
+	if ty != "tag" {
+		return nil, fmt.Errorf("%s is a %s, not a tag", shaToString(sha), ty)
+	}
+	t, err := parseTag(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("tag %s is not well formatted: %w", shaToString(sha), err)
+	}
+	t.sha = sha
+	return t, nil
+}
+
+// checkTagName makes sure name makes a valid ref under refs/tags
+func checkTagName(name string) error {
+	if strings.HasPrefix(name, "-") || CheckRefFormat(tagPrefix+name, false) != nil {
+		return fmt.Errorf("'%s' is not a valid tag name.", name)
+	}
+	return nil
+}
+
+// Tags lists the tags, sorted by name. with patterns, only the tags matching one of them are listed
+func (got *Got) Tags(ctx context.Context, patterns []string) (io.Reader, error) {
+	names, err := got.listRefs(tagPrefix)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	for _, name := range names {
+		short := strings.TrimPrefix(name, tagPrefix)
+		if len(patterns) == 0 {
+			fmt.Fprintln(&b, short)
+			continue
+		}
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, short); ok {
+				fmt.Fprintln(&b, short)
+				break
+			}
+		}
+	}
+	return &b, nil
+}
+
+// CreateTag points the tag name at rev, HEAD when rev is empty. with annotate, a tag object carrying msg is written first
+// and the tag points at that. an existing tag is only replaced with force, and then the returned message says what it was
+func (got *Got) CreateTag(ctx context.Context, name, rev string, annotate bool, msg string, force bool) (string, error) {
+	if err := checkTagName(name); err != nil {
+		return "", err
+	}
+	if rev == "" {
+		rev = "HEAD"
+	}
+	sha, err := got.ResolveRevision(rev)
+	if err != nil {
+		return "", fmt.Errorf("Failed to resolve '%s' as a valid ref.", rev)
+	}
+	ref := tagPrefix + name
+	old, err := got.readRef(ref)
+	exists := err == nil
+	if err != nil && !errors.Is(err, RefNotFoundErr) {
+		return "", err
+	}
+	if exists && !force {
+		return "", fmt.Errorf("tag '%s' already exists", name)
+	}
+
+	if annotate {
+		msg = strings.TrimSpace(msg)
+		if msg == "" {
+			return "", fmt.Errorf("no tag message")
+		}
+		ty, _, err := got.objectType(sha)
+		if err != nil {
+			return "", err
+		}
+		tagger, err := got.ident("COMMITTER")
+		if err != nil {
+			return "", err
+		}
+		t := &Tag{object: sha, objType: ty, name: name, tagger: tagger, hasTagger: true, msg: msg + "\n"}
+		var data bytes.Buffer
+		if err := t.Encode(&data); err != nil {
+			return "", err
+		}
+		t.data = data.Bytes()
+		if sha, err = t.Hash(got.baseDir); err != nil {
+			return "", err
+		}
+	}
+
+	tx := got.NewRefTransaction("")
+	if !exists {
+		tx.Create(ref, sha)
+		return "", tx.Commit()
+	}
+	tx.Update(ref, sha, old)
+	if err := tx.Commit(); err != nil {
+		return "", err
+	}
+	if old == sha {
+		return "", nil
+	}
+	return fmt.Sprintf("Updated tag '%s' (was %s)\n", name, shaToString(old)[:7]), nil
+}
+
+// DeleteTags deletes the named tags, saying what each one pointed to. it carries on past a missing tag and reports it at the end
+func (got *Got) DeleteTags(ctx context.Context, names []string) (io.Reader, error) {
+	var b bytes.Buffer
+	var missing error
+	for _, name := range names {
+		ref := tagPrefix + name
+		sha, err := got.readRef(ref)
+		if err != nil {
+			if !errors.Is(err, RefNotFoundErr) {
+				return &b, err
+			}
+			if missing == nil {
+				missing = fmt.Errorf("tag '%s' not found.", name)
+			}
+			continue
+		}
+		tx := got.NewRefTransaction("")
+		tx.Delete(ref, sha)
+		if err := tx.Commit(); err != nil {
+			return &b, err
+		}
+		fmt.Fprintf(&b, "Deleted tag '%s' (was %s)\n", name, shaToString(sha)[:7])
+	}
+	return &b, missing
+}
+
+// VerifyTag checks the signature of an annotated tag with gpg, or whatever gpg.program names.
+// the tag is returned to be shown, gpg's own report goes to stderr
+func (got *Got) VerifyTag(ctx context.Context, name string) (io.Reader, error) {
+	sha, err := got.readRef(tagPrefix + name)
+	if err != nil {
+		return nil, fmt.Errorf("tag '%s' not found.", name)
+	}
+	ty, _, err := got.objectType(sha)
+	if err != nil {
+		return nil, err
+	}
+	if ty != "tag" {
+		return nil, fmt.Errorf("%s: cannot verify a non-tag object of type %s.", name, ty)
+	}
+	t, err := got.readTag(sha)
+	if err != nil {
+		return nil, err
+	}
+	payload, sig, ok := t.signature()
+	if !ok {
+		return nil, fmt.Errorf("no signature found")
+	}
+	program := "gpg"
+	for _, where := range []int{system, global, local} {
+		if conf, err := got.loadConfig(where); err == nil {
+			if p, ok := conf.get([]string{"gpg", "program"}); ok {
+				program = p
+			}
+		}
+	}
+	//gpg wants the detached signature in a file, and reads the signed data from stdin
+	f, err := os.CreateTemp("", "got-tag-sig")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(sig)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, program, "--verify", f.Name(), "-")
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("could not verify the tag '%s': %w", name, err)
+	}
+	return bytes.NewReader(payload), nil
 }
@@ -0,0 +1,376 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+//  |||REF TRANSACTIONS||| //
+//a ref transaction changes a batch of refs at once. every change says what it expects the ref to hold now,
+//so two processes racing to move the same branch can't silently overwrite each other.
+//we lock every ref by creating <ref>.lock exclusively, check the old values under the locks,
+//and only then rename the locks over the refs. if anything fails before that, every lock is removed and nothing changes.
+//if a rename fails halfway, the refs already moved and packed-refs are written back as they were.
+//every ref that moves gets an entry in its reflog, with the message the transaction was started with.
+//read: https://git-scm.com/docs/git-update-ref
+
+// RefTxErr is returned when a ref does not hold the value a transaction expected
+var RefTxErr = errors.New("ref transaction rejected")
+
+type txState int
+
+const (
+	txOpen txState = iota
+	//a prepared transaction holds its locks and has checked its old values. it can only be committed or aborted
+	txPrepared
+	txClosed
+)
+
+type refUpdate struct {
+	name string
+	//the name the update lands on, once symbolic refs are followed
+	target string
+	newSha Sha1
+	oldSha Sha1
+	//without an old value we don't check what the ref holds
+	haveOld bool
+	delete  bool
+	//a verify only checks the old value, the ref is left as it is
+	verify bool
+	lock   string
+	//what the ref held when we locked it, for the reflog
+	curr Sha1
+	//the loose file as it was before Commit changed it, so a failed commit can put it back
+	applied bool
+	hadFile bool
+	undo    []byte
+}
+
+// RefTransaction queues ref changes and applies them all, or none of them, on Commit
+type RefTransaction struct {
+	store   refStore
+	updates []*refUpdate
+	state   txState
+	who     Sign
+	msg     string
+	//reflog errors come after the refs have moved, so they are only warned about here
+	logger *log.Logger
+	//packed-refs as it was before Commit dropped deleted refs from it
+	packedDropped bool
+	packedUndo    []byte
+}
+
+// NewRefTransaction starts an empty transaction on the refs of this repository. msg is what the reflogs will say
+func (got *Got) NewRefTransaction(msg string) *RefTransaction {
+	return &RefTransaction{store: got.refs(), who: got.reflogIdent(), msg: msg, logger: got.logger}
+}
+
+// Create queues a new ref. the ref must not exist yet
+func (tx *RefTransaction) Create(name string, newSha Sha1) {
+	tx.updates = append(tx.updates, &refUpdate{name: name, newSha: newSha, haveOld: true})
+}
+
+// Update queues moving the ref to newSha. the ref must hold oldSha, and a zero oldSha means it must not exist
+func (tx *RefTransaction) Update(name string, newSha, oldSha Sha1) {
+	tx.updates = append(tx.updates, &refUpdate{name: name, newSha: newSha, oldSha: oldSha, haveOld: true})
+}
+
+// Delete queues removing the ref, which must hold oldSha
+func (tx *RefTransaction) Delete(name string, oldSha Sha1) {
+	tx.updates = append(tx.updates, &refUpdate{name: name, oldSha: oldSha, haveOld: true, delete: true})
+}
+
+// ForceUpdate queues moving the ref to newSha, whatever it holds now
+func (tx *RefTransaction) ForceUpdate(name string, newSha Sha1) {
+	tx.updates = append(tx.updates, &refUpdate{name: name, newSha: newSha})
+}
+
+// ForceDelete queues removing the ref, whatever it holds now
+func (tx *RefTransaction) ForceDelete(name string) {
+	tx.updates = append(tx.updates, &refUpdate{name: name, delete: true})
+}
+
+// Verify queues a check that the ref holds oldSha, or doesn't exist when oldSha is zero. the ref stays locked until Commit
+func (tx *RefTransaction) Verify(name string, oldSha Sha1) {
+	tx.updates = append(tx.updates, &refUpdate{name: name, oldSha: oldSha, haveOld: true, verify: true})
+}
+
+// resolveName follows symbolic refs from name to the ref an update should land on.
+// updating HEAD on a branch moves the branch, like git does
+func (s refStore) resolveName(name string) (string, error) {
+	target, _, err := s.resolve(name)
+	if err != nil && !errors.Is(err, RefNotFoundErr) {
+		return "", err
+	}
+	return target, nil
+}
+
+// Prepare locks every ref of the transaction and checks the old values, without changing anything yet.
+// if it fails, the locks are gone and the transaction is closed. Commit is what applies a prepared transaction
+func (tx *RefTransaction) Prepare() error {
+	if tx.state != txOpen {
+		return fmt.Errorf("ref transaction already prepared or closed")
+	}
+	if err := tx.prepare(); err != nil {
+		tx.rollback()
+		tx.state = txClosed
+		return err
+	}
+	tx.state = txPrepared
+	return nil
+}
+
+// Commit applies every queued change. it either applies them all, or puts back what it had changed and returns the error.
+// the refs already moved are put back without their locks, so another process may see them moved for a moment.
+// once the refs have moved it succeeds, even if a reflog could not be written
+func (tx *RefTransaction) Commit() error {
+	if tx.state == txOpen {
+		if err := tx.Prepare(); err != nil {
+			return err
+		}
+	}
+	if tx.state != txPrepared {
+		return fmt.Errorf("ref transaction already closed")
+	}
+	tx.state = txClosed
+
+	var deleted []string
+	for _, u := range tx.updates {
+		if u.delete {
+			deleted = append(deleted, u.target)
+		}
+	}
+	//a deleted ref may be packed too. we hold the lock on packed-refs while we rewrite it
+	if len(deleted) != 0 {
+		if err := tx.dropPacked(deleted); err != nil {
+			tx.rollback()
+			return err
+		}
+	}
+	for _, u := range tx.updates {
+		if u.verify {
+			os.Remove(u.lock)
+			u.lock = ""
+			continue
+		}
+		path := tx.store.file(u.target)
+		b, err := os.ReadFile(path)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			tx.undo()
+			return fmt.Errorf("Could not update ref %s: %w", u.target, err)
+		}
+		u.hadFile, u.undo = err == nil, b
+		if u.delete {
+			if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+				tx.undo()
+				return err
+			}
+			u.applied = true
+			os.Remove(u.lock)
+			u.lock = ""
+			tx.store.pruneDirs(filepath.Dir(path))
+			continue
+		}
+		if err := os.Rename(u.lock, path); err != nil {
+			tx.undo()
+			return fmt.Errorf("Could not update ref %s: %w", u.target, err)
+		}
+		u.applied = true
+		u.lock = ""
+	}
+	//the refs have moved. a reflog we fail to write is no reason to undo that, or to report the update as failed
+	if err := tx.writeLogs(); err != nil {
+		tx.logger.Printf("warning: could not write the reflog: %s\n", err)
+	}
+	return nil
+}
+
+// writeLogs records every change of the transaction in the reflogs. a move of the branch HEAD points to is a move of HEAD too
+func (tx *RefTransaction) writeLogs() error {
+	head, _ := tx.store.readLoose("HEAD")
+	headBranch := strings.TrimPrefix(head, "ref: ")
+	var first error
+	keep := func(err error) {
+		if first == nil {
+			first = err
+		}
+	}
+	for _, u := range tx.updates {
+		if u.verify {
+			continue
+		}
+		if u.delete {
+			keep(tx.store.deleteLog(u.target))
+			continue
+		}
+		e := reflogEntry{old: u.curr, new: u.newSha, who: tx.who, msg: tx.msg}
+		keep(tx.store.appendLog(u.target, e))
+		if u.target != "HEAD" && (u.name == "HEAD" || u.target == headBranch) {
+			keep(tx.store.appendLog("HEAD", e))
+		}
+	}
+	return first
+}
+
+// Abort throws the queued changes away
+func (tx *RefTransaction) Abort() {
+	tx.rollback()
+	tx.state = txClosed
+}
+
+// prepare takes a lock on every ref, checks the old values, and writes the new values into the locks
+func (tx *RefTransaction) prepare() error {
+	seen := make(map[string]bool)
+	for _, u := range tx.updates {
+		//a bad name must never reach the disk, where it could escape .git or break revision parsing
+		if err := checkRefName(u.name); err != nil {
+			return err
+		}
+		target, err := tx.store.resolveName(u.name)
+		if err != nil {
+			return err
+		}
+		if seen[target] {
+			return fmt.Errorf("multiple updates for ref '%s' not allowed", target)
+		}
+		seen[target] = true
+		u.target = target
+	}
+	//locks are always taken in the same order, so two transactions on the same refs can't each hold half of them
+	sorted := make([]*refUpdate, len(tx.updates))
+	copy(sorted, tx.updates)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].target < sorted[j].target
+	})
+
+	for _, u := range sorted {
+		path := tx.store.file(u.target)
+		if err := os.MkdirAll(filepath.Dir(path), 0777); err != nil {
+			return fmt.Errorf("Could not lock ref %s: %w", u.target, err)
+		}
+		lock := path + ".lock"
+		f, err := os.OpenFile(lock, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			if errors.Is(err, os.ErrExist) {
+				return fmt.Errorf("Unable to create '%s': File exists.\nAnother got process seems to be running in this repository", lock)
+			}
+			return fmt.Errorf("Could not lock ref %s: %w", u.target, err)
+		}
+		u.lock = lock
+		if !u.delete && !u.verify {
+			_, err = f.WriteString(shaToString(u.newSha) + "\n")
+		}
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return fmt.Errorf("Could not write ref %s: %w", u.target, err)
+		}
+	}
+
+	for _, u := range sorted {
+		curr, err := tx.store.read(u.target)
+		exists := err == nil
+		if err != nil && !errors.Is(err, RefNotFoundErr) {
+			return err
+		}
+		u.curr = curr
+		if !u.haveOld {
+			continue
+		}
+		var zero Sha1
+		switch {
+		case u.oldSha == zero && exists:
+			return fmt.Errorf("%w: '%s' already exists", RefTxErr, u.target)
+		case u.oldSha != zero && !exists:
+			return fmt.Errorf("%w: '%s' does not exist, expected %s", RefTxErr, u.target, shaToString(u.oldSha))
+		case exists && curr != u.oldSha:
+			return fmt.Errorf("%w: '%s' is at %s but expected %s", RefTxErr, u.target, shaToString(curr), shaToString(u.oldSha))
+		}
+	}
+	return nil
+}
+
+// dropPacked removes names from packed-refs, under its lock
+func (tx *RefTransaction) dropPacked(names []string) error {
+	refs, err := tx.store.packed()
+	if err != nil {
+		return err
+	}
+	drop := make(map[string]bool)
+	for _, n := range names {
+		drop[n] = true
+	}
+	kept := refs[:0]
+	for _, r := range refs {
+		if !drop[r.name] {
+			kept = append(kept, r)
+		}
+	}
+	if len(kept) == len(refs) {
+		return nil
+	}
+	undo, err := os.ReadFile(filepath.Join(tx.store.dir, "packed-refs"))
+	if err != nil {
+		return err
+	}
+	if err := tx.store.writePacked(kept); err != nil {
+		return err
+	}
+	tx.packedDropped, tx.packedUndo = true, undo
+	return nil
+}
+
+// undo puts back the loose refs a failed Commit already changed, and packed-refs, then removes the locks left
+func (tx *RefTransaction) undo() {
+	for i := len(tx.updates) - 1; i >= 0; i-- {
+		u := tx.updates[i]
+		if !u.applied {
+			continue
+		}
+		path := tx.store.file(u.target)
+		if !u.hadFile {
+			os.Remove(path)
+			tx.store.pruneDirs(filepath.Dir(path))
+			continue
+		}
+		err := os.MkdirAll(filepath.Dir(path), 0777)
+		if err == nil {
+			err = os.WriteFile(path, u.undo, 0644)
+		}
+		if err != nil {
+			tx.logger.Printf("warning: could not restore ref %s: %s\n", u.target, err)
+		}
+	}
+	if tx.packedDropped {
+		path := filepath.Join(tx.store.dir, "packed-refs")
+		if err := os.WriteFile(path, tx.packedUndo, 0644); err != nil {
+			tx.logger.Printf("warning: could not restore packed-refs: %s\n", err)
+		}
+	}
+	tx.rollback()
+}
+
+func (tx *RefTransaction) rollback() {
+	for _, u := range tx.updates {
+		if u.lock != "" {
+			os.Remove(u.lock)
+			u.lock = ""
+		}
+	}
+}
+
+// pruneDirs removes the directories above a deleted ref while they are empty, stopping at refs/
+func (s refStore) pruneDirs(dir string) {
+	stop := s.file("refs")
+	for ; len(dir) > len(stop); dir = filepath.Dir(dir) {
+		if err := os.Remove(dir); err != nil {
+			return
+		}
+	}
+}
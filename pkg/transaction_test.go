@@ -0,0 +1,172 @@
+package pkg
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var (
+	txOne = strToSha("1111111111111111111111111111111111111111")
+	txTwo = strToSha("2222222222222222222222222222222222222222")
+)
+
+// noLocks fails the test if a transaction left a lock file behind under .git/refs
+func noLocks(t *testing.T, got *Got) {
+	t.Helper()
+	filepath.WalkDir(got.refFile("refs"), func(path string, d os.DirEntry, err error) error {
+		if err == nil && filepath.Ext(path) == ".lock" {
+			t.Errorf("lock left behind: %s", path)
+		}
+		return nil
+	})
+}
+
+func TestTransactionLockConflict(t *testing.T) {
+	got := testRepo(t)
+	if err := got.writeRef("refs/heads/a", txOne); err != nil {
+		t.Fatal(err)
+	}
+	//another process holds the lock on b. nothing moves, and only its lock is left
+	lock := got.refFile("refs/heads/b") + ".lock"
+	if err := os.MkdirAll(filepath.Dir(lock), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(lock, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tx := got.NewRefTransaction("test")
+	tx.Update("refs/heads/a", txTwo, txOne)
+	tx.Create("refs/heads/b", txTwo)
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Commit took a lock somebody else holds")
+	}
+	if sha, err := got.readRef("refs/heads/a"); err != nil || sha != txOne {
+		t.Errorf("refs/heads/a = %s, %v", shaToString(sha), err)
+	}
+	if _, err := got.readRef("refs/heads/b"); !errors.Is(err, RefNotFoundErr) {
+		t.Errorf("refs/heads/b was created: %v", err)
+	}
+	if _, err := os.Stat(got.refFile("refs/heads/a") + ".lock"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("lock on refs/heads/a was kept: %v", err)
+	}
+	if _, err := os.Stat(lock); err != nil {
+		t.Errorf("somebody else's lock was removed: %v", err)
+	}
+}
+
+func TestTransactionOldValueMismatch(t *testing.T) {
+	got := testRepo(t)
+	if err := got.writeRef("refs/heads/a", txOne); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		add  func(tx *RefTransaction)
+	}{
+		{"update", func(tx *RefTransaction) { tx.Update("refs/heads/a", txOne, txTwo) }},
+		{"create existing", func(tx *RefTransaction) { tx.Create("refs/heads/a", txTwo) }},
+		{"update missing", func(tx *RefTransaction) { tx.Update("refs/heads/b", txTwo, txOne) }},
+		{"delete", func(tx *RefTransaction) { tx.Delete("refs/heads/a", txTwo) }},
+		{"verify", func(tx *RefTransaction) { tx.Verify("refs/heads/a", txTwo) }},
+	}
+	for _, tt := range tests {
+		tx := got.NewRefTransaction("test")
+		tt.add(tx)
+		if err := tx.Commit(); !errors.Is(err, RefTxErr) {
+			t.Errorf("%s: Commit = %v, want %v", tt.name, err, RefTxErr)
+		}
+		if sha, err := got.readRef("refs/heads/a"); err != nil || sha != txOne {
+			t.Errorf("%s: refs/heads/a = %s, %v", tt.name, shaToString(sha), err)
+		}
+		noLocks(t, got)
+	}
+}
+
+func TestTransactionRollback(t *testing.T) {
+	got := testRepo(t)
+	if err := got.writeRef("refs/heads/a", txOne); err != nil {
+		t.Fatal(err)
+	}
+	//the new ref sorts before the bad one, so its lock is taken and written before the prepare fails
+	tx := got.NewRefTransaction("test")
+	tx.Create("refs/heads/0new", txOne)
+	tx.Update("refs/heads/a", txTwo, txTwo)
+	if err := tx.Prepare(); !errors.Is(err, RefTxErr) {
+		t.Fatalf("Prepare = %v, want %v", err, RefTxErr)
+	}
+	if _, err := got.readRef("refs/heads/0new"); !errors.Is(err, RefNotFoundErr) {
+		t.Errorf("refs/heads/0new exists after a failed prepare: %v", err)
+	}
+	noLocks(t, got)
+	//a transaction that failed to prepare is closed
+	if err := tx.Commit(); err == nil {
+		t.Error("Commit of a failed transaction succeeded")
+	}
+	if _, err := got.readRef("refs/heads/0new"); !errors.Is(err, RefNotFoundErr) {
+		t.Errorf("refs/heads/0new exists after a failed commit: %v", err)
+	}
+}
+
+func TestTransactionReflogFailure(t *testing.T) {
+	got := testRepo(t)
+	//a file where the reflog directory should be makes every reflog write for a branch fail
+	if err := os.MkdirAll(filepath.Join(".git", "logs", "refs"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(".git", "logs", "refs", "heads"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	tx := got.NewRefTransaction("test")
+	tx.Create("refs/heads/main", txOne)
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("Commit failed after the ref moved: %v", err)
+	}
+	if sha, err := got.readRef("refs/heads/main"); err != nil || sha != txOne {
+		t.Errorf("refs/heads/main = %s, %v", shaToString(sha), err)
+	}
+}
+
+func TestTransactionCommitFailure(t *testing.T) {
+	got := testRepo(t)
+	if err := got.refs().writePacked([]packedRef{{name: "refs/heads/c", sha: txOne}}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"refs/heads/a", "refs/heads/b"} {
+		if err := got.writeRef(name, txOne); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tx := got.NewRefTransaction("test")
+	tx.Delete("refs/heads/c", txOne)
+	tx.Update("refs/heads/a", txTwo, txOne)
+	tx.Create("refs/heads/new", txTwo)
+	tx.Update("refs/heads/b", txTwo, txOne)
+	if err := tx.Prepare(); err != nil {
+		t.Fatal(err)
+	}
+	//b turns into a directory under our feet, so Commit fails on it after it has moved the others
+	b := got.refFile("refs/heads/b")
+	if err := os.Remove(b); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(b, "x"), 0777); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Commit(); err == nil {
+		t.Fatal("Commit succeeded over a directory")
+	}
+	for name, want := range map[string]Sha1{"refs/heads/a": txOne, "refs/heads/c": txOne} {
+		if sha, err := got.readRef(name); err != nil || sha != want {
+			t.Errorf("%s = %s, %v, want it back at %s", name, shaToString(sha), err, shaToString(want))
+		}
+	}
+	if _, err := got.readRef("refs/heads/new"); !errors.Is(err, RefNotFoundErr) {
+		t.Errorf("refs/heads/new exists after a failed commit: %v", err)
+	}
+	if _, ok, err := got.refs().findPacked("refs/heads/c"); err != nil || !ok {
+		t.Errorf("refs/heads/c is not packed any more: %v", err)
+	}
+	noLocks(t, got)
+}
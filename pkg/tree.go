@@ -8,6 +8,7 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -142,18 +143,24 @@ func (t *Tree) fullyParseTree() error {
 type fileType uint8
 
 const (
-	blobfile fileType = 0b00000100
-	treefile fileType = 0b00001000
+	blobfile    fileType = 0b00000100
+	treefile    fileType = 0b00001000
+	symlinkfile fileType = 0b00010000
+	gitlinkfile fileType = 0b00100000
 )
 
-// comeback
+// modType looks at the object type bits of a mode, the top four of its sixteen
 func modType(m uint32) fileType {
-	mod := m & uint32(0xffff)
-	if (mod >> 8) == uint32(blobfile) {
+	switch (m & 0xffff) >> 12 {
+	case 010: //100644 and 100755
 		return blobfile
-	} else if (mod >> 8) == uint32(treefile) {
+	case 004: //40000
 		return treefile
-	} else {
+	case 012: //120000
+		return symlinkfile
+	case 016: //160000, a submodule's commit
+		return gitlinkfile
+	default:
 		return 0
 	}
 }
@@ -165,7 +172,7 @@ func modType(m uint32) fileType {
 
 func (t *Tree) Hash(wkdir string) (Sha1, error) {
 	b, err := HashObj(t.Type(), t.data, wkdir)
-	if err != nil {
+	if err == nil {
 		t.sha = b
 		return b, nil
 	}
@@ -215,7 +222,55 @@ func (t *Tree) Find(path string) (*item, error) {
 	return nil, nil
 }
 
-// comeback
+// peelToTree finds the tree a tree-ish names. a commit is peeled to its tree
+func (got *Got) peelToTree(treeish string) (Sha1, error) {
+	return got.peelRev(treeish, "tree")
+}
+
+// flattenTree lists every non-tree entry reachable from the tree. names are full paths from the root of the tree
+func (got *Got) flattenTree(sha Sha1, base string) []item {
+	var items []item
+	for _, obj := range got.deserTree(shaToString(sha)) {
+		p := path.Join(base, obj.name)
+		if modType(obj.mode) == treefile {
+			items = append(items, got.flattenTree(obj.sha, p)...)
+			continue
+		}
+		obj.name = p
+		items = append(items, obj)
+	}
+	return items
+}
+
+// ReadTree reads the tree into the index, replacing whatever was staged. the working tree is not touched.
+// entries that did not change keep their stat data, so status doesn't have to rehash them.
+// when sparse checkout is on, entries outside the sparse patterns are marked skip-worktree
 func (got *Got) ReadTree(treeish string) error {
-	return nil
+	treeSha, err := got.peelToTree(treeish)
+	if err != nil {
+		return err
+	}
+	old, err := readIndexFile()
+	if err != nil {
+		return err
+	}
+	sp, err := got.readSparse()
+	if err != nil {
+		return err
+	}
+	var entries []*IdxEntry
+	for _, it := range got.flattenTree(treeSha, "") {
+		e, ok := old.cache[it.name]
+		if !ok || e.sha != it.sha || e.mode != it.mode {
+			e = &IdxEntry{mode: it.mode, sha: it.sha, path: []byte(it.name)}
+		}
+		//a fresh read starts at stage zero with none of the old flags, apart from what sparse checkout says
+		e.flags = 0
+		e.extFlags = 0
+		if sp != nil && !sp.includes(it.name) {
+			e.setSkipWorktree(true)
+		}
+		entries = append(entries, e)
+	}
+	return got.UpIndexEntries(entries)
 }
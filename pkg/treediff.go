@@ -0,0 +1,367 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+//  |||TREE DIFFS||| //
+//a tree diff walks two trees side by side. tree entries are sorted, so one pass over both lists pairs them up,
+//and a subtree with the same sha on both sides is the same all the way down, so it's skipped without being read.
+//diff-tree compares two trees, diff-index a tree with the index or the working tree, and diff-files the index with
+//the working tree. they print the changes in the raw format, `:oldmode newmode oldsha newsha status\tpath`,
+//or just the status and the path.
+//read: https://git-scm.com/docs/git-diff-tree and https://git-scm.com/docs/git-diff#_raw_output_format
+
+// ChangeKind is the status letter of a change
+type ChangeKind byte
+
+const (
+	Added       ChangeKind = 'A'
+	Deleted     ChangeKind = 'D'
+	Modified    ChangeKind = 'M'
+	TypeChanged ChangeKind = 'T'
+	Renamed     ChangeKind = 'R'
+	Copied      ChangeKind = 'C'
+)
+
+// TreeChange is a path that differs between two trees. an added path has no old mode and sha, a deleted one no new ones.
+// a rename or a copy also has the path it came from, and how similar the two are, in percent
+type TreeChange struct {
+	Kind             ChangeKind
+	Path             string
+	OldMode, NewMode uint32
+	OldSha, NewSha   Sha1
+	OldPath          string
+	Similarity       int
+}
+
+// TreeDiff compares the trees a and b. the zero sha is the empty tree. with recursive, the changes inside subtrees are
+// listed instead of the subtrees themselves. paths limit the diff to those paths and what's under them
+func (got *Got) TreeDiff(a, b Sha1, recursive bool, paths ...string) ([]TreeChange, error) {
+	var changes []TreeChange
+	err := got.treeDiff(a, b, "", recursive, paths, &changes)
+	return changes, err
+}
+
+// treeEntries reads a tree for a tree diff. the zero sha has nothing in it
+func (got *Got) treeEntries(sha Sha1) []item {
+	if sha == (Sha1{}) {
+		return nil
+	}
+	return got.deserTree(shaToString(sha))
+}
+
+// treeOrderName is the name git sorts a tree entry by: a subtree sorts as if its name ended with a slash
+func treeOrderName(it item) string {
+	if modType(it.mode) == treefile {
+		return it.name + "/"
+	}
+	return it.name
+}
+
+// pathLeadsTo tells whether a directory holds, or is under, one of the paths
+func pathLeadsTo(dir string, paths []string) bool {
+	if pathMatches(dir, paths) {
+		return true
+	}
+	for _, p := range paths {
+		if strings.HasPrefix(path.Clean(p), dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (got *Got) treeDiff(a, b Sha1, base string, recursive bool, paths []string, changes *[]TreeChange) error {
+	if a == b {
+		return nil
+	}
+	olds, news := got.treeEntries(a), got.treeEntries(b)
+	i, j := 0, 0
+	for i < len(olds) || j < len(news) {
+		var o, n *item
+		switch {
+		case j == len(news):
+			o = &olds[i]
+			i++
+		case i == len(olds):
+			n = &news[j]
+			j++
+		default:
+			on, nn := treeOrderName(olds[i]), treeOrderName(news[j])
+			switch {
+			case on < nn:
+				o = &olds[i]
+				i++
+			case on > nn:
+				n = &news[j]
+				j++
+			default:
+				o, n = &olds[i], &news[j]
+				i, j = i+1, j+1
+			}
+		}
+		if err := got.diffEntry(o, n, base, recursive, paths, changes); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// diffEntry compares the entries a name has in the two trees. a missing entry is nil
+func (got *Got) diffEntry(o, n *item, base string, recursive bool, paths []string, changes *[]TreeChange) error {
+	var it *item
+	if it = o; it == nil {
+		it = n
+	}
+	p := path.Join(base, it.name)
+	isTree := modType(it.mode) == treefile
+	if isTree && !pathLeadsTo(p, paths) || !isTree && !pathMatches(p, paths) {
+		return nil
+	}
+	if o != nil && n != nil && o.sha == n.sha && o.mode == n.mode {
+		return nil
+	}
+	if isTree && recursive {
+		var a, b Sha1
+		if o != nil {
+			a = o.sha
+		}
+		if n != nil {
+			b = n.sha
+		}
+		return got.treeDiff(a, b, p, recursive, paths, changes)
+	}
+	c := TreeChange{Path: p}
+	if o != nil {
+		c.OldMode, c.OldSha = o.mode, o.sha
+	}
+	if n != nil {
+		c.NewMode, c.NewSha = n.mode, n.sha
+	}
+	switch {
+	case o == nil:
+		c.Kind = Added
+	case n == nil:
+		c.Kind = Deleted
+	case modType(o.mode) != modType(n.mode):
+		c.Kind = TypeChanged
+	default:
+		c.Kind = Modified
+	}
+	*changes = append(*changes, c)
+	return nil
+}
+
+// pair turns a change into the file pair a patch is made from
+func (c TreeChange) pair() filePair {
+	var p filePair
+	if c.Kind != Added {
+		oldPath := c.Path
+		if c.OldPath != "" {
+			oldPath = c.OldPath
+		}
+		p.old = &diffSide{path: oldPath, mode: c.OldMode, sha: c.OldSha}
+	}
+	if c.Kind != Deleted {
+		p.new = &diffSide{path: c.Path, mode: c.NewMode, sha: c.NewSha}
+	}
+	return p
+}
+
+// change is the other way round, the change a file pair is. a side from the working tree has no sha in the raw
+// format, like in git, which doesn't hash the files to list them
+func (p filePair) change() TreeChange {
+	c := TreeChange{Path: p.path()}
+	if p.old != nil {
+		c.OldMode, c.OldSha = p.old.mode, p.old.sha
+	}
+	if p.new != nil {
+		c.NewMode = p.new.mode
+		if !p.new.worktree {
+			c.NewSha = p.new.sha
+		}
+	}
+	switch {
+	case p.renamed():
+		c.Kind, c.OldPath, c.Similarity = Renamed, p.old.path, p.score*100/maxScore
+		if p.copied {
+			c.Kind = Copied
+		}
+	case p.old == nil:
+		c.Kind = Added
+	case p.new == nil:
+		c.Kind = Deleted
+	case modType(p.old.mode) != modType(p.new.mode):
+		c.Kind = TypeChanged
+	default:
+		c.Kind = Modified
+	}
+	return c
+}
+
+// DiffFormat is how the diff plumbing prints a change
+type DiffFormat int
+
+const (
+	// DiffRaw is `:oldmode newmode oldsha newsha status\tpath`
+	DiffRaw DiffFormat = iota
+	// DiffNameStatus is `status\tpath`
+	DiffNameStatus
+	// DiffNameOnly is the path alone
+	DiffNameOnly
+)
+
+// writeChanges prints the changes in one of the diff plumbing's formats. a rename or a copy has its
+// similarity after the status letter, and both paths
+func writeChanges(w io.Writer, changes []TreeChange, format DiffFormat) {
+	for _, c := range changes {
+		status, paths := string(c.Kind), c.Path
+		if c.Kind == Renamed || c.Kind == Copied {
+			status = fmt.Sprintf("%c%03d", c.Kind, c.Similarity)
+			paths = c.OldPath + "\t" + c.Path
+		}
+		switch format {
+		case DiffNameOnly:
+			fmt.Fprintln(w, c.Path)
+		case DiffNameStatus:
+			fmt.Fprintf(w, "%s\t%s\n", status, paths)
+		default:
+			fmt.Fprintf(w, ":%06o %06o %s %s %s\t%s\n", c.OldMode, c.NewMode, shaToString(c.OldSha), shaToString(c.NewSha), status, paths)
+		}
+	}
+}
+
+// DiffTree compares two tree-ishes, like `diff-tree a b`. with a single commit, it compares the commit with its
+// parent, and prints the commit's sha first. a root commit is only compared with the empty tree with root,
+// and a merge isn't compared at all
+func (got *Got) DiffTree(ctx context.Context, revs, paths []string, recursive, root bool, renames RenameOpts, format DiffFormat) (io.Reader, error) {
+	var b bytes.Buffer
+	var a, z Sha1
+	switch len(revs) {
+	case 1:
+		sha, err := got.ResolveRevision(revs[0])
+		if err != nil {
+			return nil, fmt.Errorf("bad revision '%s'", revs[0])
+		}
+		commit, err := got.peelTo(sha, "commit")
+		if err != nil {
+			return nil, err
+		}
+		c, err := got.readCommit(shaToString(commit))
+		if err != nil {
+			return nil, err
+		}
+		switch len(c.parents) {
+		case 0:
+			if !root {
+				return &b, nil
+			}
+		case 1:
+			p, err := got.readCommit(shaToString(c.parents[0]))
+			if err != nil {
+				return nil, err
+			}
+			a = p.treeSha
+		default:
+			return &b, nil
+		}
+		z = c.treeSha
+		fmt.Fprintln(&b, shaToString(commit))
+	case 2:
+		var err error
+		if a, err = got.peelToTree(revs[0]); err != nil {
+			return nil, fmt.Errorf("bad revision '%s'", revs[0])
+		}
+		if z, err = got.peelToTree(revs[1]); err != nil {
+			return nil, fmt.Errorf("bad revision '%s'", revs[1])
+		}
+	default:
+		return nil, fmt.Errorf("diff-tree takes one or two tree-ishes")
+	}
+	changes, err := got.TreeDiff(a, z, recursive, paths...)
+	if err != nil {
+		return nil, err
+	}
+	var old map[string]*diffSide
+	if renames.CopiesHarder {
+		old = got.treeSides(a)
+	}
+	if changes, err = got.findRenames(changes, old, renames); err != nil {
+		return nil, err
+	}
+	writeChanges(&b, changes, format)
+	return &b, nil
+}
+
+// findRenames runs rename detection over tree changes
+func (got *Got) findRenames(changes []TreeChange, old map[string]*diffSide, opts RenameOpts) ([]TreeChange, error) {
+	pairs := make([]filePair, len(changes))
+	for i, c := range changes {
+		pairs[i] = c.pair()
+	}
+	pairs, err := got.detectRenames(pairs, old, opts)
+	if err != nil {
+		return nil, err
+	}
+	return pairChanges(pairs), nil
+}
+
+// DiffIndex compares a tree-ish with the working tree, or with the index when cached
+func (got *Got) DiffIndex(ctx context.Context, rev string, paths []string, cached bool, renames RenameOpts, format DiffFormat) (io.Reader, error) {
+	tree, err := got.peelToTree(rev)
+	if err != nil {
+		return nil, fmt.Errorf("bad revision '%s'", rev)
+	}
+	idx, err := readIndexFile()
+	if err != nil {
+		return nil, err
+	}
+	var new map[string]*diffSide
+	if cached {
+		new = indexSides(idx)
+	} else if new, err = got.worktreeSides(idx); err != nil {
+		return nil, err
+	}
+	old := got.treeSides(tree)
+	pairs, err := got.detectRenames(pairSides(old, new, paths), old, renames)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	writeChanges(&b, pairChanges(pairs), format)
+	return &b, nil
+}
+
+// DiffFiles compares the index with the working tree
+func (got *Got) DiffFiles(ctx context.Context, paths []string, renames RenameOpts, format DiffFormat) (io.Reader, error) {
+	idx, err := readIndexFile()
+	if err != nil {
+		return nil, err
+	}
+	new, err := got.worktreeSides(idx)
+	if err != nil {
+		return nil, err
+	}
+	old := indexSides(idx)
+	pairs, err := got.detectRenames(pairSides(old, new, paths), old, renames)
+	if err != nil {
+		return nil, err
+	}
+	var b bytes.Buffer
+	writeChanges(&b, pairChanges(pairs), format)
+	return &b, nil
+}
+
+func pairChanges(pairs []filePair) []TreeChange {
+	changes := make([]TreeChange, len(pairs))
+	for i, p := range pairs {
+		changes[i] = p.change()
+	}
+	return changes
+}
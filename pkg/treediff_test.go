@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"context"
+	"io"
+	"testing"
+)
+
+func TestDiffTree(t *testing.T) {
+	got := testRepo(t)
+	ctx := context.Background()
+	one := testCommitFiles(t, got, 1700000000, map[string]string{"a": "a1\n", "d/x": "x\n", "d/y": "y1\n", "e/z": "z\n", "t": "t\n"})
+	//e goes, f comes, and t turns from a file into a directory
+	two := testCommitFiles(t, got, 1700000060, map[string]string{"a": "a2\n", "d/x": "x\n", "d/y": "y2\n", "f": "f\n", "t/u": "u\n"}, one)
+	commits := []string{shaToString(one), shaToString(two)}
+	//what git diff-tree prints for the same commits
+	const (
+		modA  = ":100644 100644 da0f8ed91a8f2f0f067b3bdf26265d5ca48cf82c c1827f07e114c20547dc6a7296588870a4b5b62c M\ta\n"
+		modD  = ":040000 040000 2894120c3b345f7bd63b5c79686321fe689b6c81 451b493076b9298565cc14b9d78b13dee78503a7 M\td\n"
+		modDY = ":100644 100644 656f4f9dcc676581418546503875242f26ea8e47 1a78173cc873f45bb2dfdb2f45b881ed321564eb M\td/y\n"
+		delE  = ":040000 000000 799450a0f8f3a9dcf7ffa00d7b73fdf822939cf9 0000000000000000000000000000000000000000 D\te\n"
+		delEZ = ":100644 000000 b68025345d5301abad4d9ec9166f455243a0d746 0000000000000000000000000000000000000000 D\te/z\n"
+		addF  = ":000000 100644 0000000000000000000000000000000000000000 6a69f92020f5df77af6e8813ff1232493383b708 A\tf\n"
+		delT  = ":100644 000000 718f4d2ff533cf8ead8d3556cf43912bd245fbc4 0000000000000000000000000000000000000000 D\tt\n"
+		addT  = ":000000 040000 0000000000000000000000000000000000000000 e749605eeca04935b4e627582a26fb79401d7ee9 A\tt\n"
+		addTU = ":000000 100644 0000000000000000000000000000000000000000 4ae8ef021bf6fcfff43a13be5abfa52bb6fb5dbc A\tt/u\n"
+	)
+	tests := []struct {
+		name      string
+		revs      []string
+		paths     []string
+		recursive bool
+		root      bool
+		format    DiffFormat
+		want      string
+	}{
+		{name: "two trees", revs: commits, want: modA + modD + delE + addF + delT + addT},
+		{name: "recursive", revs: commits, recursive: true, want: modA + modDY + delEZ + addF + delT + addTU},
+		{name: "paths", revs: commits, paths: []string{"d", "t"}, recursive: true, want: modDY + delT + addTU},
+		{name: "name-status", revs: commits, paths: []string{"d/y"}, recursive: true, format: DiffNameStatus, want: "M\td/y\n"},
+		{name: "name-only", revs: commits, format: DiffNameOnly, want: "a\nd\ne\nf\nt\nt\n"},
+		{name: "a commit and its parent", revs: commits[1:], want: commits[1] + "\n" + modA + modD + delE + addF + delT + addT},
+		{name: "a root commit", revs: commits[:1]},
+		{name: "a root commit with --root", revs: commits[:1], root: true, want: commits[0] + "\n" +
+			":000000 100644 0000000000000000000000000000000000000000 da0f8ed91a8f2f0f067b3bdf26265d5ca48cf82c A\ta\n" +
+			":000000 040000 0000000000000000000000000000000000000000 2894120c3b345f7bd63b5c79686321fe689b6c81 A\td\n" +
+			":000000 040000 0000000000000000000000000000000000000000 799450a0f8f3a9dcf7ffa00d7b73fdf822939cf9 A\te\n" +
+			":000000 100644 0000000000000000000000000000000000000000 718f4d2ff533cf8ead8d3556cf43912bd245fbc4 A\tt\n"},
+	}
+	for _, tt := range tests {
+		rdr, err := got.DiffTree(ctx, tt.revs, tt.paths, tt.recursive, tt.root, RenameOpts{}, tt.format)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if b, _ := io.ReadAll(rdr); string(b) != tt.want {
+			t.Errorf("%s: got\n%s\nwant\n%s", tt.name, b, tt.want)
+		}
+	}
+
+	//the same tree on both sides has nothing to show
+	c, err := got.readCommit(commits[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes, err := got.TreeDiff(c.treeSha, c.treeSha, true); err != nil || len(changes) != 0 {
+		t.Errorf("a tree against itself gave %+v, %v", changes, err)
+	}
+	//and the zero sha is the empty tree
+	changes, err := got.TreeDiff(Sha1{}, c.treeSha, true)
+	if err != nil || len(changes) != 5 || changes[0].Kind != Added || changes[0].OldMode != 0 || changes[0].Path != "a" {
+		t.Errorf("the empty tree against a tree gave %+v, %v", changes, err)
+	}
+}
@@ -0,0 +1,289 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+)
+
+//  |||REF PLUMBING||| //
+//update-ref moves, creates and deletes refs safely: every change goes through a ref transaction,
+//so it can say what the ref must hold before the change, and a batch from --stdin applies all at once or not at all.
+//show-ref lists refs with the objects they point at, or checks that refs exist.
+//read: https://git-scm.com/docs/git-update-ref and https://git-scm.com/docs/git-show-ref
+
+// nullSha is how update-ref spells a ref that doesn't exist, before or after the change
+var nullSha = strings.Repeat("0", 40)
+
+// parseOldValue reads the <old> of update-ref. an empty value, or the null sha, means the ref must not exist
+func (got *Got) parseOldValue(old string) (Sha1, error) {
+	if old == "" || old == nullSha {
+		return Sha1{}, nil
+	}
+	sha, err := got.ResolveRevision(old)
+	if err != nil {
+		return Sha1{}, fmt.Errorf("%s: not a valid old SHA1", old)
+	}
+	return sha, nil
+}
+
+// parseNewValue reads the <new> of update-ref, which must name an object. the null sha asks for the ref to be deleted
+func (got *Got) parseNewValue(new string) (Sha1, error) {
+	if new == nullSha {
+		return Sha1{}, nil
+	}
+	sha, err := got.ResolveRevision(new)
+	if err != nil {
+		return Sha1{}, fmt.Errorf("%s: not a valid SHA1", new)
+	}
+	return sha, nil
+}
+
+// UpdateRef points ref at new, or deletes it with del or a null new. with haveOld, the ref must hold old for the change to happen.
+// msg is what the reflog says
+func (got *Got) UpdateRef(ctx context.Context, ref, new, old string, haveOld, del bool, msg string) error {
+	tx := got.NewRefTransaction(msg)
+	var oldSha Sha1
+	if haveOld {
+		var err error
+		if oldSha, err = got.parseOldValue(old); err != nil {
+			return err
+		}
+	}
+	switch {
+	case del && haveOld:
+		tx.Delete(ref, oldSha)
+	case del:
+		tx.ForceDelete(ref)
+	default:
+		newSha, err := got.parseNewValue(new)
+		if err != nil {
+			return err
+		}
+		switch {
+		case newSha == (Sha1{}) && haveOld:
+			tx.Delete(ref, oldSha)
+		case newSha == (Sha1{}):
+			tx.ForceDelete(ref)
+		case haveOld:
+			tx.Update(ref, newSha, oldSha)
+		default:
+			tx.ForceUpdate(ref, newSha)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("cannot lock ref '%s': %w", ref, err)
+	}
+	return nil
+}
+
+// updateRefArgs is how few and how many arguments each --stdin command takes
+var updateRefArgs = map[string][2]int{
+	"update": {2, 3}, "create": {2, 2}, "delete": {1, 2}, "verify": {1, 2},
+	"start": {0, 0}, "prepare": {0, 0}, "commit": {0, 0}, "abort": {0, 0},
+}
+
+// UpdateRefStdin runs the commands of `update-ref --stdin`, one per line:
+//
+//	update <ref> <new> [<old>], create <ref> <new>, delete <ref> [<old>], verify <ref> [<old>]
+//
+// and start, prepare, commit and abort to split the input into several transactions. prepare takes the locks early,
+// after it only commit and abort are allowed.
+// without them the whole input is one transaction, committed at the end. what start, commit and abort report goes to w
+func (got *Got) UpdateRefStdin(ctx context.Context, r io.Reader, w io.Writer, msg string) error {
+	var tx *RefTransaction
+	open := func() {
+		if tx == nil {
+			tx = got.NewRefTransaction(msg)
+		}
+	}
+	//whatever goes wrong, the transaction in progress is aborted, so no lock it took stays behind
+	fail := func(err error) error {
+		if tx != nil {
+			tx.Abort()
+		}
+		return err
+	}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		fields := strings.Fields(line)
+		cmd, args := fields[0], fields[1:]
+		if tx != nil && tx.state == txPrepared && cmd != "commit" && cmd != "abort" {
+			return fail(fmt.Errorf("prepared transactions can only be closed"))
+		}
+		n, ok := updateRefArgs[cmd]
+		if !ok {
+			return fail(fmt.Errorf("unknown command: %s", line))
+		}
+		if len(args) < n[0] || len(args) > n[1] {
+			return fail(fmt.Errorf("%s: wrong number of arguments: %s", cmd, line))
+		}
+		//an old value of the zero sha must be given explicitly to ask for a missing ref
+		haveOld := len(args) == n[1] && n[0] != n[1]
+		switch cmd {
+		case "start":
+			if tx != nil {
+				return fail(fmt.Errorf("start: transaction already in progress"))
+			}
+			open()
+			fmt.Fprintln(w, "start: ok")
+			continue
+		case "prepare":
+			//the locks are taken and the old values checked now, so a commit after this can't be refused
+			open()
+			if err := tx.Prepare(); err != nil {
+				return fmt.Errorf("prepare: %w", err)
+			}
+			fmt.Fprintln(w, "prepare: ok")
+			continue
+		case "commit":
+			open()
+			if err := tx.Commit(); err != nil {
+				return err
+			}
+			tx = nil
+			fmt.Fprintln(w, "commit: ok")
+			continue
+		case "abort":
+			if tx != nil {
+				tx.Abort()
+				tx = nil
+			}
+			fmt.Fprintln(w, "abort: ok")
+			continue
+		}
+
+		open()
+		ref := args[0]
+		var oldSha Sha1
+		if haveOld || cmd == "verify" {
+			old := ""
+			if len(args) == n[1] {
+				old = args[n[1]-1]
+			}
+			var err error
+			if oldSha, err = got.parseOldValue(old); err != nil {
+				return fail(fmt.Errorf("%s %s: %w", cmd, ref, err))
+			}
+		}
+		var newSha Sha1
+		if cmd == "update" || cmd == "create" {
+			var err error
+			if newSha, err = got.parseNewValue(args[1]); err != nil {
+				return fail(fmt.Errorf("%s %s: %w", cmd, ref, err))
+			}
+		}
+		//a null <new> deletes the ref, which create is not for
+		if cmd == "create" && newSha == (Sha1{}) {
+			return fail(fmt.Errorf("create %s: zero <new-oid>", ref))
+		}
+		switch {
+		case cmd == "create":
+			tx.Create(ref, newSha)
+		case cmd == "update" && newSha == (Sha1{}) && haveOld:
+			tx.Delete(ref, oldSha)
+		case cmd == "update" && newSha == (Sha1{}):
+			tx.ForceDelete(ref)
+		case cmd == "update" && haveOld:
+			tx.Update(ref, newSha, oldSha)
+		case cmd == "update":
+			tx.ForceUpdate(ref, newSha)
+		case cmd == "delete" && haveOld:
+			tx.Delete(ref, oldSha)
+		case cmd == "delete":
+			tx.ForceDelete(ref)
+		case cmd == "verify":
+			tx.Verify(ref, oldSha)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fail(err)
+	}
+	if tx == nil {
+		return nil
+	}
+	return tx.Commit()
+}
+
+// ShowRef lists refs with the objects they point at, like `sha refname`. heads and tags keep only branches or tags.
+// patterns match the end of a ref at a slash, so main matches refs/heads/main and refs/remotes/origin/main.
+// with verify, the patterns must be full ref names instead. deref adds the peeled object of annotated tags,
+// and hashOnly leaves the names out. when nothing matches a pattern, the error wraps RefNotFoundErr
+func (got *Got) ShowRef(ctx context.Context, patterns []string, heads, tags, verify, deref, hashOnly bool) (io.Reader, error) {
+	var b bytes.Buffer
+	show := func(name string, sha Sha1) error {
+		if hashOnly {
+			fmt.Fprintln(&b, shaToString(sha))
+		} else {
+			fmt.Fprintf(&b, "%s %s\n", shaToString(sha), name)
+		}
+		if !deref {
+			return nil
+		}
+		peeled, ok, err := got.peel(sha)
+		if err != nil {
+			return err
+		}
+		//git keeps the name on the peeled line, even with --hash
+		if ok {
+			fmt.Fprintf(&b, "%s %s^{}\n", shaToString(peeled), name)
+		}
+		return nil
+	}
+
+	if verify {
+		for _, name := range patterns {
+			sha, err := got.readRef(name)
+			if err != nil || (name != "HEAD" && !strings.HasPrefix(name, "refs/")) {
+				return &b, fmt.Errorf("'%s' - not a valid ref", name)
+			}
+			if err := show(name, sha); err != nil {
+				return &b, err
+			}
+		}
+		return &b, nil
+	}
+
+	names, err := got.listRefs("refs")
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, name := range names {
+		if (heads || tags) && !(heads && strings.HasPrefix(name, branchPrefix)) && !(tags && strings.HasPrefix(name, tagPrefix)) {
+			continue
+		}
+		if len(patterns) != 0 && !refTailMatches(name, patterns) {
+			continue
+		}
+		sha, err := got.readRef(name)
+		if err != nil {
+			continue
+		}
+		if err := show(name, sha); err != nil {
+			return &b, err
+		}
+		found = true
+	}
+	if !found {
+		return &b, fmt.Errorf("no matching refs: %w", RefNotFoundErr)
+	}
+	return &b, nil
+}
+
+// refTailMatches tells whether one of the patterns is the whole ref, or its last components
+func refTailMatches(name string, patterns []string) bool {
+	for _, p := range patterns {
+		if name == p || strings.HasSuffix(name, "/"+p) {
+			return true
+		}
+	}
+	return false
+}
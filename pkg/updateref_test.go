@@ -0,0 +1,133 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestUpdateRefStdinPrepare(t *testing.T) {
+	got := testRepo(t)
+	one := testCommit(t, got, 1700000000)
+	two := testCommit(t, got, 1700000060, one)
+	if err := got.writeRef("refs/heads/main", one); err != nil {
+		t.Fatal(err)
+	}
+
+	//prepare checks the old value, so a wrong one fails there and not at commit
+	var out bytes.Buffer
+	in := fmt.Sprintf("start\nupdate refs/heads/main %s %s\nprepare\ncommit\n", shaToString(two), shaToString(two))
+	err := got.UpdateRefStdin(context.Background(), strings.NewReader(in), &out, "test")
+	if err == nil || !strings.HasPrefix(err.Error(), "prepare: ") {
+		t.Errorf("a wrong old value gave %v", err)
+	}
+	if out.String() != "start: ok\n" {
+		t.Errorf("output was %q", out.String())
+	}
+	if _, err := os.Stat(got.refFile("refs/heads/main") + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("a failed prepare left its lock behind: %v", err)
+	}
+
+	//a prepared transaction holds the lock, takes nothing more, and commits
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- got.UpdateRefStdin(context.Background(), inR, outW, "test")
+		outW.Close()
+	}()
+	replies := bufio.NewScanner(outR)
+	fmt.Fprintf(inW, "start\nupdate refs/heads/main %s %s\nprepare\n", shaToString(two), shaToString(one))
+	for _, want := range []string{"start: ok", "prepare: ok"} {
+		if !replies.Scan() || replies.Text() != want {
+			t.Fatalf("got %q, want %q", replies.Text(), want)
+		}
+	}
+	if _, err := os.Stat(got.refFile("refs/heads/main") + ".lock"); err != nil {
+		t.Errorf("prepare did not lock the ref: %v", err)
+	}
+	fmt.Fprint(inW, "commit\n")
+	if !replies.Scan() || replies.Text() != "commit: ok" {
+		t.Fatalf("got %q, want commit: ok", replies.Text())
+	}
+	inW.Close()
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if sha, err := got.readRef("refs/heads/main"); err != nil || sha != two {
+		t.Errorf("refs/heads/main = %s, %v", shaToString(sha), err)
+	}
+
+	in = fmt.Sprintf("start\nprepare\nupdate refs/heads/main %s\ncommit\n", shaToString(one))
+	if err := got.UpdateRefStdin(context.Background(), strings.NewReader(in), &out, "test"); err == nil {
+		t.Errorf("a prepared transaction took another update")
+	}
+}
+
+func TestUpdateRefStdinBadCommand(t *testing.T) {
+	got := testRepo(t)
+	one := testCommit(t, got, 1700000000)
+	two := testCommit(t, got, 1700000060, one)
+	if err := got.writeRef("refs/heads/main", one); err != nil {
+		t.Fatal(err)
+	}
+	update := fmt.Sprintf("start\nupdate refs/heads/main %s\nprepare\n", shaToString(two))
+	for _, bad := range []string{"bogus", "commit now", "start", "update refs/heads/main " + shaToString(one), "abort now"} {
+		var out bytes.Buffer
+		if err := got.UpdateRefStdin(context.Background(), strings.NewReader(update+bad+"\n"), &out, "test"); err == nil {
+			t.Errorf("%q after prepare was accepted", bad)
+		}
+		if _, err := os.Stat(got.refFile("refs/heads/main") + ".lock"); !os.IsNotExist(err) {
+			t.Errorf("%q after prepare left the lock behind: %v", bad, err)
+		}
+		if sha, err := got.readRef("refs/heads/main"); err != nil || sha != one {
+			t.Errorf("%q after prepare: refs/heads/main = %s, %v", bad, shaToString(sha), err)
+		}
+	}
+}
+
+func TestUpdateRefNullSha(t *testing.T) {
+	got := testRepo(t)
+	one := testCommit(t, got, 1700000000)
+	ctx := context.Background()
+	//only the full null sha stands for a missing ref. a short run of zeros is an object name, and names nothing here
+	for _, old := range []string{"0", "0000000"} {
+		if err := got.UpdateRef(ctx, "refs/heads/main", shaToString(one), old, true, false, "test"); err == nil {
+			t.Errorf("old value %q was taken as the null sha", old)
+		}
+	}
+	if err := got.UpdateRef(ctx, "refs/heads/main", shaToString(one), nullSha, true, false, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := got.UpdateRef(ctx, "refs/heads/main", shaToString(one), nullSha, true, false, "test"); !errors.Is(err, RefTxErr) {
+		t.Errorf("creating an existing ref gave %v", err)
+	}
+
+	//a null new value deletes the ref, checking the old value when there is one
+	if err := got.UpdateRef(ctx, "refs/heads/main", nullSha, shaToString(one), true, false, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.readRef("refs/heads/main"); !errors.Is(err, RefNotFoundErr) {
+		t.Errorf("update to the null sha kept the ref: %v", err)
+	}
+	if err := got.writeRef("refs/heads/main", one); err != nil {
+		t.Fatal(err)
+	}
+	in := fmt.Sprintf("update refs/heads/main %s %s\n", nullSha, shaToString(one))
+	if err := got.UpdateRefStdin(ctx, strings.NewReader(in), io.Discard, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := got.readRef("refs/heads/main"); !errors.Is(err, RefNotFoundErr) {
+		t.Errorf("update to the null sha from stdin kept the ref: %v", err)
+	}
+	in = fmt.Sprintf("create refs/heads/main %s\n", nullSha)
+	if err := got.UpdateRefStdin(ctx, strings.NewReader(in), io.Discard, "test"); err == nil {
+		t.Error("create took the null sha")
+	}
+}
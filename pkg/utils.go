@@ -79,7 +79,8 @@ func compress(writer io.Writer, data []byte) error {
 	if err != nil {
 		return &OpErr{Context: "IO: while compressing ", inner: err}
 	}
-	err = comp.Flush()
+	//close, not flush. only closing writes the checksum that ends a zlib stream
+	err = comp.Close()
 	if err != nil {
 		return &OpErr{Context: "IO: while compressing ", inner: err}
 	}